@@ -0,0 +1,210 @@
+package mail
+
+import (
+	"errors"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormatAddress formats an address with an optional display name for an
+// address header. ASCII names are quoted, so commas and other specials are
+// safe, non-ASCII names are encoded, and the local part is quoted if needed.
+func FormatAddress(name, addr string) string {
+	at := strings.LastIndex(addr, "@")
+	local, domain := addr, ""
+	if at >= 0 {
+		local, domain = addr[:at], addr[at:]
+	}
+
+	if !isDotAtom(local) {
+		local = quoteString(local)
+	}
+
+	if name == "" {
+		return "<" + local + domain + ">"
+	}
+
+	if !isASCII(name) || strings.ContainsAny(name, "\r\n") {
+		name = mime.QEncoding.Encode("utf-8", name)
+	} else {
+		name = quoteString(name)
+	}
+
+	return name + " <" + local + domain + ">"
+}
+
+// ToASCIIAddress converts the internationalized domain of an address to
+// ASCII with punycode (IDNA), for servers that don't support SMTPUTF8. It
+// fails if the local part isn't ASCII, since it can't be converted.
+func ToASCIIAddress(addr string) (string, error) {
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		return "", errors.New("Mail Error: Invalid address " + addr)
+	}
+
+	if !isASCII(addr[:at]) {
+		return "", errors.New("Mail Error: The local part of " + addr + " can't be converted to ASCII")
+	}
+
+	domain, err := toASCIIDomain(addr[at+1:])
+	if err != nil {
+		return "", err
+	}
+
+	return addr[:at+1] + domain, nil
+}
+
+// toASCIIDomain converts the non-ASCII labels of a domain to punycode
+func toASCIIDomain(domain string) (string, error) {
+	if isASCII(domain) {
+		return domain, nil
+	}
+
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+
+		if !utf8.ValidString(label) {
+			return "", errors.New("Mail Error: Invalid domain " + domain)
+		}
+
+		labels[i] = "xn--" + punycode(strings.ToLower(label))
+		if len(labels[i]) > 63 {
+			return "", errors.New("Mail Error: Domain label too long in " + domain)
+		}
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// punycode parameters (RFC 3492)
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encodes a label with the punycode algorithm of RFC 3492
+func punycode(label string) string {
+	runes := []rune(label)
+
+	var out []byte
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+
+	basic := len(out)
+	handled := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for handled < len(runes) {
+		// the smallest code point not handled yet
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+
+		delta += int(m-n) * (handled + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+
+		delta++
+		n++
+	}
+
+	return string(out)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+
+	return byte('0' + d - 26)
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+// isAtext returns true if c is an atom character (RFC 5322 atext)
+func isAtext(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+// isDotAtom returns true if s is a dot-atom, a local part that doesn't
+// need quoting
+func isDotAtom(s string) bool {
+	if s == "" || s[0] == '.' || s[len(s)-1] == '.' || strings.Contains(s, "..") {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '.' && !isAtext(s[i]) && s[i] < 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// quoteString returns s as a quoted-string
+func quoteString(s string) string {
+	return `"` + escapeQuotes(s) + `"`
+}
@@ -0,0 +1,41 @@
+package mail
+
+import "testing"
+
+func TestFormatAddress(t *testing.T) {
+	for _, c := range []struct {
+		name, addr, want string
+	}{
+		{"", "user@example.com", "<user@example.com>"},
+		{"John Smith", "john@example.com", `"John Smith" <john@example.com>`},
+		{"Smith, John", "john@example.com", `"Smith, John" <john@example.com>`},
+		{`John "Johnny" Smith`, "john@example.com", `"John \"Johnny\" Smith" <john@example.com>`},
+		{"Jörg", "jörg@bücher.example", "=?utf-8?q?J=C3=B6rg?= <jörg@bücher.example>"},
+		{"", "john smith@example.com", `<"john smith"@example.com>`},
+	} {
+		if got := FormatAddress(c.name, c.addr); got != c.want {
+			t.Errorf("FormatAddress(%q, %q): got %s, want %s", c.name, c.addr, got, c.want)
+		}
+	}
+}
+
+func TestToASCIIAddress(t *testing.T) {
+	for _, c := range []struct {
+		addr, want string
+	}{
+		{"user@example.com", "user@example.com"},
+		{"user@bücher.example", "user@xn--bcher-kva.example"},
+		{"user@München.de", "user@xn--mnchen-3ya.de"},
+		{"user@日本語.jp", "user@xn--wgv71a119e.jp"},
+		{"user@ñandú.example", "user@xn--and-6ma2c.example"},
+	} {
+		got, err := ToASCIIAddress(c.addr)
+		if err != nil || got != c.want {
+			t.Errorf("ToASCIIAddress(%q): got %s, %v, want %s", c.addr, got, err, c.want)
+		}
+	}
+
+	if _, err := ToASCIIAddress("jörg@example.com"); err == nil {
+		t.Error("expected error for a non-ASCII local part")
+	}
+}
@@ -0,0 +1,200 @@
+// Package archive indexes stored messages, saved as .eml files, in Maildir
+// folders or in mbox files, in a full-text search backend. Each message is
+// parsed with Go Simple Mail into a normalized Document, with the decoded
+// headers, the text and the names of the attachments, that the backend
+// indexes, for example with bleve or SQLite FTS:
+//
+//	type bleveIndex struct{ index bleve.Index }
+//
+//	func (b bleveIndex) Index(doc *archive.Document) error {
+//		return b.index.Index(doc.ID, doc)
+//	}
+//
+//	err := archive.IndexDir(bleveIndex{index}, "/var/mail/archive")
+//
+// Maildir and Mbox store the outbound messages in the same formats, as
+// senders of the application:
+//
+//	sent := &archive.Maildir{Dir: "/var/mail/archive/sent"}
+//	err := sent.Send(ctx, email)
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// Document is the normalized form of a message to index.
+type Document struct {
+	// ID identifies the message in the archive, the path of the file or,
+	// for mbox files, the path and the number of the message, like
+	// "inbox.mbox:3"
+	ID        string
+	MessageID string
+	Date      time.Time
+	From      string
+	To        string
+	Cc        string
+	Subject   string
+	// Headers are the first value of each header, decoded to UTF-8
+	Headers map[string]string
+	// Text is the text of the message, the HTML body is converted to text
+	// if there isn't a plain text body
+	Text        string
+	Attachments []string
+}
+
+// Backend is a search backend indexing the documents.
+type Backend interface {
+	Index(doc *Document) error
+}
+
+// NewDocument parses the message read from r into a document with the
+// given id.
+func NewDocument(id string, r io.Reader) (*Document, error) {
+	parsed, err := mail.ParseMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{
+		ID:        id,
+		MessageID: strings.Trim(parsed.Header.Get("Message-Id"), "<> "),
+		From:      parsed.DecodedHeader("From"),
+		To:        parsed.DecodedHeader("To"),
+		Cc:        parsed.DecodedHeader("Cc"),
+		Subject:   parsed.DecodedHeader("Subject"),
+		Headers:   make(map[string]string, len(parsed.Header)),
+	}
+
+	// an invalid date is left empty
+	doc.Date, _ = netmail.ParseDate(parsed.Header.Get("Date"))
+
+	for header := range parsed.Header {
+		doc.Headers[header] = parsed.DecodedHeader(header)
+	}
+
+	text, err := parsed.Text()
+	if err != nil {
+		return nil, err
+	}
+	doc.Text = strings.TrimSpace(text)
+
+	for _, a := range parsed.Attachments() {
+		doc.Attachments = append(doc.Attachments, a.Filename)
+	}
+
+	return doc, nil
+}
+
+// IndexEML indexes the message of the file, with the path as id.
+func IndexEML(b Backend, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return index(b, path, f)
+}
+
+// IndexDir indexes the messages of the directory and its subdirectories,
+// the .eml files and the files of the Maildir folders. The messages being
+// delivered, in the tmp folders of Maildir, are skipped.
+func IndexDir(b Backend, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == "tmp" && isMaildir(filepath.Dir(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		folder := filepath.Base(filepath.Dir(path))
+		inMaildir := (folder == "cur" || folder == "new") && isMaildir(filepath.Dir(filepath.Dir(path)))
+		if !inMaildir && !strings.EqualFold(filepath.Ext(path), ".eml") {
+			return nil
+		}
+
+		return IndexEML(b, path)
+	})
+}
+
+// isMaildir returns true if the directory has the cur, new and tmp
+// folders of a Maildir
+func isMaildir(dir string) bool {
+	for _, folder := range []string{"cur", "new", "tmp"} {
+		if info, err := os.Stat(filepath.Join(dir, folder)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IndexMbox indexes the messages of the mbox file, with the path and the
+// number of the message, from 1, as id. The ">From " lines escaped by the
+// mboxrd format are unescaped.
+func IndexMbox(b Backend, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var msg bytes.Buffer
+	n := 0
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if bytes.HasPrefix(line, []byte("From ")) || len(line) == 0 {
+			if n > 0 {
+				if err := index(b, path+":"+strconv.Itoa(n), &msg); err != nil {
+					return err
+				}
+			} else if msg.Len() > 0 {
+				return errors.New("archive: " + path + " is not a mbox file")
+			}
+
+			if len(line) == 0 {
+				return nil
+			}
+			msg.Reset()
+			n++
+			continue
+		}
+
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			line = line[1:]
+		}
+		msg.Write(line)
+	}
+}
+
+func index(b Backend, id string, r io.Reader) error {
+	doc, err := NewDocument(id, r)
+	if err != nil {
+		return errors.New("archive: " + id + ": " + err.Error())
+	}
+
+	return b.Index(doc)
+}
@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type memoryBackend map[string]*Document
+
+func (m memoryBackend) Index(doc *Document) error {
+	m[doc.ID] = doc
+	return nil
+}
+
+const testMessage = "From: =?utf-8?q?J=C3=B6hn?= <john@example.com>\r\n" +
+	"To: jane@example.com\r\n" +
+	"Subject: =?utf-8?q?R=C3=A9union?=\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"Date: Mon, 8 Jan 2024 10:00:00 +0000\r\n" +
+	"Content-Type: multipart/mixed; boundary=b\r\n" +
+	"\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>See the <b>report</b></p>\r\n" +
+	"--b\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment; filename=report.pdf\r\n" +
+	"\r\n" +
+	"%PDF\r\n" +
+	"--b--\r\n"
+
+func TestNewDocument(t *testing.T) {
+	doc, err := NewDocument("1", strings.NewReader(testMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.MessageID != "1@example.com" || doc.From != "Jöhn <john@example.com>" || doc.To != "jane@example.com" ||
+		doc.Subject != "Réunion" || doc.Headers["Subject"] != "Réunion" || doc.Date.Day() != 8 {
+		t.Errorf("got headers %+v", doc)
+	}
+	if doc.Text != "See the report" {
+		t.Errorf("got text %q", doc.Text)
+	}
+	if len(doc.Attachments) != 1 || doc.Attachments[0] != "report.pdf" {
+		t.Errorf("got attachments %q", doc.Attachments)
+	}
+}
+
+func TestIndexDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"saved/a.eml":          testMessage,
+		"saved/notes.txt":      "not a message",
+		"Maildir/cur/1:2,S":    testMessage,
+		"Maildir/new/2":        testMessage,
+		"Maildir/tmp/3":        "being delivered",
+		"Maildir/dovecot.list": "not a message",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend := memoryBackend{}
+	if err := IndexDir(backend, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for id := range backend {
+		rel, _ := filepath.Rel(dir, id)
+		ids = append(ids, filepath.ToSlash(rel))
+	}
+	sort.Strings(ids)
+
+	if got := strings.Join(ids, " "); got != "Maildir/cur/1:2,S Maildir/new/2 saved/a.eml" {
+		t.Errorf("got documents %s", got)
+	}
+}
+
+func TestIndexMbox(t *testing.T) {
+	f, err := ioutil.TempFile("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString("From john@example.com Mon Jan  8 10:00:00 2024\n" +
+		"Subject: First\n\n>From the start\n\n" +
+		"From jane@example.com Mon Jan  8 11:00:00 2024\n" +
+		"Subject: Second\n\nHello\n")
+	f.Close()
+
+	backend := memoryBackend{}
+	if err := IndexMbox(backend, f.Name()); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := backend[f.Name()+":1"], backend[f.Name()+":2"]
+	if len(backend) != 2 || first == nil || second == nil {
+		t.Fatalf("got documents %v", backend)
+	}
+	if first.Subject != "First" || first.Text != "From the start" || second.Subject != "Second" || second.Text != "Hello" {
+		t.Errorf("got %+v and %+v", first, second)
+	}
+}
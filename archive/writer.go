@@ -0,0 +1,192 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+var (
+	_ mail.Sender = (*Maildir)(nil)
+	_ mail.Sender = (*Mbox)(nil)
+)
+
+// deliveries counts the messages written in the Maildir folders by the
+// process, for the unique file names
+var deliveries uint64
+
+// Maildir stores messages in a Maildir folder. Each message is written in
+// tmp and renamed in new when complete, so the readers never see a partial
+// message. The cur, new and tmp folders are created if missing.
+type Maildir struct {
+	Dir string
+}
+
+// Send stores the message of the email, so the Maildir can be added to the
+// senders of the application to archive every outbound email.
+func (m *Maildir) Send(ctx context.Context, email *mail.Email) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	if _, err := email.WriteTo(&msg); err != nil {
+		return err
+	}
+
+	_, err := m.WriteMessage(msg.Bytes())
+	return err
+}
+
+// WriteMessage stores a RFC 5322 message, like the one written by
+// SendAndTee, and returns the path of its file in new.
+func (m *Maildir) WriteMessage(msg []byte) (string, error) {
+	for _, folder := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(m.Dir, folder), 0700); err != nil {
+			return "", err
+		}
+	}
+
+	name, err := maildirName()
+	if err != nil {
+		return "", err
+	}
+
+	tmp := filepath.Join(m.Dir, "tmp", name)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(msg)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	path := filepath.Join(m.Dir, "new", name)
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// maildirName returns a unique file name, like
+// "1700000000.M123456P42Q1R0a1b2c3d4e5f6a7b8.host", with the time, the
+// process id, the delivery counter, random bytes and the host name
+func maildirName() (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	// the separators of the file names are escaped
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+
+	now := time.Now()
+
+	return strconv.FormatInt(now.Unix(), 10) +
+		".M" + strconv.Itoa(now.Nanosecond()/1000) +
+		"P" + strconv.Itoa(os.Getpid()) +
+		"Q" + strconv.FormatUint(atomic.AddUint64(&deliveries, 1), 10) +
+		"R" + hex.EncodeToString(random) +
+		"." + host, nil
+}
+
+// Mbox appends messages to a mbox file, in the mboxrd format: each message
+// starts with a "From " line, the lines of the message starting with
+// "From ", after any number of '>', get one more '>', and the line endings
+// are LF. The appends of a Mbox are serialized, the other writers of the
+// file must not write at the same time.
+type Mbox struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Send appends the message of the email, so the Mbox can be added to the
+// senders of the application to archive every outbound email.
+func (m *Mbox) Send(ctx context.Context, email *mail.Email) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	if _, err := email.WriteTo(&msg); err != nil {
+		return err
+	}
+
+	return m.WriteMessage(email.GetFrom(), msg.Bytes())
+}
+
+// WriteMessage appends a RFC 5322 message from the envelope sender to the
+// file, created if missing.
+func (m *Mbox) WriteMessage(from string, msg []byte) error {
+	if from == "" || strings.ContainsAny(from, " \t\r\n") {
+		from = "MAILER-DAEMON"
+	}
+
+	var b bytes.Buffer
+	b.WriteString("From " + from + " " + time.Now().UTC().Format(time.ANSIC) + "\n")
+
+	msg = bytes.Replace(msg, []byte("\r\n"), []byte("\n"), -1)
+	for len(msg) > 0 {
+		line := msg
+		if i := bytes.IndexByte(msg, '\n'); i >= 0 {
+			line = msg[:i+1]
+		}
+		msg = msg[len(line):]
+
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			b.WriteByte('>')
+		}
+		b.Write(line)
+	}
+
+	// the message ends with a line feed, and a blank line separates it from
+	// the next one
+	if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,88 @@
+package archive
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func newEmail(subject, body string) *mail.Email {
+	return mail.NewMSG().
+		SetFrom("john@example.com").
+		AddTo("jane@example.com").
+		SetSubject(subject).
+		SetBody(mail.TextPlain, body)
+}
+
+func TestMaildir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maildir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := &Maildir{Dir: filepath.Join(dir, "sent")}
+	for _, subject := range []string{"First", "Second"} {
+		if err := m.Send(context.Background(), newEmail(subject, "Hello")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, _ := ioutil.ReadDir(filepath.Join(m.Dir, "new"))
+	if len(files) != 2 || files[0].Name() == files[1].Name() || strings.ContainsAny(files[0].Name(), "/:") {
+		t.Fatalf("got files %v", files)
+	}
+	if tmp, _ := ioutil.ReadDir(filepath.Join(m.Dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("got files %v left in tmp", tmp)
+	}
+
+	backend := memoryBackend{}
+	if err := IndexDir(backend, dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend) != 2 {
+		t.Errorf("got %d documents, want 2", len(backend))
+	}
+}
+
+func TestMbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := &Mbox{Path: filepath.Join(dir, "sent.mbox")}
+	if err := m.Send(context.Background(), newEmail("First", "From the start\n>From the quote")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteMessage("", []byte("Subject: Second\r\n\r\nHello")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(m.Path)
+	mbox := string(data)
+	if !strings.HasPrefix(mbox, "From john@example.com ") || !strings.Contains(mbox, "\n>From the start\n>>From the quote\n") {
+		t.Errorf("got mbox %q", mbox)
+	}
+	if strings.Contains(mbox, "\r") || !strings.Contains(mbox, "\n\nFrom MAILER-DAEMON ") || !strings.HasSuffix(mbox, "Hello\n\n") {
+		t.Errorf("got mbox %q", mbox)
+	}
+
+	backend := memoryBackend{}
+	if err := IndexMbox(backend, m.Path); err != nil {
+		t.Fatal(err)
+	}
+	first, second := backend[m.Path+":1"], backend[m.Path+":2"]
+	if first == nil || second == nil {
+		t.Fatalf("got documents %v", backend)
+	}
+	if first.Subject != "First" || first.Text != "From the start\n>From the quote" || second.Subject != "Second" {
+		t.Errorf("got %+v and %+v", first, second)
+	}
+}
@@ -0,0 +1,84 @@
+package mail
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/mail"
+	"strings"
+)
+
+// AS1MICAlgorithm is the algorithm of the AS1 message integrity checks
+const AS1MICAlgorithm = "sha-256"
+
+// SetAS1Receipt requests an AS1 receipt (RFC 3335), a MDN with the message
+// integrity check of the EDI interchange, sent to receiptTo. The
+// interchange is the only attachment of the email, added for example with
+// AddEDIAttachment, and its MIC is returned by AS1MIC, to be compared with
+// the one of the receipt, see Report.VerifyMIC.
+//
+// The interchange is sent without S/MIME signature or encryption, which
+// RFC 3335 allows for partners that agree on it.
+func (email *Email) SetAS1Receipt(receiptTo string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	address, err := mail.ParseAddress(receiptTo)
+	if err != nil {
+		email.Error = errors.New("Mail Error: " + err.Error() + "; Header: [Disposition-Notification-To] Address: [" + receiptTo + "]")
+		return email
+	}
+
+	email.headers.Set("Disposition-Notification-To", FormatAddress(address.Name, address.Address))
+	email.headers.Set("Disposition-Notification-Options",
+		"signed-receipt-protocol=optional, pkcs7-signature; signed-receipt-micalg=optional, "+AS1MICAlgorithm)
+
+	return email
+}
+
+// AS1MIC returns the message integrity check of the EDI interchange of the
+// email, the base64 digest of its content followed by the algorithm, like
+// "GqVK0t...=, sha-256", as reported in the Received-Content-MIC field of
+// the receipts.
+func (email *Email) AS1MIC() (string, error) {
+	if email.Error != nil {
+		return "", email.Error
+	}
+
+	if len(email.attachments) != 1 {
+		return "", errors.New("Mail Error: An AS1 message must have one attachment, the EDI interchange")
+	}
+
+	r, err := email.attachments[0].open()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if _, err = io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)) + ", " + AS1MICAlgorithm, nil
+}
+
+// VerifyMIC reports whether the message integrity check of the receipt
+// matches the one of the sent message, returned by AS1MIC.
+func (report *Report) VerifyMIC(mic string) bool {
+	digest, algorithm := splitMIC(report.MIC)
+	wantDigest, wantAlgorithm := splitMIC(mic)
+
+	return digest != "" && digest == wantDigest && strings.EqualFold(algorithm, wantAlgorithm)
+}
+
+// splitMIC splits a MIC in its digest and algorithm
+func splitMIC(mic string) (string, string) {
+	i := strings.LastIndex(mic, ",")
+	if i < 0 {
+		return strings.TrimSpace(mic), ""
+	}
+
+	return strings.TrimSpace(mic[:i]), strings.TrimSpace(mic[i+1:])
+}
@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+const testAS1Receipt = `From: edi@partner.example.com
+To: edi@example.com
+Subject: Receipt
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=disposition-notification; boundary="b"
+
+--b
+Content-Type: text/plain
+
+The interchange was received.
+
+--b
+Content-Type: message/disposition-notification
+
+Reporting-UA: edi.partner.example.com
+Final-Recipient: rfc822; edi@partner.example.com
+Original-Message-ID: <as1@example.com>
+Disposition: automatic-action/MDN-sent-automatically; processed
+Received-Content-MIC: %s
+
+--b--
+`
+
+func TestAS1(t *testing.T) {
+	email := NewMSG().
+		SetFrom("edi@example.com").
+		AddTo("edi@partner.example.com").
+		AddEDIAttachment([]byte("UNA:+.? 'UNB+UNOC:3+SENDER+RECEIVER+210203:0405+1'"), "order.edi").
+		SetAS1Receipt("EDI <edi@example.com>")
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+	if !strings.Contains(msg, "Disposition-Notification-To: \"EDI\" <edi@example.com>\r\n") {
+		t.Errorf("receipt not requested:\n%s", msg)
+	}
+
+	mic, err := email.AS1MIC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(mic, ", sha-256") {
+		t.Errorf("got MIC %q", mic)
+	}
+
+	for receiptMIC, want := range map[string]bool{
+		mic: true,
+		"kWl7cb9dBdh2mPiFqiEMNp3ff3ePsJIUIfEjSXbRyt0=, sha-256": false,
+		"": false,
+	} {
+		receipt := strings.Replace(strings.Replace(testAS1Receipt, "%s", receiptMIC, 1), "\n", "\r\n", -1)
+		report, err := ParseReport(strings.NewReader(receipt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := report.VerifyMIC(mic); got != want {
+			t.Errorf("VerifyMIC with receipt MIC %q: got %v, want %v", receiptMIC, got, want)
+		}
+	}
+
+	if _, err = NewMSG().AS1MIC(); err == nil {
+		t.Error("expected an error for an email without interchange")
+	}
+}
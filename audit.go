@@ -0,0 +1,188 @@
+package mail
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AuditEntry is a record of a send attempt.
+type AuditEntry struct {
+	Time          time.Time `json:"time"`
+	From          string    `json:"from"`
+	MessageID     string    `json:"message_id,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Recipients    []string  `json:"recipients"`
+	Result        string    `json:"result"`
+	Error         string    `json:"error,omitempty"`
+	// SHA256 is the hash of the transmitted message, see SendResult
+	SHA256 string `json:"sha256,omitempty"`
+	// PrevHash and Hash chain the entries of the log, they are set by the audit log
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+const (
+	// AuditSent is the result of a successful send attempt
+	AuditSent = "sent"
+	// AuditFailed is the result of a failed send attempt
+	AuditFailed = "failed"
+)
+
+// AuditLog is implemented by append-only audit logs of send attempts.
+type AuditLog interface {
+	Record(entry *AuditEntry) error
+}
+
+// AuditError is returned when the email was sent but the audit log failed
+type AuditError struct {
+	Err error
+}
+
+func (e *AuditError) Error() string {
+	return "Mail Error: Email sent but audit log failed: " + e.Err.Error()
+}
+
+func (e *AuditError) Unwrap() error {
+	return e.Err
+}
+
+// audit records the send attempt in the audit log
+func audit(log AuditLog, from string, email *Email, result *SendResult, sendErr error) error {
+	entry := &AuditEntry{
+		Time:          time.Now(),
+		From:          from,
+		MessageID:     email.headers.Get("Message-Id"),
+		CorrelationID: email.correlation,
+		Recipients:    email.recipients,
+		Result:        AuditSent,
+	}
+
+	if result != nil {
+		entry.SHA256 = result.SHA256
+	}
+
+	if sendErr != nil {
+		entry.Result = AuditFailed
+		entry.Error = sendErr.Error()
+	}
+
+	if err := log.Record(entry); err != nil && sendErr == nil {
+		return &AuditError{Err: err}
+	}
+
+	return sendErr
+}
+
+// hashEntry returns the hash of the entry chained with the previous hash
+func hashEntry(entry *AuditEntry) (string, error) {
+	e := *entry
+	e.Hash = ""
+
+	data, err := json.Marshal(&e)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(e.PrevHash+"\n"), data...))
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FileAuditLog is an audit log that appends the entries to a file, one JSON
+// object per line, each entry including the hash of the previous one.
+type FileAuditLog struct {
+	mu       sync.Mutex
+	file     *os.File
+	lastHash string
+}
+
+// OpenFileAuditLog opens or creates the audit log file in path. The existing
+// entries are verified before appending new ones.
+func OpenFileAuditLog(path string) (*FileAuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	lastHash, err := verifyAuditLog(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &FileAuditLog{file: f, lastHash: lastHash}, nil
+}
+
+// Record appends the entry to the file, setting its hashes.
+func (l *FileAuditLog) Record(entry *AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.PrevHash = l.lastHash
+
+	hash, err := hashEntry(entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err = l.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	l.lastHash = hash
+
+	return l.file.Sync()
+}
+
+// Close closes the audit log file.
+func (l *FileAuditLog) Close() error {
+	return l.file.Close()
+}
+
+// VerifyAuditLog checks the hash chain of an audit log written by FileAuditLog.
+func VerifyAuditLog(r io.Reader) error {
+	_, err := verifyAuditLog(r)
+	return err
+}
+
+// verifyAuditLog checks the hash chain and returns the last hash
+func verifyAuditLog(r io.Reader) (string, error) {
+	var lastHash string
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+
+	for line := 1; s.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(s.Bytes(), &entry); err != nil {
+			return "", errors.New("Mail Error: Invalid audit log entry at line " + strconv.Itoa(line) + ": " + err.Error())
+		}
+
+		hash, err := hashEntry(&entry)
+		if err != nil {
+			return "", err
+		}
+
+		if entry.PrevHash != lastHash || entry.Hash != hash {
+			return "", errors.New("Mail Error: Audit log hash chain broken at line " + strconv.Itoa(line))
+		}
+
+		lastHash = hash
+	}
+
+	return lastHash, s.Err()
+}
@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+
+	email := NewMSG().SetFrom("from@example.com").AddTo("to@example.com")
+
+	for i := 0; i < 2; i++ {
+		log, err := OpenFileAuditLog(path)
+		if err != nil {
+			t.Fatalf("OpenFileAuditLog: %v", err)
+		}
+
+		audit(log, "from@example.com", email, &SendResult{SHA256: "abc"}, nil)
+		audit(log, "from@example.com", email, nil, errors.New("550 rejected"))
+		log.Close()
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyAuditLog(bytes.NewReader(data)); err != nil {
+		t.Fatalf("VerifyAuditLog: %v", err)
+	}
+
+	if got := bytes.Count(data, []byte("\n")); got != 4 {
+		t.Errorf("got %d entries, want 4", got)
+	}
+
+	// tamper with an entry
+	data = bytes.Replace(data, []byte("550 rejected"), []byte("250 accepted"), 1)
+	if err := VerifyAuditLog(bytes.NewReader(data)); err == nil {
+		t.Error("expected error verifying tampered log")
+	}
+}
@@ -13,36 +13,40 @@ import (
 	"strings"
 )
 
-// auth is implemented by an SMTP authentication mechanism.
-type auth interface {
-	// start begins an authentication with a server.
+// Auth is implemented by an SMTP authentication mechanism. The built-in
+// mechanisms are chosen from the AuthType of the SMTPServer, others can be
+// set with SetCustomAuth.
+type Auth interface {
+	// Start begins an authentication with a server.
 	// It returns the name of the authentication protocol
 	// and optionally data to include in the initial AUTH message
 	// sent to the server. It can return proto == "" to indicate
 	// that the authentication should be skipped.
 	// If it returns a non-nil error, the SMTP client aborts
 	// the authentication attempt and closes the connection.
-	start(server *serverInfo) (proto string, toServer []byte, err error)
+	Start(server *ServerInfo) (proto string, toServer []byte, err error)
 
-	// next continues the authentication. The server has just sent
+	// Next continues the authentication. The server has just sent
 	// the fromServer data. If more is true, the server expects a
-	// response, which next should return as toServer; otherwise
-	// next should return toServer == nil.
-	// If next returns a non-nil error, the SMTP client aborts
+	// response, which Next should return as toServer; otherwise
+	// Next should return toServer == nil.
+	// If Next returns a non-nil error, the SMTP client aborts
 	// the authentication attempt and closes the connection.
-	next(fromServer []byte, more bool) (toServer []byte, err error)
+	Next(fromServer []byte, more bool) (toServer []byte, err error)
 }
 
-// serverInfo records information about an SMTP server.
-type serverInfo struct {
-	name string   // SMTP server name
-	tls  bool     // using TLS, with valid certificate for Name
-	auth []string // advertised authentication mechanisms
+// ServerInfo records information about an SMTP server.
+type ServerInfo struct {
+	Name string   // SMTP server name
+	TLS  bool     // using TLS, with valid certificate for Name
+	Auth []string // advertised authentication mechanisms
 }
 
 type plainAuth struct {
 	identity, username, password string
 	host                         string
+	// allowInsecure allows sending the credentials without TLS
+	allowInsecure bool
 }
 
 // plainAuthfn returns an auth that implements the PLAIN authentication
@@ -53,24 +57,27 @@ type plainAuth struct {
 // plainAuthfn will only send the credentials if the connection is using TLS
 // or is connected to localhost. Otherwise authentication will fail with an
 // error, without sending the credentials.
-func plainAuthfn(identity, username, password, host string) auth {
-	return &plainAuth{identity, username, password, host}
+func plainAuthfn(identity, username, password, host string) Auth {
+	return &plainAuth{identity: identity, username: username, password: password, host: host}
 }
 
-func (a *plainAuth) start(server *serverInfo) (string, []byte, error) {
-	// Must have TLS, or else localhost server. Unencrypted connection is permitted here too but is not recommended
-	// Note: If TLS is not true, then we can't trust ANYTHING in serverInfo.
+func (a *plainAuth) Start(server *ServerInfo) (string, []byte, error) {
+	// Must have TLS, or else localhost server, unless insecure auth is allowed.
+	// Note: If TLS is not true, then we can't trust ANYTHING in ServerInfo.
 	// In particular, it doesn't matter if the server advertises PLAIN auth.
 	// That might just be the attacker saying
 	// "it's ok, you can trust me with your password."
-	if server.name != a.host {
+	if !server.TLS && !a.allowInsecure && !isLocalhost(server.Name) {
+		return "", nil, errInsecureAuth
+	}
+	if server.Name != a.host {
 		return "", nil, errors.New("wrong host name")
 	}
 	resp := []byte(a.identity + "\x00" + a.username + "\x00" + a.password)
 	return "PLAIN", resp, nil
 }
 
-func (a *plainAuth) next(fromServer []byte, more bool) ([]byte, error) {
+func (a *plainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
 	if more {
 		// We've already sent everything.
 		return nil, errors.New("unexpected server challenge")
@@ -86,21 +93,27 @@ but username and password are sent in different commands
 type loginAuth struct {
 	identity, username, password string
 	host                         string
+	// allowInsecure allows sending the credentials without TLS
+	allowInsecure bool
 }
 
-func loginAuthfn(identity, username, password, host string) auth {
-	return &loginAuth{identity, username, password, host}
+func loginAuthfn(identity, username, password, host string) Auth {
+	return &loginAuth{identity: identity, username: username, password: password, host: host}
 }
 
-func (a *loginAuth) start(server *serverInfo) (string, []byte, error) {
-	if server.name != a.host {
+func (a *loginAuth) Start(server *ServerInfo) (string, []byte, error) {
+	// the same as PLAIN, the credentials are sent in clear text
+	if !server.TLS && !a.allowInsecure && !isLocalhost(server.Name) {
+		return "", nil, errInsecureAuth
+	}
+	if server.Name != a.host {
 		return "", nil, errors.New("wrong host name")
 	}
 	resp := []byte(a.username)
 	return "LOGIN", resp, nil
 }
 
-func (a *loginAuth) next(fromServer []byte, more bool) ([]byte, error) {
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
 	if more {
 		if strings.Contains(string(fromServer), "Username") {
 			resp := []byte(a.username)
@@ -126,15 +139,15 @@ type cramMD5Auth struct {
 // mechanism as defined in RFC 2195.
 // The returned Auth uses the given username and secret to authenticate
 // to the server using the challenge-response mechanism.
-func cramMD5Authfn(username, secret string) auth {
+func cramMD5Authfn(username, secret string) Auth {
 	return &cramMD5Auth{username, secret}
 }
 
-func (a *cramMD5Auth) start(server *serverInfo) (string, []byte, error) {
+func (a *cramMD5Auth) Start(server *ServerInfo) (string, []byte, error) {
 	return "CRAM-MD5", nil, nil
 }
 
-func (a *cramMD5Auth) next(fromServer []byte, more bool) ([]byte, error) {
+func (a *cramMD5Auth) Next(fromServer []byte, more bool) ([]byte, error) {
 	if more {
 		d := hmac.New(md5.New, []byte(a.secret))
 		d.Write(fromServer)
@@ -143,3 +156,10 @@ func (a *cramMD5Auth) next(fromServer []byte, more bool) ([]byte, error) {
 	}
 	return nil, nil
 }
+
+// errInsecureAuth is returned when the credentials would be sent in clear text
+var errInsecureAuth = errors.New("unencrypted connection, set AllowInsecureAuth to authenticate without TLS")
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// AutoReplySubjects are the subject patterns of the automatic replies that
+// lack the automatic headers, like the out-of-office replies of some
+// clients. Add patterns for other languages as needed.
+var AutoReplySubjects = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^\s*auto(matic)?[ -]?(reply|response|antwort)\b`),
+	regexp.MustCompile(`(?i)\bout[ -]of[ -](the[ -])?office\b`),
+	regexp.MustCompile(`(?i)^\s*(on )?vacation\b`),
+	regexp.MustCompile(`(?i)^\s*(abwesenheitsnotiz|automatische antwort|réponse automatique|respuesta automática|risposta automatica|resposta automática|autosvar|automatisch antwoord)\b`),
+}
+
+// autoReplyHeaders are the headers only present in automatic replies
+var autoReplyHeaders = []string{"X-Autoreply", "X-Autorespond", "X-Autoreply-From", "X-Mail-Autoreply"}
+
+// IsAutoReply returns true if the headers of a received message show it's
+// an automatic response, like an out-of-office reply or a message of a
+// list, so reply-processing pipelines can skip it. It checks the
+// Auto-Submitted header (RFC 3834), the X-Autoreply headers, the
+// Precedence header and the AutoReplySubjects patterns.
+func IsAutoReply(header textproto.MIMEHeader) bool {
+	if value := strings.ToLower(strings.TrimSpace(header.Get("Auto-Submitted"))); value != "" && value != "no" {
+		return true
+	}
+
+	for _, name := range autoReplyHeaders {
+		if header.Get(name) != "" {
+			return true
+		}
+	}
+
+	for _, name := range []string{"Precedence", "X-Precedence"} {
+		switch strings.ToLower(strings.TrimSpace(header.Get(name))) {
+		case "auto_reply", "bulk", "junk", "list":
+			return true
+		}
+	}
+
+	subject := decodeHeader(header.Get("Subject"))
+	for _, re := range AutoReplySubjects {
+		if re.MatchString(subject) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,29 @@
+package mail
+
+import (
+	"net/textproto"
+	"testing"
+)
+
+func TestIsAutoReply(t *testing.T) {
+	tests := []struct {
+		header textproto.MIMEHeader
+		want   bool
+	}{
+		{textproto.MIMEHeader{"Subject": {"Re: Hello"}}, false},
+		{textproto.MIMEHeader{"Subject": {"Re: Hello"}, "Auto-Submitted": {"no"}}, false},
+		{textproto.MIMEHeader{"Subject": {"Re: Hello"}, "Auto-Submitted": {"auto-replied"}}, true},
+		{textproto.MIMEHeader{"Subject": {"Re: Hello"}, "X-Autoreply": {"yes"}}, true},
+		{textproto.MIMEHeader{"Subject": {"Re: Hello"}, "Precedence": {"bulk"}}, true},
+		{textproto.MIMEHeader{"Subject": {"Automatic reply: Hello"}}, true},
+		{textproto.MIMEHeader{"Subject": {"I'm out of the office until Monday"}}, true},
+		{textproto.MIMEHeader{"Subject": {"=?utf-8?q?R=C3=A9ponse_automatique_:_Hello?="}}, true},
+		{textproto.MIMEHeader{"Subject": {"Re: Our office layout"}}, false},
+	}
+
+	for _, test := range tests {
+		if got := IsAutoReply(test.header); got != test.want {
+			t.Errorf("IsAutoReply(%v) = %v, want %v", test.header, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package mail
+
+import "errors"
+
+// sendBatches sends the message to the recipients in batches of at most
+// MaxRecipients of the client server. It returns the recipients of the
+// batches that were sent, and a PartialSendError if some batches failed.
+// The message is written to the tee only in the first batch.
+func sendBatches(from string, to []string, msg string, client *SMTPClient, opts sendOptions) ([]string, error) {
+	max := 0
+	if client.server != nil {
+		max = client.server.MaxRecipients
+	}
+
+	if max <= 0 || len(to) <= max {
+		if err := send(from, to, msg, client, opts); err != nil {
+			return nil, err
+		}
+		return to, nil
+	}
+
+	partial := &PartialSendError{}
+	var firstErr error
+
+	for start := 0; start < len(to); start += max {
+		end := start + max
+		if end > len(to) {
+			end = len(to)
+		}
+		batch := to[start:end]
+
+		err := send(from, batch, msg, client, opts)
+		opts.tee = nil
+
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			// the transaction of the batch may still be open, after a
+			// rejected recipient
+			if client.Client != nil {
+				client.Client.reset()
+			}
+
+			// with PRDR the message was accepted by some recipients
+			var batchPartial *PartialSendError
+			if errors.As(err, &batchPartial) {
+				partial.Accepted = append(partial.Accepted, batchPartial.Accepted...)
+				partial.Rejected = append(partial.Rejected, batchPartial.Rejected...)
+				continue
+			}
+			for _, address := range batch {
+				partial.Rejected = append(partial.Rejected, RecipientError{Address: address, Err: err})
+			}
+			continue
+		}
+
+		partial.Accepted = append(partial.Accepted, batch...)
+	}
+
+	switch {
+	case len(partial.Rejected) == 0:
+		return partial.Accepted, nil
+	case len(partial.Accepted) == 0:
+		return nil, firstErr
+	}
+
+	return partial.Accepted, partial
+}
@@ -0,0 +1,117 @@
+package mail
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaxRecipients(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 3)
+	go fakeSMTP(ln, messages, map[string]string{"MAIL": "550 5.7.1 Not now"})
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	server.KeepAlive = true
+	server.MaxRecipients = 2
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("1@example.com", "2@example.com", "3@example.com", "4@example.com", "5@example.com").
+		SetBody(TextPlain, "Hello")
+
+	result, err := email.SendWithResult(client)
+
+	var partial *PartialSendError
+	if !errors.As(err, &partial) || len(partial.Rejected) != 2 || partial.Rejected[1].Address != "2@example.com" {
+		t.Fatalf("got error %v, want the first batch rejected", err)
+	}
+	if len(result.Recipients) != 3 || result.Recipients[0] != "3@example.com" {
+		t.Errorf("got recipients %v, want the last 3", result.Recipients)
+	}
+
+	first, second := <-messages, <-messages
+	if first != second {
+		t.Errorf("got different messages in the batches:\n%s\n%s", first, second)
+	}
+}
+
+func TestMaxRecipientsPRDR(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 2)
+	go fakeSMTP(ln, messages, map[string]string{
+		"EHLO": "250-fake\r\n250 PRDR",
+		".":    "353 content analysis started\r\n250 ok\r\n450 4.2.1 Try again later\r\n250 done",
+	})
+
+	server := newPoolServer(ln)
+	server.KeepAlive = true
+	server.MaxRecipients = 2
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	result, err := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("one@example.com", "two@example.com", "three@example.com").
+		SetBody(TextPlain, "Hello").
+		SendWithResult(client)
+
+	// the recipients accepted in a partially rejected batch are not sent again
+	var partial *PartialSendError
+	if !errors.As(err, &partial) || len(partial.Rejected) != 1 || partial.Rejected[0].Address != "two@example.com" {
+		t.Fatalf("got error %v, want two@example.com rejected", err)
+	}
+	if len(partial.Accepted) != 2 || partial.Accepted[0] != "one@example.com" || partial.Accepted[1] != "three@example.com" {
+		t.Errorf("got accepted %v, want one@example.com and three@example.com", partial.Accepted)
+	}
+	if result == nil || len(result.Recipients) != 2 {
+		t.Errorf("got result %+v, want 2 recipients", result)
+	}
+}
+
+func TestMaxRecipientsReset(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 2)
+	go fakeSMTP(ln, messages, map[string]string{"RCPT": "550 5.1.1 No such user"})
+
+	// without SendTimeout, the send doesn't reset the connection itself
+	server := newPoolServer(ln)
+	server.KeepAlive = true
+	server.SendTimeout = 0
+	server.MaxRecipients = 2
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	result, err := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("one@example.com", "two@example.com", "three@example.com").
+		SetBody(TextPlain, "Hello").
+		SendWithResult(client)
+
+	// the transaction of the failed batch is reset before the next MAIL
+	var partial *PartialSendError
+	if !errors.As(err, &partial) || len(partial.Rejected) != 2 || len(partial.Accepted) != 1 {
+		t.Fatalf("got error %v, want the first batch rejected", err)
+	}
+	if result == nil || len(result.Recipients) != 1 || result.Recipients[0] != "three@example.com" {
+		t.Errorf("got result %+v, want three@example.com", result)
+	}
+}
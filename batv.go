@@ -0,0 +1,115 @@
+package mail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBATVLifetime is the number of days a signed address is valid if
+// the BATV Lifetime is not set.
+const DefaultBATVLifetime = 7
+
+// BATV signs envelope senders with Bounce Address Tag Validation, in the
+// prvs=KDDDSSSSSS=user@domain format, where K is the key number, DDD the
+// day the address expires and SSSSSS the signature. The receiving side
+// validates the bounces with the same keys, rejecting backscatter to forged
+// sender addresses.
+//
+// Keys are rotated by adding a new key and setting KeyID to its index,
+// the addresses signed with the old keys are valid until they expire.
+type BATV struct {
+	// Keys are the secret keys, up to 10 indexed by the key number
+	Keys [][]byte
+	// KeyID is the index of the key used to sign
+	KeyID int
+	// Lifetime is the number of days a signed address is valid
+	Lifetime int
+	// Clock provides the current time, the local time if nil
+	Clock Clock
+}
+
+// ErrInvalidBATV is returned when a BATV address is not signed, expired or
+// the signature doesn't match
+var ErrInvalidBATV = errors.New("Mail Error: Invalid BATV address")
+
+// Sign returns the signed address.
+func (b *BATV) Sign(address string) (string, error) {
+	if b.KeyID < 0 || b.KeyID >= len(b.Keys) || b.KeyID > 9 {
+		return "", errors.New("Mail Error: Invalid BATV key " + strconv.Itoa(b.KeyID))
+	}
+
+	if strings.LastIndex(address, "@") < 0 {
+		return "", errors.New("Mail Error: Invalid address " + address)
+	}
+
+	lifetime := b.Lifetime
+	if lifetime <= 0 {
+		lifetime = DefaultBATVLifetime
+	}
+
+	tag := strconv.Itoa(b.KeyID) + b.day(lifetime)
+
+	return "prvs=" + tag + b.signature(b.KeyID, tag, address) + "=" + address, nil
+}
+
+// Validate checks a signed address and returns the original address.
+func (b *BATV) Validate(address string) (string, error) {
+	if len(address) < 17 || !strings.EqualFold(address[:5], "prvs=") || address[15] != '=' {
+		return "", ErrInvalidBATV
+	}
+
+	tag, signature, original := address[5:9], address[9:15], address[16:]
+
+	key, err := strconv.Atoi(tag[:1])
+	if err != nil || key >= len(b.Keys) {
+		return "", ErrInvalidBATV
+	}
+
+	expires, err := strconv.Atoi(tag[1:])
+	if err != nil {
+		return "", ErrInvalidBATV
+	}
+
+	lifetime := b.Lifetime
+	if lifetime <= 0 {
+		lifetime = DefaultBATVLifetime
+	}
+
+	// the days left, modulo 1000 days
+	today, _ := strconv.Atoi(b.day(0))
+	if left := (expires - today + 1000) % 1000; left > lifetime {
+		return "", ErrInvalidBATV
+	}
+
+	want := b.signature(key, tag, original)
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(want)) {
+		return "", ErrInvalidBATV
+	}
+
+	return original, nil
+}
+
+// day returns the last three digits of the day number after the given days
+func (b *BATV) day(days int) string {
+	now := time.Now()
+	if b.Clock != nil {
+		now = b.Clock.Now()
+	}
+
+	day := (now.Unix()/86400 + int64(days)) % 1000
+
+	return strconv.FormatInt(1000+day, 10)[1:]
+}
+
+// signature returns the signature of the tag and address with the key
+func (b *BATV) signature(key int, tag, address string) string {
+	mac := hmac.New(sha256.New, b.Keys[key])
+	mac.Write([]byte(tag + strings.ToLower(address)))
+
+	return hex.EncodeToString(mac.Sum(nil))[:6]
+}
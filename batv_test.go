@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBATV(t *testing.T) {
+	clock := fixedClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	b := &BATV{Keys: [][]byte{[]byte("old key"), []byte("new key")}, KeyID: 0, Clock: clock}
+
+	old, err := b.Sign("bounces@example.com")
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !strings.HasPrefix(old, "prvs=0") || !strings.HasSuffix(old, "=bounces@example.com") || len(old) != len("prvs=0DDDSSSSSS=bounces@example.com") {
+		t.Errorf("got %s", old)
+	}
+
+	// rotate the key
+	b.KeyID = 1
+	signed, err := b.Sign("bounces@example.com")
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	for _, address := range []string{old, signed, strings.ToUpper(signed[:15]) + signed[15:]} {
+		if got, err := b.Validate(address); err != nil || got != "bounces@example.com" {
+			t.Errorf("Validate(%s): got %s, %v", address, got, err)
+		}
+	}
+
+	for _, address := range []string{
+		"bounces@example.com",
+		strings.Replace(signed, "bounces", "forged", 1),
+		signed[:9] + "000000" + signed[15:],
+		"prvs=9" + signed[6:],
+	} {
+		if _, err := b.Validate(address); err != ErrInvalidBATV {
+			t.Errorf("Validate(%s): got %v, want ErrInvalidBATV", address, err)
+		}
+	}
+
+	// expired
+	b.Clock = fixedClock(time.Time(clock).AddDate(0, 0, DefaultBATVLifetime+1))
+	if _, err := b.Validate(signed); err != ErrInvalidBATV {
+		t.Errorf("got %v for an expired address, want ErrInvalidBATV", err)
+	}
+}
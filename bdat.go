@@ -0,0 +1,75 @@
+package mail
+
+import "strconv"
+
+// bdatChunkSize is the maximum size of the BDAT chunks
+const bdatChunkSize = 1 << 20
+
+// bdatWriter sends the data written to it in BDAT chunks
+type bdatWriter struct {
+	c   *smtpClient
+	buf []byte
+	err error
+}
+
+// bdat returns a writer that sends the message with BDAT commands (RFC
+// 3030), without dot-stuffing, instead of DATA. The message must have
+// CRLF line endings. The last chunk is sent when the writer is closed.
+func (c *smtpClient) bdat() *bdatWriter {
+	return &bdatWriter{c: c}
+}
+
+func (w *bdatWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= bdatChunkSize && w.err == nil {
+		w.err = w.chunk(w.buf[:bdatChunkSize], false)
+		w.buf = w.buf[bdatChunkSize:]
+	}
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	return len(p), nil
+}
+
+// Close sends the last chunk and returns the reply to the message.
+func (w *bdatWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.err = w.chunk(w.buf, true)
+	w.buf = nil
+
+	return w.err
+}
+
+// chunk sends a BDAT command with the chunk and reads its reply
+func (w *bdatWriter) chunk(data []byte, last bool) error {
+	cmd := "BDAT " + strconv.Itoa(len(data))
+	if last {
+		cmd += " LAST"
+	}
+
+	text := w.c.text
+	id := text.Next()
+	text.StartRequest(id)
+	text.W.WriteString(cmd + "\r\n")
+	text.W.Write(data)
+	err := text.W.Flush()
+	text.EndRequest(id)
+	if err != nil {
+		return err
+	}
+
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	_, _, err = text.ReadResponse(250)
+
+	return err
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBDAT(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 1)
+	go fakeSMTP(ln, messages, map[string]string{"EHLO": "250-fake\r\n250-8BITMIME\r\n250 CHUNKING"})
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	// a body with lines starting with dots, larger than a chunk
+	body := strings.Repeat(".line\r\n", bdatChunkSize/7+100)
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, body)
+
+	var archive bytes.Buffer
+	if err = client.SendAndTee(email, &archive); err != nil {
+		t.Fatalf("SendAndTee: %v", err)
+	}
+
+	msg := <-messages
+	if msg != archive.String() {
+		t.Error("the message received with BDAT differs from the sent message")
+	}
+	if !strings.Contains(msg, "\r\n.line\r\n") || strings.Contains(msg, "\r\n..line") {
+		t.Error("the message was dot-stuffed")
+	}
+}
@@ -0,0 +1,242 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Mailbox is a mailbox the bounces are fetched from. POP3Mailbox implements
+// it, an IMAP client can implement it to keep the dependencies optional.
+type Mailbox interface {
+	// Messages returns the ids of the messages in the mailbox
+	Messages() ([]string, error)
+	// Fetch returns the message with the id
+	Fetch(id string) ([]byte, error)
+	// Delete marks the message with the id to be deleted
+	Delete(id string) error
+	// Close applies the deletions and closes the mailbox
+	Close() error
+}
+
+// SuppressionReason is the reason an address should not receive more
+// emails.
+type SuppressionReason string
+
+const (
+	// SuppressionBounce is a permanent delivery failure reported by a DSN
+	SuppressionBounce SuppressionReason = "bounce"
+	// SuppressionComplaint is a complaint of the recipient reported by an
+	// ARF feedback report (RFC 5965)
+	SuppressionComplaint SuppressionReason = "complaint"
+)
+
+// SuppressionEvent reports an address to suppress from the recipients of
+// the next emails.
+type SuppressionEvent struct {
+	Address string
+	Reason  SuppressionReason
+	// MessageID is the Message-ID of the original message, if reported
+	MessageID string
+	// Status is the DSN status code, like 5.1.1, or the ARF feedback type,
+	// like abuse
+	Status     string
+	Diagnostic string
+	Date       time.Time
+}
+
+// BouncePoller fetches the DSNs and ARF feedback reports sent to a bounce
+// mailbox and emits a SuppressionEvent for every permanent failure or
+// complaint. The processed reports are deleted, the other messages are
+// left in the mailbox.
+type BouncePoller struct {
+	// Connect opens the mailbox for every poll
+	Connect func() (Mailbox, error)
+	// OnSuppression is called for every address to suppress
+	OnSuppression func(SuppressionEvent)
+	// Tracker, if set, handles the delivery reports too
+	Tracker *DeliveryTracker
+	// Interval is the time between polls of Run, one minute if not set
+	Interval time.Duration
+	// KeepReports keeps the processed reports in the mailbox
+	KeepReports bool
+}
+
+// Poll fetches the reports of the mailbox once, and returns the number of
+// reports processed.
+func (p *BouncePoller) Poll() (int, error) {
+	mb, err := p.Connect()
+	if err != nil {
+		return 0, err
+	}
+
+	ids, err := mb.Messages()
+	if err != nil {
+		mb.Close()
+		return 0, err
+	}
+
+	processed := 0
+	for _, id := range ids {
+		data, err := mb.Fetch(id)
+		if err != nil {
+			mb.Close()
+			return processed, err
+		}
+
+		events, ok := suppressionEvents(data)
+		if !ok {
+			continue
+		}
+
+		if p.Tracker != nil {
+			p.Tracker.Handle(bytes.NewReader(data))
+		}
+		for _, event := range events {
+			if p.OnSuppression != nil {
+				p.OnSuppression(event)
+			}
+		}
+		processed++
+
+		if !p.KeepReports {
+			if err = mb.Delete(id); err != nil {
+				mb.Close()
+				return processed, err
+			}
+		}
+	}
+
+	return processed, mb.Close()
+}
+
+// Run polls the mailbox every Interval until the context is done. It
+// returns the first poll error.
+func (p *BouncePoller) Run(ctx context.Context) error {
+	interval := p.Interval
+	if interval == 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Poll(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// suppressionEvents returns the addresses to suppress of a DSN or ARF
+// report, and false if the message isn't one
+func suppressionEvents(data []byte) ([]SuppressionEvent, bool) {
+	if events, ok := feedbackEvents(data); ok {
+		return events, true
+	}
+
+	report, err := ParseReport(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+
+	var events []SuppressionEvent
+	for address, delivery := range report.Recipients {
+		// only the permanent failures, the 4.x.x are retried
+		if delivery.State != DeliveryFailed || strings.HasPrefix(delivery.Status, "4") {
+			continue
+		}
+		events = append(events, SuppressionEvent{
+			Address:    address,
+			Reason:     SuppressionBounce,
+			MessageID:  normalizeMessageID(report.MessageID),
+			Status:     delivery.Status,
+			Diagnostic: delivery.Diagnostic,
+			Date:       delivery.Updated,
+		})
+	}
+
+	return events, true
+}
+
+// feedbackEvents returns the complaints of an ARF feedback report, and
+// false if the message isn't one
+func feedbackEvents(data []byte) ([]SuppressionEvent, bool) {
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "feedback-report") {
+		return nil, false
+	}
+
+	date, _ := m.Header.Date()
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var feedback, original textproto.MIMEHeader
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+
+		partType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		switch partType {
+		case "message/feedback-report":
+			feedback, err = textproto.NewReader(bufio.NewReader(p)).ReadMIMEHeader()
+		case "message/rfc822", "text/rfc822-headers":
+			original, err = textproto.NewReader(bufio.NewReader(p)).ReadMIMEHeader()
+		}
+		if err != nil && err != io.EOF {
+			return nil, false
+		}
+	}
+
+	if feedback == nil {
+		return nil, false
+	}
+
+	// the recipients are in the report or in the original message
+	addresses := feedback["Original-Rcpt-To"]
+	if len(addresses) == 0 && original != nil {
+		if list, err := mail.ParseAddressList(original.Get("To")); err == nil {
+			for _, a := range list {
+				addresses = append(addresses, a.Address)
+			}
+		}
+	}
+
+	var events []SuppressionEvent
+	for _, address := range addresses {
+		events = append(events, SuppressionEvent{
+			Address:   reportAddress(address),
+			Reason:    SuppressionComplaint,
+			MessageID: normalizeMessageID(original.Get("Message-Id")),
+			Status:    strings.ToLower(feedback.Get("Feedback-Type")),
+			Date:      date,
+		})
+	}
+
+	return events, true
+}
@@ -0,0 +1,219 @@
+package mail
+
+import (
+	"net/textproto"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testARF = `From: feedback@example.net
+To: abuse@example.com
+Subject: FW: Hello
+Date: Fri, 01 Mar 2024 12:00:00 +0000
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report; boundary="b"
+
+--b
+Content-Type: text/plain
+
+This is an email abuse report.
+
+--b
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: Example-FBL/1.0
+Version: 1
+Original-Rcpt-To: Three@example.net
+
+--b
+Content-Type: message/rfc822
+
+Message-Id: <456@example.com>
+From: from@example.com
+To: three@example.net
+Subject: Hello
+
+Hello
+--b--
+`
+
+type memoryMailbox struct {
+	messages map[string]string
+	deleted  []string
+	closed   bool
+}
+
+func (mb *memoryMailbox) Messages() ([]string, error) {
+	var ids []string
+	for id := range mb.messages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (mb *memoryMailbox) Fetch(id string) ([]byte, error) { return []byte(mb.messages[id]), nil }
+
+func (mb *memoryMailbox) Delete(id string) error {
+	mb.deleted = append(mb.deleted, id)
+	return nil
+}
+
+func (mb *memoryMailbox) Close() error {
+	mb.closed = true
+	return nil
+}
+
+func TestBouncePoller(t *testing.T) {
+	mb := &memoryMailbox{messages: map[string]string{
+		"1": testDSN,
+		"2": "From: someone@example.com\r\nSubject: Out of office\r\n\r\nI'm away",
+		"3": testARF,
+	}}
+
+	var events []SuppressionEvent
+	p := &BouncePoller{
+		Connect:       func() (Mailbox, error) { return mb, nil },
+		OnSuppression: func(e SuppressionEvent) { events = append(events, e) },
+	}
+
+	n, err := p.Poll()
+	if err != nil || n != 2 {
+		t.Fatalf("got %d reports, %v", n, err)
+	}
+	if strings.Join(mb.deleted, " ") != "1 3" || !mb.closed {
+		t.Errorf("got deleted %q, closed %v", mb.deleted, mb.closed)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("got events %+v", events)
+	}
+	if e := events[0]; e.Address != "two@example.com" || e.Reason != SuppressionBounce || e.Status != "5.1.1" || e.MessageID != "123@example.com" {
+		t.Errorf("got bounce %+v", e)
+	}
+	if e := events[1]; e.Address != "three@example.net" || e.Reason != SuppressionComplaint || e.Status != "abuse" || e.MessageID != "456@example.com" {
+		t.Errorf("got complaint %+v", e)
+	}
+}
+
+func TestPOP3Mailbox(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	commands := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		text := textproto.NewConn(conn)
+		defer text.Close()
+
+		text.PrintfLine("+OK POP3 ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			commands <- line
+
+			switch strings.Fields(line)[0] {
+			case "PASS":
+				if line != "PASS secret" {
+					text.PrintfLine("-ERR invalid password")
+					continue
+				}
+				text.PrintfLine("+OK logged in")
+			case "LIST":
+				text.PrintfLine("+OK 1 message\r\n1 120\r\n.")
+			case "RETR":
+				text.PrintfLine("+OK\r\nSubject: Hello\r\n\r\n..dot\r\n.")
+			case "QUIT":
+				text.PrintfLine("+OK bye")
+				return
+			default:
+				text.PrintfLine("+OK")
+			}
+		}
+	}()
+
+	mb, err := DialPOP3Insecure(ln.Addr().String(), "user", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := mb.Messages()
+	if err != nil || len(ids) != 1 || ids[0] != "1" {
+		t.Fatalf("got messages %q, %v", ids, err)
+	}
+
+	data, err := mb.Fetch("1")
+	if err != nil || string(data) != "Subject: Hello\n\n.dot\n" {
+		t.Errorf("got message %q, %v", data, err)
+	}
+
+	if err = mb.Delete("1"); err != nil {
+		t.Error(err)
+	}
+	if err = mb.Close(); err != nil {
+		t.Error(err)
+	}
+
+	close(commands)
+	var got []string
+	for c := range commands {
+		got = append(got, c)
+	}
+	if strings.Join(got, ", ") != "USER user, PASS secret, LIST, RETR 1, DELE 1, QUIT" {
+		t.Errorf("got commands %q", got)
+	}
+}
+
+func TestPOP3MailboxWithoutTLS(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	commands := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		text := textproto.NewConn(conn)
+		defer text.Close()
+
+		text.PrintfLine("+OK POP3 ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				close(commands)
+				return
+			}
+			commands <- line
+			text.PrintfLine("-ERR unknown command")
+		}
+	}()
+
+	if _, err := DialPOP3(ln.Addr().String(), nil, "user", "secret"); err == nil {
+		t.Fatal("expected the login without TLS to be refused")
+	}
+
+	var got []string
+	for c := range commands {
+		got = append(got, c)
+	}
+	if strings.Join(got, ", ") != "STLS" {
+		t.Errorf("got commands %q", got)
+	}
+}
+
+func TestPOP3MailboxInjection(t *testing.T) {
+	if _, err := DialPOP3Insecure("127.0.0.1:1", "user\r\nDELE 1", "secret"); err == nil || !strings.Contains(err.Error(), "CR or LF") {
+		t.Errorf("expected the username to be refused, got %v", err)
+	}
+	if _, err := DialPOP3Insecure("127.0.0.1:1", "user", "secret\nDELE 1"); err == nil || !strings.Contains(err.Error(), "CR or LF") {
+		t.Errorf("expected the password to be refused, got %v", err)
+	}
+}
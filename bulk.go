@@ -0,0 +1,220 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// mergeField matches the merge fields of a BulkSender template, like
+// {{first_name}}
+var mergeField = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// MergeRecipient is a recipient of a BulkSender, with its merge fields.
+type MergeRecipient struct {
+	// Address is the To address, with an optional display name
+	Address string
+	// Fields are the values of the merge fields of the template
+	Fields map[string]string
+	// Headers are added to the message of the recipient, like a
+	// List-Unsubscribe with its own token
+	Headers map[string]string
+}
+
+// BulkSender sends a personalized copy of a template email to each
+// recipient, over one connection of a pool, renewed every
+// MaxMessagesPerConn messages of the pool. The merge fields of the subject,
+// the headers and the bodies, like {{first_name}}, are replaced by the
+// fields of the recipient, HTML escaped in the HTML bodies.
+//
+// The template is parsed once, and the copies share its attachments. Each
+// copy gets its own Message-ID, and only the recipient in its envelope.
+type BulkSender struct {
+	// Limiter, if set, limits the rate of the copies sent to each domain
+	Limiter *AdaptiveLimiter
+
+	pool     *Pool
+	template *Email
+	// headers and parts are the parsed values with merge fields, the
+	// others are sent as they are
+	headers map[string][]mergeTemplate
+	parts   map[int]mergeTemplate
+}
+
+// mergeTemplate is a text split at its merge fields, the odd elements are
+// the names of the fields
+type mergeTemplate []string
+
+// NewBulkSender returns a sender of the template email with the pool. The
+// recipients of the template are ignored.
+func NewBulkSender(pool *Pool, template *Email) (*BulkSender, error) {
+	if template.Error != nil {
+		return nil, template.Error
+	}
+
+	b := &BulkSender{
+		pool:     pool,
+		template: template.clone(),
+		headers:  make(map[string][]mergeTemplate),
+		parts:    make(map[int]mergeTemplate),
+	}
+	// the copies are filtered when they are sent
+	b.template.filters = template.filters
+
+	// the addresses can't have merge fields
+	for header, values := range template.headers {
+		if addressHeaders[header] {
+			continue
+		}
+		var parsed []mergeTemplate
+		merged := false
+		for _, value := range values {
+			t := parseMergeTemplate(value)
+			parsed = append(parsed, t)
+			merged = merged || len(t) > 1
+		}
+		if merged {
+			b.headers[header] = parsed
+		}
+	}
+
+	for i, p := range template.parts {
+		if t := parseMergeTemplate(p.body.String()); len(t) > 1 {
+			b.parts[i] = t
+		}
+	}
+
+	return b, nil
+}
+
+// parseMergeTemplate splits the text at its merge fields
+func parseMergeTemplate(text string) mergeTemplate {
+	var t mergeTemplate
+	last := 0
+	for _, m := range mergeField.FindAllStringSubmatchIndex(text, -1) {
+		t = append(t, text[last:m[0]], text[m[2]:m[3]])
+		last = m[1]
+	}
+
+	return append(t, text[last:])
+}
+
+// execute returns the text with the merge fields replaced by their values
+func (t mergeTemplate) execute(fields map[string]string, escape bool) (string, error) {
+	var b strings.Builder
+	for i, s := range t {
+		if i%2 == 0 {
+			b.WriteString(s)
+			continue
+		}
+
+		value, ok := fields[s]
+		if !ok {
+			return "", errors.New("Mail Error: Missing merge field [" + s + "]")
+		}
+		if escape {
+			value = html.EscapeString(value)
+		}
+		b.WriteString(value)
+	}
+
+	return b.String(), nil
+}
+
+// Send sends a copy of the template to each recipient. If the copies of
+// some recipients fail, a PartialSendError is returned, or the first error
+// if all of them fail. The send stops when the context is done.
+func (b *BulkSender) Send(ctx context.Context, recipients []MergeRecipient) error {
+	partial := &PartialSendError{}
+	var client *SMTPClient
+
+	for _, r := range recipients {
+		err := ctx.Err()
+		if err == nil && client == nil {
+			client, err = b.pool.Get()
+		}
+
+		var email *Email
+		if err == nil {
+			email, err = b.personalize(r)
+		}
+		if err == nil && b.Limiter != nil {
+			err = b.Limiter.waitDomains(ctx, email.recipients)
+		}
+		if err == nil {
+			err = email.SendWithContext(ctx, client)
+			if b.Limiter != nil {
+				b.Limiter.reportDomains(email.recipients, err)
+			}
+		}
+
+		if err == nil {
+			partial.Accepted = append(partial.Accepted, r.Address)
+			if b.pool.exhausted(client) {
+				// the next recipient gets a new connection
+				b.pool.Put(client)
+				client = nil
+			}
+			continue
+		}
+
+		partial.Rejected = append(partial.Rejected, RecipientError{Address: r.Address, Err: err})
+		if client != nil && client.Client != nil && client.Reset() != nil {
+			// the connection is broken, the next recipient gets a new one
+			b.pool.discard(client)
+			client = nil
+		}
+	}
+
+	if client != nil {
+		b.pool.Put(client)
+	}
+
+	switch {
+	case len(partial.Rejected) == 0:
+		return nil
+	case len(partial.Accepted) == 0:
+		return partial.Rejected[0].Err
+	}
+
+	return partial
+}
+
+// personalize returns the copy of the template for the recipient
+func (b *BulkSender) personalize(r MergeRecipient) (*Email, error) {
+	email := b.template.clone()
+	email.filters = b.template.filters
+	email.recipients = nil
+	for _, header := range []string{"To", "Cc", "Message-Id"} {
+		email.headers.Del(header)
+	}
+
+	for header, values := range b.headers {
+		merged := make([]string, len(values))
+		for i, t := range values {
+			var err error
+			if merged[i], err = t.execute(r.Fields, false); err != nil {
+				return nil, err
+			}
+		}
+		email.headers[header] = merged
+	}
+
+	for i, t := range b.parts {
+		body, err := t.execute(r.Fields, strings.HasPrefix(email.parts[i].contentType, TextHTML.string()))
+		if err != nil {
+			return nil, err
+		}
+		email.parts[i].body = bytes.NewBufferString(body)
+	}
+
+	email.AddTo(r.Address)
+	for header, value := range r.Headers {
+		email.AddHeader(header, value)
+	}
+
+	return email, email.Error
+}
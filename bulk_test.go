@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBulkSender(t *testing.T) {
+	ln := &countingListener{Listener: newLocalListener(t)}
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go fakeSMTP(ln, messages)
+
+	pool := NewPool(newPoolServer(ln), 2)
+	defer pool.Close()
+
+	template := NewMSG().
+		SetFrom("news@example.com").
+		SetSubject("Hello {{name}}").
+		AddHeader("X-Campaign", "spring").
+		SetBody(TextPlain, "Hi {{ name }}, your code is {{code}}").
+		AddAlternative(TextHTML, "<p>Hi {{name}}, your code is {{code}}</p>")
+
+	bulk, err := NewBulkSender(pool, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bulk.Send(context.Background(), []MergeRecipient{
+		{Address: "ann@example.com", Fields: map[string]string{"name": "<Ann>", "code": "A1"}, Headers: map[string]string{"List-Unsubscribe": "<https://example.com/u/ann>"}},
+		{Address: "Bob <bob@example.com>", Fields: map[string]string{"name": "Bob", "code": "B2"}},
+		{Address: "carl@example.com", Fields: map[string]string{"name": "Carl"}},
+	})
+
+	partial, ok := err.(*PartialSendError)
+	if !ok {
+		t.Fatalf("got error %v, want a PartialSendError", err)
+	}
+	if len(partial.Accepted) != 2 || len(partial.Rejected) != 1 || partial.Rejected[0].Address != "carl@example.com" {
+		t.Errorf("got %+v", partial)
+	}
+
+	if n := atomic.LoadInt32(&ln.accepted); n != 1 {
+		t.Errorf("got %d connections, want 1", n)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+
+	ann, err := ParseMessage(strings.NewReader(<-messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := ParseMessage(strings.NewReader(<-messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ann.DecodedHeader("Subject"); got != "Hello <Ann>" {
+		t.Errorf("got subject %q", got)
+	}
+	if text, _ := ann.Body("text/plain"); text != "Hi <Ann>, your code is A1" {
+		t.Errorf("got text %q", text)
+	}
+	if html, _ := ann.Body("text/html"); html != "<p>Hi &lt;Ann&gt;, your code is A1</p>" {
+		t.Errorf("got html %q", html)
+	}
+	if ann.Header.Get("To") != "<ann@example.com>" || ann.Header.Get("List-Unsubscribe") != "<https://example.com/u/ann>" {
+		t.Errorf("got header %v", ann.Header)
+	}
+	if ann.Header.Get("X-Campaign") != "spring" {
+		t.Errorf("got X-Campaign %q", ann.Header.Get("X-Campaign"))
+	}
+
+	if got := bob.DecodedHeader("Subject"); got != "Hello Bob" || bob.Header.Get("To") != `"Bob" <bob@example.com>` {
+		t.Errorf("got subject %q, to %q", got, bob.Header.Get("To"))
+	}
+	if bob.Header.Get("List-Unsubscribe") != "" {
+		t.Error("the headers of a recipient were added to the next one")
+	}
+	if ann.Header.Get("Message-Id") == bob.Header.Get("Message-Id") {
+		t.Error("the copies have the same Message-Id")
+	}
+}
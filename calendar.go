@@ -0,0 +1,61 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// calendarMethods are the iTIP methods (RFC 5546)
+var calendarMethods = map[string]bool{
+	"PUBLISH": true, "REQUEST": true, "REPLY": true, "ADD": true,
+	"CANCEL": true, "REFRESH": true, "COUNTER": true, "DECLINECOUNTER": true,
+}
+
+// AddCalendarEvent adds the iCalendar object as a text/calendar alternative
+// part with the iTIP method, like REQUEST for a meeting invitation or
+// CANCEL, so Outlook and Gmail show it as an event with the answer buttons
+// instead of an .ics attachment. The METHOD property of the object must be
+// the same method.
+func (email *Email) AddCalendarEvent(ics []byte, method string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	method = strings.ToUpper(method)
+	if !calendarMethods[method] {
+		email.Error = errors.New("Mail Error: Invalid calendar method; Method: [" + method + "]")
+		return email
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(ics), []byte("BEGIN:VCALENDAR")) {
+		email.Error = errors.New("Mail Error: The calendar event is not an iCalendar object")
+		return email
+	}
+
+	if icsMethod := calendarMethod(ics); icsMethod != method {
+		email.Error = errors.New("Mail Error: The calendar method doesn't match the METHOD of the event; Method: [" + method + "] METHOD: [" + icsMethod + "]")
+		return email
+	}
+
+	email.parts = append(email.parts, part{
+		contentType: "text/calendar; method=" + method,
+		body:        bytes.NewBuffer(ics),
+	})
+
+	return email
+}
+
+// calendarMethod returns the METHOD property of the iCalendar object
+func calendarMethod(ics []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(ics))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) > 7 && strings.EqualFold(line[:7], "METHOD:") {
+			return strings.ToUpper(line[7:])
+		}
+	}
+
+	return ""
+}
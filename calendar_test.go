@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+const testInvite = "BEGIN:VCALENDAR\r\n" +
+	"PRODID:-//Example//Calendar//EN\r\n" +
+	"VERSION:2.0\r\n" +
+	"METHOD:REQUEST\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:1234@example.com\r\n" +
+	"DTSTART:20240108T100000Z\r\n" +
+	"DTEND:20240108T110000Z\r\n" +
+	"SUMMARY:Planning\r\n" +
+	"ORGANIZER:mailto:from@example.com\r\n" +
+	"ATTENDEE;RSVP=TRUE:mailto:to@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestAddCalendarEvent(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetSubject("Planning").
+		SetBody(TextPlain, "Planning on Monday").
+		AddAlternative(TextHTML, "<p>Planning on Monday</p>").
+		AddCalendarEvent([]byte(testInvite), "request")
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+	if err := checkMessage(email, msg); err != nil {
+		t.Errorf("checkMessage: %v", err)
+	}
+
+	parsed, err := ParseMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType := parsed.Header.Get("Content-Type"); !strings.HasPrefix(mediaType, "multipart/alternative;") {
+		t.Errorf("got Content-Type %q", mediaType)
+	}
+
+	calendar := parsed.parts[2]
+	if calendar.mediaType != "text/calendar" || calendar.params["method"] != "REQUEST" || calendar.header.Get("Content-Disposition") != "" {
+		t.Errorf("got calendar part %v", calendar.header)
+	}
+	if string(calendar.data) != testInvite {
+		t.Errorf("got calendar %q", calendar.data)
+	}
+}
+
+func TestAddCalendarEventErrors(t *testing.T) {
+	tests := []struct {
+		ics, method string
+	}{
+		{testInvite, "INVITE"},
+		{"Planning on Monday", "REQUEST"},
+		{testInvite, "CANCEL"},
+		{strings.Replace(testInvite, "METHOD:REQUEST\r\n", "", 1), "REQUEST"},
+	}
+
+	for _, test := range tests {
+		email := NewMSG().AddCalendarEvent([]byte(test.ics), test.method)
+		if email.Error == nil {
+			t.Errorf("%s %q: expected an error", test.method, test.ics)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+// CharsetReader, if set, returns a reader that decodes to UTF-8 the
+// charsets that aren't built in, like Shift_JIS, for the parsed messages.
+// It has the signature of charset.NewReaderLabel of
+// golang.org/x/net/html/charset. The built-in charsets are UTF-8,
+// US-ASCII, ISO-8859-x, Windows-125x, KOI8-R and KOI8-U.
+var CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+// charsetAliases are the other names of the built-in charsets
+var charsetAliases = map[string]string{
+	"ascii":    "us-ascii",
+	"utf8":     "utf-8",
+	"latin1":   "iso-8859-1",
+	"latin-1":  "iso-8859-1",
+	"latin2":   "iso-8859-2",
+	"latin-2":  "iso-8859-2",
+	"latin9":   "iso-8859-15",
+	"latin-9":  "iso-8859-15",
+	"koi8r":    "koi8-r",
+	"koi8u":    "koi8-u",
+	"cyrillic": "iso-8859-5",
+	"greek":    "iso-8859-7",
+	"hebrew":   "iso-8859-8",
+}
+
+// normalizeCharset returns the canonical name of a charset
+func normalizeCharset(charset string) string {
+	name := strings.Replace(strings.ToLower(strings.TrimSpace(charset)), "_", "-", -1)
+
+	if alias, ok := charsetAliases[name]; ok {
+		return alias
+	}
+	if strings.HasPrefix(name, "iso8859-") {
+		return "iso-" + name[3:]
+	}
+	if strings.HasPrefix(name, "cp125") || strings.HasPrefix(name, "windows125") {
+		return "windows-" + name[len(name)-4:]
+	}
+
+	return name
+}
+
+// decodeCharset decodes the text in the charset to UTF-8
+func decodeCharset(charset string, data []byte) (string, error) {
+	name := normalizeCharset(charset)
+
+	switch name {
+	case "", "utf-8", "us-ascii":
+		return string(data), nil
+	case "iso-8859-1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	}
+
+	if charmap, ok := charmaps[name]; ok {
+		table := []rune(charmap)
+		var b strings.Builder
+		b.Grow(len(data))
+		for _, c := range data {
+			if c < utf8.RuneSelf {
+				b.WriteByte(c)
+			} else {
+				b.WriteRune(table[c-utf8.RuneSelf])
+			}
+		}
+		return b.String(), nil
+	}
+
+	if CharsetReader != nil {
+		r, err := CharsetReader(charset, strings.NewReader(string(data)))
+		if err != nil {
+			return "", err
+		}
+		decoded, err := ioutil.ReadAll(r)
+		return string(decoded), err
+	}
+
+	return "", errors.New("Mail Error: Unsupported charset " + charset)
+}
+
+// charsetReader decodes the charsets of the encoded words
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	data, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := decodeCharset(charset, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.NewReader(decoded), nil
+}
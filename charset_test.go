@@ -0,0 +1,63 @@
+package mail
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		charset string
+		data    string
+		want    string
+	}{
+		{"UTF-8", "héllo", "héllo"},
+		{"latin1", "h\xe9llo", "héllo"},
+		{"windows-1252", "\x93hi\x94 \x80", "“hi” €"},
+		{"CP1252", "\x93hi\x94", "“hi”"},
+		{"ISO_8859-2", "\xa3\xf3d\xbc", "Łódź"},
+		{"KOI8-R", "\xf0\xd2\xc9\xd7\xc5\xd4", "Привет"},
+	}
+
+	for _, test := range tests {
+		if got, err := decodeCharset(test.charset, []byte(test.data)); err != nil || got != test.want {
+			t.Errorf("decodeCharset(%s) = %q, %v, want %q", test.charset, got, err, test.want)
+		}
+	}
+
+	if _, err := decodeCharset("shift_jis", []byte("\x82\xa0")); err == nil {
+		t.Error("got no error with an unsupported charset")
+	}
+
+	CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return strings.NewReader("あ"), nil
+	}
+	defer func() { CharsetReader = nil }()
+
+	if got, err := decodeCharset("shift_jis", []byte("\x82\xa0")); err != nil || got != "あ" {
+		t.Errorf("got %q, %v with the CharsetReader", got, err)
+	}
+}
+
+func TestParsedCharsets(t *testing.T) {
+	message := "From: sender@example.com\r\n" +
+		"Subject: =?koi8-r?B?8NLJ18XU?= =?iso-8859-2?Q?=A3=F3d=BC?=\r\n" +
+		"Content-Type: text/plain; charset=windows-1252\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"=93Caf=E9=94\r\n"
+
+	parsed, err := ParseMessage(strings.NewReader(message))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+
+	if got := parsed.DecodedHeader("Subject"); got != "ПриветŁódź" {
+		t.Errorf("got subject %q", got)
+	}
+
+	if body, err := parsed.Body("text/plain"); err != nil || body != "“Café”\r\n" {
+		t.Errorf("got body %q, %v", body, err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package clamd implements a client for the ClamAV daemon that can be used
+// as attachment scanner with Go Simple Mail:
+//
+//	server.Scanners = []mail.Scanner{
+//		mail.ScanAttachments(clamd.NewClient("tcp", "localhost:3310")),
+//	}
+package clamd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the chunks sent with the INSTREAM command
+const chunkSize = 64 * 1024
+
+// Client is a clamd client.
+type Client struct {
+	// Network and Address of the clamd daemon, for example "tcp" and
+	// "localhost:3310" or "unix" and "/var/run/clamav/clamd.ctl"
+	Network string
+	Address string
+	// Timeout for the whole scan of a file, 0 means no timeout
+	Timeout time.Duration
+}
+
+// NewClient returns a client for the clamd daemon listening at address.
+func NewClient(network, address string) *Client {
+	return &Client{
+		Network: network,
+		Address: address,
+		Timeout: 30 * time.Second,
+	}
+}
+
+// Ping checks the connection with the clamd daemon.
+func (c *Client) Ping() error {
+	reply, err := c.command("zPING\x00", nil)
+	if err != nil {
+		return err
+	}
+
+	if reply != "PONG" {
+		return errors.New("clamd: unexpected reply: " + reply)
+	}
+
+	return nil
+}
+
+// ScanAttachment scans the content of r with the INSTREAM command and returns
+// the name of the threat found, if any.
+func (c *Client) ScanAttachment(filename string, r io.Reader) (string, error) {
+	reply, err := c.command("zINSTREAM\x00", r)
+	if err != nil {
+		return "", err
+	}
+
+	// the reply is "stream: OK", "stream: <threat> FOUND" or "<error> ERROR"
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return strings.TrimSuffix(reply, " FOUND"), nil
+	default:
+		return "", errors.New("clamd: scan failed: " + reply)
+	}
+}
+
+// command sends the command to the daemon, streaming r if not nil, and
+// returns the reply
+func (c *Client) command(cmd string, r io.Reader) (string, error) {
+	conn, err := net.Dial(c.Network, c.Address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if c.Timeout != 0 {
+		conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+
+	if _, err = io.WriteString(conn, cmd); err != nil {
+		return "", err
+	}
+
+	if r != nil {
+		if err = writeChunks(conn, r); err != nil {
+			return "", err
+		}
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && (err != io.EOF || reply == "") {
+		return "", err
+	}
+
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+// writeChunks writes r in chunks prefixed with their length, ending
+// with a zero length chunk
+func writeChunks(w io.Writer, r io.Reader) error {
+	buf := make([]byte, 4+chunkSize)
+
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{0, 0, 0, 0})
+
+	return err
+}
@@ -0,0 +1,81 @@
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd handles one INSTREAM command, finding eicar in the stream
+func fakeClamd(t *testing.T, ln net.Listener) {
+	c, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+
+	cmd, err := r.ReadString(0)
+	if err != nil || cmd != "zINSTREAM\x00" {
+		t.Errorf("unexpected command %q: %v", cmd, err)
+		return
+	}
+
+	var data bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			t.Errorf("reading chunk size: %v", err)
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+			t.Errorf("reading chunk: %v", err)
+			return
+		}
+	}
+
+	if strings.Contains(data.String(), eicar) {
+		io.WriteString(c, "stream: Eicar-Signature FOUND\x00")
+	} else {
+		io.WriteString(c, "stream: OK\x00")
+	}
+}
+
+func TestScanAttachment(t *testing.T) {
+	tests := []struct {
+		data, want string
+	}{
+		{"clean file", ""},
+		{eicar, "Eicar-Signature"},
+	}
+
+	for _, test := range tests {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go fakeClamd(t, ln)
+
+		threat, err := NewClient("tcp", ln.Addr().String()).ScanAttachment("file.txt", strings.NewReader(test.data))
+		ln.Close()
+
+		if err != nil {
+			t.Fatalf("ScanAttachment: %v", err)
+		}
+
+		if threat != test.want {
+			t.Errorf("got threat %q, want %q", threat, test.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package mail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// the CMS (RFC 5652) object identifiers
+var (
+	oidData              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidAttrContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidAES256CBC         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type cmsIssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      cmsEncapsulatedContent
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsEncapsulatedContent struct {
+	ContentType asn1.ObjectIdentifier
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                cmsIssuerAndSerial
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type cmsEnvelopedData struct {
+	Version              int
+	RecipientInfos       []cmsRecipientInfo `asn1:"set"`
+	EncryptedContentInfo cmsEncryptedContent
+}
+
+type cmsRecipientInfo struct {
+	Version                int
+	RID                    cmsIssuerAndSerial
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type cmsEncryptedContent struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0"`
+}
+
+// contentInfo returns the DER of a ContentInfo with the content
+func contentInfo(contentType asn1.ObjectIdentifier, content interface{}) ([]byte, error) {
+	der, err := asn1.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(cmsContentInfo{
+		ContentType: contentType,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der},
+	})
+}
+
+func issuerAndSerial(cert *x509.Certificate) cmsIssuerAndSerial {
+	return cmsIssuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber}
+}
+
+// derSet returns the DER of a SET OF the elements, sorted as DER requires
+func derSet(class, tag int, elements ...[]byte) asn1.RawValue {
+	sort.Slice(elements, func(i, j int) bool {
+		return bytes.Compare(elements[i], elements[j]) < 0
+	})
+
+	return asn1.RawValue{Class: class, Tag: tag, IsCompound: true, Bytes: bytes.Join(elements, nil)}
+}
+
+func cmsAttr(attrType asn1.ObjectIdentifier, value interface{}) ([]byte, error) {
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(cmsAttribute{Type: attrType, Values: derSet(asn1.ClassUniversal, asn1.TagSet, der)})
+}
+
+// cmsSign returns a detached CMS SignedData of the content, signed with
+// SHA-256 by the key of the certificate, including the chain certificates
+func cmsSign(content []byte, cert *x509.Certificate, key crypto.Signer, chain []*x509.Certificate, signingTime time.Time) ([]byte, error) {
+	var signatureAlgorithm pkix.AlgorithmIdentifier
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		signatureAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	case *ecdsa.PublicKey:
+		signatureAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	default:
+		return nil, errors.New("Mail Error: S/MIME signing supports only RSA and ECDSA keys")
+	}
+
+	digest := sha256.Sum256(content)
+
+	var attrs [][]byte
+	for _, a := range []struct {
+		attrType asn1.ObjectIdentifier
+		value    interface{}
+	}{
+		{oidAttrContentType, oidData},
+		{oidAttrSigningTime, signingTime.UTC()},
+		{oidAttrMessageDigest, digest[:]},
+	} {
+		attr, err := cmsAttr(a.attrType, a.value)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+
+	// the signature is over the DER of the attributes with the SET tag
+	signedAttrs := derSet(asn1.ClassContextSpecific, 0, attrs...)
+	set, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: signedAttrs.Bytes})
+	if err != nil {
+		return nil, err
+	}
+
+	attrsDigest := sha256.Sum256(set)
+	signature, err := key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []byte
+	for _, c := range append([]*x509.Certificate{cert}, chain...) {
+		certs = append(certs, c.Raw...)
+	}
+
+	sha256Algorithm := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+
+	return contentInfo(oidSignedData, cmsSignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Algorithm},
+		ContentInfo:      cmsEncapsulatedContent{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []cmsSignerInfo{{
+			Version:            1,
+			SID:                issuerAndSerial(cert),
+			DigestAlgorithm:    sha256Algorithm,
+			SignedAttrs:        signedAttrs,
+			SignatureAlgorithm: signatureAlgorithm,
+			Signature:          signature,
+		}},
+	})
+}
+
+// cmsEncrypt returns a CMS EnvelopedData of the content, encrypted with
+// AES-256-CBC and a key transported to each recipient with RSA
+func cmsEncrypt(content []byte, recipients []*x509.Certificate) ([]byte, error) {
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	var infos []cmsRecipientInfo
+	for _, cert := range recipients {
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("Mail Error: S/MIME encryption supports only RSA certificates; Subject: [" + cert.Subject.String() + "]")
+		}
+
+		encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, cmsRecipientInfo{
+			RID:                    issuerAndSerial(cert),
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			EncryptedKey:           encryptedKey,
+		})
+	}
+
+	// PKCS #7 padding
+	padding := aes.BlockSize - len(content)%aes.BlockSize
+	data := append(append([]byte(nil), content...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	return contentInfo(oidEnvelopedData, cmsEnvelopedData{
+		RecipientInfos: infos,
+		EncryptedContentInfo: cmsEncryptedContent{
+			ContentType: oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  oidAES256CBC,
+				Parameters: asn1.RawValue{Tag: asn1.TagOctetString, Bytes: iv},
+			},
+			EncryptedContent: data,
+		},
+	})
+}
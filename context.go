@@ -0,0 +1,41 @@
+package mail
+
+import "context"
+
+// SendWithContext sends the email like Send, aborting the send when the
+// context is canceled or its deadline expires, even in the middle of the
+// DATA transfer of a large message, and returns the context error. An
+// aborted send closes the connection, so the client must reconnect to
+// send more emails.
+func (email *Email) SendWithContext(ctx context.Context, client *SMTPClient) error {
+	_, err := email.sendEnvelope(email.from, client, sendOptions{ctx: ctx})
+	return err
+}
+
+// watchContext closes the connection when the context is done, aborting
+// the blocked reads and writes, until stop is called with the result of
+// the send. stop returns the context error if the send failed because the
+// connection was closed.
+func (c *smtpClient) watchContext(ctx context.Context) (stop func(error) error) {
+	done := make(chan struct{})
+	aborted := make(chan bool, 1)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.conn.Close()
+			aborted <- true
+		case <-done:
+			aborted <- false
+		}
+	}()
+
+	return func(err error) error {
+		close(done)
+		if <-aborted && err != nil {
+			return ctx.Err()
+		}
+
+		return err
+	}
+}
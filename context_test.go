@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stallSMTP accepts the DATA command of a connection and stops reading
+func stallSMTP(ln net.Listener) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		switch strings.ToUpper(strings.Fields(line + " ")[0]) {
+		case "EHLO":
+			text.PrintfLine("250-fake\r\n250 8BITMIME")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			// wait until the client closes the connection
+			var b [1]byte
+			conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+			for {
+				if _, err = conn.Read(b[:]); err != nil {
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestSendWithContext(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	go stallSMTP(ln)
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	server.SendTimeout = 0
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData(bytes.Repeat([]byte("x"), 16<<20), "large.bin", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err = email.SendWithContext(ctx, client); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the send was aborted after %v", elapsed)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = email.SendWithContext(canceled, client); err != context.Canceled {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
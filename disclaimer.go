@@ -0,0 +1,112 @@
+package mail
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// signatureDelimiter is the signature separator line as defined in RFC 3676
+const signatureDelimiter = "\n-- \n"
+
+var bodyCloseTag = regexp.MustCompile(`(?i)</body\s*>`)
+
+// Disclaimer is a filter that appends a disclaimer to the text and html
+// parts of the email. In text parts the disclaimer is placed before the
+// signature, if any, and in html parts it is placed inside the body tag.
+type Disclaimer struct {
+	// Text is the disclaimer added to text/plain parts
+	Text string
+	// HTML is the disclaimer added to text/html parts. If it's empty
+	// the Text disclaimer is escaped and used instead.
+	HTML string
+}
+
+// NewDisclaimer returns a disclaimer filter for the provided text and html
+// disclaimers. The html disclaimer is optional.
+func NewDisclaimer(text string, htmlText ...string) *Disclaimer {
+	d := &Disclaimer{Text: text}
+	if len(htmlText) > 0 {
+		d.HTML = htmlText[0]
+	}
+
+	return d
+}
+
+// Filter adds the disclaimer to the email parts.
+func (d *Disclaimer) Filter(email *Email) error {
+	for i, p := range email.parts {
+		var body string
+
+		switch p.contentType {
+		case TextPlain.string():
+			if d.Text == "" || strings.Contains(p.body.String(), d.Text) {
+				continue
+			}
+			body = d.addText(p.body.String())
+		case TextHTML.string():
+			disclaimer := d.htmlDisclaimer()
+			if disclaimer == "" || strings.Contains(p.body.String(), disclaimer) {
+				continue
+			}
+			body = d.addHTML(p.body.String(), disclaimer)
+		default:
+			continue
+		}
+
+		email.parts[i].body = bytes.NewBufferString(body)
+	}
+
+	return nil
+}
+
+// addText adds the disclaimer before the signature delimiter or at the end of the body
+func (d *Disclaimer) addText(body string) string {
+	newline := "\n"
+	if strings.Contains(body, "\r\n") {
+		newline = "\r\n"
+	}
+
+	delimiter := strings.Replace(signatureDelimiter, "\n", newline, -1)
+
+	var i int
+	switch {
+	case strings.HasPrefix(body, delimiter[len(newline):]):
+		i = 0
+	case strings.Contains(body, delimiter):
+		i = strings.LastIndex(body, delimiter) + len(newline)
+	default:
+		return strings.TrimRight(body, "\r\n") + newline + newline + d.Text + newline
+	}
+
+	return body[:i] + d.Text + newline + newline + body[i:]
+}
+
+// addHTML adds the disclaimer before the closing body tag or at the end of the body
+func (d *Disclaimer) addHTML(body, disclaimer string) string {
+	loc := bodyCloseTag.FindAllStringIndex(body, -1)
+	if loc == nil {
+		return body + disclaimer
+	}
+
+	i := loc[len(loc)-1][0]
+
+	return body[:i] + disclaimer + body[i:]
+}
+
+// htmlDisclaimer returns the html disclaimer, building one from the text if necessary
+func (d *Disclaimer) htmlDisclaimer() string {
+	if d.HTML != "" {
+		return d.HTML
+	}
+
+	if d.Text == "" {
+		return ""
+	}
+
+	text := html.EscapeString(strings.TrimSpace(d.Text))
+	text = strings.Replace(text, "\r\n", "\n", -1)
+
+	return "<p>" + strings.Replace(text, "\n", "<br>", -1) + "</p>"
+}
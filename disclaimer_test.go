@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"testing"
+)
+
+func TestDisclaimer(t *testing.T) {
+	d := NewDisclaimer("Confidential & private")
+
+	tests := []struct {
+		contentType contentType
+		in, want    string
+	}{
+		{TextPlain, "Hello", "Hello\n\nConfidential & private\n"},
+		{TextPlain, "Hello\r\n\r\n", "Hello\r\n\r\nConfidential & private\r\n"},
+		{TextPlain, "Hello\n-- \nJohn", "Hello\nConfidential & private\n\n-- \nJohn"},
+		{TextPlain, "-- \nJohn", "Confidential & private\n\n-- \nJohn"},
+		{TextPlain, "Hello\n\nConfidential & private\n", "Hello\n\nConfidential & private\n"},
+		{TextHTML, "<html><body><p>Hello</p></body></html>", "<html><body><p>Hello</p><p>Confidential &amp; private</p></body></html>"},
+		{TextHTML, "<p>Hello</p>", "<p>Hello</p><p>Confidential &amp; private</p>"},
+	}
+
+	for _, test := range tests {
+		email := NewMSG().SetBody(test.contentType, test.in)
+
+		if err := d.Filter(email); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := email.parts[0].body.String(); got != test.want {
+			t.Errorf("Disclaimer(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"regexp"
+)
+
+// ContentKind is the kind of content passed to a scanner
+type ContentKind int
+
+const (
+	// ContentBody is a body part of the email (text or html)
+	ContentBody ContentKind = iota
+	// ContentAttachment is an attached file
+	ContentAttachment
+	// ContentInline is an inline file
+	ContentInline
+)
+
+// Content represents a decoded part of the email passed to the scanners.
+type Content struct {
+	Kind        ContentKind
+	ContentType string
+	// Filename is empty for body parts
+	Filename string
+	Reader   io.Reader
+}
+
+// Action is the action to take with a message after scanning its content
+type Action int
+
+const (
+	// ActionAllow allows the message to be sent
+	ActionAllow Action = iota
+	// ActionQuarantine stores the message in the quarantine store instead of sending it
+	ActionQuarantine
+	// ActionBlock blocks the message
+	ActionBlock
+)
+
+// Verdict is the result of scanning a content
+type Verdict struct {
+	Action Action
+	Reason string
+}
+
+// Scanner is implemented by pre-send content scanners. Scan is called with
+// each decoded part of the email before sending it.
+type Scanner interface {
+	Scan(content *Content) (Verdict, error)
+}
+
+// ScannerFunc is an adapter to allow the use of ordinary functions as scanners.
+type ScannerFunc func(content *Content) (Verdict, error)
+
+// Scan calls f(content).
+func (f ScannerFunc) Scan(content *Content) (Verdict, error) {
+	return f(content)
+}
+
+// ScanError is returned when a scanner blocks or quarantines the message.
+type ScanError struct {
+	Action Action
+	Reason string
+	// QuarantineID is the id of the message in the quarantine store
+	QuarantineID string
+}
+
+func (e *ScanError) Error() string {
+	if e.Action == ActionQuarantine {
+		return "Mail Error: Message quarantined with id " + e.QuarantineID + ": " + e.Reason
+	}
+
+	return "Mail Error: Message blocked by scanner: " + e.Reason
+}
+
+// contents returns the decoded contents of the email to scan
+func (email *Email) contents() []*Content {
+	var contents []*Content
+
+	for _, p := range email.parts {
+		contents = append(contents, &Content{
+			Kind:        ContentBody,
+			ContentType: p.contentType,
+			Reader:      bytes.NewReader(p.body.Bytes()),
+		})
+	}
+
+	for _, files := range []struct {
+		kind  ContentKind
+		files []*file
+	}{{ContentInline, email.inlines}, {ContentAttachment, email.attachments}} {
+		for _, f := range files.files {
+			r, err := f.open()
+			if err != nil {
+				// the scanners fail instead of skipping the file
+				r = errReader{err}
+			}
+
+			contents = append(contents, &Content{
+				Kind:        files.kind,
+				ContentType: f.mimeType,
+				Filename:    f.filename,
+				Reader:      r,
+			})
+		}
+	}
+
+	return contents
+}
+
+// scan runs the scanners over the email contents. If a scanner asks for
+// quarantine the email is saved in the store, if any, otherwise it's blocked.
+func (email *Email) scan(scanners []Scanner, store QuarantineStore) error {
+	if len(scanners) == 0 {
+		return nil
+	}
+
+	result := Verdict{Action: ActionAllow}
+
+	for _, content := range email.contents() {
+		for _, scanner := range scanners {
+			if seeker, ok := content.Reader.(io.Seeker); ok {
+				seeker.Seek(0, io.SeekStart)
+			}
+
+			verdict, err := scanner.Scan(content)
+			if err != nil {
+				return &ScanError{Action: ActionBlock, Reason: "scanner failed: " + err.Error()}
+			}
+
+			if verdict.Action > result.Action {
+				result = verdict
+			}
+		}
+	}
+
+	switch result.Action {
+	case ActionAllow:
+		return nil
+	case ActionQuarantine:
+		if store != nil {
+			id, err := store.Quarantine(email, result.Reason)
+			if err != nil {
+				return &ScanError{Action: ActionBlock, Reason: "quarantine failed: " + err.Error()}
+			}
+
+			return &ScanError{Action: ActionQuarantine, Reason: result.Reason, QuarantineID: id}
+		}
+	}
+
+	return &ScanError{Action: ActionBlock, Reason: result.Reason}
+}
+
+// Pattern is a named regular expression used by the pattern scanner.
+// If Validate is not nil, the matches are only reported when it returns true.
+type Pattern struct {
+	Name     string
+	Regexp   *regexp.Regexp
+	Validate func(match string) bool
+}
+
+var (
+	// CreditCardPattern matches credit card numbers that pass the Luhn check
+	CreditCardPattern = &Pattern{
+		Name:     "credit card number",
+		Regexp:   regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Validate: luhn,
+	}
+	// SSNPattern matches US social security numbers
+	SSNPattern = &Pattern{
+		Name:   "social security number",
+		Regexp: regexp.MustCompile(`\b(?:00[1-9]|0[1-9]\d|[1-578]\d{2}|6[0-57-9]\d|66[0-57-9])-(?:0[1-9]|[1-9]\d)-(?:000[1-9]|00[1-9]\d|0[1-9]\d{2}|[1-9]\d{3})\b`),
+	}
+)
+
+// PatternScanner is a scanner that looks for patterns in the email
+// body parts and text attachments.
+type PatternScanner struct {
+	Action   Action
+	Patterns []*Pattern
+}
+
+// NewPatternScanner returns a scanner that returns the given action when
+// any of the patterns is found.
+func NewPatternScanner(action Action, patterns ...*Pattern) *PatternScanner {
+	return &PatternScanner{Action: action, Patterns: patterns}
+}
+
+// Scan looks for the patterns in the content. Only text contents are scanned.
+func (s *PatternScanner) Scan(content *Content) (Verdict, error) {
+	if !isText(content.ContentType) {
+		return Verdict{}, nil
+	}
+
+	data, err := ioutil.ReadAll(content.Reader)
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	for _, pattern := range s.Patterns {
+		for _, match := range pattern.Regexp.FindAll(data, -1) {
+			if pattern.Validate == nil || pattern.Validate(string(match)) {
+				reason := pattern.Name + " found"
+				if content.Filename != "" {
+					reason += " in " + content.Filename
+				}
+
+				return Verdict{Action: s.Action, Reason: reason}, nil
+			}
+		}
+	}
+
+	return Verdict{}, nil
+}
+
+var textMimeType = regexp.MustCompile(`^(text/|application/(json|xml|csv)|[^;]*\+xml)`)
+
+// isText returns true for textual mime types
+func isText(mimeType string) bool {
+	return textMimeType.MatchString(mimeType)
+}
+
+// luhn checks a number with the Luhn algorithm, ignoring spaces and dashes
+func luhn(number string) bool {
+	sum, digits := 0, 0
+	double := false
+
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+
+		sum += d
+		digits++
+		double = !double
+	}
+
+	return digits >= 13 && sum%10 == 0
+}
+
+// randomID returns a random hex encoded id
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// AttachmentScanner is implemented by virus scanners. ScanAttachment is called
+// with the content of each attachment and inline file before sending the email.
+type AttachmentScanner interface {
+	// ScanAttachment returns the name of the threat found in the content or
+	// an empty string if the content is clean.
+	ScanAttachment(filename string, r io.Reader) (threat string, err error)
+}
+
+// ScanAttachments returns a scanner that runs the attachment scanner over the
+// attachments and inline files of the email, blocking the email if a threat is
+// found. If the attachment scanner fails the email is blocked too.
+func ScanAttachments(scanner AttachmentScanner) Scanner {
+	return ScannerFunc(func(content *Content) (Verdict, error) {
+		if content.Kind == ContentBody {
+			return Verdict{}, nil
+		}
+
+		threat, err := scanner.ScanAttachment(content.Filename, content.Reader)
+		if err != nil {
+			return Verdict{}, err
+		}
+
+		if threat != "" {
+			return Verdict{Action: ActionBlock, Reason: "threat " + threat + " found in " + content.Filename}, nil
+		}
+
+		return Verdict{}, nil
+	})
+}
@@ -0,0 +1,122 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestPatternScanner(t *testing.T) {
+	scanner := NewPatternScanner(ActionBlock, CreditCardPattern, SSNPattern)
+
+	tests := []struct {
+		body string
+		want Action
+	}{
+		{"Nothing to see here", ActionAllow},
+		{"My card is 4111 1111 1111 1111", ActionBlock},
+		{"Order number 1234 5678 9012 3456", ActionAllow},
+		{"SSN: 123-45-6789", ActionBlock},
+		{"SSN: 000-45-6789", ActionAllow},
+	}
+
+	for _, test := range tests {
+		email := NewMSG().SetBody(TextPlain, test.body)
+
+		err := email.scan([]Scanner{scanner}, nil)
+
+		var scanErr *ScanError
+		switch {
+		case test.want == ActionAllow && err != nil:
+			t.Errorf("scan(%q) got error %v, want nil", test.body, err)
+		case test.want != ActionAllow && (!errors.As(err, &scanErr) || scanErr.Action != test.want):
+			t.Errorf("scan(%q) got error %v, want action %d", test.body, err, test.want)
+		}
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	store := NewMemoryQuarantine()
+	scanner := NewPatternScanner(ActionQuarantine, SSNPattern)
+
+	email := NewMSG().
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte("SSN: 123-45-6789"), "data.txt", "")
+
+	err := email.scan([]Scanner{scanner}, store)
+
+	var scanErr *ScanError
+	if !errors.As(err, &scanErr) || scanErr.Action != ActionQuarantine {
+		t.Fatalf("got error %v, want quarantine", err)
+	}
+
+	m := store.Get(scanErr.QuarantineID)
+	if m == nil || m.Email != email {
+		t.Fatalf("message %s not found in quarantine store", scanErr.QuarantineID)
+	}
+	if want := "social security number found in data.txt"; m.Reason != want {
+		t.Errorf("got reason %q, want %q", m.Reason, want)
+	}
+
+	// without store the message is blocked
+	err = email.scan([]Scanner{scanner}, nil)
+	if !errors.As(err, &scanErr) || scanErr.Action != ActionBlock {
+		t.Errorf("got error %v, want block", err)
+	}
+}
+
+type fakeAttachmentScanner struct {
+	scanned []string
+	err     error
+}
+
+func (s *fakeAttachmentScanner) ScanAttachment(filename string, r io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	s.scanned = append(s.scanned, filename)
+
+	if strings.Contains(string(data), "EICAR") {
+		return "Eicar-Test-Signature", s.err
+	}
+	return "", s.err
+}
+
+func TestScanAttachments(t *testing.T) {
+	scanner := &fakeAttachmentScanner{}
+	email := NewMSG().
+		SetBody(TextPlain, "EICAR in the body").
+		AddAlternative(TextHTML, "<p>EICAR in the body</p>").
+		AddInlineData([]byte("logo"), "logo.png", "").
+		AddAttachmentData([]byte("report"), "report.pdf", "")
+
+	// only the attachments and inline files are scanned
+	if err := email.scan([]Scanner{ScanAttachments(scanner)}, nil); err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	if got := strings.Join(scanner.scanned, ", "); got != "logo.png, report.pdf" {
+		t.Errorf("got scanned files %q", got)
+	}
+
+	// a threat blocks the email
+	email.AddAttachmentData([]byte("EICAR"), "virus.exe", "")
+	err := email.scan([]Scanner{ScanAttachments(scanner)}, NewMemoryQuarantine())
+
+	var scanErr *ScanError
+	if !errors.As(err, &scanErr) || scanErr.Action != ActionBlock {
+		t.Fatalf("got error %v, want block", err)
+	}
+	if want := "threat Eicar-Test-Signature found in virus.exe"; scanErr.Reason != want {
+		t.Errorf("got reason %q, want %q", scanErr.Reason, want)
+	}
+
+	// so does a failed attachment scanner
+	scanner.err = errors.New("clamd unavailable")
+	err = email.scan([]Scanner{ScanAttachments(scanner)}, nil)
+	if !errors.As(err, &scanErr) || scanErr.Action != ActionBlock {
+		t.Errorf("got error %v, want block", err)
+	}
+}
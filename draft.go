@@ -0,0 +1,349 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MessageStore is implemented by stores where messages are saved by id.
+type MessageStore interface {
+	Save(id string, data []byte) error
+	// Load returns ErrNotFound if there is no message with the given id
+	Load(id string) ([]byte, error)
+}
+
+// draft is the serialized form of an email
+type draft struct {
+	ID          string               `json:"id"`
+	From        string               `json:"from,omitempty"`
+	Sender      string               `json:"sender,omitempty"`
+	ReplyTo     string               `json:"reply_to,omitempty"`
+	ReturnPath  string               `json:"return_path,omitempty"`
+	Recipients  []string             `json:"recipients,omitempty"`
+	Headers     textproto.MIMEHeader `json:"headers"`
+	Parts       []draftPart          `json:"parts,omitempty"`
+	Attachments []draftFile          `json:"attachments,omitempty"`
+	Inlines     []draftFile          `json:"inlines,omitempty"`
+	Correlation string               `json:"correlation,omitempty"`
+	Metadata    map[string]string    `json:"metadata,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Charset     string               `json:"charset"`
+	Encoding    encoding             `json:"encoding"`
+	SevenBit    bool                 `json:"seven_bit,omitempty"`
+	AutoText    bool                 `json:"auto_text,omitempty"`
+	Profile     Profile              `json:"profile"`
+	MailParams  []Param              `json:"mail_params,omitempty"`
+	RcptParams  []Param              `json:"rcpt_params,omitempty"`
+	HeaderOrder []string             `json:"header_order,omitempty"`
+	Manifest    ManifestFormat       `json:"manifest,omitempty"`
+	Preamble    string               `json:"preamble,omitempty"`
+	Epilogue    string               `json:"epilogue,omitempty"`
+	DSN         dsnOptions           `json:"dsn"`
+}
+
+type draftPart struct {
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+type draftFile struct {
+	Filename    string `json:"filename"`
+	MimeType    string `json:"mime_type"`
+	Data        []byte `json:"data"`
+	ContentType string `json:"content_type,omitempty"`
+	Disposition string `json:"disposition,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+	CID         string `json:"cid,omitempty"`
+}
+
+// SaveDraft saves the email in the store and returns its draft id. Saving
+// an email loaded with LoadDraft overwrites the same draft. Filters added to
+// the email and its S/MIME keys are not saved.
+func (email *Email) SaveDraft(store MessageStore) (string, error) {
+	if email.Error != nil {
+		return "", email.Error
+	}
+
+	for _, f := range email.attachments {
+		if f.reader != nil {
+			return "", errors.New("Mail Error: Attachment streams can't be saved as draft; File: [" + f.filename + "]")
+		}
+	}
+
+	if email.draftID == "" {
+		id, err := randomID()
+		if err != nil {
+			return "", err
+		}
+		email.draftID = id
+	}
+
+	d := draft{
+		ID:          email.draftID,
+		From:        email.from,
+		Sender:      email.sender,
+		ReplyTo:     email.replyTo,
+		ReturnPath:  email.returnPath,
+		Recipients:  email.recipients,
+		Headers:     email.headers,
+		Attachments: draftFiles(email.attachments),
+		Inlines:     draftFiles(email.inlines),
+		Correlation: email.correlation,
+		Metadata:    email.metadata,
+		Tags:        email.tags,
+		Charset:     email.Charset,
+		Encoding:    email.Encoding,
+		SevenBit:    email.sevenBit,
+		AutoText:    email.autoText,
+		Profile:     email.profile,
+		MailParams:  email.mailParams,
+		RcptParams:  email.rcptParams,
+		HeaderOrder: email.headerOrder,
+		Manifest:    email.manifest,
+		Preamble:    email.preamble,
+		Epilogue:    email.epilogue,
+		DSN:         email.dsn,
+	}
+
+	for _, p := range email.parts {
+		d.Parts = append(d.Parts, draftPart{ContentType: p.contentType, Body: p.body.String()})
+	}
+
+	data, err := json.Marshal(&d)
+	if err != nil {
+		return "", errors.New("Mail Error: Failed to serialize draft: " + err.Error())
+	}
+
+	if err = store.Save(d.ID, data); err != nil {
+		return "", err
+	}
+
+	return d.ID, nil
+}
+
+// LoadDraft loads the email saved with SaveDraft from the store.
+func LoadDraft(store MessageStore, id string) (*Email, error) {
+	data, err := store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var d draft
+	if err = json.Unmarshal(data, &d); err != nil {
+		return nil, errors.New("Mail Error: Failed to load draft: " + err.Error())
+	}
+
+	email := &Email{
+		draftID:     d.ID,
+		from:        d.From,
+		sender:      d.Sender,
+		replyTo:     d.ReplyTo,
+		returnPath:  d.ReturnPath,
+		recipients:  d.Recipients,
+		headers:     d.Headers,
+		attachments: emailFiles(d.Attachments),
+		inlines:     emailFiles(d.Inlines),
+		correlation: d.Correlation,
+		metadata:    d.Metadata,
+		tags:        d.Tags,
+		Charset:     d.Charset,
+		Encoding:    d.Encoding,
+		sevenBit:    d.SevenBit,
+		autoText:    d.AutoText,
+		profile:     d.Profile,
+		mailParams:  d.MailParams,
+		rcptParams:  d.RcptParams,
+		headerOrder: d.HeaderOrder,
+		manifest:    d.Manifest,
+		preamble:    d.Preamble,
+		epilogue:    d.Epilogue,
+		dsn:         d.DSN,
+	}
+
+	if email.headers == nil {
+		email.headers = make(textproto.MIMEHeader)
+	}
+
+	for _, p := range d.Parts {
+		email.parts = append(email.parts, part{contentType: p.ContentType, body: bytes.NewBufferString(p.Body)})
+	}
+
+	return email, nil
+}
+
+func draftFiles(files []*file) []draftFile {
+	var d []draftFile
+	for _, f := range files {
+		d = append(d, draftFile{
+			Filename:    f.filename,
+			MimeType:    f.mimeType,
+			Data:        f.data,
+			ContentType: f.contentType,
+			Disposition: f.disposition,
+			Encoding:    f.encoding,
+			CID:         f.cid,
+		})
+	}
+
+	return d
+}
+
+func emailFiles(files []draftFile) []*file {
+	var f []*file
+	for _, d := range files {
+		f = append(f, &file{
+			filename:    d.Filename,
+			mimeType:    d.MimeType,
+			data:        d.Data,
+			contentType: d.ContentType,
+			disposition: d.Disposition,
+			encoding:    d.Encoding,
+			cid:         d.CID,
+		})
+	}
+
+	return f
+}
+
+// MemoryStore is an in-memory message store.
+type MemoryStore struct {
+	mu       sync.Mutex
+	messages map[string][]byte
+}
+
+// NewMemoryStore returns a new in-memory message store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{messages: make(map[string][]byte)}
+}
+
+// Save saves the message in memory.
+func (s *MemoryStore) Save(id string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages[id] = append([]byte(nil), data...)
+
+	return nil
+}
+
+// Load returns the message with the given id.
+func (s *MemoryStore) Load(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.messages[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return data, nil
+}
+
+// Delete removes the message with the given id.
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.messages, id)
+
+	return nil
+}
+
+// List returns the ids of the messages, in no particular order.
+func (s *MemoryStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.messages))
+	for id := range s.messages {
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// DirStore is a message store that saves each message in a file in a directory.
+type DirStore struct {
+	Dir string
+}
+
+var validStoreID = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Save writes the message in a file named as the id.
+func (s *DirStore) Save(id string, data []byte) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename it, so the file is never partially written
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Load reads the message with the given id.
+func (s *DirStore) Load(id string) ([]byte, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	return data, err
+}
+
+// Delete removes the file of the message with the given id.
+func (s *DirStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// List returns the ids of the messages of the directory, sorted.
+func (s *DirStore) List() ([]string, error) {
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, f := range files {
+		// skip the files being written
+		if f.Mode().IsRegular() && !strings.HasSuffix(f.Name(), ".tmp") && validStoreID.MatchString(f.Name()) {
+			ids = append(ids, f.Name())
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *DirStore) path(id string) (string, error) {
+	if !validStoreID.MatchString(id) || id == "." || id == ".." {
+		return "", errors.New("Mail Error: Invalid message id: " + id)
+	}
+
+	return filepath.Join(s.Dir, id), nil
+}
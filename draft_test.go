@@ -0,0 +1,57 @@
+package mail
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDraft(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drafts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, store := range []MessageStore{NewMemoryStore(), &DirStore{Dir: dir}} {
+		email := NewMSG()
+		email.SetFrom("From <from@example.com>").
+			AddTo("to@example.com").
+			AddBcc("bcc@example.com").
+			SetSubject("Draft").
+			SetDate("2015-04-28 10:32:00 CDT").
+			SetBody(TextHTML, "<p>Hello</p>").
+			AddAlternative(TextPlain, "Hello").
+			AddAttachmentData([]byte("data"), "file.txt", "").
+			AddTag("draft")
+
+		id, err := email.SaveDraft(store)
+		if err != nil {
+			t.Fatalf("SaveDraft: %v", err)
+		}
+
+		loaded, err := LoadDraft(store, id)
+		if err != nil {
+			t.Fatalf("LoadDraft: %v", err)
+		}
+
+		if got := loaded.GetRecipients(); len(got) != 2 {
+			t.Errorf("got recipients %v, want 2", got)
+		}
+
+		want, _ := store.Load(id)
+
+		// saving a loaded draft keeps the same id and content
+		if id2, _ := loaded.SaveDraft(store); id2 != id {
+			t.Errorf("got draft id %q, want %q", id2, id)
+		}
+
+		if got, _ := store.Load(id); string(got) != string(want) {
+			t.Errorf("got draft %s, want %s", got, want)
+		}
+
+		if _, err := LoadDraft(store, "missing"); err != ErrNotFound {
+			t.Errorf("got error %v, want ErrNotFound", err)
+		}
+	}
+}
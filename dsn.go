@@ -0,0 +1,135 @@
+package mail
+
+import (
+	"errors"
+	"strings"
+)
+
+// DSNReturn is the part of the message returned in the failure DSNs
+type DSNReturn string
+
+const (
+	// DSNReturnFull returns the full message
+	DSNReturnFull DSNReturn = "FULL"
+	// DSNReturnHeaders returns only the headers of the message
+	DSNReturnHeaders DSNReturn = "HDRS"
+)
+
+// DSNNotify is a condition that sends a DSN to the sender
+type DSNNotify string
+
+const (
+	// DSNNotifySuccess sends a DSN when the message is delivered
+	DSNNotifySuccess DSNNotify = "SUCCESS"
+	// DSNNotifyFailure sends a DSN when the delivery fails
+	DSNNotifyFailure DSNNotify = "FAILURE"
+	// DSNNotifyDelay sends a DSN when the delivery is delayed
+	DSNNotifyDelay DSNNotify = "DELAY"
+	// DSNNotifyNever doesn't send DSNs, it can't be used with the others
+	DSNNotifyNever DSNNotify = "NEVER"
+)
+
+// dsnOptions are the DSN parameters of an email
+type dsnOptions struct {
+	Ret    DSNReturn         `json:"ret,omitempty"`
+	Notify []DSNNotify       `json:"notify,omitempty"`
+	ORCPT  map[string]string `json:"orcpt,omitempty"`
+}
+
+// SetDSN requests Delivery Status Notifications (RFC 3461) of the email,
+// with the RET parameter in the MAIL FROM command and the NOTIFY parameter
+// in the RCPT TO command of every recipient. Unlike AddMailParam, the
+// parameters are only sent if the server advertises DSN. An empty ret
+// leaves the choice to the server.
+func (email *Email) SetDSN(ret DSNReturn, notify ...DSNNotify) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	if ret != "" && ret != DSNReturnFull && ret != DSNReturnHeaders {
+		email.Error = errors.New("Mail Error: Invalid DSN return " + string(ret))
+		return email
+	}
+
+	for _, n := range notify {
+		switch n {
+		case DSNNotifySuccess, DSNNotifyFailure, DSNNotifyDelay:
+		case DSNNotifyNever:
+			if len(notify) > 1 {
+				email.Error = errors.New("Mail Error: DSN notify NEVER can't be combined with other conditions")
+				return email
+			}
+		default:
+			email.Error = errors.New("Mail Error: Invalid DSN notify " + string(n))
+			return email
+		}
+	}
+
+	email.dsn.Ret = ret
+	email.dsn.Notify = notify
+
+	return email
+}
+
+// SetOriginalRecipient sets the original recipient of an address, sent in
+// the ORCPT parameter of its RCPT TO command when the server advertises
+// DSN, so the DSNs report the address the message was originally sent to,
+// like the address of a mailing list member before forwarding.
+func (email *Email) SetOriginalRecipient(address, original string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	if email.dsn.ORCPT == nil {
+		email.dsn.ORCPT = make(map[string]string)
+	}
+	email.dsn.ORCPT[address] = original
+
+	return email
+}
+
+// mailParams returns the DSN parameters of the MAIL FROM command
+func (dsn dsnOptions) mailParams(params []Param) []Param {
+	if dsn.Ret == "" || hasParam(params, "RET") {
+		return params
+	}
+
+	return append(params[:len(params):len(params)], Param{Keyword: "RET", Value: string(dsn.Ret)})
+}
+
+// rcptParams returns the DSN parameters of the RCPT TO command of the
+// address
+func (dsn dsnOptions) rcptParams(params []Param, address string) []Param {
+	params = params[:len(params):len(params)]
+
+	if len(dsn.Notify) > 0 && !hasParam(params, "NOTIFY") {
+		notify := make([]string, len(dsn.Notify))
+		for i, n := range dsn.Notify {
+			notify[i] = string(n)
+		}
+		params = append(params, Param{Keyword: "NOTIFY", Value: strings.Join(notify, ",")})
+	}
+
+	if original, ok := dsn.ORCPT[address]; ok && !hasParam(params, "ORCPT") {
+		params = append(params, Param{Keyword: "ORCPT", Value: "rfc822;" + xtext(original)})
+	}
+
+	return params
+}
+
+// xtext encodes a DSN parameter value (RFC 3461 4)
+func xtext(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 33 || c > 126 || c == '+' || c == '=' {
+			b.WriteString("+" + string(hex[c>>4]) + string(hex[c&15]))
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// fakeClient returns a client that reads the replies of the server and
+// writes the commands to the returned buffer
+func fakeClient(replies ...string) (*smtpClient, *bufio.Writer, *bytes.Buffer) {
+	server := strings.Join(replies, "\r\n") + "\r\n"
+
+	cmdbuf := &bytes.Buffer{}
+	bcmdbuf := bufio.NewWriter(cmdbuf)
+	var fake faker
+	fake.ReadWriter = bufio.NewReadWriter(bufio.NewReader(strings.NewReader(server)), bcmdbuf)
+
+	return &smtpClient{text: textproto.NewConn(fake), localName: "localhost"}, bcmdbuf, cmdbuf
+}
+
+func TestDSN(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("one@example.com", "two@example.com").
+		SetBody(TextPlain, "Hello").
+		SetDSN(DSNReturnHeaders, DSNNotifyFailure, DSNNotifyDelay).
+		SetOriginalRecipient("two@example.com", "list+member=1@example.com")
+	if email.Error != nil {
+		t.Fatalf("SetDSN: %v", email.Error)
+	}
+
+	tests := []struct {
+		ext  string
+		want []string
+	}{
+		{"DSN", []string{
+			"MAIL FROM:<from@example.com> RET=HDRS\r\n",
+			"RCPT TO:<one@example.com> NOTIFY=FAILURE,DELAY\r\n",
+			"RCPT TO:<two@example.com> NOTIFY=FAILURE,DELAY ORCPT=rfc822;list+2Bmember+3D1@example.com\r\n",
+		}},
+		{"PIPELINING", []string{
+			"MAIL FROM:<from@example.com>\r\n",
+			"RCPT TO:<one@example.com>\r\n",
+			"RCPT TO:<two@example.com>\r\n",
+		}},
+	}
+
+	for _, test := range tests {
+		c, bcmdbuf, cmdbuf := fakeClient("250-fake", "250 "+test.ext, "250 Sender OK",
+			"250 Receiver OK", "250 Receiver OK", "354 Go ahead", "250 Data OK")
+		if err := c.hello(); err != nil {
+			t.Fatalf("EHLO: %v", err)
+		}
+
+		opts := sendOptions{dsn: email.dsn}
+		if err := sendMailProcess(email.from, email.recipients, email.GetMessage(), c, opts); err != nil {
+			t.Fatalf("%s: sendMailProcess: %v", test.ext, err)
+		}
+
+		bcmdbuf.Flush()
+		for _, want := range test.want {
+			if !strings.Contains(cmdbuf.String(), want) {
+				t.Errorf("%s: commands don't contain %q:\n%s", test.ext, want, cmdbuf.String())
+			}
+		}
+	}
+}
+
+func TestSetDSNInvalid(t *testing.T) {
+	if err := NewMSG().SetDSN(DSNReturnFull, DSNNotifyNever, DSNNotifyFailure).Error; err == nil {
+		t.Error("got no error with NEVER and FAILURE")
+	}
+	if err := NewMSG().SetDSN("BODY").Error; err == nil {
+		t.Error("got no error with an invalid return")
+	}
+}
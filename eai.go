@@ -0,0 +1,164 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"mime"
+	"net/mail"
+	"strings"
+)
+
+// utf8Headers returns true if the email headers can be sent as raw UTF-8
+// (RFC 6532) in the client connection, because UTF8Headers is set and the
+// server supports SMTPUTF8
+func (client *SMTPClient) utf8Headers() bool {
+	if client.server == nil || !client.server.UTF8Headers || client.Client == nil {
+		return false
+	}
+
+	ok, _ := client.Client.extension("SMTPUTF8")
+
+	return ok
+}
+
+// messageID returns a new Message-ID with the domain of the From address
+func (msg *message) messageID() string {
+	random := make([]byte, 8)
+	rand.Read(random)
+
+	domain := senderDomain(msg.headers.Get("From"), msg.utf8Headers)
+
+	return "<" + msg.now().Format("20060102150405") + "." + hex.EncodeToString(random) + "@" + domain + ">"
+}
+
+// senderDomain returns the domain of the From header, converted to ASCII
+// unless utf8 is set, or localhost if there isn't a valid one
+func senderDomain(fromHeader string, utf8 bool) string {
+	domain := "localhost"
+	if from, err := mail.ParseAddress(fromHeader); err == nil {
+		if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+			domain = from.Address[i+1:]
+		}
+	}
+
+	if !utf8 {
+		var err error
+		if domain, err = toASCIIDomain(domain); err != nil {
+			domain = "localhost"
+		}
+	}
+
+	return domain
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// decodeHeader decodes the encoded words of a header value, like the
+// display names of the address headers
+func decodeHeader(value string) string {
+	dec := mime.WordDecoder{CharsetReader: charsetReader}
+	if decoded, err := dec.DecodeHeader(value); err == nil {
+		return decoded
+	}
+
+	return value
+}
+
+// maxLineLength is the maximum length of a line of a message, without the
+// CRLF (RFC 5322, section 2.1.1)
+const maxLineLength = 998
+
+// utf8HeaderValue returns the raw UTF-8 value of a header, decoded and
+// sanitized like the encoded values: the CR, LF and TAB, also the ones of
+// the decoded words, are removed so the value can't add headers. The value
+// is folded at its spaces to keep the lines within 998 octets, usedChars
+// being the length of the header name.
+func utf8HeaderValue(value string, usedChars int) string {
+	value = strings.TrimSpace(decodeHeader(value))
+	value = strings.NewReplacer("\r", "", "\n", "", "\t", "").Replace(value)
+
+	var b strings.Builder
+	line := usedChars
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 {
+			if line+1+len(word) > maxLineLength {
+				b.WriteString("\r\n")
+				line = 0
+			}
+			b.WriteByte(' ')
+			line++
+		}
+		b.WriteString(word)
+		line += len(word)
+	}
+
+	return b.String()
+}
+
+// addressHeaders are the headers with addresses
+var addressHeaders = map[string]bool{
+	"From":     true,
+	"Sender":   true,
+	"Reply-To": true,
+	"To":       true,
+	"Cc":       true,
+}
+
+// asciiEnvelope converts the internationalized domains of the envelope
+// addresses to punycode, for servers that don't support SMTPUTF8. It fails
+// if a local part isn't ASCII.
+func asciiEnvelope(from string, to []string) (string, []string, error) {
+	var err error
+	if !isASCII(from) {
+		if from, err = ToASCIIAddress(from); err != nil {
+			return "", nil, err
+		}
+	}
+
+	converted := make([]string, len(to))
+	for i, addr := range to {
+		converted[i] = addr
+		if isASCII(addr) {
+			continue
+		}
+		if converted[i], err = ToASCIIAddress(addr); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return from, converted, nil
+}
+
+// asciiAddresses converts the internationalized domains of the addresses
+// of a header to punycode. The addresses with non-ASCII local parts are
+// kept as they are.
+func asciiAddresses(values []string) []string {
+	converted := make([]string, 0, len(values))
+	for _, value := range values {
+		list, err := mail.ParseAddressList(value)
+		if err != nil || isASCII(value) {
+			converted = append(converted, value)
+			continue
+		}
+
+		addresses := make([]string, 0, len(list))
+		for _, a := range list {
+			if addr, err := ToASCIIAddress(a.Address); err == nil {
+				addresses = append(addresses, FormatAddress(a.Name, addr))
+			} else {
+				addresses = append(addresses, FormatAddress(a.Name, a.Address))
+			}
+		}
+		converted = append(converted, strings.Join(addresses, ", "))
+	}
+
+	return converted
+}
@@ -0,0 +1,134 @@
+package mail
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestUTF8Headers(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 2)
+	go fakeSMTP(ln, messages, map[string]string{"EHLO": "250-fake\r\n250 SMTPUTF8"})
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	server.KeepAlive = true
+	server.UTF8Headers = true
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	email := NewMSG().
+		SetFrom("Jörg <jörg@bücher.example>").
+		AddTo("to@example.com").
+		SetSubject("Grüße").
+		SetBody(TextPlain, "Hello")
+
+	if err = email.Send(client); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	msg := <-messages
+	for _, want := range []string{"Subject: Grüße\n", "From: Jörg <jörg@bücher.example>\n", "@bücher.example>\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message doesn't contain %q:\n%s", want, msg)
+		}
+	}
+
+	// the original email is not modified
+	if msg := email.GetMessage(); strings.Contains(msg, "Subject: Grüße") {
+		t.Errorf("got UTF-8 headers without SMTPUTF8:\n%s", msg)
+	}
+}
+
+func TestMessageID(t *testing.T) {
+	email := NewMSG().SetFrom("from@example.com").AddTo("to@example.com")
+	email.GetMessage()
+
+	if id := email.headers.Get("Message-Id"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("got Message-Id %q", id)
+	}
+
+	email = NewMSG().SetFrom("from@bücher.example").AddTo("to@example.com")
+	email.GetMessage()
+
+	if id := email.headers.Get("Message-Id"); !strings.HasSuffix(id, "@xn--bcher-kva.example>") {
+		t.Errorf("got Message-Id %q, want the punycode domain", id)
+	}
+}
+
+func TestASCIIEnvelope(t *testing.T) {
+	c, bcmdbuf, cmdbuf := fakeClient("250-fake", "250 8BITMIME", "250 Sender OK",
+		"250 Receiver OK", "354 Go ahead", "250 Data OK")
+
+	if err := c.hello(); err != nil {
+		t.Fatalf("EHLO: %v", err)
+	}
+
+	email := NewMSG().
+		SetFrom("Jörg <from@bücher.example>").
+		AddTo("to@münchen.example").
+		SetBody(TextPlain, "Hello")
+
+	msg := email.GetMessage()
+	for _, want := range []string{"From: =?utf-8?q?J=C3=B6rg?= <from@xn--bcher-kva.example>\r\n", "To: <to@xn--mnchen-3ya.example>\r\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message doesn't contain %q:\n%s", want, msg)
+		}
+	}
+
+	if err := sendMailProcess(email.from, email.recipients, msg, c, sendOptions{}); err != nil {
+		t.Fatalf("sendMailProcess: %v", err)
+	}
+
+	bcmdbuf.Flush()
+	for _, want := range []string{"MAIL FROM:<from@xn--bcher-kva.example> BODY=8BITMIME\r\n", "RCPT TO:<to@xn--mnchen-3ya.example>\r\n"} {
+		if !strings.Contains(cmdbuf.String(), want) {
+			t.Errorf("commands don't contain %q:\n%s", want, cmdbuf.String())
+		}
+	}
+
+	// the local part can't be converted
+	if err := sendMailProcess("jörg@bücher.example", email.recipients, msg, c, sendOptions{}); err == nil {
+		t.Error("got no error with a non-ASCII local part and no SMTPUTF8")
+	}
+}
+
+func TestUTF8HeadersInjection(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetSubject("hi\r\nBcc: evil@x.com\r\nX-Inj: 1").
+		AddHeader("X-Decoded", "=?utf-8?b?"+base64.StdEncoding.EncodeToString([]byte("a\r\nX-Inj: 2"))+"?=").
+		AddHeader("X-Long", strings.Repeat("wörd ", 300)).
+		SetBody(TextPlain, "Hello")
+
+	email = email.clone()
+	email.utf8Headers = true
+	msg, err := email.buildMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := msg[:strings.Index(msg, "\r\n\r\n")+2]
+	for _, line := range strings.Split(header, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") || strings.HasPrefix(line, "X-Inj:") {
+			t.Errorf("injected header %q", line)
+		}
+		if len(line) > 998 {
+			t.Errorf("got a line of %d octets", len(line))
+		}
+	}
+	if !strings.Contains(header, "Subject: hiBcc: evil@x.comX-Inj: 1\r\n") {
+		t.Errorf("got headers:\n%s", header)
+	}
+	if !strings.Contains(header, "X-Long: wörd") || !strings.Contains(header, "\r\n wörd") {
+		t.Errorf("X-Long is not folded:\n%s", header)
+	}
+}
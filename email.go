@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"mime"
 	"net"
@@ -13,24 +14,46 @@ import (
 	"net/textproto"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Email represents an email message.
 type Email struct {
-	from        string
-	sender      string
-	replyTo     string
-	returnPath  string
-	recipients  []string
-	headers     textproto.MIMEHeader
-	parts       []part
-	attachments []*file
-	inlines     []*file
-	Charset     string
-	Encoding    encoding
-	Error       error
-	SMTPServer  *smtpClient
+	from              string
+	sender            string
+	replyTo           string
+	returnPath        string
+	recipients        []string
+	headers           textproto.MIMEHeader
+	parts             []part
+	attachments       []*file
+	inlines           []*file
+	filters           []Filter
+	correlation       string
+	correlationHeader string
+	metadata          map[string]string
+	tags              []string
+	draftID           string
+	boundary          func() string
+	clock             Clock
+	sevenBit          bool
+	autoText          bool
+	utf8Headers       bool
+	profile           Profile
+	headerOrder       []string
+	manifest          ManifestFormat
+	smime             smimeOptions
+	pgp               pgpOptions
+	preamble          string
+	epilogue          string
+	mailParams        []Param
+	rcptParams        []Param
+	dsn               dsnOptions
+	Charset           string
+	Encoding          encoding
+	Error             error
+	SMTPServer        *smtpClient
 }
 
 /*
@@ -49,13 +72,88 @@ type SMTPServer struct {
 	Port           int
 	KeepAlive      bool
 	TLSConfig      *tls.Config
+	// Filters are applied to every email sent with the client,
+	// after the filters added to the email itself
+	Filters []Filter
+	// Scanners are run over the decoded contents of every email before
+	// sending it. Quarantined emails are saved in the Quarantine store.
+	Scanners   []Scanner
+	Quarantine QuarantineStore
+	// AuditLog records every send attempt
+	AuditLog AuditLog
+	// Stats, if set, collects the delivery stats of all the clients of the
+	// server, otherwise each client has its own
+	Stats *DeliveryStats
+	// SandboxAddress, if set, redirects all emails to this address.
+	// See the Sandbox filter.
+	SandboxAddress string
+	// RecipientPolicy, if set, restricts the recipients of the emails
+	RecipientPolicy *RecipientPolicy
+	// AuthMechanisms are the authentication mechanisms in order of
+	// preference. The first one advertised by the server is used. If empty,
+	// Authentication is used.
+	AuthMechanisms []authType
+	// AllowInsecureAuth allows PLAIN and LOGIN authentication, which send the
+	// password in clear text, over connections without TLS to hosts other
+	// than localhost. It's refused by default.
+	AllowInsecureAuth bool
+	// TokenProvider, if set, returns the OAuth 2.0 access token of the
+	// XOAUTH2 and OAUTHBEARER authentications for every connection.
+	// Otherwise the Password is the token.
+	TokenProvider TokenProvider
+	// FailIfAuthRequired, with AuthNone, makes Connect check that the relay
+	// accepts mail without authentication, failing with ErrAuthRequired if not.
+	FailIfAuthRequired bool
+	// AutoReconnect reconnects and sends the email again, once, when the
+	// server replies 421 and closes the connection without suggesting a
+	// retry delay. Otherwise a TransientError is returned.
+	AutoReconnect bool
+	// MinDataRate is the minimum rate, in bytes per second, the server must
+	// read the message data at. If it's slower over SlowPeerWindow, or
+	// DefaultSlowPeerWindow if not set, the send is aborted with a
+	// SlowPeerError. Zero disables the check.
+	MinDataRate    int
+	SlowPeerWindow time.Duration
+	// UTF8Headers sends the headers as raw UTF-8 (RFC 6532) instead of
+	// encoded words when the server supports SMTPUTF8, so the recipients
+	// with internationalized addresses see clean headers.
+	UTF8Headers bool
+	// MaxRecipients, if set, splits the emails with more recipients in
+	// several transactions of at most MaxRecipients each, sending the same
+	// message data. Many providers don't accept more than 100.
+	MaxRecipients int
+	// StampOriginalTo and StampDeliveredTo send a copy of every email to
+	// each recipient, with the recipient in the X-Original-To or
+	// Delivered-To header, for gateways whose downstream sorting rules
+	// depend on them. The X-Original-To header set by the Sandbox filter
+	// is kept
+	StampOriginalTo  bool
+	StampDeliveredTo bool
+	// BATV, if set, signs the envelope sender of every email, so the
+	// bounces can be validated
+	BATV *BATV
+	// customAuth is the authentication set with SetCustomAuth
+	customAuth Auth
+	// logger is the logger set with SetLogger
+	logger recordLogger
 }
 
+// ErrAuthRequired is returned by Connect when the relay requires
+// authentication and FailIfAuthRequired is set
+var ErrAuthRequired = errors.New("Mail Error: The SMTP server requires authentication")
+
 //SMTPClient represents a SMTP Client for send email
 type SMTPClient struct {
-	Client      *smtpClient
-	KeepAlive   bool
-	SendTimeout time.Duration
+	Client          *smtpClient
+	KeepAlive       bool
+	SendTimeout     time.Duration
+	Filters         []Filter
+	Scanners        []Scanner
+	Quarantine      QuarantineStore
+	AuditLog        AuditLog
+	RecipientPolicy *RecipientPolicy
+	Stats           *DeliveryStats
+	server          *SMTPServer
 }
 
 // part represents the different content parts of an email body.
@@ -69,6 +167,19 @@ type file struct {
 	filename string
 	mimeType string
 	data     []byte
+	// reader is the source of the attachments added with
+	// AddAttachmentReader, read when the message is built
+	reader io.Reader
+	// contentType and disposition are the exact headers of the files
+	// added with AddAttachmentExact
+	contentType string
+	disposition string
+	// encoding is the name of the encoder of the file, base64 if empty
+	encoding string
+	// cid is the Content-ID of the inline files added with
+	// AddInlineImage or AddInlineWithCID, otherwise it's generated from the
+	// filename
+	cid string
 }
 
 // Encryption type to enum encryption types (None, SSL/TLS, STARTTLS)
@@ -130,8 +241,30 @@ const (
 	AuthLogin
 	// AuthCRAMMD5 implements the CRAM-MD5 authentication
 	AuthCRAMMD5
+	// AuthNone skips the authentication, for relays that don't require it
+	AuthNone
+	// AuthXOAUTH2 implements the XOAUTH2 authentication of Gmail and
+	// Office 365, with the token of the TokenProvider or the Password
+	AuthXOAUTH2
+	// AuthOAuthBearer implements the OAUTHBEARER authentication (RFC 7628)
+	AuthOAuthBearer
+	// AuthSCRAMSHA1 and AuthSCRAMSHA256 implement the SCRAM
+	// authentications (RFC 5802 and RFC 7677). AuthSCRAMSHA1 is upgraded
+	// to SCRAM-SHA-256 if the server advertises it, AuthSCRAMSHA256 is
+	// never downgraded unless AuthSCRAMSHA1 is in AuthMechanisms.
+	AuthSCRAMSHA1
+	AuthSCRAMSHA256
+	// AuthNTLM implements the NTLM authentication of Exchange servers. The
+	// Username can include the domain, as "DOMAIN\user".
+	AuthNTLM
 )
 
+var authTypes = [...]string{"PLAIN", "LOGIN", "CRAM-MD5", "NONE", "XOAUTH2", "OAUTHBEARER", "SCRAM-SHA-1", "SCRAM-SHA-256", "NTLM"}
+
+func (auth authType) String() string {
+	return authTypes[auth]
+}
+
 // NewMSG creates a new email. It uses UTF-8 by default. All charsets: http://webcheatsheet.com/HTML/character_sets_list.php
 func NewMSG() *Email {
 	email := &Email{
@@ -157,6 +290,14 @@ func NewSMTPClient() *SMTPServer {
 	return server
 }
 
+// SetCustomAuth sets the authentication mechanism of the connections,
+// instead of the built-in mechanism of Authentication, for proprietary
+// mechanisms. It's used even without Username or Password.
+func (server *SMTPServer) SetCustomAuth(mech Auth) *SMTPServer {
+	server.customAuth = mech
+	return server
+}
+
 // GetEncryptionType returns the encryption type used to connect to SMTP server
 func (server *SMTPServer) GetEncryptionType() Encryption {
 	return server.Encryption
@@ -332,7 +473,7 @@ func (email *Email) AddAddresses(header string, addresses ...string) *Email {
 		// add all addresses to the headers except for Bcc and Return-Path
 		if header != "Bcc" && header != "Return-Path" {
 			// add the address to the headers
-			email.headers.Add(header, address.String())
+			email.headers.Add(header, FormatAddress(address.Name, address.Address))
 		}
 	}
 
@@ -386,8 +527,52 @@ func (email *Email) SetPriority(priority priority) *Email {
 	return email
 }
 
+// DefaultCorrelationIDHeader is the header used by SetCorrelationID by default
+const DefaultCorrelationIDHeader = "X-Correlation-ID"
+
+// SetCorrelationID sets an id to trace the email from the user action to the
+// SMTP transaction. The id is added to the X-Correlation-ID header, or to the
+// optionally provided header, to the audit log entries of the email and to
+// the correlation_id attribute of its log records, see SetLogger. It replaces
+// the id set before, and an empty id removes it.
+func (email *Email) SetCorrelationID(id string, header ...string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	if len(header) > 1 {
+		email.Error = errors.New("Mail Error: Correlation ID can only have an id and an optional header")
+		return email
+	}
+
+	name := DefaultCorrelationIDHeader
+	if len(header) == 1 {
+		name = header[0]
+	}
+
+	if email.correlationHeader != "" {
+		email.headers.Del(email.correlationHeader)
+	}
+
+	email.correlation = id
+	email.correlationHeader = ""
+	if id != "" {
+		email.correlationHeader = name
+		email.AddHeader(name, id)
+	}
+
+	return email
+}
+
+// GetCorrelationID returns the correlation id of the email, if any
+func (email *Email) GetCorrelationID() string {
+	return email.correlation
+}
+
 // SetDate sets the date header to the provided date/time.
 // The format of the string should be YYYY-MM-DD HH:MM:SS Time Zone.
+// The time zones defined in RFC 5322 (UT, GMT, EST, EDT, CST, CDT, MST,
+// MDT, PST and PDT) are converted to their offsets.
 //
 // Example: SetDate("2015-04-28 10:32:00 CDT")
 func (email *Email) SetDate(dateTime string) *Email {
@@ -404,7 +589,50 @@ func (email *Email) SetDate(dateTime string) *Email {
 		return email
 	}
 
-	email.headers.Set("Date", dt.Format(time.RFC1123Z))
+	// unknown zone abbreviations are parsed with zero offset
+	if name, offset := dt.Zone(); offset == 0 {
+		if offset, ok := rfc5322Zones[name]; ok {
+			dt = time.Date(dt.Year(), dt.Month(), dt.Day(), dt.Hour(), dt.Minute(), dt.Second(), 0, time.FixedZone(name, offset))
+		}
+	}
+
+	return email.SetDateTime(dt)
+}
+
+// rfc5322Zones are the offsets of the obsolete zones defined in RFC 5322
+var rfc5322Zones = map[string]int{
+	"UT": 0, "GMT": 0,
+	"EST": -5 * 3600, "EDT": -4 * 3600,
+	"CST": -6 * 3600, "CDT": -5 * 3600,
+	"MST": -7 * 3600, "MDT": -6 * 3600,
+	"PST": -8 * 3600, "PDT": -7 * 3600,
+}
+
+// SetDateTime sets the date header to the provided time,
+// keeping its time zone offset.
+func (email *Email) SetDateTime(t time.Time) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.headers.Set("Date", t.Format(time.RFC1123Z))
+
+	return email
+}
+
+// Clock provides the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// SetClock sets the clock used to set the Date header when it's not provided.
+// By default the local time is used.
+func (email *Email) SetClock(clock Clock) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.clock = clock
 
 	return email
 }
@@ -420,6 +648,46 @@ func (email *Email) SetSubject(subject string) *Email {
 	return email
 }
 
+// SetBoundaryFunc sets the function used to generate the multipart boundaries
+// of the message, for example to get deterministic messages in tests or to
+// match the boundary pattern required by a legacy system. The boundaries
+// must be valid as defined in RFC 2046, otherwise building the message fails.
+// See mime.PrefixBoundary and mime.SequenceBoundary.
+func (email *Email) SetBoundaryFunc(fn func() string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.boundary = fn
+
+	return email
+}
+
+// SetPreamble sets the text written before the first part of a multipart
+// message, like "This is a multi-part message in MIME format.", shown by
+// the mail clients that don't support MIME.
+func (email *Email) SetPreamble(text string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.preamble = text
+
+	return email
+}
+
+// SetEpilogue sets the text written after the last part of a multipart
+// message.
+func (email *Email) SetEpilogue(text string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.epilogue = text
+
+	return email
+}
+
 // SetBody sets the body of the email message.
 func (email *Email) SetBody(contentType contentType, body string) *Email {
 	if email.Error != nil {
@@ -436,6 +704,67 @@ func (email *Email) SetBody(contentType contentType, body string) *Email {
 	return email
 }
 
+// ReplaceBody replaces the body of the parts with the given content type,
+// keeping the order of the parts. If the email has no part with that
+// content type the body is added as an alternative.
+func (email *Email) ReplaceBody(contentType contentType, body string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	replaced := false
+	for i, p := range email.parts {
+		if p.contentType == contentType.string() {
+			email.parts[i].body = bytes.NewBufferString(body)
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		email.AddAlternative(contentType, body)
+	}
+
+	return email
+}
+
+// RemoveAttachment removes the attachments and inline files with the given name
+func (email *Email) RemoveAttachment(name string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.attachments = removeFile(email.attachments, name)
+	email.inlines = removeFile(email.inlines, name)
+
+	return email
+}
+
+// removeFile returns files without the files with the given name
+func removeFile(files []*file, name string) []*file {
+	var kept []*file
+	for _, f := range files {
+		if f.filename != name {
+			kept = append(kept, f)
+		}
+	}
+
+	return kept
+}
+
+// ClearRecipients removes all the recipients of the email, including
+// the To, Cc and Bcc addresses.
+func (email *Email) ClearRecipients() *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.recipients = nil
+	email.headers.Del("To")
+	email.headers.Del("Cc")
+
+	return email
+}
+
 // AddHeader adds the given "header" with the passed "value".
 func (email *Email) AddHeader(header string, values ...string) *Email {
 	if email.Error != nil {
@@ -472,6 +801,12 @@ func (email *Email) AddHeader(header string, values ...string) *Email {
 			return email
 		}
 		email.SetDate(values[0])
+	case "Message-Id", "Mime-Version":
+		if len(values) > 1 {
+			email.Error = errors.New("Mail Error: Only one value allowed; Header: [" + header + "]")
+			return email
+		}
+		email.headers[header] = values
 	default:
 		email.headers[header] = values
 	}
@@ -584,6 +919,72 @@ func (email *Email) AddInlineData(data []byte, filename, mimeType string) *Email
 	return email
 }
 
+// AddInlineWithCID adds the file as an inline file with the given
+// Content-ID, to reference it in the HTML body as "cid:" + cid. The cid must
+// be a valid RFC 2392 id, like "logo@example.com", with or without the angle
+// brackets.
+func (email *Email) AddInlineWithCID(file, cid string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	cid = strings.TrimSuffix(strings.TrimPrefix(cid, "<"), ">")
+	if !validCID(cid) {
+		email.Error = errors.New("Mail Error: Invalid Content-ID; CID: [" + cid + "]")
+		return email
+	}
+
+	if email.Error = email.attach(file, true); email.Error != nil {
+		return email
+	}
+	email.inlines[len(email.inlines)-1].cid = cid
+
+	return email
+}
+
+// validCID returns true if the cid is an id-left "@" id-right (RFC 2392,
+// RFC 5322 msg-id), both ASCII dot-atoms
+func validCID(cid string) bool {
+	i := strings.Index(cid, "@")
+	if i < 0 || !isASCII(cid) {
+		return false
+	}
+
+	return isDotAtom(cid[:i]) && isDotAtom(cid[i+1:])
+}
+
+// AddInlineImage adds the image file as an inline file and returns its
+// Content-ID, to reference it in the HTML body as "cid:" + cid instead of
+// by its file name.
+func (email *Email) AddInlineImage(path string) (string, error) {
+	if email.Error != nil {
+		return "", email.Error
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		email.Error = errors.New("Mail Error: Failed to add file with following error: " + err.Error())
+		return "", email.Error
+	}
+
+	mimeType := mimeTypeByName(path)
+	if !strings.HasPrefix(mimeType, "image/") {
+		email.Error = errors.New("Mail Error: The inline file is not an image; File: [" + path + "]")
+		return "", email.Error
+	}
+
+	cid := newCID(email.headers.Get("From"))
+
+	email.inlines = append(email.inlines, &file{
+		filename: filepath.Base(path),
+		mimeType: mimeType,
+		data:     data,
+		cid:      cid,
+	})
+
+	return cid, nil
+}
+
 // attach does the low level attaching of the files
 func (email *Email) attach(f string, inline bool, name ...string) error {
 	// Get the file data
@@ -593,10 +994,7 @@ func (email *Email) attach(f string, inline bool, name ...string) error {
 	}
 
 	// get the file mime type
-	mimeType := mime.TypeByExtension(filepath.Ext(f))
-	if mimeType == "" {
-		mimeType = "application/octet-stream"
-	}
+	mimeType := mimeTypeByName(f)
 
 	// get the filename
 	_, filename := filepath.Split(f)
@@ -626,10 +1024,7 @@ func (email *Email) attach(f string, inline bool, name ...string) error {
 // attachData does the low level attaching of the in-memory data
 func (email *Email) attachData(data []byte, inline bool, filename, mimeType string) {
 	if mimeType == "" {
-		mimeType = mime.TypeByExtension(filepath.Ext(filename))
-		if mimeType == "" {
-			mimeType = "application/octet-stream"
-		}
+		mimeType = mimeTypeByName(filename)
 	}
 
 	if inline {
@@ -647,6 +1042,16 @@ func (email *Email) attachData(data []byte, inline bool, filename, mimeType stri
 	}
 }
 
+// mimeTypeByName returns the mime type of a file based on its extension
+func mimeTypeByName(filename string) string {
+	mimeType := mime.TypeByExtension(filepath.Ext(filename))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	return mimeType
+}
+
 // attachB64 does the low level attaching of the files but decoding base64 instead have a filepath
 func (email *Email) attachB64(b64File string, name string) error {
 
@@ -692,6 +1097,89 @@ func (email *Email) GetRecipients() []string {
 	return email.recipients
 }
 
+// Envelope returns the envelope sender, like GetFrom, and the recipients
+// of the email after its filters, so a Sandbox or a redirect changes the
+// recipients of the transports sending the message built by WriteTo.
+func (email *Email) Envelope() (string, []string, error) {
+	if email.Error != nil {
+		return "", nil, email.Error
+	}
+
+	filtered, err := email.applyFilters(nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return filtered.GetFrom(), filtered.recipients, nil
+}
+
+// PartInfo describes a body part of the email
+type PartInfo struct {
+	ContentType string
+	Body        string
+}
+
+// AttachmentInfo describes an attachment or inline file of the email.
+// Data is shared with the email and must not be modified.
+type AttachmentInfo struct {
+	Filename string
+	MimeType string
+	Size     int
+	Inline   bool
+	Data     []byte
+}
+
+// Parts returns the body parts of the email, in the order they were added
+func (email *Email) Parts() []PartInfo {
+	parts := make([]PartInfo, 0, len(email.parts))
+	for _, p := range email.parts {
+		parts = append(parts, PartInfo{ContentType: p.contentType, Body: p.body.String()})
+	}
+
+	return parts
+}
+
+// Attachments returns the attachments of the email followed by the inline files
+func (email *Email) Attachments() []AttachmentInfo {
+	attachments := make([]AttachmentInfo, 0, len(email.attachments)+len(email.inlines))
+	for _, f := range email.attachments {
+		attachments = append(attachments, f.info(false))
+	}
+	for _, f := range email.inlines {
+		attachments = append(attachments, f.info(true))
+	}
+
+	return attachments
+}
+
+// GetHeaders returns a copy of the email headers. Bcc and Return-Path
+// addresses are not included, see GetRecipients and GetFrom.
+func (email *Email) GetHeaders() textproto.MIMEHeader {
+	headers := make(textproto.MIMEHeader, len(email.headers))
+	for header, values := range email.headers {
+		headers[header] = append([]string(nil), values...)
+	}
+
+	return headers
+}
+
+func (f *file) info(inline bool) AttachmentInfo {
+	info := AttachmentInfo{
+		Filename: f.filename,
+		MimeType: f.mimeType,
+		Size:     len(f.data),
+		Inline:   inline,
+		Data:     f.data,
+	}
+
+	if f.reader != nil {
+		// the size of a stream is unknown
+		info.Size = -1
+	}
+
+	return info
+}
+
 func (email *Email) hasMixedPart() bool {
 	return (len(email.parts) > 0 && len(email.attachments) > 0) || len(email.attachments) > 1
 }
@@ -704,15 +1192,74 @@ func (email *Email) hasAlternativePart() bool {
 	return len(email.parts) > 1
 }
 
+// htmlPart returns the index of the first HTML part, or -1
+func (email *Email) htmlPart() int {
+	for i, part := range email.parts {
+		if part.contentType == TextHTML.string() {
+			return i
+		}
+	}
+
+	return -1
+}
+
 // GetMessage builds and returns the email message (RFC822 formatted message)
+// If a filter rejects the message, an empty string is returned and the
+// error is saved in email.Error
 func (email *Email) GetMessage() string {
+	filtered, err := email.applyFilters(nil)
+	if err != nil {
+		email.Error = err
+		return ""
+	}
+
+	msg, err := filtered.buildMessage()
+	if err != nil {
+		email.Error = err
+		return ""
+	}
+
+	return msg
+}
+
+// buildMessage builds the email message without applying filters
+func (email *Email) buildMessage() (string, error) {
+	msg, err := email.build()
+	if err != nil {
+		return "", err
+	}
+
+	// the message is in memory, reading can't fail
+	data, _ := ioutil.ReadAll(newMessageReader(msg.segments))
+	message := string(data)
+
+	if email.sevenBit {
+		if err := check7bit(message); err != nil {
+			return "", err
+		}
+	}
+
+	return message, nil
+}
+
+// build lays out the message without encoding the parts
+func (email *Email) build() (*message, error) {
+	email, err := email.withManifest().withPlainText().withUUEncodedAttachments()
+	if err != nil {
+		return nil, err
+	}
+
 	msg := newMessage(email)
 
+	// nest the related part with the HTML part inside the alternative part
+	nestRelated := email.profile.RelatedInAlternative && email.hasAlternativePart() &&
+		len(email.inlines) > 0 && email.htmlPart() >= 0
+
 	if email.hasMixedPart() {
 		msg.openMultipart("mixed")
 	}
 
-	if email.hasRelatedPart() {
+	if email.hasRelatedPart() && !nestRelated {
 		msg.openMultipart("related")
 	}
 
@@ -720,7 +1267,15 @@ func (email *Email) GetMessage() string {
 		msg.openMultipart("alternative")
 	}
 
-	for _, part := range email.parts {
+	for i, part := range email.parts {
+		if nestRelated && i == email.htmlPart() {
+			msg.openMultipart("related")
+			msg.addBody(part.contentType, part.body.Bytes())
+			msg.addFiles(email.inlines, true)
+			msg.closeMultipart()
+			continue
+		}
+
 		msg.addBody(part.contentType, part.body.Bytes())
 	}
 
@@ -728,9 +1283,11 @@ func (email *Email) GetMessage() string {
 		msg.closeMultipart()
 	}
 
-	msg.addFiles(email.inlines, true)
-	if email.hasRelatedPart() {
-		msg.closeMultipart()
+	if !nestRelated {
+		msg.addFiles(email.inlines, true)
+		if email.hasRelatedPart() {
+			msg.closeMultipart()
+		}
 	}
 
 	msg.addFiles(email.attachments, false)
@@ -738,7 +1295,19 @@ func (email *Email) GetMessage() string {
 		msg.closeMultipart()
 	}
 
-	return msg.getHeaders() + msg.body.String()
+	msg.smime(email)
+	msg.pgp(email)
+	if msg.err != nil {
+		return nil, msg.err
+	}
+
+	if err := checkSingleHeaders(msg.headers); err != nil {
+		return nil, err
+	}
+
+	msg.finish()
+
+	return msg, nil
 }
 
 // Send sends the composed email
@@ -749,21 +1318,117 @@ func (email *Email) Send(client *SMTPClient) error {
 // SendEnvelopeFrom sends the composed email with envelope
 // sender. 'from' must be an email address.
 func (email *Email) SendEnvelopeFrom(from string, client *SMTPClient) error {
+	_, err := email.sendEnvelope(from, client, sendOptions{})
+	return err
+}
+
+// sendEnvelope sends the email with the options, the tee and context of
+// the send
+func (email *Email) sendEnvelope(from string, client *SMTPClient, opts sendOptions) (*SendResult, error) {
 	if email.Error != nil {
-		return email.Error
+		return nil, email.Error
+	}
+
+	if opts.ctx != nil {
+		if err := opts.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var filters []Filter
+	if client != nil {
+		filters = client.Filters
+	}
+
+	filtered, err := email.applyFilters(filters)
+	if err != nil {
+		return nil, err
 	}
 
 	if from == "" {
-		from = email.from
+		from = filtered.from
 	}
 
-	if len(email.recipients) < 1 {
-		return errors.New("Mail Error: No recipient specified")
+	if len(filtered.recipients) < 1 {
+		return nil, errors.New("Mail Error: No recipient specified")
+	}
+
+	if client == nil {
+		return nil, errors.New("Mail Error: No SMTP Client Provided")
+	}
+
+	if client.server != nil && client.server.BATV != nil {
+		if from, err = client.server.BATV.Sign(from); err != nil {
+			return nil, err
+		}
+	}
+
+	if client.RecipientPolicy != nil {
+		err = client.RecipientPolicy.Check(filtered.recipients)
+	}
+	if err == nil {
+		err = filtered.scan(client.Scanners, client.Quarantine)
+	}
+	if err == nil && client.utf8Headers() {
+		if filtered == email {
+			filtered = email.clone()
+		}
+		filtered.utf8Headers = true
+	}
+	if err != nil {
+		if client.AuditLog != nil {
+			return nil, audit(client.AuditLog, from, filtered, nil, err)
+		}
+		return nil, err
 	}
 
-	msg := email.GetMessage()
+	if client.server != nil && (client.server.StampOriginalTo || client.server.StampDeliveredTo) {
+		return filtered.sendCopies(from, client, opts)
+	}
 
-	return send(from, email.recipients, msg, client)
+	return filtered.transmit(from, client, opts)
+}
+
+// transmit builds and sends the email, recording it in the audit log
+func (email *Email) transmit(from string, client *SMTPClient, opts sendOptions) (*SendResult, error) {
+	var result *SendResult
+
+	msg, err := email.buildMessage()
+	if err == nil {
+		// the same data sent in the DATA command
+		msg = normalizeCRLF(msg)
+		opts.mailParams, opts.rcptParams = email.mailParams, email.rcptParams
+		opts.dsn = email.dsn
+		opts.correlation = email.correlation
+		var accepted []string
+		start := time.Now()
+		if accepted, err = sendBatches(from, email.recipients, msg, client, opts); accepted != nil {
+			result = newSendResult(email, msg)
+			result.Recipients = accepted
+		}
+		if client.Stats != nil {
+			client.Stats.record(email.recipients, err, time.Since(start))
+		}
+		args := []interface{}{
+			logKeyMessageID, email.headers.Get("Message-Id"),
+			logKeyFrom, from,
+			logKeyRecipients, len(email.recipients),
+			logKeySize, len(msg),
+			logKeyDuration, time.Since(start),
+		}
+		args = append(args, correlationArgs(email.correlation)...)
+		if err != nil {
+			client.server.log(levelWarn, "smtp send failed", append(args, errorArgs(err)...)...)
+		} else {
+			client.server.log(levelInfo, "smtp message sent", args...)
+		}
+	}
+
+	if client.AuditLog != nil {
+		return result, audit(client.AuditLog, from, email, result, err)
+	}
+
+	return result, err
 }
 
 // dial connects to the smtp server with the request encryption type
@@ -771,7 +1436,7 @@ func dial(host string, port string, encryption Encryption, config *tls.Config) (
 	var conn net.Conn
 	var err error
 
-	address := host + ":" + port
+	address := net.JoinHostPort(host, port)
 
 	// do the actual dial
 	switch encryption {
@@ -782,7 +1447,7 @@ func dial(host string, port string, encryption Encryption, config *tls.Config) (
 	}
 
 	if err != nil {
-		return nil, errors.New("Mail Error on dailing with encryption type " + encryption.String() + ": " + err.Error())
+		return nil, fmt.Errorf("Mail Error on dailing with encryption type %s: %w", encryption.String(), err)
 	}
 
 	c, err := newClient(conn, host)
@@ -796,14 +1461,15 @@ func dial(host string, port string, encryption Encryption, config *tls.Config) (
 
 // smtpConnect connects to the smtp server and starts TLS and passes auth
 // if necessary
-func smtpConnect(host, port, helo string, a auth, encryption Encryption, config *tls.Config) (*smtpClient, error) {
+func smtpConnect(server *SMTPServer, host, port string, config *tls.Config) (*smtpClient, error) {
 	// connect to the mail server
-	c, err := dial(host, port, encryption, config)
+	c, err := dial(host, port, server.Encryption, config)
 
 	if err != nil {
 		return nil, err
 	}
 
+	helo := server.Helo
 	if helo == "" {
 		helo = "localhost"
 	}
@@ -815,7 +1481,7 @@ func smtpConnect(host, port, helo string, a auth, encryption Encryption, config
 	}
 
 	// start TLS if necessary
-	if encryption == EncryptionTLS {
+	if server.Encryption == EncryptionTLS {
 		if ok, _ := c.extension("STARTTLS"); ok {
 			if err = c.startTLS(config); err != nil {
 				c.close()
@@ -824,10 +1490,28 @@ func smtpConnect(host, port, helo string, a auth, encryption Encryption, config
 		}
 	}
 
+	c.minDataRate = server.MinDataRate
+	c.slowPeerWindow = server.SlowPeerWindow
+
+	if server.Authentication == AuthNone && server.customAuth == nil {
+		if server.FailIfAuthRequired {
+			if err = checkNoAuth(c); err != nil {
+				c.close()
+				return nil, err
+			}
+		}
+
+		return c, nil
+	}
+
 	// pass the authentication if necessary
-	if a != nil {
+	if server.Username != "" || server.Password != "" || server.TokenProvider != nil || server.customAuth != nil {
 		if ok, _ := c.extension("AUTH"); ok {
-			if err = c.authenticate(a); err != nil {
+			a, err := server.auth(host, c.a)
+			if err == nil {
+				err = c.authenticate(a)
+			}
+			if err != nil {
 				c.close()
 				return nil, fmt.Errorf("Mail Error on Auth: %w", err)
 			}
@@ -837,40 +1521,204 @@ func smtpConnect(host, port, helo string, a auth, encryption Encryption, config
 	return c, nil
 }
 
-//Connect returns the smtp client
-func (server *SMTPServer) Connect() (*SMTPClient, error) {
+// checkNoAuth starts and aborts a mail transaction to check the server
+// accepts mail without authentication
+func checkNoAuth(c *smtpClient) error {
+	code, _, err := c.cmd(250, "MAIL FROM:<>")
+	if code == 530 {
+		return ErrAuthRequired
+	}
+	if err != nil {
+		return fmt.Errorf("Mail Error on MAIL FROM: %w", err)
+	}
 
-	var a auth
+	if err = c.reset(); err != nil {
+		return fmt.Errorf("Mail Error on RSET: %w", err)
+	}
 
-	switch server.Authentication {
-	case AuthPlain:
-		if server.Username != "" || server.Password != "" {
-			a = plainAuthfn("", server.Username, server.Password, server.Host)
+	return nil
+}
+
+// auth returns the auth of the preferred mechanism advertised by the server
+func (server *SMTPServer) auth(host string, advertised []string) (Auth, error) {
+	if server.customAuth != nil {
+		return server.customAuth, nil
+	}
+
+	mechanism := server.Authentication
+
+	if len(server.AuthMechanisms) > 0 {
+		found := false
+		for _, m := range server.AuthMechanisms {
+			for _, name := range advertised {
+				if strings.EqualFold(name, m.String()) {
+					mechanism, found = m, true
+					break
+				}
+			}
+			if found {
+				break
+			}
 		}
-	case AuthLogin:
-		if server.Username != "" || server.Password != "" {
-			a = loginAuthfn("", server.Username, server.Password, server.Host)
+
+		if !found {
+			return nil, errors.New("none of the authentication mechanisms are supported by the server")
+		}
+	}
+
+	// SCRAM-SHA-1 is upgraded to SCRAM-SHA-256 if it's advertised, never
+	// downgraded: SCRAM-SHA-1 is only used if it's in AuthMechanisms
+	if mechanism == AuthSCRAMSHA1 || mechanism == AuthSCRAMSHA256 {
+		sha256 := false
+		for _, name := range advertised {
+			sha256 = sha256 || strings.EqualFold(name, AuthSCRAMSHA256.String())
 		}
+		switch {
+		case sha256:
+			mechanism = AuthSCRAMSHA256
+		case mechanism == AuthSCRAMSHA256:
+			return nil, errors.New("Mail Error: SCRAM-SHA-256 is not supported by the server")
+		}
+	}
+
+	switch mechanism {
+	case AuthPlain:
+		return &plainAuth{username: server.Username, password: server.Password, host: host, allowInsecure: server.AllowInsecureAuth}, nil
+	case AuthLogin:
+		return &loginAuth{username: server.Username, password: server.Password, host: host, allowInsecure: server.AllowInsecureAuth}, nil
 	case AuthCRAMMD5:
-		if server.Username != "" || server.Password != "" {
-			a = cramMD5Authfn(server.Username, server.Password)
+		return cramMD5Authfn(server.Username, server.Password), nil
+	case AuthNTLM:
+		return newNTLMAuth(server.Username, server.Password), nil
+	case AuthSCRAMSHA1, AuthSCRAMSHA256:
+		return newSCRAMAuth(mechanism, server.Username, server.Password), nil
+	case AuthXOAUTH2, AuthOAuthBearer:
+		token, err := server.oauthToken()
+		if err != nil {
+			return nil, err
+		}
+		if mechanism == AuthXOAUTH2 {
+			return &xoauth2Auth{username: server.Username, token: token, host: host, allowInsecure: server.AllowInsecureAuth}, nil
+		}
+		return &oauthBearerAuth{username: server.Username, token: token, host: host, allowInsecure: server.AllowInsecureAuth}, nil
+	}
+
+	return nil, errors.New("unknown authentication mechanism")
+}
+
+// hostPort returns the host and port to connect to. Host can be a domain,
+// an IP address, or an address literal like "[192.0.2.1]" or
+// "[IPv6:2001:db8::1]", and can include the port if Port is not set.
+func (server *SMTPServer) hostPort() (string, string, error) {
+	host := server.Host
+	port := ""
+
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, p
+		// SplitHostPort removes the brackets of an address literal
+		if strings.HasPrefix(server.Host, "[") {
+			host = "[" + host + "]"
+		}
+	}
+
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		// address literal, RFC 5321 section 4.1.3
+		literal := host[1 : len(host)-1]
+		if len(literal) > 5 && strings.EqualFold(literal[:5], "IPv6:") {
+			literal = literal[5:]
+		}
+		if net.ParseIP(literal) == nil {
+			return "", "", errors.New("Mail Error: Invalid address literal host " + server.Host)
 		}
+		host = literal
 	}
 
+	// only an IPv6 address has colons outside of an address literal
+	if host == "" || strings.ContainsAny(host, " /[]") || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+		return "", "", errors.New("Mail Error: Invalid host " + server.Host)
+	}
+
+	if server.Port != 0 {
+		if port != "" && port != strconv.Itoa(server.Port) {
+			return "", "", errors.New("Mail Error: Host port " + port + " doesn't match Port " + strconv.Itoa(server.Port))
+		}
+		port = strconv.Itoa(server.Port)
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", "", errors.New("Mail Error: Invalid port " + strconv.Quote(port))
+	}
+
+	return host, port, nil
+}
+
+//Connect returns the smtp client
+func (server *SMTPServer) Connect() (*SMTPClient, error) {
+	c, err := server.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	filters := server.Filters
+	if server.SandboxAddress != "" {
+		filters = append(filters[:len(filters):len(filters)], Sandbox(server.SandboxAddress))
+	}
+
+	stats := server.Stats
+	if stats == nil {
+		stats = NewDeliveryStats()
+	}
+
+	return &SMTPClient{
+		Client:          c,
+		KeepAlive:       server.KeepAlive,
+		SendTimeout:     server.SendTimeout,
+		Filters:         filters,
+		Scanners:        server.Scanners,
+		Quarantine:      server.Quarantine,
+		AuditLog:        server.AuditLog,
+		RecipientPolicy: server.RecipientPolicy,
+		Stats:           stats,
+		server:          server,
+	}, nil
+}
+
+// connect connects to the smtp server, logging the connection with the
+// key-value pairs of args
+func (server *SMTPServer) connect(args ...interface{}) (*smtpClient, error) {
+	start := time.Now()
+	c, err := server.dialTimeout()
+	if err != nil {
+		server.log(levelError, "smtp connect failed", append(append([]interface{}{logKeyDuration, time.Since(start)}, errorArgs(err)...), args...)...)
+		return nil, err
+	}
+
+	server.log(levelInfo, "smtp connected", append([]interface{}{logKeyEncryption, server.Encryption.String(), logKeyDuration, time.Since(start)}, args...)...)
+
+	return c, nil
+}
+
+// dialTimeout connects to the smtp server within the connect timeout
+func (server *SMTPServer) dialTimeout() (*smtpClient, error) {
+
 	var smtpConnectChannel chan error
 	var c *smtpClient
-	var err error
+
+	host, port, err := server.hostPort()
+	if err != nil {
+		return nil, err
+	}
 
 	tlsConfig := server.TLSConfig
 	if tlsConfig == nil {
-		tlsConfig = &tls.Config{ServerName: server.Host}
+		tlsConfig = &tls.Config{ServerName: host}
 	}
 
 	// if there is a ConnectTimeout, setup the channel and do the connect under a goroutine
 	if server.ConnectTimeout != 0 {
 		smtpConnectChannel = make(chan error, 2)
 		go func() {
-			c, err = smtpConnect(server.Host, fmt.Sprintf("%d", server.Port), server.Helo, a, server.Encryption, tlsConfig)
+			c, err = smtpConnect(server, host, port, tlsConfig)
 			// send the result
 			smtpConnectChannel <- err
 		}()
@@ -878,24 +1726,47 @@ func (server *SMTPServer) Connect() (*SMTPClient, error) {
 		select {
 		case err = <-smtpConnectChannel:
 			if err != nil {
-				return nil, err
+				return nil, transientError(err)
 			}
 		case <-time.After(server.ConnectTimeout):
-			return nil, errors.New("Mail Error: SMTP Connection timed out")
+			return nil, timeoutError("Mail Error: SMTP Connection timed out")
 		}
 	} else {
 		// no ConnectTimeout, just fire the connect
-		c, err = smtpConnect(server.Host, fmt.Sprintf("%d", server.Port), server.Helo, a, server.Encryption, tlsConfig)
+		c, err = smtpConnect(server, host, port, tlsConfig)
 		if err != nil {
-			return nil, err
+			return nil, transientError(err)
 		}
 	}
 
-	return &SMTPClient{
-		Client:      c,
-		KeepAlive:   server.KeepAlive,
-		SendTimeout: server.SendTimeout,
-	}, nil
+	return c, nil
+}
+
+// Reconnect closes the connection and connects again to the smtp server.
+// It can only be used with clients returned by SMTPServer.Connect.
+func (smtpClient *SMTPClient) Reconnect() error {
+	return smtpClient.reconnect()
+}
+
+// reconnect reconnects the client, logging the connection with the
+// key-value pairs of args
+func (smtpClient *SMTPClient) reconnect(args ...interface{}) error {
+	if smtpClient.server == nil {
+		return errors.New("Mail Error: SMTP client was not created with Connect")
+	}
+
+	if smtpClient.Client != nil {
+		smtpClient.Client.close()
+	}
+
+	c, err := smtpClient.server.connect(args...)
+	if err != nil {
+		return err
+	}
+
+	smtpClient.Client = c
+
+	return nil
 }
 
 // Reset send RSET command to smtp client
@@ -910,11 +1781,13 @@ func (smtpClient *SMTPClient) Noop() error {
 
 // Quit send QUIT command to smtp client
 func (smtpClient *SMTPClient) Quit() error {
+	smtpClient.server.log(levelDebug, "smtp connection closed")
 	return smtpClient.Client.quit()
 }
 
 // Close closes the connection
 func (smtpClient *SMTPClient) Close() error {
+	smtpClient.server.log(levelDebug, "smtp connection closed")
 	return smtpClient.Client.close()
 }
 
@@ -928,11 +1801,59 @@ func SendMessage(from string, recipients []string, msg string, client *SMTPClien
 		return errors.New("Mail Error: No recipient specified")
 	}
 
-	return send(from, recipients, msg, client)
+	// the data writer sends bare line feeds as CRLF, normalize them first
+	// so the size and the tee data are the same as the transmitted data
+	return send(from, recipients, normalizeCRLF(msg), client, sendOptions{})
 }
 
 // send does the low level sending of the email
-func send(from string, to []string, msg string, client *SMTPClient) error {
+func send(from string, to []string, msg string, client *SMTPClient, opts sendOptions) error {
+	if opts.ctx != nil {
+		if err := opts.ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	err := transientError(sendOnce(from, to, msg, client, opts))
+
+	// the send was canceled, don't send it again
+	if err != nil && opts.ctx != nil && opts.ctx.Err() != nil {
+		return opts.ctx.Err()
+	}
+
+	// the connection was lost before the message was accepted, so it's
+	// safe to send it again in a new connection
+	if lost, ok := err.(*connLostError); ok {
+		if client.server == nil || client.reconnect(correlationArgs(opts.correlation)...) != nil {
+			return lost.err
+		}
+
+		return unwrapConnLost(transientError(sendOnce(from, to, msg, client, opts)))
+	}
+
+	var closing *TransientError
+	if !errors.As(err, &closing) || closing.Code != 421 {
+		return err
+	}
+
+	// the server closed the connection
+	client.Client.close()
+
+	// the message can't be sent again if it was written to the tee
+	if client.server == nil || !client.server.AutoReconnect || closing.RetryAfter != 0 || opts.tee != nil {
+		return err
+	}
+
+	client.server.log(levelInfo, "smtp reconnecting", append(errorArgs(err), correlationArgs(opts.correlation)...)...)
+	if client.reconnect(correlationArgs(opts.correlation)...) != nil {
+		return err
+	}
+
+	return transientError(sendOnce(from, to, msg, client, opts))
+}
+
+// sendOnce sends the email in the current connection
+func sendOnce(from string, to []string, msg string, client *SMTPClient, opts sendOptions) error {
 	//Check if client struct is not nil
 	if client != nil {
 
@@ -945,13 +1866,13 @@ func send(from string, to []string, msg string, client *SMTPClient) error {
 				smtpSendChannel = make(chan error, 1)
 
 				go func(from string, to []string, msg string, c *smtpClient) {
-					smtpSendChannel <- sendMailProcess(from, to, msg, c)
+					smtpSendChannel <- sendMailProcess(from, to, msg, c, opts)
 				}(from, to, msg, client.Client)
 			}
 
 			if client.SendTimeout == 0 {
 				// no SendTimeout, just fire the sendMailProcess
-				return sendMailProcess(from, to, msg, client.Client)
+				return sendMailProcess(from, to, msg, client.Client, opts)
 			}
 
 			// get the send result or timeout result, which ever happens first
@@ -961,7 +1882,7 @@ func send(from string, to []string, msg string, client *SMTPClient) error {
 				return sendError
 			case <-time.After(client.SendTimeout):
 				checkKeepAlive(client)
-				return errors.New("Mail Error: SMTP Send timed out")
+				return timeoutError("Mail Error: SMTP Send timed out")
 			}
 
 		}
@@ -970,7 +1891,16 @@ func send(from string, to []string, msg string, client *SMTPClient) error {
 	return errors.New("Mail Error: No SMTP Client Provided")
 }
 
-func sendMailProcess(from string, to []string, msg string, c *smtpClient) error {
+func sendMailProcess(from string, to []string, msg string, c *smtpClient, opts sendOptions) error {
+	if opts.ctx != nil {
+		// abort the blocked reads and writes when the context is done
+		stop := c.watchContext(opts.ctx)
+		watched := opts
+		watched.ctx = nil
+		return stop(sendMailProcess(from, to, msg, c, watched))
+	}
+
+	c.messages++
 
 	cmdArgs := make(map[string]string)
 
@@ -978,26 +1908,75 @@ func sendMailProcess(from string, to []string, msg string, c *smtpClient) error
 		cmdArgs["SIZE"] = strconv.Itoa(len(msg))
 	}
 
+	// request per-recipient responses to the data
+	mailParams := opts.mailParams
+	_, prdr := c.ext["PRDR"]
+	prdr = prdr && len(to) > 1
+	if prdr && !hasParam(mailParams, "PRDR") {
+		mailParams = append(mailParams[:len(mailParams):len(mailParams)], Param{Keyword: "PRDR"})
+	}
+
+	// the server can't take internationalized addresses, send their
+	// domains in punycode
+	if _, ok := c.ext["SMTPUTF8"]; !ok {
+		var err error
+		if from, to, err = asciiEnvelope(from, to); err != nil {
+			return err
+		}
+	}
+
+	_, dsn := c.ext["DSN"]
+	if dsn {
+		mailParams = opts.dsn.mailParams(mailParams)
+	}
+
 	// Set the sender
-	if err := c.mail(from, cmdArgs); err != nil {
-		return err
+	if err := c.mailParams(from, cmdArgs, mailParams); err != nil {
+		return connectionError(err)
 	}
 
 	// Set the recipients
 	for _, address := range to {
-		if err := c.rcpt(address); err != nil {
-			return err
+		rcptParams := opts.rcptParams
+		if dsn {
+			rcptParams = opts.dsn.rcptParams(rcptParams, address)
+		}
+		if err := c.rcpt(address, rcptParams...); err != nil {
+			return connectionError(err)
 		}
 	}
 
 	// Send the data command
-	w, err := c.data()
+	var w io.WriteCloser
+	var err error
+	_, chunking := c.ext["CHUNKING"]
+	switch {
+	case prdr:
+		w, err = c.dataPRDR(to)
+	case chunking:
+		w = c.bdat()
+	default:
+		w, err = c.data()
+	}
 	if err != nil {
-		return err
+		return connectionError(err)
+	}
+
+	if opts.tee != nil {
+		w = &teeWriteCloser{Writer: io.MultiWriter(w, opts.tee), closer: w}
 	}
 
 	// write the message
-	_, err = fmt.Fprint(w, msg)
+	if c.minDataRate > 0 {
+		_, err = io.WriteString(newRateWriter(w, c.conn, c.minDataRate, c.slowPeerWindow), msg)
+		c.conn.SetWriteDeadline(time.Time{})
+		if _, ok := err.(*SlowPeerError); ok {
+			// the data can't be finished, drop the connection
+			c.close()
+		}
+	} else {
+		_, err = fmt.Fprint(w, msg)
+	}
 	if err != nil {
 		return err
 	}
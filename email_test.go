@@ -0,0 +1,232 @@
+package mail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func TestDate(t *testing.T) {
+	tests := []struct {
+		email *Email
+		want  string
+	}{
+		{NewMSG().SetDate("2015-04-28 10:32:00 CDT"), "Tue, 28 Apr 2015 10:32:00 -0500"},
+		{NewMSG().SetDate("2015-04-28 10:32:00 GMT"), "Tue, 28 Apr 2015 10:32:00 +0000"},
+		{NewMSG().SetDateTime(time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 5*3600+1800))), "Thu, 02 Jan 2020 03:04:05 +0530"},
+		{NewMSG().SetClock(fixedClock(time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC))), "Wed, 03 Feb 2021 04:05:06 +0000"},
+	}
+
+	for _, test := range tests {
+		test.email.SetFrom("from@example.com").AddTo("to@example.com").SetBody(TextPlain, "Hello")
+
+		msg := test.email.GetMessage()
+		if test.email.Error != nil {
+			t.Fatalf("unexpected error: %v", test.email.Error)
+		}
+
+		if !strings.Contains(msg, "Date: "+test.want+"\r\n") {
+			t.Errorf("Date %q not found in message:\n%s", test.want, msg)
+		}
+	}
+}
+
+func TestCoreHeaders(t *testing.T) {
+	count := func(msg, header string) int {
+		return strings.Count("\r\n"+msg, "\r\n"+header+": ")
+	}
+
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		AddHeader("Message-ID", "<custom@example.com>").
+		AddHeader("MIME-Version", "1.0").
+		AddHeader("Date", "2015-04-28 10:32:00 GMT").
+		SetBody(TextPlain, "Hello")
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+
+	for header, want := range map[string]string{
+		"Message-Id":   "<custom@example.com>",
+		"Mime-Version": "1.0",
+		"Date":         "Tue, 28 Apr 2015 10:32:00 +0000",
+	} {
+		if n := count(msg, header); n != 1 {
+			t.Errorf("got %d %s headers, want 1", n, header)
+		}
+		if !strings.Contains(msg, header+": "+want+"\r\n") {
+			t.Errorf("%s: %s not found in message:\n%s", header, want, msg)
+		}
+	}
+
+	// the mime version is added when missing
+	email = NewMSG().SetFrom("from@example.com").AddTo("to@example.com").SetBody(TextPlain, "Hello")
+	delete(email.headers, "Mime-Version")
+	if msg = email.GetMessage(); count(msg, "Mime-Version") != 1 {
+		t.Errorf("Mime-Version not found in message:\n%s", msg)
+	}
+
+	// conflicts
+	if email = NewMSG().AddHeader("Message-ID", "<a@example.com>", "<b@example.com>"); email.Error == nil {
+		t.Error("expected error for several Message-IDs")
+	}
+
+	email = NewMSG().SetFrom("from@example.com").AddTo("to@example.com").AddHeader("Message-ID", "<a@example.com>")
+	email.headers.Add("Message-Id", "<b@example.com>")
+	if email.GetMessage(); email.Error == nil {
+		t.Error("expected error for a duplicate Message-ID")
+	}
+}
+
+func TestSetCorrelationID(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		SetCorrelationID("order-1").
+		SetCorrelationID("order-2", "X-Request-ID")
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+	if strings.Contains(msg, "X-Correlation-Id:") || !strings.Contains(msg, "X-Request-Id: order-2\r\n") {
+		t.Errorf("the correlation id isn't replaced in message:\n%s", msg)
+	}
+	if id := email.GetCorrelationID(); id != "order-2" {
+		t.Errorf("got correlation id %q, want order-2", id)
+	}
+
+	// an empty id removes the header
+	msg = email.SetCorrelationID("").GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+	if strings.Contains(msg, "X-Request-Id:") || email.GetCorrelationID() != "" {
+		t.Errorf("the correlation id isn't removed from message:\n%s", msg)
+	}
+}
+
+func TestPreambleEpilogue(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte("data"), "file.txt", "").
+		SetBoundaryFunc(func() string { return "b1" }).
+		SetPreamble("This is a multi-part message in MIME format.").
+		SetEpilogue("End of message")
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	body := msg[strings.Index(msg, "\r\n\r\n")+4:]
+	if !strings.HasPrefix(body, "This is a multi-part message in MIME format.\r\n--b1\r\n") {
+		t.Errorf("preamble not found:\n%s", body)
+	}
+	if !strings.HasSuffix(body, "\r\n--b1--\r\nEnd of message\r\n") {
+		t.Errorf("epilogue not found:\n%s", body)
+	}
+	if err := checkMessage(email, msg); err != nil {
+		t.Errorf("checkMessage: %v", err)
+	}
+}
+
+func TestAddInlineImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logo.png")
+	ioutil.WriteFile(path, []byte("\x89PNG\r\n\x1a\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0600)
+
+	email := NewMSG().SetFrom("from@example.com").AddTo("to@example.com")
+	cid, err := email.AddInlineImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email.SetBody(TextHTML, `<img src="cid:`+cid+`">`)
+
+	parsed, err := ParseMessage(strings.NewReader(email.GetMessage()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := parsed.Attachments()
+	if len(attachments) != 1 || attachments[0].ContentID != cid || attachments[0].Filename != "logo.png" || !attachments[0].Inline {
+		t.Errorf("got inline %+v, want cid %s", attachments, cid)
+	}
+	if html, _ := parsed.Body("text/html"); html != `<img src="cid:`+cid+`">` {
+		t.Errorf("got html %q", html)
+	}
+
+	if _, err := NewMSG().AddInlineImage(filepath.Join(dir, "notes.txt")); err == nil {
+		t.Error("expected an error for a file that isn't an image")
+	}
+}
+
+func TestAddInlineWithCID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logo := filepath.Join(dir, "logo.png")
+	ioutil.WriteFile(logo, []byte("\x89PNG\r\n\x1a\n"), 0600)
+	banner := filepath.Join(dir, "banner.png")
+	ioutil.WriteFile(banner, []byte("\x89PNG\r\n\x1a\n"), 0600)
+
+	email := NewMSG().SetFrom("from@example.com").AddTo("to@example.com")
+	email.AddInlineWithCID(logo, "<logo@example.com>").AddInline(banner)
+	email.SetBody(TextHTML, `<img src="cid:logo@example.com"><img src="cid:banner.png">`)
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	parsed, err := ParseMessage(strings.NewReader(email.GetMessage()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := parsed.Attachments()
+	if len(attachments) != 2 {
+		t.Fatalf("got %d inlines, want 2", len(attachments))
+	}
+	if attachments[0].ContentID != "logo@example.com" {
+		t.Errorf("got cid %q, want logo@example.com", attachments[0].ContentID)
+	}
+
+	// the generated cids are random, in the domain of the sender
+	generated := regexp.MustCompile(`^[0-9a-f]{32}@example\.com$`)
+	if !generated.MatchString(attachments[1].ContentID) {
+		t.Errorf("got generated cid %q", attachments[1].ContentID)
+	}
+
+	want := `<img src="cid:logo@example.com"><img src="cid:` + attachments[1].ContentID + `">`
+	if html, _ := parsed.Body("text/html"); html != want {
+		t.Errorf("got html %q, want %q", html, want)
+	}
+
+	for _, cid := range []string{"logo", "logo@", "@example.com", "lo go@example.com", "logo@example..com", "a@b@c"} {
+		if NewMSG().AddInlineWithCID(logo, cid).Error == nil {
+			t.Errorf("expected an error for the cid %q", cid)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+)
+
+// emlTraceHeaders are the headers added on the way to the mailbox, which
+// aren't loaded by ParseEML
+var emlTraceHeaders = map[string]bool{
+	"Received":                   true,
+	"Return-Path":                true,
+	"Delivered-To":               true,
+	"X-Original-To":              true,
+	"Dkim-Signature":             true,
+	"Authentication-Results":     true,
+	"Arc-Seal":                   true,
+	"Arc-Message-Signature":      true,
+	"Arc-Authentication-Results": true,
+}
+
+// ParseEML parses a RFC 5322 message, like a saved .eml file, into an
+// Email that can be edited and sent again. The text/plain and text/html
+// bodies, the attachments and the inline files are loaded, and the
+// references of the HTML body to the inline files are kept. The headers
+// added by the delivery, like Received or DKIM-Signature, are dropped.
+func ParseEML(r io.Reader) (*Email, error) {
+	parsed, err := ParseMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	email := NewMSG()
+
+	addressParser := &mail.AddressParser{WordDecoder: &mime.WordDecoder{CharsetReader: charsetReader}}
+
+	for header, values := range parsed.Header {
+		switch {
+		case emlTraceHeaders[header] || header == "Mime-Version" || strings.HasPrefix(header, "Content-"):
+			continue
+		case header == "Date":
+			date, err := mail.ParseDate(values[0])
+			if err != nil {
+				return nil, err
+			}
+			email.SetDateTime(date)
+		case header == "Message-Id":
+			email.AddHeader(header, values[0])
+		case addressHeaders[header] || header == "Bcc":
+			for _, value := range values {
+				list, err := addressParser.ParseList(value)
+				if err != nil {
+					return nil, err
+				}
+				for _, address := range list {
+					email.AddAddresses(header, address.String())
+				}
+			}
+		default:
+			decoded := make([]string, len(values))
+			for i, value := range values {
+				decoded[i] = decodeHeader(value)
+			}
+			email.AddHeader(header, decoded...)
+		}
+	}
+
+	// the bodies in the order of the message
+	for _, p := range parsed.parts {
+		if p.isAttachment() || p.mediaType != TextPlain.string() && p.mediaType != TextHTML.string() {
+			continue
+		}
+
+		body, err := decodeCharset(p.params["charset"], p.data)
+		if err != nil {
+			return nil, err
+		}
+
+		contentType := TextPlain
+		if p.mediaType == TextHTML.string() {
+			contentType = TextHTML
+		}
+
+		if len(email.parts) == 0 {
+			email.SetBody(contentType, body)
+		} else {
+			email.AddAlternative(contentType, body)
+		}
+	}
+
+	for _, a := range parsed.Attachments() {
+		if !a.Inline {
+			email.AddAttachmentData(a.data, a.Filename, a.ContentType)
+			continue
+		}
+
+		// the HTML body refers to the inline files by their file name
+		filename := a.Filename
+		if filename == "attachment" && a.ContentID != "" {
+			filename = safeFilename(a.ContentID)
+		}
+		if a.ContentID != "" && a.ContentID != filename {
+			for i, p := range email.parts {
+				if p.contentType == TextHTML.string() {
+					body := strings.Replace(p.body.String(), "cid:"+a.ContentID, "cid:"+filename, -1)
+					email.parts[i].body = bytes.NewBufferString(body)
+				}
+			}
+		}
+		email.AddInlineData(a.data, filename, a.ContentType)
+	}
+
+	// text parts that aren't bodies, like text/calendar, are kept as
+	// attachments
+	for _, p := range parsed.parts {
+		if !p.isAttachment() && p.mediaType != TextPlain.string() && p.mediaType != TextHTML.string() {
+			email.AddAttachmentData(p.data, "attachment", p.mediaType)
+		}
+	}
+
+	if email.Error != nil {
+		return nil, email.Error
+	}
+
+	return email, nil
+}
@@ -0,0 +1,102 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEML(t *testing.T) {
+	email := NewMSG().
+		SetFrom("Jöhn Smith <from@example.com>").
+		AddTo("to@example.com", "Other <other@example.com>").
+		AddCc("cc@example.com").
+		SetSubject("Réunion").
+		AddHeader("X-Ticket", "1234").
+		SetBody(TextPlain, "Hello").
+		AddAlternative(TextHTML, `<p>Hello <img src="cid:logo.png"></p>`).
+		AddAttachmentData([]byte("%PDF-1.4\n"), "report.pdf", "application/pdf").
+		AddInlineData([]byte("\x89PNG\r\n\x1a\n"), "logo.png", "image/png")
+
+	original := email.GetMessage()
+
+	loaded, err := ParseEML(strings.NewReader("Received: from relay\r\nDKIM-Signature: v=1\r\n" + original))
+	if err != nil {
+		t.Fatalf("ParseEML: %v", err)
+	}
+
+	if from := loaded.GetFrom(); from != "from@example.com" {
+		t.Errorf("got from %q", from)
+	}
+	if rcpts := strings.Join(loaded.GetRecipients(), " "); rcpts != "to@example.com other@example.com cc@example.com" && rcpts != "cc@example.com to@example.com other@example.com" {
+		t.Errorf("got recipients %q", rcpts)
+	}
+
+	headers := loaded.GetHeaders()
+	if headers.Get("Subject") != "Réunion" || headers.Get("X-Ticket") != "1234" || decodeHeader(headers.Get("From")) != "Jöhn Smith <from@example.com>" {
+		t.Errorf("got headers %v", headers)
+	}
+	if headers.Get("Received") != "" || headers.Get("Dkim-Signature") != "" {
+		t.Errorf("got trace headers %v", headers)
+	}
+	if headers.Get("Message-Id") != email.GetHeaders().Get("Message-Id") || headers.Get("Date") == "" {
+		t.Errorf("got Message-Id %q and Date %q", headers.Get("Message-Id"), headers.Get("Date"))
+	}
+
+	parts := loaded.Parts()
+	if len(parts) != 2 || parts[0].Body != "Hello" || parts[1].ContentType != "text/html" || parts[1].Body != `<p>Hello <img src="cid:logo.png"></p>` {
+		t.Errorf("got parts %+v", parts)
+	}
+
+	attachments := loaded.Attachments()
+	if len(attachments) != 2 || attachments[0].Filename != "report.pdf" || attachments[0].MimeType != "application/pdf" ||
+		!attachments[1].Inline || attachments[1].Filename != "logo.png" || string(attachments[1].Data) != "\x89PNG\r\n\x1a\n" {
+		t.Errorf("got attachments %+v", attachments)
+	}
+
+	// the message built again has the same content
+	parsed, err := ParseMessage(strings.NewReader(loaded.GetMessage()))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if html, _ := parsed.Body("text/html"); !strings.Contains(html, `src="cid:`) || strings.Contains(html, "cid:logo.png") {
+		t.Errorf("got html %q", html)
+	}
+}
+
+func TestParseEMLContentID(t *testing.T) {
+	const eml = "From: from@example.com\r\n" +
+		"To: =?utf-8?q?J=C3=B6hn?= <to@example.com>\r\n" +
+		"Subject: Logo\r\n" +
+		"Content-Type: multipart/related; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/html; charset=iso-8859-1\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"<p>Caf=E9 <img src=3D\"cid:part1.abc@example.com\"></p>\r\n" +
+		"--b\r\n" +
+		"Content-Type: image/png\r\n" +
+		"Content-ID: <part1.abc@example.com>\r\n" +
+		"\r\n" +
+		"png\r\n" +
+		"--b--\r\n"
+
+	email, err := ParseEML(strings.NewReader(eml))
+	if err != nil {
+		t.Fatalf("ParseEML: %v", err)
+	}
+
+	if to := decodeHeader(email.GetHeaders().Get("To")); to != "Jöhn <to@example.com>" {
+		t.Errorf("got To %q", to)
+	}
+
+	parts := email.Parts()
+	if len(parts) != 1 || parts[0].Body != `<p>Café <img src="cid:part1.abc@example.com"></p>` {
+		t.Errorf("got parts %+v", parts)
+	}
+
+	attachments := email.Attachments()
+	if len(attachments) != 1 || attachments[0].Filename != "part1.abc@example.com" || !attachments[0].Inline {
+		t.Errorf("got attachments %+v", attachments)
+	}
+}
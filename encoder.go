@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/xhit/go-simple-mail/v2/mime"
+)
+
+// Encoder is a Content-Transfer-Encoding, like base64 or quoted-printable.
+// Additional encodings are added with RegisterEncoder and selected for an
+// attachment with SetAttachmentEncoding.
+type Encoder interface {
+	// Name returns the value of the Content-Transfer-Encoding header
+	Name() string
+	// NewWriter returns a writer that encodes the data of a part to w.
+	// Close must flush the last bytes.
+	NewWriter(w io.Writer, options EncoderOptions) io.WriteCloser
+}
+
+// EncoderOptions are the options of the encoded part.
+type EncoderOptions struct {
+	// LineLength is the maximum length of the encoded lines, see Profile
+	LineLength int
+	// Filename is the filename of an attachment, empty for the bodies
+	Filename string
+	// Size is the size of the data, -1 if it's unknown
+	Size int64
+}
+
+type base64Encoder struct{}
+
+func (base64Encoder) Name() string {
+	return "base64"
+}
+
+func (base64Encoder) NewWriter(w io.Writer, options EncoderOptions) io.WriteCloser {
+	return mime.NewBase64WriterWidth(w, options.LineLength)
+}
+
+type qpEncoder struct{}
+
+func (qpEncoder) Name() string {
+	return "quoted-printable"
+}
+
+func (qpEncoder) NewWriter(w io.Writer, options EncoderOptions) io.WriteCloser {
+	return mime.NewQPWriter(w)
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{
+		"base64":           base64Encoder{},
+		"quoted-printable": qpEncoder{},
+	}
+)
+
+// RegisterEncoder adds an encoder, replacing the one with the same name.
+func RegisterEncoder(encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	encoders[strings.ToLower(encoder.Name())] = encoder
+}
+
+// encoderByName returns the registered encoder with the name
+func encoderByName(name string) (Encoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	encoder, ok := encoders[strings.ToLower(name)]
+
+	return encoder, ok
+}
+
+// encoder returns the encoder of the encoding, nil if the data is not
+// encoded
+func (encoding encoding) encoder() Encoder {
+	switch encoding {
+	case EncodingBase64:
+		return base64Encoder{}
+	case EncodingQuotedPrintable:
+		return qpEncoder{}
+	}
+
+	return nil
+}
+
+// SetAttachmentEncoding sets the Content-Transfer-Encoding of the
+// attachments and inlines with the filename to the registered encoder with
+// the name, instead of base64.
+func (email *Email) SetAttachmentEncoding(filename, name string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	if _, ok := encoderByName(name); !ok {
+		email.Error = errors.New("Mail Error: Unknown Content-Transfer-Encoding: " + name)
+		return email
+	}
+
+	found := false
+	for _, f := range append(append([]*file(nil), email.attachments...), email.inlines...) {
+		if f.filename == filename {
+			f.encoding = name
+			found = true
+		}
+	}
+
+	if !found {
+		email.Error = errors.New("Mail Error: Attachment not found; File: [" + filename + "]")
+	}
+
+	return email
+}
+
+// encoder returns the encoder of the file
+func (f *file) encoder() Encoder {
+	if encoder, ok := encoderByName(f.encoding); ok {
+		return encoder
+	}
+
+	return base64Encoder{}
+}
@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+// hexEncoder is a test encoding
+type hexEncoder struct{}
+
+func (hexEncoder) Name() string {
+	return "x-hex"
+}
+
+func (hexEncoder) NewWriter(w io.Writer, options EncoderOptions) io.WriteCloser {
+	io.WriteString(w, options.Filename+"\r\n")
+	return nopCloser{hex.NewEncoder(w)}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func TestSetAttachmentEncoding(t *testing.T) {
+	RegisterEncoder(hexEncoder{})
+
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte("abc"), "data.bin", "").
+		AddAttachmentData([]byte("abc"), "other.bin", "").
+		SetAttachmentEncoding("data.bin", "X-Hex")
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	if !strings.Contains(msg, "Content-Transfer-Encoding: x-hex\r\n") || !strings.Contains(msg, "data.bin\r\n616263") {
+		t.Errorf("attachment not encoded with the registered encoder:\n%s", msg)
+	}
+	if !strings.Contains(msg, "YWJj") {
+		t.Errorf("the other attachment is not base64 encoded:\n%s", msg)
+	}
+
+	store := NewMemoryStore()
+	id, err := email.SaveDraft(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	draft, err := LoadDraft(store, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draft.attachments[0].encoder().Name() != "x-hex" {
+		t.Error("the encoding is not saved in the draft")
+	}
+
+	if email = NewMSG().AddAttachmentData([]byte("abc"), "data.bin", "").SetAttachmentEncoding("data.bin", "x-unknown"); email.Error == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+	if email = NewMSG().SetAttachmentEncoding("data.bin", "base64"); email.Error == nil {
+		t.Error("expected an error for a missing attachment")
+	}
+}
@@ -0,0 +1,148 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Param is an ESMTP parameter of the MAIL FROM or RCPT TO commands, like
+// AUTH=<> or NOTIFY=SUCCESS,FAILURE. The value is empty for parameters
+// without value.
+type Param struct {
+	Keyword string
+	Value   string
+}
+
+// String returns the parameter as it's sent to the server.
+func (p Param) String() string {
+	if p.Value == "" {
+		return p.Keyword
+	}
+
+	return p.Keyword + "=" + p.Value
+}
+
+// paramExtensions are the extensions the server must advertise to accept
+// the parameters, the other parameters require an extension with their
+// own name
+var paramExtensions = map[string]string{
+	"BODY":        "8BITMIME",
+	"ENVID":       "DSN",
+	"RET":         "DSN",
+	"NOTIFY":      "DSN",
+	"ORCPT":       "DSN",
+	"HOLDFOR":     "FUTURERELEASE",
+	"HOLDUNTIL":   "FUTURERELEASE",
+	"BY":          "DELIVERBY",
+	"MT-PRIORITY": "MT-PRIORITY",
+}
+
+// sendOptions are the options of a send besides the message and envelope
+type sendOptions struct {
+	// tee receives the transmitted message if it's not nil
+	tee        io.Writer
+	mailParams []Param
+	rcptParams []Param
+	// dsn are the DSN parameters sent if the server supports them
+	dsn dsnOptions
+	// ctx cancels the send if it's not nil
+	ctx context.Context
+	// correlation is the correlation id of the email, added to the log
+	// records of the send
+	correlation string
+}
+
+// AddMailParam adds an ESMTP parameter to the MAIL FROM command, for
+// gateways that must pass extensions through. The send fails if the server
+// doesn't advertise the extension of the parameter. The BODY, SIZE and
+// SMTPUTF8 parameters replace the ones added automatically.
+func (email *Email) AddMailParam(keyword, value string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	p, err := newParam(keyword, value)
+	if err != nil {
+		email.Error = err
+		return email
+	}
+
+	email.mailParams = append(email.mailParams, p)
+
+	return email
+}
+
+// AddRcptParam adds an ESMTP parameter to the RCPT TO command of every
+// recipient. The send fails if the server doesn't advertise the extension
+// of the parameter.
+func (email *Email) AddRcptParam(keyword, value string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	p, err := newParam(keyword, value)
+	if err != nil {
+		email.Error = err
+		return email
+	}
+
+	email.rcptParams = append(email.rcptParams, p)
+
+	return email
+}
+
+// newParam validates the syntax of an ESMTP parameter (RFC 5321 4.1.2)
+func newParam(keyword, value string) (Param, error) {
+	keyword = strings.ToUpper(keyword)
+
+	if keyword == "" || keyword[0] == '-' {
+		return Param{}, errors.New("Mail Error: Invalid ESMTP parameter keyword " + keyword)
+	}
+	for i := 0; i < len(keyword); i++ {
+		c := keyword[i]
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return Param{}, errors.New("Mail Error: Invalid ESMTP parameter keyword " + keyword)
+		}
+	}
+
+	for i := 0; i < len(value); i++ {
+		if value[i] < 33 || value[i] > 126 || value[i] == '=' {
+			return Param{}, errors.New("Mail Error: Invalid value of ESMTP parameter " + keyword)
+		}
+	}
+
+	return Param{Keyword: keyword, Value: value}, nil
+}
+
+// checkParams returns an error if the server doesn't advertise the
+// extensions of the parameters
+func (c *smtpClient) checkParams(params []Param) error {
+	for _, p := range params {
+		ext, ok := paramExtensions[p.Keyword]
+		if !ok {
+			ext = p.Keyword
+		}
+		if p.Keyword == "BODY" && p.Value == "BINARYMIME" {
+			ext = "BINARYMIME"
+		}
+
+		if _, ok := c.ext[ext]; !ok {
+			return errors.New("Mail Error: The server doesn't support the ESMTP parameter " + p.Keyword)
+		}
+	}
+
+	return nil
+}
+
+// hasParam returns true if the keyword is in params
+func hasParam(params []Param, keyword string) bool {
+	for _, p := range params {
+		if p.Keyword == keyword {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,21 @@
+package mail
+
+import (
+	"testing"
+)
+
+func TestAddParam(t *testing.T) {
+	email := NewMSG().AddMailParam("auth", "<>").AddRcptParam("NOTIFY", "SUCCESS,FAILURE")
+	if email.Error != nil {
+		t.Fatalf("got error %v", email.Error)
+	}
+	if got := email.mailParams[0].String(); got != "AUTH=<>" {
+		t.Errorf("got %s, want AUTH=<>", got)
+	}
+
+	for _, p := range []Param{{"", ""}, {"-X", ""}, {"X_Y", ""}, {"X", "a b"}, {"X", "a=b"}, {"X", "a\r\nDATA"}} {
+		if email := NewMSG().AddMailParam(p.Keyword, p.Value); email.Error == nil {
+			t.Errorf("expected error for %q", p.String())
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// Filter is implemented by message filters. Filters are applied when the
+// message is built and can inspect or modify headers, body parts and
+// recipients of the email, or reject it returning an error (see Reject).
+//
+// Filters always receive a copy of the email, so the original email is not
+// modified and can be built or sent again.
+type Filter interface {
+	Filter(email *Email) error
+}
+
+// FilterFunc is an adapter to allow the use of ordinary functions as filters.
+type FilterFunc func(email *Email) error
+
+// Filter calls f(email).
+func (f FilterFunc) Filter(email *Email) error {
+	return f(email)
+}
+
+// RejectError is the error returned when a filter rejects the message.
+type RejectError struct {
+	Reason string
+}
+
+func (e *RejectError) Error() string {
+	return "Mail Error: Message rejected by filter: " + e.Reason
+}
+
+// Reject returns an error that rejects the message with the given reason.
+// Filters should return it to stop the message from being built.
+func Reject(reason string) error {
+	return &RejectError{Reason: reason}
+}
+
+// AddFilter adds filters to the email. Filters are applied in the same order
+// they were added, each time the message is built.
+func (email *Email) AddFilter(filters ...Filter) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.filters = append(email.filters, filters...)
+
+	return email
+}
+
+// GetHeader returns the values of the given header, if any
+func (email *Email) GetHeader(header string) []string {
+	return email.headers[textproto.CanonicalMIMEHeaderKey(header)]
+}
+
+// DelHeader deletes the given header from the email. Address headers
+// can not be deleted, use RemoveRecipient instead.
+func (email *Email) DelHeader(header string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.headers.Del(header)
+
+	return email
+}
+
+// RemoveRecipient removes the address from the recipients of the email,
+// including the To and Cc headers.
+func (email *Email) RemoveRecipient(address string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	for i, a := range email.recipients {
+		if strings.EqualFold(a, address) {
+			email.recipients = append(email.recipients[:i:i], email.recipients[i+1:]...)
+			break
+		}
+	}
+
+	for _, header := range []string{"To", "Cc"} {
+		var values []string
+		for _, value := range email.headers[header] {
+			if a, err := mail.ParseAddress(value); err == nil && strings.EqualFold(a.Address, address) {
+				continue
+			}
+			values = append(values, value)
+		}
+
+		if len(values) > 0 {
+			email.headers[header] = values
+		} else {
+			email.headers.Del(header)
+		}
+	}
+
+	return email
+}
+
+// applyFilters applies the email filters and then the provided ones to
+// a copy of the email, returning the filtered copy. If there are no filters
+// the email itself is returned.
+func (email *Email) applyFilters(filters []Filter) (*Email, error) {
+	if len(email.filters) == 0 && len(filters) == 0 {
+		return email, nil
+	}
+
+	filtered := email.clone()
+
+	for _, f := range append(email.filters[:len(email.filters):len(email.filters)], filters...) {
+		if err := f.Filter(filtered); err != nil {
+			return nil, err
+		}
+
+		if filtered.Error != nil {
+			return nil, filtered.Error
+		}
+	}
+
+	return filtered, nil
+}
+
+// clone returns a copy of the email that can be modified without
+// affecting the original. File data is shared between both emails.
+func (email *Email) clone() *Email {
+	c := *email
+
+	c.headers = make(textproto.MIMEHeader, len(email.headers))
+	for header, values := range email.headers {
+		c.headers[header] = append([]string(nil), values...)
+	}
+
+	c.recipients = append([]string(nil), email.recipients...)
+
+	c.parts = make([]part, len(email.parts))
+	for i, p := range email.parts {
+		c.parts[i] = part{
+			contentType: p.contentType,
+			body:        bytes.NewBuffer(append([]byte(nil), p.body.Bytes()...)),
+		}
+	}
+
+	c.attachments = append([]*file(nil), email.attachments...)
+	c.inlines = append([]*file(nil), email.inlines...)
+	c.filters = nil
+	c.metadata = email.Metadata()
+	c.tags = email.Tags()
+
+	if email.dsn.ORCPT != nil {
+		c.dsn.ORCPT = make(map[string]string, len(email.dsn.ORCPT))
+		for address, original := range email.dsn.ORCPT {
+			c.dsn.ORCPT[address] = original
+		}
+	}
+
+	return &c
+}
+
+// OriginalToHeader is the header where the sandbox filter saves the original recipients
+const OriginalToHeader = "X-Original-To"
+
+// Sandbox returns a filter that redirects the email to the given address,
+// saving the original To and Cc recipients in the X-Original-To header, the
+// Bcc recipients are not disclosed. The other message headers are not
+// modified, only the envelope recipients.
+// Use it in non-production environments to avoid sending emails to real users.
+func Sandbox(address string) Filter {
+	return FilterFunc(func(email *Email) error {
+		a, err := mail.ParseAddress(address)
+		if err != nil {
+			return errors.New("Mail Error: Invalid sandbox address: " + err.Error())
+		}
+
+		var original []string
+		for _, header := range []string{"To", "Cc"} {
+			for _, value := range email.headers[header] {
+				list, err := mail.ParseAddressList(value)
+				if err != nil {
+					original = append(original, value)
+					continue
+				}
+				for _, a := range list {
+					original = append(original, a.Address)
+				}
+			}
+		}
+		if len(original) > 0 {
+			email.headers.Set(OriginalToHeader, strings.Join(original, ", "))
+		}
+
+		email.recipients = []string{a.Address}
+
+		return nil
+	})
+}
@@ -0,0 +1,171 @@
+package mail
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com", "other@example.org").
+		SetSubject("Test").
+		SetBody(TextPlain, "Hello")
+
+	email.AddFilter(FilterFunc(func(e *Email) error {
+		e.RemoveRecipient("other@example.org")
+		e.AddHeader("X-Filtered", "yes")
+		return nil
+	}))
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+	if !strings.Contains(msg, "X-Filtered: yes\r\n") {
+		t.Errorf("filter header not found in message:\n%s", msg)
+	}
+	if strings.Contains(msg, "other@example.org") {
+		t.Errorf("removed recipient found in message:\n%s", msg)
+	}
+
+	// the original email must not be modified by the filters
+	if got := email.GetRecipients(); len(got) != 2 {
+		t.Errorf("got recipients %v, want 2 recipients", got)
+	}
+	if got := email.GetHeader("X-Filtered"); got != nil {
+		t.Errorf("got X-Filtered header %v in original email", got)
+	}
+}
+
+func TestFilterReject(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello")
+
+	email.AddFilter(FilterFunc(func(e *Email) error {
+		return Reject("not allowed")
+	}))
+
+	if msg := email.GetMessage(); msg != "" {
+		t.Errorf("got message %q, want empty", msg)
+	}
+
+	var reject *RejectError
+	if !errors.As(email.Error, &reject) || reject.Reason != "not allowed" {
+		t.Errorf("got error %v, want RejectError", email.Error)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		AddCc("Cc <cc@example.com>").
+		AddBcc("bcc@example.com").
+		SetBody(TextPlain, "Hello")
+
+	filtered, err := email.applyFilters([]Filter{Sandbox("Test <catchall@example.net>")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := filtered.GetRecipients(); len(got) != 1 || got[0] != "catchall@example.net" {
+		t.Errorf("got recipients %v, want [catchall@example.net]", got)
+	}
+
+	// the Bcc recipients are not disclosed
+	if got, want := filtered.headers.Get(OriginalToHeader), "to@example.com, cc@example.com"; got != want {
+		t.Errorf("got %s %q, want %q", OriginalToHeader, got, want)
+	}
+
+	if got := filtered.headers.Get("To"); got != "<to@example.com>" {
+		t.Errorf("got To %q, want the original", got)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		AddBcc("bcc@example.com").
+		SetBody(TextPlain, "Hello").
+		AddFilter(Sandbox("catchall@example.net"))
+
+	from, recipients, err := email.Envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != "from@example.com" || len(recipients) != 1 || recipients[0] != "catchall@example.net" {
+		t.Errorf("got envelope %s %v, want the sandbox", from, recipients)
+	}
+
+	// the email is not modified
+	if got := email.GetRecipients(); len(got) != 2 {
+		t.Errorf("got recipients %v", got)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAlternative(TextHTML, "<p>Hello</p>").
+		AddAttachmentData([]byte("data"), "file.txt", "").
+		AddInlineData([]byte("png"), "image.png", "")
+
+	parts := email.Parts()
+	if len(parts) != 2 || parts[0].ContentType != "text/plain" || parts[1].Body != "<p>Hello</p>" {
+		t.Errorf("got parts %v", parts)
+	}
+
+	attachments := email.Attachments()
+	if len(attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2", len(attachments))
+	}
+	if a := attachments[0]; a.Filename != "file.txt" || a.MimeType != "text/plain; charset=utf-8" || a.Size != 4 || a.Inline {
+		t.Errorf("got attachment %+v", a)
+	}
+	if a := attachments[1]; a.Filename != "image.png" || a.MimeType != "image/png" || !a.Inline {
+		t.Errorf("got inline %+v", a)
+	}
+
+	headers := email.GetHeaders()
+	headers.Set("From", "other@example.com")
+	if got := email.GetHeader("From"); got[0] != "<from@example.com>" {
+		t.Errorf("GetHeaders returned the email headers, not a copy")
+	}
+}
+
+func TestRemoveAndReplace(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		AddBcc("bcc@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte("data"), "secret.txt", "").
+		AddAttachmentData([]byte("data"), "public.txt", "")
+
+	email.RemoveAttachment("secret.txt").
+		ReplaceBody(TextPlain, "Hello, the attachment was removed").
+		ReplaceBody(TextHTML, "<p>Hello</p>")
+
+	if a := email.Attachments(); len(a) != 1 || a[0].Filename != "public.txt" {
+		t.Errorf("got attachments %v, want public.txt", a)
+	}
+
+	if p := email.Parts(); len(p) != 2 || p[0].Body != "Hello, the attachment was removed" || p[1].ContentType != "text/html" {
+		t.Errorf("got parts %v", p)
+	}
+
+	email.ClearRecipients().AddTo("new@example.com")
+	if r := email.GetRecipients(); len(r) != 1 || r[0] != "new@example.com" {
+		t.Errorf("got recipients %v, want [new@example.com]", r)
+	}
+	if got := email.GetHeader("To"); len(got) != 1 {
+		t.Errorf("got To %v, want only the new recipient", got)
+	}
+}
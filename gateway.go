@@ -0,0 +1,60 @@
+package mail
+
+// DeliveredToHeader is the header stamped with the recipient of each copy
+// when StampDeliveredTo is set
+const DeliveredToHeader = "Delivered-To"
+
+// sendCopies sends a copy of the email to each recipient, stamped with the
+// recipient headers. All the copies have the same Message-ID. If the copies
+// of some recipients fail, a PartialSendError is returned, or the first
+// error if all of them fail.
+func (email *Email) sendCopies(from string, client *SMTPClient, opts sendOptions) (*SendResult, error) {
+	if email.headers.Get("Message-Id") == "" {
+		email.headers.Set("Message-Id", newMessage(email).messageID())
+	}
+
+	result := &SendResult{MessageID: email.headers.Get("Message-Id")}
+	partial := &PartialSendError{}
+	var auditErr error
+
+	for _, recipient := range email.recipients {
+		if opts.ctx != nil && opts.ctx.Err() != nil {
+			partial.Rejected = append(partial.Rejected, RecipientError{Address: recipient, Err: opts.ctx.Err()})
+			continue
+		}
+
+		c := email.clone()
+		c.recipients = []string{recipient}
+
+		// the recipients saved by the Sandbox filter are kept
+		if client.server.StampOriginalTo && c.headers.Get(OriginalToHeader) == "" {
+			c.headers.Set(OriginalToHeader, recipient)
+		}
+		if client.server.StampDeliveredTo {
+			c.headers.Set(DeliveredToHeader, recipient)
+		}
+
+		r, err := c.transmit(from, client, opts)
+		if r == nil {
+			partial.Rejected = append(partial.Rejected, RecipientError{Address: recipient, Err: err})
+			continue
+		}
+
+		partial.Accepted = append(partial.Accepted, recipient)
+		result.Recipients = append(result.Recipients, recipient)
+		result.Size += r.Size
+		if err != nil && auditErr == nil {
+			// sent, but the audit log failed
+			auditErr = err
+		}
+	}
+
+	switch {
+	case len(partial.Rejected) == 0:
+		return result, auditErr
+	case len(partial.Accepted) == 0:
+		return nil, partial.Rejected[0].Err
+	}
+
+	return result, partial
+}
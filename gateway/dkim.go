@@ -0,0 +1,207 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSignedHeaders are the headers signed by a DKIMSigner without
+// Headers
+var DefaultSignedHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-Id",
+	"In-Reply-To", "References", "MIME-Version", "Content-Type",
+	"Content-Transfer-Encoding", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// DKIMSigner signs the messages with DKIM (RFC 6376), with the relaxed
+// canonicalization of the header and the body.
+type DKIMSigner struct {
+	// Domain is the signing domain, the d= tag
+	Domain string
+	// Selector is the selector of the public key record, the s= tag
+	Selector string
+	// Key is a *rsa.PrivateKey, signing with rsa-sha256, or a
+	// ed25519.PrivateKey, signing with ed25519-sha256 (RFC 8463)
+	Key crypto.Signer
+	// Headers are the headers signed if present, DefaultSignedHeaders if
+	// not set
+	Headers []string
+}
+
+// Sign returns the message with a DKIM-Signature header prepended.
+func (s *DKIMSigner) Sign(msg []byte) ([]byte, error) {
+	var algorithm string
+	switch s.Key.(type) {
+	case *rsa.PrivateKey:
+		algorithm = "rsa-sha256"
+	case ed25519.PrivateKey:
+		algorithm = "ed25519-sha256"
+	default:
+		return nil, errors.New("gateway: unsupported DKIM key type")
+	}
+
+	msg = toCRLF(msg)
+	header, body := msg, []byte(nil)
+	if i := bytes.Index(msg, []byte("\r\n\r\n")); i >= 0 {
+		header, body = msg[:i+2], msg[i+4:]
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+	fields := headerFields(header)
+
+	names := s.Headers
+	if len(names) == 0 {
+		names = DefaultSignedHeaders
+	}
+
+	// the headers with several instances are signed from the last one
+	h := sha256.New()
+	var signed []string
+	used := make(map[string]int)
+	for _, name := range names {
+		key := strings.ToLower(name)
+		for i := len(fields) - 1 - used[key]; i >= 0; i-- {
+			if fieldName(fields[i]) != key {
+				continue
+			}
+			h.Write([]byte(relaxedHeader(fields[i]) + "\r\n"))
+			signed = append(signed, key)
+			used[key] = len(fields) - i
+			break
+		}
+	}
+
+	signature := "DKIM-Signature: v=1; a=" + algorithm + "; c=relaxed/relaxed; d=" + s.Domain +
+		"; s=" + s.Selector + ";\r\n\tt=" + strconv.FormatInt(time.Now().Unix(), 10) +
+		"; h=" + strings.Join(signed, ":") +
+		";\r\n\tbh=" + base64.StdEncoding.EncodeToString(bodyHash[:]) + ";\r\n\tb="
+	h.Write([]byte(relaxedHeader(signature)))
+
+	var b []byte
+	var err error
+	if algorithm == "rsa-sha256" {
+		b, err = s.Key.Sign(rand.Reader, h.Sum(nil), crypto.SHA256)
+	} else {
+		b, err = s.Key.Sign(rand.Reader, h.Sum(nil), crypto.Hash(0))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signature += foldBase64(base64.StdEncoding.EncodeToString(b)) + "\r\n"
+
+	return append([]byte(signature), msg...), nil
+}
+
+// foldBase64 folds a long base64 value in lines of 72 characters
+func foldBase64(s string) string {
+	var b strings.Builder
+	for len(s) > 72 {
+		b.WriteString(s[:72] + "\r\n\t")
+		s = s[72:]
+	}
+	b.WriteString(s)
+
+	return b.String()
+}
+
+// toCRLF converts the bare line feeds of the message to CRLF
+func toCRLF(msg []byte) []byte {
+	if bytes.Count(msg, []byte("\n")) == bytes.Count(msg, []byte("\r\n")) {
+		return msg
+	}
+
+	return bytes.Replace(bytes.Replace(msg, []byte("\r\n"), []byte("\n"), -1), []byte("\n"), []byte("\r\n"), -1)
+}
+
+// headerFields splits the header in fields, with their continuation lines
+func headerFields(header []byte) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+
+	for i, field := range fields {
+		fields[i] = strings.TrimSuffix(field, "\r\n")
+	}
+
+	return fields
+}
+
+func fieldName(field string) string {
+	i := strings.Index(field, ":")
+	if i < 0 {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimRight(field[:i], " \t"))
+}
+
+// relaxedHeader returns the relaxed canonicalization of a header field,
+// without the CRLF
+func relaxedHeader(field string) string {
+	i := strings.Index(field, ":")
+	if i < 0 {
+		return field
+	}
+
+	value := strings.Replace(field[i+1:], "\r\n", "", -1)
+
+	return fieldName(field) + ":" + strings.TrimSpace(compressWSP(value))
+}
+
+// relaxedBody returns the relaxed canonicalization of a body
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(compressWSP(line), " ")
+	}
+
+	// remove the empty lines at the end
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// compressWSP replaces the sequences of spaces and tabs with a space
+func compressWSP(s string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+
+	return b.String()
+}
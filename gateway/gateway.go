@@ -0,0 +1,333 @@
+// Package gateway is an internal mail relay, for the applications of a
+// cluster that can't reach the SMTP servers directly. It accepts the
+// messages with SMTP on a local port, signs them with DKIM, queues them, in
+// a spool directory to survive restarts, and relays them to a smarthost,
+// retrying the temporary failures:
+//
+//	smarthost := mail.NewSMTPClient()
+//	smarthost.Host = "smtp.example.com"
+//	smarthost.Port = 587
+//	smarthost.Username = "relay@example.com"
+//	smarthost.Password = password
+//	smarthost.Encryption = mail.EncryptionTLS
+//
+//	g := &gateway.Gateway{
+//		Addr:      ":2525",
+//		Smarthost: smarthost,
+//		Signer:    &gateway.DKIMSigner{Domain: "example.com", Selector: "mail", Key: key},
+//		SpoolDir:  "/var/spool/gateway",
+//	}
+//	err := g.ListenAndServe()
+//
+// The SMTP server has no authentication and no TLS, only the clients of
+// the Networks can relay.
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// DefaultRetryPolicy is the schedule of the relay attempts of a Gateway
+// without Retry: 5 retries, from 1 minute to 4 hours after the previous
+// attempt
+var DefaultRetryPolicy = mail.RetryPolicy{
+	MaxAttempts:  6,
+	InitialDelay: time.Minute,
+	MaxDelay:     4 * time.Hour,
+	Multiplier:   4,
+}
+
+// Message is a message accepted by the gateway, queued until it's relayed.
+// Its Data is signed if the gateway has a Signer.
+type Message = mail.QueuedMessage
+
+// ErrGatewayClosed is returned by Serve after Close.
+var ErrGatewayClosed = errors.New("gateway: closed")
+
+// Gateway accepts messages with SMTP and relays them to a smarthost.
+type Gateway struct {
+	// Addr is the address the SMTP server listens on, ":2525" if not set
+	Addr string
+	// Hostname is the name of the greeting and the Received headers, the
+	// host name if not set
+	Hostname string
+	// Smarthost is the server the messages are relayed to
+	Smarthost *mail.SMTPServer
+	// Signer, if set, signs the accepted messages with DKIM
+	Signer *DKIMSigner
+	// Networks are the networks of the clients allowed to relay, the
+	// loopback and private networks if not set
+	Networks []*net.IPNet
+	// MaxSize is the maximum size of the messages, 25 MiB if not set
+	MaxSize int64
+	// SpoolDir is the directory the queue is stored in, the queue is in
+	// memory if not set
+	SpoolDir string
+	// Workers is the number of messages relayed at the same time, and of
+	// connections to the smarthost, 4 if not set
+	Workers int
+	// Retry is the schedule of the relay attempts, the message fails after
+	// the last one. DefaultRetryPolicy if not set
+	Retry mail.RetryPolicy
+	// OnFailure is called when a message is rejected by the smarthost, or
+	// still fails after all the retries, before it's removed from the queue
+	OnFailure func(msg *Message, err error)
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	queue    *mail.Queue
+	pool     *mail.Pool
+	done     chan struct{}
+}
+
+// ListenAndServe listens on Addr and serves the connections until Close.
+func (g *Gateway) ListenAndServe() error {
+	addr := g.Addr
+	if addr == "" {
+		addr = ":2525"
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return g.Serve(l)
+}
+
+// Serve loads the queue, starts relaying it and serves the connections of
+// the listener until Close.
+func (g *Gateway) Serve(l net.Listener) error {
+	if err := g.start(l); err != nil {
+		l.Close()
+		return err
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-g.done:
+				return ErrGatewayClosed
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		if !g.track(conn) {
+			conn.Close()
+			return ErrGatewayClosed
+		}
+		go func() {
+			defer g.untrack(conn)
+			g.serveConn(conn)
+		}()
+	}
+}
+
+// start loads the queue and starts relaying it
+func (g *Gateway) start(l net.Listener) error {
+	if g.Smarthost == nil {
+		return errors.New("gateway: no smarthost")
+	}
+
+	workers := g.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.done != nil {
+		return errors.New("gateway: already serving")
+	}
+
+	pool := mail.NewPool(g.Smarthost, workers)
+	q := mail.NewQueue(pool, workers)
+	q.Retry = g.Retry
+	if q.Retry.MaxAttempts == 0 {
+		q.Retry = DefaultRetryPolicy
+	}
+	if g.SpoolDir != "" {
+		if err := os.MkdirAll(g.SpoolDir, 0700); err != nil {
+			return err
+		}
+		q.Store = &mail.DirStore{Dir: g.SpoolDir}
+	}
+	q.OnMessageResult = func(msg *Message, err error) {
+		if err != nil && g.OnFailure != nil {
+			g.OnFailure(msg, err)
+		}
+	}
+	if err := q.Start(); err != nil {
+		pool.Close()
+		return err
+	}
+
+	g.listener = l
+	g.conns = make(map[net.Conn]struct{})
+	g.queue = q
+	g.pool = pool
+	g.done = make(chan struct{})
+
+	return nil
+}
+
+// Close stops accepting messages, closes the connections and waits for the
+// messages being relayed. The queued messages are relayed by the next
+// Serve if the queue is in a spool directory.
+func (g *Gateway) Close() error {
+	g.mu.Lock()
+	if g.done == nil {
+		g.mu.Unlock()
+		return nil
+	}
+	select {
+	case <-g.done:
+		g.mu.Unlock()
+		return nil
+	default:
+	}
+
+	close(g.done)
+	err := g.listener.Close()
+	for conn := range g.conns {
+		conn.Close()
+	}
+	g.mu.Unlock()
+
+	g.queue.Close()
+	g.pool.Close()
+
+	return err
+}
+
+// Queued returns the number of messages waiting to be relayed.
+func (g *Gateway) Queued() int {
+	g.mu.Lock()
+	q := g.queue
+	g.mu.Unlock()
+
+	if q == nil {
+		return 0
+	}
+
+	return q.Len()
+}
+
+func (g *Gateway) track(conn net.Conn) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	select {
+	case <-g.done:
+		return false
+	default:
+	}
+	g.conns[conn] = struct{}{}
+
+	return true
+}
+
+func (g *Gateway) untrack(conn net.Conn) {
+	conn.Close()
+
+	g.mu.Lock()
+	delete(g.conns, conn)
+	g.mu.Unlock()
+}
+
+// accept signs and queues a message received by the SMTP server
+func (g *Gateway) accept(id, from string, to []string, data []byte) error {
+	if g.Signer != nil {
+		var err error
+		if data, err = g.Signer.Sign(data); err != nil {
+			return err
+		}
+	}
+
+	_, err := g.queue.EnqueueMessage(&Message{
+		ID:   id,
+		From: from,
+		To:   to,
+		Data: data,
+	})
+
+	return err
+}
+
+// allowed returns true if the client can relay
+func (g *Gateway) allowed(ip net.IP) bool {
+	if len(g.Networks) == 0 {
+		return ip.IsLoopback() || isPrivate(ip)
+	}
+
+	for _, network := range g.Networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// privateNetworks are the networks of RFC 1918 and RFC 4193
+var privateNetworks = []*net.IPNet{
+	{IP: net.IPv4(10, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(172, 16, 0, 0), Mask: net.CIDRMask(12, 32)},
+	{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(16, 32)},
+	{IP: net.IP{0xfc, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, Mask: net.CIDRMask(7, 128)},
+}
+
+func isPrivate(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *Gateway) hostname() string {
+	if g.Hostname != "" {
+		return g.Hostname
+	}
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+
+	return "localhost"
+}
+
+func (g *Gateway) maxSize() int64 {
+	if g.MaxSize > 0 {
+		return g.MaxSize
+	}
+
+	return 25 << 20
+}
+
+// newID returns a random id for a queued message
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
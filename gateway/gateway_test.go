@@ -0,0 +1,351 @@
+package gateway
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// smarthost is a SMTP server receiving the relayed messages. It replies to
+// RCPT with the rcptReplies first.
+type smarthost struct {
+	ln          net.Listener
+	mu          sync.Mutex
+	rcptReplies []string
+	messages    chan string
+}
+
+func newSmarthost(t *testing.T, rcptReplies ...string) *smarthost {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &smarthost{ln: ln, rcptReplies: rcptReplies, messages: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smarthost) serve(conn net.Conn) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 smarthost ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		switch strings.ToUpper(strings.Fields(line + " ")[0]) {
+		case "RCPT":
+			s.mu.Lock()
+			reply := "250 OK"
+			if len(s.rcptReplies) > 0 {
+				reply, s.rcptReplies = s.rcptReplies[0], s.rcptReplies[1:]
+			}
+			s.mu.Unlock()
+			text.PrintfLine("%s", reply)
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := ioutil.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			text.PrintfLine("250 OK")
+			s.messages <- string(data)
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (s *smarthost) server() *mail.SMTPServer {
+	server := mail.NewSMTPClient()
+	server.Authentication = mail.AuthNone
+	server.Host = s.ln.Addr().String()
+	return server
+}
+
+// startGateway serves the gateway on a local port
+func startGateway(t *testing.T, g *Gateway) *mail.SMTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go g.Serve(ln)
+
+	client := mail.NewSMTPClient()
+	client.Authentication = mail.AuthNone
+	client.Host = ln.Addr().String()
+	return client
+}
+
+func sendTestEmail(server *mail.SMTPServer) error {
+	client, err := server.Connect()
+	if err != nil {
+		return err
+	}
+
+	return mail.NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetSubject("Hello").
+		SetBody(mail.TextPlain, "Hello from the cluster").
+		Send(client)
+}
+
+func TestGateway(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	host := newSmarthost(t, "451 4.7.1 Try again later")
+	defer host.ln.Close()
+
+	g := &Gateway{
+		Hostname:  "gateway.test",
+		Smarthost: host.server(),
+		Signer:    &DKIMSigner{Domain: "example.com", Selector: "mail", Key: private},
+		SpoolDir:  dir,
+		Retry:     mail.RetryPolicy{MaxAttempts: 2, InitialDelay: 10 * time.Millisecond},
+	}
+	defer g.Close()
+
+	if err := sendTestEmail(startGateway(t, g)); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg string
+	select {
+	case msg = <-host.messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message was not relayed")
+	}
+
+	if !strings.Contains(msg, "by gateway.test with ESMTP id") {
+		t.Errorf("missing Received header in %q", msg)
+	}
+	if err := verifyDKIM(msg, public); err != nil {
+		t.Error(err)
+	}
+
+	// the message is removed from the queue after it's relayed
+	for i := 0; g.Queued() > 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := g.Queued(); n != 0 {
+		t.Errorf("got %d queued messages, want 0", n)
+	}
+}
+
+func TestGatewayFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []string
+		code    int
+	}{
+		{"permanent", []string{"550 5.1.1 No such user"}, 550},
+		{"retries", []string{"451 4.7.1 Try again later", "452 4.2.2 Mailbox full"}, 452},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host := newSmarthost(t, test.replies...)
+			defer host.ln.Close()
+
+			failures := make(chan error, 1)
+			g := &Gateway{
+				Smarthost: host.server(),
+				Retry:     mail.RetryPolicy{MaxAttempts: 2, InitialDelay: 10 * time.Millisecond},
+				OnFailure: func(msg *Message, err error) {
+					failures <- err
+				},
+			}
+			defer g.Close()
+
+			if err := sendTestEmail(startGateway(t, g)); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case err := <-failures:
+				var reply *textproto.Error
+				if !errors.As(err, &reply) || reply.Code != test.code {
+					t.Errorf("got error %v, want %d", err, test.code)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the failure was not reported")
+			}
+		})
+	}
+}
+
+func TestGatewayRejects(t *testing.T) {
+	host := newSmarthost(t)
+	defer host.ln.Close()
+
+	_, network, _ := net.ParseCIDR("192.0.2.0/24")
+	g := &Gateway{Smarthost: host.server(), Networks: []*net.IPNet{network}}
+	defer g.Close()
+
+	err := sendTestEmail(startGateway(t, g))
+	if err == nil || !strings.Contains(err.Error(), "554") {
+		t.Errorf("got error %v, want 554", err)
+	}
+
+	g = &Gateway{Smarthost: host.server(), MaxSize: 100}
+	defer g.Close()
+
+	err = sendTestEmail(startGateway(t, g))
+	if err == nil || !strings.Contains(err.Error(), "552") {
+		t.Errorf("got error %v, want 552", err)
+	}
+}
+
+func TestGatewaySpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the smarthost is down, the message stays in the spool
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down.Close()
+	smarthost := mail.NewSMTPClient()
+	smarthost.Authentication = mail.AuthNone
+	smarthost.Host = down.Addr().String()
+
+	g := &Gateway{
+		Smarthost: smarthost,
+		SpoolDir:  dir,
+		Retry:     mail.RetryPolicy{MaxAttempts: 2, InitialDelay: time.Hour},
+	}
+	if err := sendTestEmail(startGateway(t, g)); err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("got spool files %v, want the queued message", files)
+	}
+
+	// the queue survives a restart
+	host := newSmarthost(t)
+	defer host.ln.Close()
+
+	g = &Gateway{Smarthost: host.server(), SpoolDir: dir}
+	defer g.Close()
+	startGateway(t, g)
+
+	select {
+	case msg := <-host.messages:
+		if !strings.Contains(msg, "Hello from the cluster") {
+			t.Errorf("got message %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the spooled message was not relayed")
+	}
+
+	for i := 0; g.Queued() > 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("got spool files %v after the relay", files)
+	}
+}
+
+func TestRelaxedCanonicalization(t *testing.T) {
+	// RFC 6376, section 3.4.5
+	fields := headerFields([]byte("A: X\r\nB : Y\t\r\n\tZ  \r\n"))
+	if got := relaxedHeader(fields[0]) + "\r\n" + relaxedHeader(fields[1]) + "\r\n"; got != "a:X\r\nb:Y Z\r\n" {
+		t.Errorf("got header %q", got)
+	}
+
+	if got := string(relaxedBody([]byte(" C \r\nD \t E\r\n\r\n\r\n"))); got != " C\r\nD E\r\n" {
+		t.Errorf("got body %q", got)
+	}
+}
+
+// verifyDKIM checks the DKIM-Signature of the message with the public key
+func verifyDKIM(msg string, key ed25519.PublicKey) error {
+	msg = string(toCRLF([]byte(msg)))
+	i := strings.Index(msg, "\r\n\r\n")
+	fields := headerFields([]byte(msg[:i+2]))
+
+	if fieldName(fields[0]) != "dkim-signature" {
+		return errors.New("the first header is not DKIM-Signature")
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(fields[0][strings.Index(fields[0], ":")+1:], ";") {
+		if kv := strings.SplitN(tag, "=", 2); len(kv) == 2 {
+			tags[strings.TrimSpace(kv[0])] = strings.Join(strings.Fields(kv[1]), "")
+		}
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody([]byte(msg[i+4:])))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(bodyHash[:]) {
+		return errors.New("invalid body hash")
+	}
+
+	h := sha256.New()
+	used := make(map[string]int)
+	for _, name := range strings.Split(tags["h"], ":") {
+		for j := len(fields) - 1 - used[name]; j > 0; j-- {
+			if fieldName(fields[j]) == name {
+				h.Write([]byte(relaxedHeader(fields[j]) + "\r\n"))
+				used[name] = len(fields) - j
+				break
+			}
+		}
+	}
+	unsigned := regexp.MustCompile(`(;\s*b=)[^;]*$`).ReplaceAllString(fields[0], "$1")
+	h.Write([]byte(relaxedHeader(unsigned)))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, h.Sum(nil), signature) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
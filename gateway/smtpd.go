@@ -0,0 +1,233 @@
+package gateway
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxRecipients is the maximum number of recipients of a message
+	maxRecipients = 100
+	// commandTimeout is the time the server waits for a command
+	commandTimeout = 5 * time.Minute
+)
+
+// session is a SMTP connection of a client
+type session struct {
+	g    *Gateway
+	conn net.Conn
+	text *textproto.Conn
+	ip   net.IP
+	helo string
+	// from is set by MAIL, hasFrom is false before it
+	from    string
+	hasFrom bool
+	to      []string
+}
+
+// serveConn serves the SMTP commands of a connection
+func (g *Gateway) serveConn(conn net.Conn) {
+	s := &session{g: g, conn: conn, text: textproto.NewConn(conn)}
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		s.ip = addr.IP
+	}
+
+	if s.ip == nil || !g.allowed(s.ip) {
+		s.reply(554, "5.7.1 Relay access denied")
+		return
+	}
+
+	s.reply(220, g.hostname()+" ESMTP gateway ready")
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(commandTimeout))
+		line, err := s.text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		switch strings.ToUpper(verb) {
+		case "HELO", "EHLO":
+			s.hello(strings.ToUpper(verb), arg)
+		case "MAIL":
+			s.mail(arg)
+		case "RCPT":
+			s.rcpt(arg)
+		case "DATA":
+			if !s.data() {
+				return
+			}
+		case "RSET":
+			s.reset()
+			s.reply(250, "2.0.0 OK")
+		case "NOOP":
+			s.reply(250, "2.0.0 OK")
+		case "VRFY":
+			s.reply(252, "2.1.5 Cannot verify the user")
+		case "QUIT":
+			s.reply(221, "2.0.0 Bye")
+			return
+		default:
+			s.reply(502, "5.5.2 Command not implemented")
+		}
+	}
+}
+
+func (s *session) reply(code int, msg string) {
+	s.text.PrintfLine("%d %s", code, msg)
+}
+
+func (s *session) reset() {
+	s.from, s.hasFrom, s.to = "", false, nil
+}
+
+func (s *session) hello(verb, arg string) {
+	if arg == "" {
+		s.reply(501, "5.5.4 Missing domain")
+		return
+	}
+
+	s.reset()
+	s.helo = arg
+
+	if verb == "HELO" {
+		s.reply(250, s.g.hostname())
+		return
+	}
+
+	s.text.PrintfLine("250-%s", s.g.hostname())
+	s.text.PrintfLine("250-SIZE %d", s.g.maxSize())
+	s.text.PrintfLine("250-8BITMIME")
+	s.reply(250, "ENHANCEDSTATUSCODES")
+}
+
+func (s *session) mail(arg string) {
+	if s.helo == "" {
+		s.reply(503, "5.5.1 Send HELO first")
+		return
+	}
+	if s.hasFrom {
+		s.reply(503, "5.5.1 Sender already specified")
+		return
+	}
+
+	from, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		s.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+		return
+	}
+	// the smarthost client needs a sender
+	if from == "" {
+		s.reply(550, "5.1.7 The null sender is not relayed")
+		return
+	}
+
+	for _, param := range params {
+		if strings.HasPrefix(strings.ToUpper(param), "SIZE=") {
+			size, err := strconv.ParseInt(param[5:], 10, 64)
+			if err == nil && size > s.g.maxSize() {
+				s.reply(552, "5.3.4 Message size exceeds fixed maximum message size")
+				return
+			}
+		}
+	}
+
+	s.from, s.hasFrom = from, true
+	s.reply(250, "2.1.0 OK")
+}
+
+func (s *session) rcpt(arg string) {
+	if !s.hasFrom {
+		s.reply(503, "5.5.1 Send MAIL first")
+		return
+	}
+
+	to, _, ok := parsePath(arg, "TO:")
+	if !ok || to == "" {
+		s.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+		return
+	}
+	if len(s.to) >= maxRecipients {
+		s.reply(452, "4.5.3 Too many recipients")
+		return
+	}
+
+	s.to = append(s.to, to)
+	s.reply(250, "2.1.5 OK")
+}
+
+// data receives and queues the message, it returns false if the connection
+// must be closed
+func (s *session) data() bool {
+	if len(s.to) == 0 {
+		s.reply(503, "5.5.1 Send RCPT first")
+		return true
+	}
+
+	s.reply(354, "End data with <CR><LF>.<CR><LF>")
+
+	maxSize := s.g.maxSize()
+	r := s.text.DotReader()
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return false
+	}
+	defer s.reset()
+
+	if int64(len(data)) > maxSize {
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
+			return false
+		}
+		s.reply(552, "5.3.4 Message size exceeds fixed maximum message size")
+		return true
+	}
+
+	id, err := newID()
+	if err == nil {
+		err = s.g.accept(id, s.from, s.to, append([]byte(s.received(id)), toCRLF(data)...))
+	}
+	if err != nil {
+		s.reply(451, "4.3.0 Failed to queue the message")
+		return true
+	}
+
+	s.reply(250, "2.0.0 OK queued as "+id)
+	return true
+}
+
+// received returns the Received header of the message
+func (s *session) received(id string) string {
+	var b bytes.Buffer
+	b.WriteString("Received: from " + s.helo + " ([" + s.ip.String() + "])\r\n")
+	b.WriteString("\tby " + s.g.hostname() + " with ESMTP id " + id + ";\r\n")
+	b.WriteString("\t" + time.Now().Format(time.RFC1123Z) + "\r\n")
+
+	return b.String()
+}
+
+// parsePath parses the "FROM:<address> params" argument of MAIL, or the
+// "TO:<address> params" argument of RCPT
+func parsePath(arg, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+
+	arg = strings.TrimSpace(arg[len(prefix):])
+	end := strings.IndexByte(arg, '>')
+	if !strings.HasPrefix(arg, "<") || end < 0 {
+		return "", nil, false
+	}
+
+	return arg[1:end], strings.Fields(arg[end+1:]), true
+}
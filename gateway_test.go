@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestStampRecipients(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 3)
+	go fakeSMTP(ln, messages, map[string]string{"RCPT": "550 5.1.1 No such user"})
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	server.KeepAlive = true
+	server.StampOriginalTo = true
+	server.StampDeliveredTo = true
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("one@example.com", "two@example.com", "three@example.com").
+		SetBody(TextPlain, "Hello")
+
+	result, err := email.SendWithResult(client)
+
+	var partial *PartialSendError
+	if !errors.As(err, &partial) || len(partial.Rejected) != 1 || partial.Rejected[0].Address != "one@example.com" {
+		t.Fatalf("got error %v, want the first recipient rejected", err)
+	}
+	if len(result.Recipients) != 2 {
+		t.Errorf("got recipients %v, want 2", result.Recipients)
+	}
+
+	var stamped []string
+	for i := 0; i < 2; i++ {
+		msg := <-messages
+		if !strings.Contains(msg, "Message-Id: "+result.MessageID+"\n") {
+			t.Errorf("Message-Id %s not found in copy:\n%s", result.MessageID, msg)
+		}
+		for _, line := range strings.Split(msg, "\n") {
+			if strings.HasPrefix(line, "Delivered-To: ") || strings.HasPrefix(line, "X-Original-To: ") {
+				stamped = append(stamped, line)
+			}
+		}
+	}
+
+	sort.Strings(stamped)
+	want := "Delivered-To: three@example.com,Delivered-To: two@example.com,X-Original-To: three@example.com,X-Original-To: two@example.com"
+	if got := strings.Join(stamped, ","); got != want {
+		t.Errorf("got stamped headers %s, want %s", got, want)
+	}
+
+	if len(email.headers[DeliveredToHeader]) != 0 {
+		t.Error("the original email was stamped")
+	}
+}
+
+func TestStampSandbox(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 1)
+	go fakeSMTP(ln, messages)
+
+	server := newPoolServer(ln)
+	server.StampOriginalTo = true
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	err = NewMSG().
+		SetFrom("from@example.com").
+		AddTo("one@example.com").
+		AddBcc("bcc@example.com").
+		SetBody(TextPlain, "Hello").
+		AddFilter(Sandbox("sandbox@example.com")).
+		Send(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the recipients saved by the sandbox are not replaced by its address
+	if msg := <-messages; !strings.Contains(msg, "X-Original-To: one@example.com\n") {
+		t.Errorf("got message, want the original recipient:\n%s", msg)
+	}
+}
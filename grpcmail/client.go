@@ -0,0 +1,183 @@
+package grpcmail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// DefaultChunkSize is the size of the chunks of the raw message
+const DefaultChunkSize = 64 * 1024
+
+// maxResponseSize limits the size of the SubmitResponse read by the client
+const maxResponseSize = 64 * 1024
+
+var _ mail.Sender = (*Client)(nil)
+
+// Client submits the emails to a gateway.
+type Client struct {
+	// URL is the base URL of the gateway, like "https://mail-gateway:8443"
+	URL string
+	// Header is sent as metadata of every call, for the authentication
+	Header http.Header
+	// ChunkSize is the size of the chunks of the raw message,
+	// DefaultChunkSize if not set
+	ChunkSize int
+	// HTTPClient must support HTTP/2, the default client does with TLS
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client of the gateway at the URL.
+func NewClient(url string) *Client {
+	return &Client{
+		URL:    url,
+		Header: make(http.Header),
+	}
+}
+
+// Send submits the email to the gateway, with its sender and recipients,
+// including Bcc, in the envelope. The message is streamed while it's built.
+func (c *Client) Send(ctx context.Context, email *mail.Email) error {
+	_, err := c.Submit(ctx, email)
+	return err
+}
+
+// Submit is like Send, and returns the id given to the message by the
+// gateway.
+func (c *Client) Submit(ctx context.Context, email *mail.Email) (string, error) {
+	if email.Error != nil {
+		return "", email.Error
+	}
+
+	from, recipients, err := email.Envelope()
+	if err != nil {
+		return "", err
+	}
+	env := &Envelope{MailFrom: from, RcptTo: recipients}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(c.writeRequests(pw, env, email))
+	}()
+	defer pr.Close()
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.URL, "/")+SubmitPath, pr)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+
+	for name, values := range c.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Te", "trailers")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", errors.New("grpcmail: " + resp.Status + ": " + strings.TrimSpace(string(data)))
+	}
+
+	// a failed call can reply only with the trailers, in the headers
+	if err := status(resp.Header); err != nil {
+		return "", err
+	}
+
+	msg, err := readFrame(resp.Body, maxResponseSize)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	// the trailers are set at the end of the body
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if err := status(resp.Trailer); err != nil {
+		return "", err
+	}
+	if resp.Trailer.Get("Grpc-Status") == "" {
+		return "", errors.New("grpcmail: missing grpc-status, the server must support HTTP/2")
+	}
+
+	var id string
+	err = fields(msg, func(field int, data []byte) {
+		if field == fieldResponseID {
+			id = string(data)
+		}
+	})
+
+	return id, err
+}
+
+// writeRequests writes the envelope and the chunks of the message
+func (c *Client) writeRequests(w io.Writer, env *Envelope, email *mail.Email) error {
+	if err := writeFrame(w, appendBytes(nil, fieldRequestEnvelope, marshalEnvelope(env))); err != nil {
+		return err
+	}
+
+	size := c.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+
+	chunks := bufio.NewWriterSize(chunkWriter{w, size}, size)
+	if _, err := email.WriteTo(chunks); err != nil {
+		return err
+	}
+
+	return chunks.Flush()
+}
+
+// chunkWriter writes the data in chunk requests of at most size bytes, it's
+// buffered to not send smaller chunks
+type chunkWriter struct {
+	w    io.Writer
+	size int
+}
+
+func (cw chunkWriter) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		chunk := p[n:]
+		if len(chunk) > cw.size {
+			chunk = chunk[:cw.size]
+		}
+		if err := writeFrame(cw.w, appendBytes(nil, fieldRequestChunk, chunk)); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+
+	return n, nil
+}
+
+// status returns the error of the grpc-status of the trailers, if any
+func status(trailer http.Header) error {
+	value := trailer.Get("Grpc-Status")
+	if value == "" || value == "0" {
+		return nil
+	}
+
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		code = int(CodeUnknown)
+	}
+
+	return &StatusError{Code: Code(code), Message: decodeStatusMessage(trailer.Get("Grpc-Message"))}
+}
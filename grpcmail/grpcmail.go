@@ -0,0 +1,76 @@
+// Package grpcmail submits emails built with Go Simple Mail to a mail
+// gateway with gRPC, so the services of a cluster can send through a single
+// SMTP egress. The service is published in mail.proto: the client streams
+// the envelope, then the raw message in chunks.
+//
+// The client and the server implement the gRPC protocol over the HTTP/2 of
+// net/http, without dependencies, and interoperate with the stubs generated
+// from mail.proto:
+//
+//	client := grpcmail.NewClient("https://mail-gateway:8443")
+//	client.Header.Set("Authorization", "Bearer "+token)
+//	err := client.Send(ctx, email)
+//
+// The gateway serves the Server over TLS, or over unencrypted HTTP/2:
+//
+//	server := &grpcmail.Server{Handler: func(ctx context.Context, env *grpcmail.Envelope, msg io.Reader) (string, error) {
+//		return queue.Enqueue(env.MailFrom, env.RcptTo, msg)
+//	}}
+//	err := http.ListenAndServeTLS(":8443", "cert.pem", "key.pem", server)
+package grpcmail
+
+import (
+	"strconv"
+)
+
+// SubmitPath is the path of the Submit method of the MailGateway service
+const SubmitPath = "/gosimplemail.v1.MailGateway/Submit"
+
+// Envelope is the SMTP envelope of a message.
+type Envelope struct {
+	// MailFrom is the envelope sender, the return path
+	MailFrom string
+	// RcptTo are the envelope recipients, including Bcc
+	RcptTo []string
+}
+
+// Code is a gRPC status code.
+type Code int
+
+// The gRPC status codes used by the client and the server
+const (
+	CodeOK                Code = 0
+	CodeCanceled          Code = 1
+	CodeUnknown           Code = 2
+	CodeInvalidArgument   Code = 3
+	CodeDeadlineExceeded  Code = 4
+	CodePermissionDenied  Code = 7
+	CodeResourceExhausted Code = 8
+	CodeAborted           Code = 10
+	CodeUnimplemented     Code = 12
+	CodeInternal          Code = 13
+	CodeUnavailable       Code = 14
+	CodeUnauthenticated   Code = 16
+)
+
+// StatusError is a gRPC status other than OK. The Handler of a Server can
+// return it to reply with a specific code, the other errors are replied
+// with CodeUnknown.
+type StatusError struct {
+	Code    Code
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return "grpcmail: status " + strconv.Itoa(int(e.Code)) + ": " + e.Message
+}
+
+// Temporary returns true if the email can be sent again later.
+func (e *StatusError) Temporary() bool {
+	switch e.Code {
+	case CodeDeadlineExceeded, CodeResourceExhausted, CodeAborted, CodeUnavailable:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package grpcmail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func newTestServer(h http.Handler) (*httptest.Server, *Client) {
+	ts := httptest.NewUnstartedServer(h)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+
+	client := NewClient(ts.URL)
+	client.HTTPClient = ts.Client()
+
+	return ts, client
+}
+
+func newTestEmail() *mail.Email {
+	return mail.NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		AddBcc("bcc@example.com").
+		SetSubject("Hello").
+		SetBody(mail.TextPlain, strings.Repeat("Hello gRPC\n", 100))
+}
+
+func TestSubmit(t *testing.T) {
+	var env *Envelope
+	var message []byte
+	server := &Server{Handler: func(ctx context.Context, e *Envelope, msg io.Reader) (string, error) {
+		env = e
+		var err error
+		message, err = ioutil.ReadAll(msg)
+		return "queue-1", err
+	}}
+
+	var authorization string
+	ts, client := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		server.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	client.Header.Set("Authorization", "Bearer token")
+	client.ChunkSize = 100
+
+	id, err := client.Submit(context.Background(), newTestEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != "queue-1" {
+		t.Errorf("got id %q, want queue-1", id)
+	}
+	if authorization != "Bearer token" {
+		t.Errorf("got authorization %q", authorization)
+	}
+	if env.MailFrom != "from@example.com" || strings.Join(env.RcptTo, ",") != "to@example.com,bcc@example.com" {
+		t.Errorf("got envelope %+v", env)
+	}
+
+	parsed, err := mail.ParseMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := parsed.Body("text/plain"); parsed.Header.Get("Subject") != "Hello" || body != strings.Repeat("Hello gRPC\r\n", 100) {
+		t.Errorf("got message %q", message)
+	}
+	// the filters of the email change the envelope
+	if _, err := client.Submit(context.Background(), newTestEmail().AddFilter(mail.Sandbox("sandbox@example.com"))); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(env.RcptTo, ",") != "sandbox@example.com" {
+		t.Errorf("got envelope %+v, want the sandbox", env)
+	}
+}
+
+func TestSubmitErrors(t *testing.T) {
+	ts, client := newTestServer(&Server{
+		MaxSize: 500,
+		Handler: func(ctx context.Context, env *Envelope, msg io.Reader) (string, error) {
+			if env.RcptTo[0] == "blocked@example.com" {
+				return "", &StatusError{Code: CodePermissionDenied, Message: "recipient blocked: 100% sure"}
+			}
+			if _, err := ioutil.ReadAll(msg); err != nil {
+				return "", err
+			}
+			return "", errors.New("queue full")
+		},
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		email   *mail.Email
+		code    Code
+		message string
+	}{
+		{"status", mail.NewMSG().SetFrom("from@example.com").AddTo("blocked@example.com").SetBody(mail.TextPlain, "Hello"), CodePermissionDenied, "recipient blocked: 100% sure"},
+		{"size", newTestEmail(), CodeResourceExhausted, "message larger than 500 bytes"},
+		{"error", mail.NewMSG().SetFrom("from@example.com").AddTo("to@example.com").SetBody(mail.TextPlain, "Hello"), CodeUnknown, "queue full"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := client.Send(context.Background(), test.email)
+
+			status, ok := err.(*StatusError)
+			if !ok {
+				t.Fatalf("got error %v, want a StatusError", err)
+			}
+			if status.Code != test.code || status.Message != test.message {
+				t.Errorf("got status %d %q, want %d %q", status.Code, status.Message, test.code, test.message)
+			}
+		})
+	}
+}
+
+func TestEnvelopeWire(t *testing.T) {
+	env := &Envelope{MailFrom: "from@example.com", RcptTo: []string{"a@example.com", "b@example.com"}}
+
+	got, err := unmarshalEnvelope(marshalEnvelope(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MailFrom != env.MailFrom || strings.Join(got.RcptTo, ",") != strings.Join(env.RcptTo, ",") {
+		t.Errorf("got %+v, want %+v", got, env)
+	}
+
+	// the unknown fields are skipped
+	b := appendVarint(nil, 5<<3|wireVarint)
+	b = appendVarint(b, 300)
+	b = append(b, marshalEnvelope(env)...)
+	if got, err = unmarshalEnvelope(b); err != nil || got.MailFrom != env.MailFrom {
+		t.Errorf("got %+v, %v", got, err)
+	}
+
+	if _, err = unmarshalEnvelope([]byte{1<<3 | wireBytes, 10, 'a'}); err != errMalformed {
+		t.Errorf("got error %v, want %v", err, errMalformed)
+	}
+}
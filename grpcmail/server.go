@@ -0,0 +1,177 @@
+package grpcmail
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxRequestSize limits the size of each request of the stream, the chunks
+// are smaller
+const maxRequestSize = 4 * 1024 * 1024
+
+// Handler receives the messages submitted to a Server, and returns the id
+// given to the message. The message is read from the stream while the
+// handler reads it.
+type Handler func(ctx context.Context, env *Envelope, msg io.Reader) (string, error)
+
+// Server is the http.Handler of the MailGateway service. It must be served
+// with HTTP/2. The metadata of the calls, like the authentication, are the
+// headers of the requests, it can be wrapped to check them.
+type Server struct {
+	Handler Handler
+	// MaxSize is the maximum size of the messages, 0 means no limit
+	MaxSize int64
+}
+
+// ServeHTTP serves a call of the service.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc+proto")
+
+	if r.URL.Path != SubmitPath {
+		writeStatus(w, &StatusError{Code: CodeUnimplemented, Message: "unknown method " + r.URL.Path})
+		return
+	}
+
+	id, err := s.submit(r)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	writeFrame(w, appendBytes(nil, fieldResponseID, []byte(id)))
+	w.Header().Set("Grpc-Status", "0")
+}
+
+// submit reads the envelope and gives the message to the handler
+func (s *Server) submit(r *http.Request) (string, error) {
+	msg, err := readFrame(r.Body, maxRequestSize)
+	if err == io.EOF {
+		err = errMalformed
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var env *Envelope
+	err = fields(msg, func(field int, data []byte) {
+		if field == fieldRequestEnvelope && env == nil {
+			env, err = unmarshalEnvelope(data)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if env == nil {
+		return "", &StatusError{Code: CodeInvalidArgument, Message: "the first request must have the envelope"}
+	}
+	if len(env.RcptTo) == 0 {
+		return "", &StatusError{Code: CodeInvalidArgument, Message: "the envelope has no recipients"}
+	}
+
+	chunks := &chunkReader{r: r.Body, maxSize: s.MaxSize}
+	id, err := s.Handler(r.Context(), env, chunks)
+	if err != nil {
+		return "", err
+	}
+
+	// the handler may not read the whole message
+	if _, err = io.Copy(ioutil.Discard, chunks); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// chunkReader reads the message from the chunk requests
+type chunkReader struct {
+	r       io.Reader
+	maxSize int64
+	size    int64
+	chunk   []byte
+	err     error
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for len(cr.chunk) == 0 && cr.err == nil {
+		cr.chunk, cr.err = cr.next()
+	}
+
+	if len(cr.chunk) == 0 {
+		return 0, cr.err
+	}
+
+	n := copy(p, cr.chunk)
+	cr.chunk = cr.chunk[n:]
+
+	return n, nil
+}
+
+// next reads the next chunk
+func (cr *chunkReader) next() ([]byte, error) {
+	msg, err := readFrame(cr.r, maxRequestSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunk []byte
+	var envelope bool
+	err = fields(msg, func(field int, data []byte) {
+		switch field {
+		case fieldRequestChunk:
+			chunk = append(chunk, data...)
+		case fieldRequestEnvelope:
+			envelope = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if envelope {
+		return nil, &StatusError{Code: CodeInvalidArgument, Message: "the envelope must be sent once"}
+	}
+
+	cr.size += int64(len(chunk))
+	if cr.maxSize > 0 && cr.size > cr.maxSize {
+		return nil, &StatusError{Code: CodeResourceExhausted, Message: "message larger than " + strconv.FormatInt(cr.maxSize, 10) + " bytes"}
+	}
+
+	return chunk, nil
+}
+
+// writeStatus replies with the status of the error only, in the headers
+func writeStatus(w http.ResponseWriter, err error) {
+	var status *StatusError
+	if !errors.As(err, &status) {
+		code := CodeUnknown
+		if errors.Is(err, context.Canceled) {
+			code = CodeCanceled
+		} else if err == errMalformed {
+			code = CodeInvalidArgument
+		}
+		status = &StatusError{Code: code, Message: err.Error()}
+	}
+
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(status.Code)))
+	w.Header().Set("Grpc-Message", encodeStatusMessage(status.Message))
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,189 @@
+package grpcmail
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The field numbers of mail.proto
+const (
+	fieldEnvelopeMailFrom = 1
+	fieldEnvelopeRcptTo   = 2
+
+	fieldRequestEnvelope = 1
+	fieldRequestChunk    = 2
+
+	fieldResponseID = 1
+)
+
+// The protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errMalformed = errors.New("grpcmail: malformed protobuf message")
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+
+	return append(b, byte(v))
+}
+
+// appendBytes appends a length-delimited field, used for the strings, the
+// bytes and the embedded messages
+func appendBytes(b []byte, field int, data []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+
+	return append(b, data...)
+}
+
+// fields calls fn with the number and the data of every length-delimited
+// field of the message, the other fields are skipped
+func fields(b []byte, fn func(field int, data []byte)) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformed
+		}
+		b = b[n:]
+
+		var size uint64
+		switch key & 7 {
+		case wireVarint:
+			if _, n = binary.Uvarint(b); n <= 0 {
+				return errMalformed
+			}
+			size = uint64(n)
+		case wireFixed64:
+			size = 8
+		case wireFixed32:
+			size = 4
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformed
+			}
+			b = b[n:]
+			size = length
+		default:
+			return errMalformed
+		}
+
+		if size > uint64(len(b)) {
+			return errMalformed
+		}
+		if key&7 == wireBytes {
+			fn(int(key>>3), b[:size])
+		}
+		b = b[size:]
+	}
+
+	return nil
+}
+
+func marshalEnvelope(env *Envelope) []byte {
+	var b []byte
+	if env.MailFrom != "" {
+		b = appendBytes(b, fieldEnvelopeMailFrom, []byte(env.MailFrom))
+	}
+	for _, rcpt := range env.RcptTo {
+		b = appendBytes(b, fieldEnvelopeRcptTo, []byte(rcpt))
+	}
+
+	return b
+}
+
+func unmarshalEnvelope(b []byte) (*Envelope, error) {
+	env := &Envelope{}
+	err := fields(b, func(field int, data []byte) {
+		switch field {
+		case fieldEnvelopeMailFrom:
+			env.MailFrom = string(data)
+		case fieldEnvelopeRcptTo:
+			env.RcptTo = append(env.RcptTo, string(data))
+		}
+	})
+
+	return env, err
+}
+
+// The gRPC messages are framed with a compressed flag and their length
+const frameHeaderSize = 5
+
+func writeFrame(w io.Writer, msg []byte) error {
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
+
+// readFrame reads a message of at most maxSize bytes, it returns io.EOF at
+// the end of the stream
+func readFrame(r io.Reader, maxSize int) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errMalformed
+		}
+		return nil, err
+	}
+
+	if header[0] != 0 {
+		return nil, &StatusError{Code: CodeUnimplemented, Message: "compressed messages are not supported"}
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if uint64(size) > uint64(maxSize) {
+		return nil, &StatusError{Code: CodeResourceExhausted, Message: "message larger than " + strconv.Itoa(maxSize) + " bytes"}
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, errMalformed
+	}
+
+	return msg, nil
+}
+
+// encodeStatusMessage percent-encodes the grpc-message trailer
+func encodeStatusMessage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c > '~' || c == '%' {
+			b.WriteString("%" + strings.ToUpper(strconv.FormatInt(int64(c)|0x100, 16)[1:]))
+		} else {
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// decodeStatusMessage decodes the grpc-message trailer, the invalid
+// escapes are kept
+func decodeStatusMessage(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(c))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+
+	return string(b)
+}
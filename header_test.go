@@ -3,7 +3,7 @@ package mail
 import (
 	//"fmt"
 	"bytes"
-	//"strings"
+	"strings"
 	"testing"
 )
 
@@ -117,3 +117,44 @@ func testWriter(t *testing.T, binary bool) {
 		}
 	}
 }
+
+func TestHeaderOrder(t *testing.T) {
+	names := func(msg string) []string {
+		var names []string
+		for _, line := range strings.Split(msg[:strings.Index(msg, "\r\n\r\n")], "\r\n") {
+			if i := strings.Index(line, ":"); i > 0 && line[0] != ' ' {
+				names = append(names, line[:i])
+			}
+		}
+		return names
+	}
+
+	newEmail := func() *Email {
+		return NewMSG().
+			SetFrom("from@example.com").
+			AddTo("to@example.com").
+			SetSubject("Hello").
+			AddHeader("X-Custom", "1").
+			AddHeader("Message-ID", "<id@example.com>").
+			AddHeader("Date", "2015-04-28 10:32:00 GMT").
+			AddHeader("Received", "from localhost").
+			SetBody(TextPlain, "Hello")
+	}
+
+	want := "Received Date From To Message-Id Subject Mime-Version Content-Type Content-Transfer-Encoding X-Custom"
+	for i := 0; i < 5; i++ {
+		if got := strings.Join(names(newEmail().GetMessage()), " "); got != want {
+			t.Fatalf("got headers %s, want %s", got, want)
+		}
+	}
+
+	// the trace headers are always first
+	want = "Received Subject X-Custom Content-Transfer-Encoding Content-Type Date From Message-Id Mime-Version To"
+	if got := strings.Join(names(newEmail().SetHeaderOrder("subject", "x-custom").GetMessage()), " "); got != want {
+		t.Errorf("got headers %s, want %s", got, want)
+	}
+	want = "Received Subject Content-Transfer-Encoding Content-Type Date From Message-Id Mime-Version To X-Custom"
+	if got := strings.Join(names(newEmail().SetHeaderOrder("subject", "received").GetMessage()), " "); got != want {
+		t.Errorf("got headers %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package mail
+
+import (
+	"net/textproto"
+	"sort"
+)
+
+// DefaultHeaderOrder is the conventional order the message headers are
+// written in. The headers not listed are written after them, sorted by name.
+var DefaultHeaderOrder = []string{
+	"Return-Path",
+	"Received",
+	"Date",
+	"From",
+	"Sender",
+	"Reply-To",
+	"To",
+	"Cc",
+	"Message-Id",
+	"In-Reply-To",
+	"References",
+	"Subject",
+	"Mime-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+}
+
+// SetHeaderOrder sets the order the message headers are written in,
+// instead of DefaultHeaderOrder. The headers not listed are written after
+// them, sorted by name. The trace headers, Return-Path and Received, are
+// always written first, as RFC 5321 requires.
+func (email *Email) SetHeaderOrder(headers ...string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.headerOrder = make([]string, len(headers))
+	for i, header := range headers {
+		email.headerOrder[i] = textproto.CanonicalMIMEHeaderKey(header)
+	}
+
+	return email
+}
+
+// traceHeaders are the headers written first whatever the order, the
+// servers prepend them to the message
+var traceHeaders = []string{"Return-Path", "Received"}
+
+// orderHeaders returns the names of the trace headers and of the headers in
+// the given order, followed by the other headers sorted by name
+func orderHeaders(headers textproto.MIMEHeader, order []string) []string {
+	if order == nil {
+		order = DefaultHeaderOrder
+	}
+
+	names := make([]string, 0, len(headers))
+	listed := make(map[string]bool, len(traceHeaders)+len(order))
+	for _, header := range append(traceHeaders[:len(traceHeaders):len(traceHeaders)], order...) {
+		if _, ok := headers[header]; ok && !listed[header] {
+			names = append(names, header)
+		}
+		listed[header] = true
+	}
+
+	var rest []string
+	for header := range headers {
+		if !listed[header] {
+			rest = append(rest, header)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(names, rest...)
+}
@@ -0,0 +1,135 @@
+package mail
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlSkipped matches the elements whose content isn't text
+	htmlSkipped = regexp.MustCompile(`(?is)<!--.*?-->|<(script|style|head|title)\b.*?</(script|style|head|title)\s*>`)
+	htmlTag     = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*)>`)
+	htmlHref    = regexp.MustCompile(`(?is)\bhref\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	htmlAlt     = regexp.MustCompile(`(?is)\balt\s*=\s*("[^"]*"|'[^']*')`)
+	htmlSpaces  = regexp.MustCompile(`[ \t\r\n\f]+`)
+	textLines   = regexp.MustCompile(` *\n *`)
+	textBlank   = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlBlocks are the elements that break the lines, with the number of
+// line breaks
+var htmlBlocks = map[string]string{
+	"br": "\n", "tr": "\n", "dt": "\n", "dd": "\n",
+	"p": "\n\n", "div": "\n", "h1": "\n\n", "h2": "\n\n", "h3": "\n\n", "h4": "\n\n",
+	"h5": "\n\n", "h6": "\n\n", "ul": "\n", "ol": "\n", "table": "\n", "blockquote": "\n\n",
+	"hr": "\n\n", "pre": "\n",
+}
+
+// htmlToText converts HTML to plain text, keeping the line breaks of the
+// block elements and the targets of the links
+func htmlToText(s string) string {
+	s = htmlSkipped.ReplaceAllString(s, "")
+
+	var b strings.Builder
+	var href string
+	linkStart := 0
+
+	last := 0
+	for _, m := range htmlTag.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(htmlSpaces.ReplaceAllString(html.UnescapeString(s[last:m[0]]), " "))
+		last = m[1]
+
+		closing := m[3] > m[2]
+		name := strings.ToLower(s[m[4]:m[5]])
+
+		switch {
+		case name == "a" && !closing:
+			href, linkStart = "", b.Len()
+			if h := htmlHref.FindStringSubmatch(s[m[6]:m[7]]); h != nil {
+				href = html.UnescapeString(strings.Trim(h[1], `"'`))
+			}
+		case name == "a" && closing:
+			text := strings.TrimSpace(b.String()[linkStart:])
+			target := strings.TrimPrefix(href, "mailto:")
+			if href != "" && !strings.HasPrefix(href, "#") && target != text {
+				b.WriteString(" (" + target + ")")
+			}
+			href = ""
+		case name == "li" && !closing:
+			b.WriteString("\n* ")
+		case name == "img" && !closing:
+			// keep the alternative text of the images
+			if alt := htmlAlt.FindStringSubmatch(s[m[6]:m[7]]); alt != nil {
+				b.WriteString(html.UnescapeString(strings.Trim(alt[1], `"'`)))
+			}
+		default:
+			if breaks, ok := htmlBlocks[name]; ok {
+				b.WriteString(breaks)
+			}
+		}
+	}
+	b.WriteString(htmlSpaces.ReplaceAllString(html.UnescapeString(s[last:]), " "))
+
+	text := strings.Replace(b.String(), "\u00a0", " ", -1)
+	text = textLines.ReplaceAllString(text, "\n")
+	text = textBlank.ReplaceAllString(text, "\n\n")
+
+	return strings.TrimSpace(text)
+}
+
+// Text returns the text of the message, the plain text body or, if the
+// message doesn't have one, the HTML body converted to text.
+func (parsed *ParsedMessage) Text() (string, error) {
+	text, err := parsed.Body("text/plain")
+	if err != nil || strings.TrimSpace(text) != "" {
+		return text, err
+	}
+
+	body, err := parsed.Body("text/html")
+	if err != nil {
+		return "", err
+	}
+
+	return htmlToText(body), nil
+}
+
+// AutoPlainText enables the generation of the text/plain part from the
+// HTML body when the email has no plain text body, with the links kept as
+// "text (url)", so the message is a multipart/alternative readable by the
+// text clients.
+func (email *Email) AutoPlainText(enabled bool) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.autoText = enabled
+
+	return email
+}
+
+// withPlainText returns a copy of the email with the text/plain part
+// generated from the HTML part, if enabled and there isn't one
+func (email *Email) withPlainText() *Email {
+	html := email.htmlPart()
+	if !email.autoText || html < 0 {
+		return email
+	}
+
+	for _, p := range email.parts {
+		if p.contentType == TextPlain.string() {
+			return email
+		}
+	}
+
+	text := part{
+		contentType: TextPlain.string(),
+		body:        bytes.NewBufferString(htmlToText(email.parts[html].body.String())),
+	}
+
+	c := *email
+	c.parts = append([]part{text}, email.parts...)
+
+	return &c
+}
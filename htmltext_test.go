@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"<p>Hello&nbsp;<b>world</b></p><p>Bye</p>", "Hello world\n\nBye"},
+		{"<head><title>T</title><style>p {}</style></head><body>A<br>B<!-- hidden --></body>", "A\nB"},
+		{`See <a href="https://example.com/x?a=1&amp;b=2">the docs</a>.`, "See the docs (https://example.com/x?a=1&b=2)."},
+		{`<a href="https://example.com">https://example.com</a>`, "https://example.com"},
+		{`<a href="mailto:me@example.com">me@example.com</a> <a href="#top">top</a>`, "me@example.com top"},
+		{"<ul><li>One</li>\n  <li>Two</li></ul>", "* One\n* Two"},
+		{`<img src="cid:logo" alt="Logo"> <script>alert(1)</script>Text`, "Logo Text"},
+	}
+
+	for _, test := range tests {
+		if got := htmlToText(test.html); got != test.want {
+			t.Errorf("htmlToText(%q) = %q, want %q", test.html, got, test.want)
+		}
+	}
+}
+
+func TestParsedText(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextHTML, "<p>Hello <b>there</b></p>")
+
+	parsed, err := ParseMessage(strings.NewReader(email.GetMessage()))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if text, err := parsed.Text(); err != nil || text != "Hello there" {
+		t.Errorf("got text %q, %v from the HTML body", text, err)
+	}
+
+	email.AddAlternative(TextPlain, "Plain hello")
+	if parsed, err = ParseMessage(strings.NewReader(email.GetMessage())); err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if text, err := parsed.Text(); err != nil || text != "Plain hello" {
+		t.Errorf("got text %q, %v, want the plain text body", text, err)
+	}
+}
+
+func TestAutoPlainText(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextHTML, `<p>Read the <a href="https://example.com/report">report</a></p>`).
+		AutoPlainText(true)
+
+	msg := email.GetMessage()
+	if err := checkMessage(email, msg); err != nil {
+		t.Errorf("checkMessage: %v", err)
+	}
+
+	parsed, err := ParseMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(parsed.Header.Get("Content-Type"), "multipart/alternative;") {
+		t.Errorf("got Content-Type %q", parsed.Header.Get("Content-Type"))
+	}
+	if text, _ := parsed.Body("text/plain"); text != "Read the report (https://example.com/report)" {
+		t.Errorf("got text %q", text)
+	}
+
+	// the email isn't modified
+	if parts := email.Parts(); len(parts) != 1 {
+		t.Errorf("got parts %+v", parts)
+	}
+
+	// a plain text body is kept
+	email.AddAlternative(TextPlain, "See the report")
+	parsed, _ = ParseMessage(strings.NewReader(email.GetMessage()))
+	if text, _ := parsed.Body("text/plain"); text != "See the report" || len(parsed.parts) != 2 {
+		t.Errorf("got text %q", text)
+	}
+}
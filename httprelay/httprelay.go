@@ -0,0 +1,181 @@
+// Package httprelay sends emails built with Go Simple Mail to an HTTP mail
+// gateway. The raw message is posted to the endpoint with the envelope in
+// headers, and the request is signed with HMAC-SHA256 so the gateway can
+// check it with Verify:
+//
+//	relay := httprelay.New("https://mail-gateway.internal/send", secret)
+//	relay.Header.Set("Authorization", "Bearer "+token)
+//	err := relay.Send(ctx, email)
+package httprelay
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// The headers of the requests
+const (
+	// MailFromHeader is the envelope sender
+	MailFromHeader = "X-Mail-From"
+	// RcptToHeader are the envelope recipients, separated by commas
+	RcptToHeader = "X-Rcpt-To"
+	// SignatureHeader is the signature, "t=<unix time>,v1=<hex HMAC>"
+	SignatureHeader = "X-Signature"
+)
+
+var _ mail.Sender = (*Relay)(nil)
+
+// Relay posts the emails to an HTTP endpoint.
+type Relay struct {
+	URL string
+	// Secret is the key of the HMAC-SHA256 signature, the requests aren't
+	// signed if it's empty
+	Secret []byte
+	// Header is added to every request, for the authentication
+	Header     http.Header
+	HTTPClient *http.Client
+}
+
+// StatusError is returned when the endpoint doesn't reply with a 2xx
+// status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return "httprelay: " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode) + ": " + e.Body
+}
+
+// Temporary returns true if the email can be sent again later, when the
+// endpoint is unavailable or limits the rate.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// New returns a relay to the URL signing the requests with the secret.
+func New(url string, secret []byte) *Relay {
+	return &Relay{
+		URL:        url,
+		Secret:     secret,
+		Header:     make(http.Header),
+		HTTPClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Send posts the email as message/rfc822, with its sender and recipients,
+// including Bcc, in the envelope headers.
+func (r *Relay) Send(ctx context.Context, email *mail.Email) error {
+	if email.Error != nil {
+		return email.Error
+	}
+
+	from, recipients, err := email.Envelope()
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if _, err := email.WriteTo(&body); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.URL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	for name, values := range r.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "message/rfc822")
+
+	mailFrom, rcptTo := from, strings.Join(recipients, ",")
+	req.Header.Set(MailFromHeader, mailFrom)
+	req.Header.Set(RcptToHeader, rcptTo)
+
+	if len(r.Secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		signature := sign(r.Secret, timestamp, mailFrom, rcptTo, body.Bytes())
+		req.Header.Set(SignatureHeader, "t="+timestamp+",v1="+signature)
+	}
+
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+
+	return nil
+}
+
+// sign returns the hex HMAC-SHA256 of the timestamp, the envelope and the
+// message, separated by line feeds
+func sign(secret []byte, timestamp, mailFrom, rcptTo string, message []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "\n" + mailFrom + "\n" + rcptTo + "\n"))
+	mac.Write(message)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrInvalidSignature is returned by Verify when the signature is missing,
+// invalid or too old.
+var ErrInvalidSignature = errors.New("httprelay: invalid signature")
+
+// Verify checks the signature of a request received by the gateway, made
+// less than maxAge ago, and returns the message.
+func Verify(req *http.Request, secret []byte, maxAge time.Duration) ([]byte, error) {
+	var timestamp, signature string
+	for _, field := range strings.Split(req.Header.Get(SignatureHeader), ",") {
+		switch {
+		case strings.HasPrefix(field, "t="):
+			timestamp = field[2:]
+		case strings.HasPrefix(field, "v1="):
+			signature = field[3:]
+		}
+	}
+
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return nil, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(t, 0)); age > maxAge || age < -maxAge {
+		return nil, ErrInvalidSignature
+	}
+
+	message, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	want := sign(secret, timestamp, req.Header.Get(MailFromHeader), req.Header.Get(RcptToHeader), message)
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return nil, ErrInvalidSignature
+	}
+
+	return message, nil
+}
@@ -0,0 +1,78 @@
+package httprelay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func TestSend(t *testing.T) {
+	secret := []byte("secret")
+
+	var got *http.Request
+	var message []byte
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		message, verifyErr = Verify(r, secret, time.Minute)
+		if verifyErr != nil {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	email := mail.NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		AddBcc("bcc@example.com").
+		SetSubject("Hello").
+		SetBody(mail.TextPlain, "Hello")
+
+	relay := New(server.URL, secret)
+	relay.Header.Set("Authorization", "Bearer token")
+	if err := relay.Send(context.Background(), email); err != nil {
+		t.Fatal(err)
+	}
+
+	if verifyErr != nil {
+		t.Errorf("Verify: %v", verifyErr)
+	}
+	if got.Header.Get("Authorization") != "Bearer token" || got.Header.Get("Content-Type") != "message/rfc822" ||
+		got.Header.Get(MailFromHeader) != "from@example.com" || got.Header.Get(RcptToHeader) != "to@example.com,bcc@example.com" {
+		t.Errorf("got headers %v", got.Header)
+	}
+	if !strings.Contains(string(message), "Subject: Hello\r\n") || strings.Contains(string(message), "bcc@example.com") {
+		t.Errorf("got message %q", message)
+	}
+
+	// a wrong secret is refused
+	relay.Secret = []byte("wrong")
+	err := relay.Send(context.Background(), email)
+	if e, ok := err.(*StatusError); !ok || e.StatusCode != http.StatusForbidden || e.Temporary() || verifyErr != ErrInvalidSignature {
+		t.Errorf("got error %v, verify error %v", err, verifyErr)
+	}
+	// the filters of the email change the envelope
+	relay.Secret = secret
+	email.AddFilter(mail.Sandbox("sandbox@example.com"))
+	if err := relay.Send(context.Background(), email); err != nil {
+		t.Fatal(err)
+	}
+	if got := got.Header.Get(RcptToHeader); got != "sandbox@example.com" {
+		t.Errorf("got %s %q, want the sandbox", RcptToHeader, got)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("message"))
+	timestamp := "1000000000"
+	req.Header.Set(SignatureHeader, "t="+timestamp+",v1="+sign([]byte("secret"), timestamp, "", "", []byte("message")))
+
+	if _, err := Verify(req, []byte("secret"), time.Minute); err != ErrInvalidSignature {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,440 @@
+// Package jmap sends emails built with Go Simple Mail with JMAP (RFC 8620
+// and RFC 8621), for providers like Fastmail. The email is created in the
+// Drafts mailbox, with its attachments uploaded as blobs, and submitted
+// with EmailSubmission, which moves it to the Sent mailbox:
+//
+//	client := jmap.NewClient("https://api.fastmail.com/jmap/session", token)
+//	err := client.Send(ctx, email)
+//
+// The message is built like for SMTP, with the filters of the email, and
+// converted to a JMAP Email object. Signed or encrypted messages can't be
+// converted.
+package jmap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+const (
+	capabilityCore       = "urn:ietf:params:jmap:core"
+	capabilityMail       = "urn:ietf:params:jmap:mail"
+	capabilitySubmission = "urn:ietf:params:jmap:submission"
+)
+
+var _ mail.Sender = (*Client)(nil)
+
+// Client is a JMAP client sending emails.
+type Client struct {
+	// SessionURL is the URL of the JMAP session resource
+	SessionURL string
+	// Token is the bearer token of the account
+	Token      string
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	session *session
+}
+
+// session is the part of the JMAP session resource used by the client
+type session struct {
+	APIURL          string            `json:"apiUrl"`
+	UploadURL       string            `json:"uploadUrl"`
+	PrimaryAccounts map[string]string `json:"primaryAccounts"`
+}
+
+// NewClient returns a client for the JMAP session URL, authenticated with
+// the bearer token.
+func NewClient(sessionURL, token string) *Client {
+	return &Client{
+		SessionURL: sessionURL,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Send sends the email from the identity of its From address, to all its
+// recipients, including Bcc.
+func (c *Client) Send(ctx context.Context, email *mail.Email) error {
+	if email.Error != nil {
+		return email.Error
+	}
+
+	s, err := c.getSession(ctx)
+	if err != nil {
+		return err
+	}
+	accountID := s.PrimaryAccounts[capabilityMail]
+	if accountID == "" {
+		return errors.New("jmap: the account has no mail capability")
+	}
+
+	from, recipients, err := email.Envelope()
+	if err != nil {
+		return err
+	}
+
+	r, err := email.NewReader()
+	if err != nil {
+		return err
+	}
+	parsed, err := mail.ParseMessage(r)
+	if err != nil {
+		return err
+	}
+
+	identityID, drafts, sent, err := c.mailboxes(ctx, s, accountID, from)
+	if err != nil {
+		return err
+	}
+
+	obj, err := newEmailObject(parsed)
+	if err != nil {
+		return err
+	}
+	obj["mailboxIds"] = map[string]bool{drafts: true}
+	obj["keywords"] = map[string]bool{"$draft": true, "$seen": true}
+
+	// the attachments are uploaded first
+	var attachments []map[string]interface{}
+	for _, a := range parsed.Attachments() {
+		blobID, err := c.upload(ctx, s, accountID, a.ContentType, a.Open())
+		if err != nil {
+			return err
+		}
+		attachment := map[string]interface{}{
+			"blobId": blobID,
+			"type":   a.ContentType,
+			"name":   a.Filename,
+		}
+		if a.Inline {
+			attachment["disposition"] = "inline"
+			attachment["cid"] = a.ContentID
+		}
+		attachments = append(attachments, attachment)
+	}
+	if len(attachments) > 0 {
+		obj["attachments"] = attachments
+	}
+
+	var rcptTo []map[string]string
+	for _, address := range recipients {
+		rcptTo = append(rcptTo, map[string]string{"email": address})
+	}
+
+	update := map[string]interface{}{"keywords/$draft": nil}
+	if sent != "" {
+		update["mailboxIds/"+drafts] = nil
+		update["mailboxIds/"+sent] = true
+	}
+
+	responses, err := c.call(ctx, s, []interface{}{
+		[]interface{}{"Email/set", map[string]interface{}{
+			"accountId": accountID,
+			"create":    map[string]interface{}{"draft": obj},
+		}, "0"},
+		[]interface{}{"EmailSubmission/set", map[string]interface{}{
+			"accountId": accountID,
+			"create": map[string]interface{}{"submission": map[string]interface{}{
+				"identityId": identityID,
+				"emailId":    "#draft",
+				"envelope": map[string]interface{}{
+					"mailFrom": map[string]string{"email": from},
+					"rcptTo":   rcptTo,
+				},
+			}},
+			"onSuccessUpdateEmail": map[string]interface{}{"#submission": update},
+		}, "1"},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, resp := range responses {
+		var result struct {
+			NotCreated map[string]setError `json:"notCreated"`
+		}
+		if err = json.Unmarshal(resp.Args, &result); err != nil {
+			return err
+		}
+		for _, e := range result.NotCreated {
+			return errors.New("jmap: " + resp.Name + " failed: " + e.Error())
+		}
+	}
+
+	return nil
+}
+
+// setError is an error of a /set method
+type setError struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+func (e setError) Error() string {
+	if e.Description != "" {
+		return e.Type + ": " + e.Description
+	}
+	return e.Type
+}
+
+// newEmailObject converts the headers and bodies of the message to a JMAP
+// Email object
+func newEmailObject(parsed *mail.ParsedMessage) (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+
+	for header := range parsed.Header {
+		switch header {
+		case "From", "To", "Cc", "Reply-To", "Sender":
+			list, err := netmail.ParseAddressList(parsed.Header.Get(header))
+			if err != nil {
+				return nil, errors.New("jmap: invalid " + header + " header: " + err.Error())
+			}
+			var addresses []map[string]string
+			for _, a := range list {
+				addresses = append(addresses, map[string]string{"name": a.Name, "email": a.Address})
+			}
+			name := strings.ToLower(header[:1]) + strings.Replace(header[1:], "-", "", -1)
+			obj[name] = addresses
+		case "Subject":
+			obj["subject"] = parsed.DecodedHeader(header)
+		case "Date":
+			if date, err := netmail.ParseDate(parsed.Header.Get(header)); err == nil {
+				obj["sentAt"] = date.Format(time.RFC3339)
+			}
+		case "Message-Id", "In-Reply-To", "References":
+			name := map[string]string{"Message-Id": "messageId", "In-Reply-To": "inReplyTo", "References": "references"}[header]
+			obj[name] = messageIDs(parsed.Header.Get(header))
+		case "Mime-Version", "Bcc":
+		default:
+			if !strings.HasPrefix(header, "Content-") {
+				obj["header:"+header+":asText"] = parsed.DecodedHeader(header)
+			}
+		}
+	}
+
+	bodyValues := make(map[string]interface{})
+	for _, body := range []struct{ partID, mediaType, property string }{
+		{"text", "text/plain", "textBody"},
+		{"html", "text/html", "htmlBody"},
+	} {
+		value, err := parsed.Body(body.mediaType)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			continue
+		}
+		bodyValues[body.partID] = map[string]string{"value": value}
+		obj[body.property] = []map[string]string{{"partId": body.partID, "type": body.mediaType}}
+	}
+	obj["bodyValues"] = bodyValues
+
+	return obj, nil
+}
+
+// messageIDs returns the ids, without angle brackets, of a header
+func messageIDs(value string) []string {
+	var ids []string
+	for _, id := range strings.Fields(value) {
+		ids = append(ids, strings.Trim(id, "<>"))
+	}
+
+	return ids
+}
+
+// mailboxes returns the identity of the address and the ids of the Drafts
+// and Sent mailboxes. The Sent mailbox is optional.
+func (c *Client) mailboxes(ctx context.Context, s *session, accountID, from string) (identityID, drafts, sent string, err error) {
+	responses, err := c.call(ctx, s, []interface{}{
+		[]interface{}{"Identity/get", map[string]interface{}{"accountId": accountID}, "0"},
+		[]interface{}{"Mailbox/get", map[string]interface{}{"accountId": accountID, "properties": []string{"id", "role"}}, "1"},
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var identities struct {
+		List []struct {
+			ID    string `json:"id"`
+			Email string `json:"email"`
+		} `json:"list"`
+	}
+	var mailboxes struct {
+		List []struct {
+			ID   string `json:"id"`
+			Role string `json:"role"`
+		} `json:"list"`
+	}
+	if err = json.Unmarshal(responses[0].Args, &identities); err != nil {
+		return "", "", "", err
+	}
+	if err = json.Unmarshal(responses[1].Args, &mailboxes); err != nil {
+		return "", "", "", err
+	}
+
+	domain := from[strings.LastIndex(from, "@")+1:]
+	for _, identity := range identities.List {
+		if strings.EqualFold(identity.Email, from) || identity.Email == "*@"+domain && identityID == "" {
+			identityID = identity.ID
+		}
+	}
+	if identityID == "" {
+		return "", "", "", errors.New("jmap: no identity for the address " + from)
+	}
+
+	for _, mailbox := range mailboxes.List {
+		switch mailbox.Role {
+		case "drafts":
+			drafts = mailbox.ID
+		case "sent":
+			sent = mailbox.ID
+		}
+	}
+	if drafts == "" {
+		return "", "", "", errors.New("jmap: the account has no Drafts mailbox")
+	}
+
+	return identityID, drafts, sent, nil
+}
+
+// getSession returns the session resource, fetched on the first call
+func (c *Client) getSession(ctx context.Context) (*session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session != nil {
+		return c.session, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.SessionURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var s session
+	if err = c.do(ctx, req, &s); err != nil {
+		return nil, err
+	}
+	if s.APIURL == "" {
+		return nil, errors.New("jmap: the session has no API URL")
+	}
+	c.session = &s
+
+	return c.session, nil
+}
+
+// upload uploads the data as a blob and returns its id
+func (c *Client) upload(ctx context.Context, s *session, accountID, contentType string, data io.Reader) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, strings.Replace(s.UploadURL, "{accountId}", accountID, -1), data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	var blob struct {
+		BlobID string `json:"blobId"`
+	}
+	if err = c.do(ctx, req, &blob); err != nil {
+		return "", err
+	}
+
+	return blob.BlobID, nil
+}
+
+// methodResponse is the response of a method call
+type methodResponse struct {
+	Name string
+	Args json.RawMessage
+}
+
+// call makes an API request with the method calls and returns their
+// responses, or the first method error
+func (c *Client) call(ctx context.Context, s *session, calls []interface{}) ([]methodResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"using":       []string{capabilityCore, capabilityMail, capabilitySubmission},
+		"methodCalls": calls,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.APIURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		MethodResponses [][]json.RawMessage `json:"methodResponses"`
+	}
+	if err = c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+
+	var responses []methodResponse
+	for _, r := range result.MethodResponses {
+		if len(r) != 3 {
+			return nil, errors.New("jmap: invalid method response")
+		}
+
+		var resp methodResponse
+		if err = json.Unmarshal(r[0], &resp.Name); err != nil {
+			return nil, err
+		}
+		resp.Args = r[1]
+
+		if resp.Name == "error" {
+			var e setError
+			json.Unmarshal(resp.Args, &e)
+			return nil, errors.New("jmap: method error: " + e.Error())
+		}
+		responses = append(responses, resp)
+	}
+
+	if len(responses) != len(calls) {
+		return nil, errors.New("jmap: missing method responses")
+	}
+
+	return responses, nil
+}
+
+// do sends the authenticated request and decodes the JSON response
+func (c *Client) do(ctx context.Context, req *http.Request, v interface{}) error {
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return errors.New("jmap: " + req.URL.String() + ": " + resp.Status + ": " + strings.TrimSpace(string(data)))
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,138 @@
+package jmap
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func TestSend(t *testing.T) {
+	var uploads []string
+	var calls [][]interface{}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/session":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"apiUrl":          server.URL + "/api",
+				"uploadUrl":       server.URL + "/upload/{accountId}",
+				"primaryAccounts": map[string]string{capabilityMail: "a1"},
+			})
+		case "/upload/a1":
+			data, _ := ioutil.ReadAll(r.Body)
+			uploads = append(uploads, r.Header.Get("Content-Type")+" "+string(data))
+			json.NewEncoder(w).Encode(map[string]string{"blobId": "blob" + string(rune('0'+len(uploads)))})
+		case "/api":
+			var req struct {
+				MethodCalls [][]interface{} `json:"methodCalls"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			calls = append(calls, req.MethodCalls...)
+
+			var responses []interface{}
+			for _, call := range req.MethodCalls {
+				switch call[0] {
+				case "Identity/get":
+					responses = append(responses, []interface{}{call[0], map[string]interface{}{
+						"list": []map[string]string{{"id": "i1", "email": "other@example.com"}, {"id": "i2", "email": "from@example.com"}},
+					}, call[2]})
+				case "Mailbox/get":
+					responses = append(responses, []interface{}{call[0], map[string]interface{}{
+						"list": []map[string]interface{}{{"id": "m1", "role": "inbox"}, {"id": "m2", "role": "drafts"}, {"id": "m3", "role": "sent"}},
+					}, call[2]})
+				default:
+					responses = append(responses, []interface{}{call[0], map[string]interface{}{"created": map[string]interface{}{}}, call[2]})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"methodResponses": responses})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	email := mail.NewMSG().
+		SetFrom("Jöhn <from@example.com>").
+		AddTo("to@example.com").
+		AddBcc("bcc@example.com").
+		SetSubject("Réport").
+		AddHeader("X-Ticket", "1234").
+		SetBody(mail.TextPlain, "See the report").
+		AddAlternative(mail.TextHTML, `<p>See the report <img src="cid:logo.png"></p>`).
+		AddAttachmentData([]byte("%PDF"), "report.pdf", "application/pdf").
+		AddInlineData([]byte("png"), "logo.png", "image/png")
+
+	client := NewClient(server.URL+"/session", "token")
+	if err := client.Send(context.Background(), email); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(uploads, ", ") != "image/png png, application/pdf %PDF" {
+		t.Errorf("got uploads %q", uploads)
+	}
+	if len(calls) != 4 {
+		t.Fatalf("got calls %v", calls)
+	}
+
+	data, _ := json.Marshal(calls[2][1])
+	var set struct {
+		Create map[string]struct {
+			From        []map[string]string          `json:"from"`
+			To          []map[string]string          `json:"to"`
+			Subject     string                       `json:"subject"`
+			Ticket      string                       `json:"header:X-Ticket:asText"`
+			MailboxIDs  map[string]bool              `json:"mailboxIds"`
+			BodyValues  map[string]map[string]string `json:"bodyValues"`
+			HTMLBody    []map[string]string          `json:"htmlBody"`
+			Attachments []struct {
+				BlobID      string `json:"blobId"`
+				Name        string `json:"name"`
+				Disposition string `json:"disposition"`
+				CID         string `json:"cid"`
+			} `json:"attachments"`
+		} `json:"create"`
+	}
+	json.Unmarshal(data, &set)
+
+	draft := set.Create["draft"]
+	if len(draft.From) != 1 || draft.From[0]["name"] != "Jöhn" || draft.From[0]["email"] != "from@example.com" ||
+		len(draft.To) != 1 || draft.Subject != "Réport" || draft.Ticket != "1234" || !draft.MailboxIDs["m2"] {
+		t.Errorf("got email %s", data)
+	}
+	if len(draft.Attachments) != 2 || draft.Attachments[1].Name != "report.pdf" || draft.Attachments[1].BlobID != "blob2" ||
+		draft.Attachments[0].Disposition != "inline" || draft.Attachments[0].CID == "" {
+		t.Fatalf("got attachments %+v", draft.Attachments)
+	}
+
+	if draft.BodyValues["text"]["value"] != "See the report" || len(draft.HTMLBody) != 1 ||
+		!strings.Contains(draft.BodyValues["html"]["value"], `src="cid:`+draft.Attachments[0].CID+`"`) {
+		t.Errorf("got bodies %v", draft.BodyValues)
+	}
+	data, _ = json.Marshal(calls[3][1])
+	if s := string(data); !strings.Contains(s, `"identityId":"i2"`) || !strings.Contains(s, `"rcptTo":[{"email":"to@example.com"},{"email":"bcc@example.com"}]`) ||
+		!strings.Contains(s, `"mailboxIds/m3":true`) {
+		t.Errorf("got submission %s", data)
+	}
+
+	// the filters of the email change the envelope
+	email.AddFilter(mail.Sandbox("sandbox@example.com"))
+	if err := client.Send(context.Background(), email); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(calls[len(calls)-1][1])
+	if s := string(data); !strings.Contains(s, `"rcptTo":[{"email":"sandbox@example.com"}]`) {
+		t.Errorf("got submission %s, want the sandbox", data)
+	}
+}
@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"errors"
+	"net/textproto"
+)
+
+// logLevel is the level of a log record, with the values of the slog
+// levels
+type logLevel int
+
+const (
+	levelDebug logLevel = -4
+	levelInfo  logLevel = 0
+	levelWarn  logLevel = 4
+	levelError logLevel = 8
+)
+
+// The keys of the log records, the same for all the records
+const (
+	logKeyHost       = "host"
+	logKeyPort       = "port"
+	logKeyEncryption = "encryption"
+	logKeyDuration   = "duration"
+	logKeyMessageID  = "message_id"
+	logKeyFrom       = "from"
+	logKeyRecipients = "recipients"
+	logKeySize       = "size"
+	logKeyCode       = "code"
+	logKeyError      = "error"
+	// logKeyCorrelationID is set on the records of an email with a
+	// correlation id, see SetCorrelationID
+	logKeyCorrelationID = "correlation_id"
+)
+
+// recordLogger receives the structured log records of the clients of a
+// server, see SetLogger
+type recordLogger interface {
+	log(level logLevel, msg string, args ...interface{})
+}
+
+// log records an event of the server, with the host and port first and
+// the key-value pairs of args
+func (server *SMTPServer) log(level logLevel, msg string, args ...interface{}) {
+	if server == nil || server.logger == nil {
+		return
+	}
+
+	server.logger.log(level, msg, append([]interface{}{logKeyHost, server.Host, logKeyPort, server.Port}, args...)...)
+}
+
+// errorArgs returns the error and reply code key-value pairs of a failed
+// operation
+func errorArgs(err error) []interface{} {
+	args := []interface{}{logKeyError, err.Error()}
+
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		args = append(args, logKeyCode, reply.Code)
+	}
+
+	return args
+}
+
+// correlationArgs returns the correlation id key-value pair, or nothing if
+// the id is empty
+func correlationArgs(id string) []interface{} {
+	if id == "" {
+		return nil
+	}
+
+	return []interface{}{logKeyCorrelationID, id}
+}
@@ -0,0 +1,173 @@
+// Package mailgun sends emails built with Go Simple Mail with the Mailgun
+// messages API. The message is built like for SMTP, with the filters of the
+// email, and posted as MIME to the messages.mime endpoint of the domain, so
+// switching off SMTP only changes the sender:
+//
+//	client := mailgun.NewClient("mg.example.com", apiKey)
+//	client.BaseURL = mailgun.EUBaseURL
+//	err := client.Send(ctx, email)
+//
+// The metadata of the email is sent as v: variables and its tags as o:tag
+// options, so they are reported in the events of the message.
+package mailgun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// The base URLs of the API regions
+const (
+	DefaultBaseURL = "https://api.mailgun.net/v3"
+	EUBaseURL      = "https://api.eu.mailgun.net/v3"
+)
+
+var _ mail.Sender = (*Client)(nil)
+
+// Client sends the emails with the Mailgun API.
+type Client struct {
+	// BaseURL is the base URL of the API, DefaultBaseURL if not set
+	BaseURL string
+	// Domain is the sending domain of the account
+	Domain string
+	// APIKey is the private key of the account
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// StatusError is returned when the API doesn't reply with a 2xx status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return "mailgun: " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode) + ": " + e.Body
+}
+
+// Temporary returns true if the email can be sent again later, when the
+// API is unavailable or limits the rate.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// NewClient returns a client sending from the domain, authenticated with
+// the API key.
+func NewClient(domain, apiKey string) *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		Domain:     domain,
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Send posts the message of the email to all its recipients, including
+// Bcc, and returns nil when Mailgun queued it.
+func (c *Client) Send(ctx context.Context, email *mail.Email) error {
+	_, err := c.SendWithID(ctx, email)
+	return err
+}
+
+// SendWithID sends the email like Send and returns the id given by
+// Mailgun, to match its events.
+func (c *Client) SendWithID(ctx context.Context, email *mail.Email) (string, error) {
+	if email.Error != nil {
+		return "", email.Error
+	}
+
+	_, recipients, err := email.Envelope()
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("to", strings.Join(recipients, ",")); err != nil {
+		return "", err
+	}
+	if err := writeMetadata(form, email); err != nil {
+		return "", err
+	}
+	w, err := form.CreateFormFile("message", "message.eml")
+	if err != nil {
+		return "", err
+	}
+	if _, err := email.WriteTo(w); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/"+c.Domain+"/messages.mime", &body)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth("api", c.APIKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return "", &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(data, &result)
+
+	return strings.Trim(result.ID, "<>"), nil
+}
+
+// writeMetadata writes the metadata of the email as v:key fields, sorted by
+// key, and its tags as o:tag fields
+func writeMetadata(form *multipart.Writer, email *mail.Email) error {
+	metadata := email.Metadata()
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := form.WriteField("v:"+key, metadata[key]); err != nil {
+			return err
+		}
+	}
+	for _, tag := range email.Tags() {
+		if err := form.WriteField("o:tag", tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package mailgun
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func TestSend(t *testing.T) {
+	var to, message, userID string
+	var tags []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, key, _ := r.BasicAuth(); user != "api" || key != "key" {
+			http.Error(w, "Forbidden", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/v3/mg.example.com/messages.mime" {
+			http.NotFound(w, r)
+			return
+		}
+
+		to = r.FormValue("to")
+		userID = r.FormValue("v:user_id")
+		tags = r.MultipartForm.Value["o:tag"]
+		f, _, err := r.FormFile("message")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(f)
+		message = string(data)
+
+		w.Write([]byte(`{"id":"<20240101.1@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("mg.example.com", "key")
+	client.BaseURL = server.URL + "/v3"
+
+	email := mail.NewMSG().
+		SetFrom("from@example.com").
+		AddTo("ann@example.com").
+		AddBcc("bob@example.com").
+		SetSubject("Hello").
+		SetMetadata("user_id", "42").
+		AddTag("welcome", "spring").
+		SetBody(mail.TextPlain, "Hello")
+
+	id, err := client.SendWithID(context.Background(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != "20240101.1@mg.example.com" {
+		t.Errorf("got id %q", id)
+	}
+	if to != "ann@example.com,bob@example.com" {
+		t.Errorf("got to %q", to)
+	}
+	if userID != "42" || len(tags) != 2 || tags[0] != "welcome" || tags[1] != "spring" {
+		t.Errorf("got v:user_id %q, o:tag %v", userID, tags)
+	}
+	if !strings.Contains(message, "Subject: Hello") || strings.Contains(message, "Bcc") {
+		t.Errorf("got message %q", message)
+	}
+
+	client.APIKey = "invalid"
+	err = client.Send(context.Background(), email)
+	if e, ok := err.(*StatusError); !ok || e.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got error %v, want a 401 StatusError", err)
+	}
+}
@@ -0,0 +1,328 @@
+// Package mailtest decodes messages built with Go Simple Mail into a
+// normalized text, without boundaries, dates, message ids and transfer
+// encodings, and compares them with expected fixtures, to make regression
+// tests of email templates practical:
+//
+//	func TestWelcome(t *testing.T) {
+//		email := welcomeEmail(user)
+//		mailtest.CheckFixture(t, email.GetMessage(), "testdata/welcome.txt")
+//	}
+//
+// The fixtures are written, instead of compared, if the MAILTEST_UPDATE
+// environment variable is set.
+package mailtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// IgnoredHeaders are the headers left out of the normalized message,
+// because they change in every build
+var IgnoredHeaders = []string{"Date", "Message-Id", "Thread-Index", "Content-Transfer-Encoding"}
+
+// Part is a decoded leaf part of a message.
+type Part struct {
+	ContentType string
+	Filename    string
+	Header      textproto.MIMEHeader
+	Body        []byte
+}
+
+// Message is a decoded message.
+type Message struct {
+	Header textproto.MIMEHeader
+	Parts  []Part
+}
+
+// Text returns the body of the first text/plain part.
+func (m *Message) Text() string {
+	return m.body("text/plain")
+}
+
+// HTML returns the body of the first text/html part.
+func (m *Message) HTML() string {
+	return m.body("text/html")
+}
+
+func (m *Message) body(contentType string) string {
+	for _, p := range m.Parts {
+		if p.ContentType == contentType && p.Filename == "" {
+			return string(p.Body)
+		}
+	}
+
+	return ""
+}
+
+// Decode parses a message and decodes its headers and leaf parts.
+func Decode(msg string) (*Message, error) {
+	m, err := mail.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+
+	header := decodeHeader(textproto.MIMEHeader(m.Header))
+	parts, err := decodeParts(textproto.MIMEHeader(m.Header), m.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{Header: header, Parts: parts}, nil
+}
+
+// decodeHeader decodes the encoded words of the header values
+func decodeHeader(header textproto.MIMEHeader) textproto.MIMEHeader {
+	var dec mime.WordDecoder
+
+	decoded := make(textproto.MIMEHeader, len(header))
+	for name, values := range header {
+		for _, value := range values {
+			if v, err := dec.DecodeHeader(value); err == nil {
+				value = v
+			}
+			decoded.Add(name, value)
+		}
+	}
+
+	return decoded
+}
+
+// decodeParts decodes the leaf parts of a body
+func decodeParts(header textproto.MIMEHeader, body io.Reader) ([]Part, error) {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.New("mailtest: invalid Content-Type " + contentType + ": " + err.Error())
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var parts []Part
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				return parts, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			leaves, err := decodeParts(p.Header, p)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, leaves...)
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	part := Part{ContentType: mediaType, Header: decodeHeader(header), Body: data}
+
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		part.Filename = params["filename"]
+		if part.Filename != "" {
+			var dec mime.WordDecoder
+			if f, err := dec.DecodeHeader(part.Filename); err == nil {
+				part.Filename = f
+			}
+		}
+	}
+
+	return []Part{part}, nil
+}
+
+// Normalize returns a normalized text of the message: the headers sorted,
+// without IgnoredHeaders and the multipart boundaries, and the decoded
+// leaf parts, with LF line endings. Binary parts are replaced with their
+// size. The random Content-IDs of the parts are replaced by their number,
+// in the headers and in the cid: references of the bodies.
+func Normalize(msg string) (string, error) {
+	m, err := Decode(msg)
+	if err != nil {
+		return "", err
+	}
+
+	cids := contentIDs(m)
+
+	var b strings.Builder
+	writeHeader(&b, m.Header, cids)
+
+	for _, p := range m.Parts {
+		b.WriteString("\n--- " + p.ContentType)
+		if p.Filename != "" {
+			b.WriteString(" " + p.Filename)
+		}
+		b.WriteString("\n")
+
+		if !strings.HasPrefix(p.ContentType, "text/") || !utf8.Valid(p.Body) {
+			b.WriteString("[" + strconv.Itoa(len(p.Body)) + " bytes]\n")
+			continue
+		}
+
+		body := strings.Replace(string(p.Body), "\r\n", "\n", -1)
+		b.WriteString(strings.TrimRight(cids.Replace(body), "\n") + "\n")
+	}
+
+	return b.String(), nil
+}
+
+// contentIDs returns a replacer of the Content-IDs of the parts with their
+// number, from 1 in the order of the parts
+func contentIDs(m *Message) *strings.Replacer {
+	var pairs []string
+	for _, p := range m.Parts {
+		id := strings.Trim(p.Header.Get("Content-Id"), "<> ")
+		if id != "" {
+			pairs = append(pairs, id, strconv.Itoa(len(pairs)/2+1))
+		}
+	}
+
+	return strings.NewReplacer(pairs...)
+}
+
+func writeHeader(b *strings.Builder, header textproto.MIMEHeader, cids *strings.Replacer) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		if !ignored(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			if name == "Content-Type" {
+				value = withoutBoundary(value)
+			}
+			b.WriteString(name + ": " + cids.Replace(value) + "\n")
+		}
+	}
+}
+
+func ignored(name string) bool {
+	for _, h := range IgnoredHeaders {
+		if textproto.CanonicalMIMEHeaderKey(h) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withoutBoundary removes the boundary parameter of a content type
+func withoutBoundary(contentType string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["boundary"] == "" {
+		return contentType
+	}
+
+	delete(params, "boundary")
+
+	return mime.FormatMediaType(mediaType, params)
+}
+
+// Diff returns a line diff of want and got, with the lines only in want
+// prefixed by "-" and the lines only in got by "+", or an empty string if
+// they are equal.
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// longest common subsequence of the lines
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		default:
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		}
+	}
+
+	return out.String()
+}
+
+// CheckFixture normalizes the message and compares it with the fixture
+// file, failing the test with the diff if they differ. If the
+// MAILTEST_UPDATE environment variable is set, the fixture is written
+// instead.
+func CheckFixture(t testing.TB, msg, path string) {
+	t.Helper()
+
+	got, err := Normalize(msg)
+	if err != nil {
+		t.Fatalf("mailtest: decoding the message: %v", err)
+	}
+
+	if os.Getenv("MAILTEST_UPDATE") != "" {
+		if err = ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("mailtest: %v", err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("mailtest: %v", err)
+	}
+
+	if diff := Diff(string(want), got); diff != "" {
+		t.Errorf("mailtest: message differs from %s:\n%s", path, diff)
+	}
+}
@@ -0,0 +1,110 @@
+package mailtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func newEmail(name string) *mail.Email {
+	return mail.NewMSG().
+		SetFrom("From <from@example.com>").
+		AddTo("to@example.com").
+		SetSubject("Welcome, "+name).
+		SetBody(mail.TextPlain, "Hello "+name+",\n\nWelcome!").
+		AddAlternative(mail.TextHTML, "<p>Hello <b>"+name+"</b></p>").
+		AddAttachmentData([]byte{0, 1, 2}, "data.bin", "application/octet-stream")
+}
+
+func TestCheckFixture(t *testing.T) {
+	CheckFixture(t, newEmail("Jörg").GetMessage(), "testdata/welcome.txt")
+}
+
+func TestDecode(t *testing.T) {
+	m, err := Decode(newEmail("Jörg").GetMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.Header.Get("Subject"); got != "Welcome, Jörg" {
+		t.Errorf("got subject %q", got)
+	}
+	if got := m.Text(); got != "Hello Jörg,\r\n\r\nWelcome!" {
+		t.Errorf("got text %q", got)
+	}
+	if got := m.HTML(); got != "<p>Hello <b>Jörg</b></p>" {
+		t.Errorf("got html %q", got)
+	}
+}
+
+func TestNormalizeContentIDs(t *testing.T) {
+	newInline := func() string {
+		return mail.NewMSG().
+			SetFrom("from@example.com").
+			AddTo("to@example.com").
+			SetSubject("Logo").
+			SetBody(mail.TextHTML, `<img src="cid:logo.png">`).
+			AddInlineData([]byte{0x89, 'P', 'N', 'G'}, "logo.png", "image/png").
+			GetMessage()
+	}
+
+	first, err := Normalize(newInline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Normalize(newInline())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := Diff(first, second); diff != "" {
+		t.Errorf("got different normalized builds:\n%s", diff)
+	}
+	if !strings.Contains(first, `<img src="cid:1">`) {
+		t.Errorf("got normalized message without the stable cid:\n%s", first)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	want, _ := Normalize(newEmail("Jörg").GetMessage())
+	got, _ := Normalize(newEmail("Anna").GetMessage())
+
+	diff := Diff(want, got)
+	for _, line := range []string{"- Subject: Welcome, Jörg", "+ Subject: Welcome, Anna", "+ Hello Anna,", "  Welcome!"} {
+		if !strings.Contains(diff, line+"\n") {
+			t.Errorf("diff doesn't contain %q:\n%s", line, diff)
+		}
+	}
+
+	if diff := Diff(want, want); diff != "" {
+		t.Errorf("got diff of equal messages:\n%s", diff)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var sender mail.Sender = &Sink{}
+	if err := sender.Send(context.Background(), newEmail("Ann")); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := sender.(*Sink)
+	messages, err := sink.Messages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Header.Get("Subject") != "Welcome, Ann" {
+		t.Fatalf("got messages %+v", messages)
+	}
+
+	sink.Reset()
+	sink.Err = errors.New("unavailable")
+	if err := sink.Send(context.Background(), newEmail("Bob")); err != sink.Err {
+		t.Errorf("got error %v, want %v", err, sink.Err)
+	}
+	if messages, _ := sink.Messages(); len(messages) != 0 {
+		t.Errorf("got %d messages after Reset, want 0", len(messages))
+	}
+}
@@ -0,0 +1,65 @@
+package mailtest
+
+import (
+	"context"
+	"sync"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+var _ mail.Sender = (*Sink)(nil)
+
+// Sink is a mail.Sender recording the emails instead of sending them, for
+// the tests of the code sending with a mail.Sender.
+type Sink struct {
+	// Err, if set, is returned by Send, and the email is not recorded
+	Err error
+
+	mu       sync.Mutex
+	messages []string
+}
+
+// Send builds the email and records its message.
+func (s *Sink) Send(ctx context.Context, email *mail.Email) error {
+	if s.Err != nil {
+		return s.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		return email.Error
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Messages returns the recorded messages, decoded.
+func (s *Sink) Messages() ([]*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := make([]*Message, len(s.messages))
+	for i, msg := range s.messages {
+		m, err := Decode(msg)
+		if err != nil {
+			return nil, err
+		}
+		messages[i] = m
+	}
+
+	return messages, nil
+}
+
+// Reset forgets the recorded messages.
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	s.messages = nil
+	s.mu.Unlock()
+}
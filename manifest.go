@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ManifestFormat is the format of the attachment manifest
+type ManifestFormat int
+
+const (
+	// ManifestNone doesn't add a manifest
+	ManifestNone ManifestFormat = iota
+	// ManifestText adds a manifest.txt attachment, one line per attachment
+	// with the SHA-256, the size and the filename
+	ManifestText
+	// ManifestJSON adds a manifest.json attachment
+	ManifestJSON
+)
+
+// ManifestEntry is an attachment listed in the manifest.
+type ManifestEntry struct {
+	Filename string `json:"filename"`
+	MimeType string `json:"mime_type"`
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// SetManifest adds a manifest listing all the attachments with their
+// sizes and SHA-256 hashes, as the last attachment of the message, as
+// required by some B2B document exchange agreements.
+func (email *Email) SetManifest(format ManifestFormat) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.manifest = format
+
+	return email
+}
+
+// Manifest returns the entries of the attachment manifest.
+func (email *Email) Manifest() []ManifestEntry {
+	entries := make([]ManifestEntry, 0, len(email.attachments))
+	for _, f := range email.attachments {
+		entry := ManifestEntry{Filename: f.filename, MimeType: f.mimeType, Size: -1}
+
+		// a stream that can't be read twice is listed without hash
+		if r, err := f.open(); err == nil {
+			h := sha256.New()
+			if n, err := io.Copy(h, r); err == nil {
+				entry.Size = int(n)
+				entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// withManifest returns a copy of the email with the manifest attachment
+func (email *Email) withManifest() *Email {
+	if email.manifest == ManifestNone || len(email.attachments) == 0 {
+		return email
+	}
+
+	manifest := &file{filename: "manifest.txt", mimeType: "text/plain; charset=UTF-8"}
+
+	switch email.manifest {
+	case ManifestJSON:
+		manifest.filename = "manifest.json"
+		manifest.mimeType = "application/json"
+		manifest.data, _ = json.MarshalIndent(email.Manifest(), "", "  ")
+	default:
+		var b strings.Builder
+		for _, e := range email.Manifest() {
+			b.WriteString(e.SHA256 + "  " + strconv.Itoa(e.Size) + "  " + e.Filename + "\r\n")
+		}
+		manifest.data = []byte(b.String())
+	}
+
+	c := *email
+	c.attachments = append(email.attachments[:len(email.attachments):len(email.attachments)], manifest)
+
+	return &c
+}
@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	newEmail := func() *Email {
+		return NewMSG().
+			SetFrom("from@example.com").
+			AddTo("to@example.com").
+			SetBody(TextPlain, "Invoice attached").
+			AddAttachmentData([]byte("abc"), "invoice.xml", "application/xml")
+	}
+
+	email := newEmail().SetManifest(ManifestText)
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	// sha256 of "abc"
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  3  invoice.xml\r\n"
+	if err := checkMessage(email.withManifest(), msg); err != nil {
+		t.Errorf("checkMessage: %v", err)
+	}
+	if got := string(email.withManifest().attachments[1].data); got != want {
+		t.Errorf("got manifest %q, want %q", got, want)
+	}
+	if !strings.Contains(msg, `filename="manifest.txt"`) {
+		t.Errorf("manifest not found in message:\n%s", msg)
+	}
+	if len(email.attachments) != 1 {
+		t.Errorf("got %d attachments, want the original one", len(email.attachments))
+	}
+
+	email = newEmail().SetManifest(ManifestJSON)
+	manifest := email.withManifest().attachments[1]
+	if manifest.filename != "manifest.json" || !strings.Contains(string(manifest.data), `"size": 3`) {
+		t.Errorf("got manifest %s:\n%s", manifest.filename, manifest.data)
+	}
+}
@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// MDN is a parsed message disposition notification (RFC 8098), the read
+// receipt of a message.
+type MDN struct {
+	// OriginalMessageID is the Message-ID of the message the MDN is about
+	OriginalMessageID string
+	// ReportingUA is the user agent that sent the MDN, like
+	// "mua.example.com; Mail 1.0"
+	ReportingUA string
+	// OriginalRecipient and FinalRecipient are the addresses of the
+	// recipient, the original one only if it was requested
+	OriginalRecipient string
+	FinalRecipient    string
+	// ActionMode is manual-action or automatic-action and SendingMode is
+	// MDN-sent-manually or MDN-sent-automatically
+	ActionMode  string
+	SendingMode string
+	// Disposition is displayed, deleted, dispatched or processed
+	Disposition string
+	// Modifiers are the disposition modifiers, like error
+	Modifiers []string
+	Date      time.Time
+}
+
+// State returns the delivery state reported by the MDN, DeliveryDisplayed
+// if the message was displayed or DeliveryDeleted otherwise.
+func (mdn *MDN) State() DeliveryState {
+	if mdn.Disposition == "displayed" {
+		return DeliveryDisplayed
+	}
+
+	return DeliveryDeleted
+}
+
+// ParseMDN parses a message disposition notification, a multipart/report
+// message with a message/disposition-notification part. It returns
+// ErrNotReport if the message isn't a MDN.
+func ParseMDN(r io.Reader) (*MDN, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" {
+		return nil, ErrNotReport
+	}
+
+	date, _ := m.Header.Date()
+
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, ErrNotReport
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if partType != "message/disposition-notification" && partType != "message/global-disposition-notification" {
+			continue
+		}
+
+		fields, err := textproto.NewReader(bufio.NewReader(p)).ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		return newMDN(fields, date), nil
+	}
+}
+
+// newMDN returns the MDN of the fields of a disposition notification
+func newMDN(fields textproto.MIMEHeader, date time.Time) *MDN {
+	mdn := &MDN{
+		OriginalMessageID: fields.Get("Original-Message-Id"),
+		ReportingUA:       strings.TrimSpace(fields.Get("Reporting-Ua")),
+		OriginalRecipient: reportAddress(fields.Get("Original-Recipient")),
+		FinalRecipient:    reportAddress(fields.Get("Final-Recipient")),
+		Date:              date,
+	}
+	mdn.parseDisposition(fields.Get("Disposition"))
+
+	return mdn
+}
+
+// parseDisposition parses a disposition field like
+// "manual-action/MDN-sent-manually; displayed"
+func (mdn *MDN) parseDisposition(value string) {
+	modes, disposition := "", value
+	if i := strings.Index(value, ";"); i >= 0 {
+		modes, disposition = value[:i], value[i+1:]
+	}
+
+	if i := strings.Index(modes, "/"); i >= 0 {
+		mdn.ActionMode = strings.TrimSpace(modes[:i])
+		mdn.SendingMode = strings.TrimSpace(modes[i+1:])
+	}
+
+	values := strings.Split(disposition, "/")
+	mdn.Disposition = strings.ToLower(strings.TrimSpace(values[0]))
+	for _, modifier := range values[1:] {
+		mdn.Modifiers = append(mdn.Modifiers, strings.ToLower(strings.TrimSpace(modifier)))
+	}
+}
@@ -0,0 +1,42 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMDN(t *testing.T) {
+	mdn, err := ParseMDN(strings.NewReader(testMDN))
+	if err != nil {
+		t.Fatalf("ParseMDN: %v", err)
+	}
+
+	if mdn.OriginalMessageID != "<123@example.com>" {
+		t.Errorf("got original Message-ID %q", mdn.OriginalMessageID)
+	}
+	if mdn.ReportingUA != "client.example.com; Mail" {
+		t.Errorf("got reporting UA %q", mdn.ReportingUA)
+	}
+	if mdn.FinalRecipient != "one@example.com" {
+		t.Errorf("got final recipient %q", mdn.FinalRecipient)
+	}
+	if mdn.ActionMode != "manual-action" || mdn.SendingMode != "MDN-sent-manually" {
+		t.Errorf("got modes %q and %q", mdn.ActionMode, mdn.SendingMode)
+	}
+	if mdn.Disposition != "displayed" || mdn.State() != DeliveryDisplayed {
+		t.Errorf("got disposition %q", mdn.Disposition)
+	}
+
+	deleted := strings.Replace(testMDN, "manual-action/MDN-sent-manually; displayed",
+		"automatic-action/MDN-sent-automatically; deleted/error", 1)
+	if mdn, err = ParseMDN(strings.NewReader(deleted)); err != nil {
+		t.Fatalf("ParseMDN: %v", err)
+	}
+	if mdn.Disposition != "deleted" || mdn.State() != DeliveryDeleted || len(mdn.Modifiers) != 1 || mdn.Modifiers[0] != "error" {
+		t.Errorf("got disposition %q with modifiers %v", mdn.Disposition, mdn.Modifiers)
+	}
+
+	if _, err = ParseMDN(strings.NewReader(testDSN)); err != ErrNotReport {
+		t.Errorf("got error %v parsing a DSN, want ErrNotReport", err)
+	}
+}
@@ -2,66 +2,122 @@ package mail
 
 import (
 	"bytes"
-	"encoding/base64"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"io"
-	"mime/multipart"
-	"mime/quotedprintable"
 	"net/textproto"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/xhit/go-simple-mail/v2/mime"
 )
 
 type message struct {
-	headers  textproto.MIMEHeader
-	body     *bytes.Buffer
-	writers  []*multipart.Writer
-	parts    uint8
-	cids     map[string]string
-	charset  string
-	encoding encoding
+	headers   textproto.MIMEHeader
+	body      *bytes.Buffer
+	multipart *mime.NestedWriter
+	cids      map[string]string
+	charset   string
+	encoding  encoding
+	clock     Clock
+	sevenBit  bool
+	// utf8Headers sends the headers as raw UTF-8 instead of encoded words
+	utf8Headers bool
+	profile     Profile
+	headerOrder []string
+	files       int
+	segments    []segment
+	err         error
 }
 
 func newMessage(email *Email) *message {
-	return &message{
-		headers:  email.headers,
-		body:     new(bytes.Buffer),
-		cids:     make(map[string]string),
-		charset:  email.Charset,
-		encoding: email.Encoding}
+	body := new(bytes.Buffer)
+
+	msg := &message{
+		headers:     email.headers,
+		body:        body,
+		multipart:   mime.NewNestedWriter(body),
+		cids:        make(map[string]string),
+		charset:     email.Charset,
+		encoding:    email.Encoding,
+		clock:       email.clock,
+		sevenBit:    email.sevenBit,
+		utf8Headers: email.utf8Headers && !email.sevenBit,
+		profile:     email.profile,
+		headerOrder: email.headerOrder}
+
+	// the cids of the inline images are referenced as they are
+	for _, f := range email.inlines {
+		if f.cid != "" {
+			msg.cids[f.cid] = f.cid
+		}
+	}
+
+	msg.multipart.BoundaryFunc = email.boundary
+	msg.multipart.Preamble = email.preamble
+	msg.multipart.Epilogue = email.epilogue
+
+	return msg
 }
 
 func encodeHeader(text string, charset string, usedChars int) string {
-	// create buffer
+	return mime.EncodeHeader(text, charset, usedChars)
+}
+
+// forceEncodeHeader encodes the header value with encoded words even if
+// it's printable
+func forceEncodeHeader(text string, charset string, usedChars int) string {
 	buf := new(bytes.Buffer)
 
-	// encode
-	encoder := newEncoder(buf, charset, usedChars)
-	encoder.encode([]byte(text))
+	e := mime.NewHeaderEncoder(buf, charset, usedChars)
+	e.Force = true
+	e.Encode([]byte(text))
 
 	return buf.String()
-
-	/*
-			switch encoding {
-			case EncodingBase64:
-				return mime.BEncoding.Encode(charset, text)
-			default:
-				return mime.QEncoding.Encode(charset, text)
-		}
-	*/
 }
 
 // getHeaders returns the message headers
 func (msg *message) getHeaders() (headers string) {
 	// if the date header isn't set, set it
 	if date := msg.headers.Get("Date"); date == "" {
-		msg.headers.Set("Date", time.Now().Format(time.RFC1123Z))
+		msg.headers.Set("Date", msg.now().Format(time.RFC1123Z))
+	}
+
+	// if the message id header isn't set, set it
+	if id := msg.headers.Get("Message-Id"); id == "" {
+		msg.headers.Set("Message-Id", msg.messageID())
+	}
+
+	// if the mime version header isn't set, set it
+	if version := msg.headers.Get("Mime-Version"); version == "" {
+		msg.headers.Set("Mime-Version", "1.0")
 	}
 
 	// encode and combine the headers
-	for header, values := range msg.headers {
-		headers += header + ": " + encodeHeader(strings.Join(values, ", "), msg.charset, len(header)+2) + "\r\n"
+	for _, header := range orderHeaders(msg.headers, msg.headerOrder) {
+		if msg.profile.StrictRFC && nonStandardHeaders[header] {
+			continue
+		}
+
+		values := msg.headers[header]
+		if addressHeaders[header] && !msg.utf8Headers {
+			// the domains can't be encoded words
+			values = asciiAddresses(values)
+		}
+		if msg.utf8Headers {
+			headers += header + ": " + utf8HeaderValue(strings.Join(values, ", "), len(header)+2) + "\r\n"
+			continue
+		}
+
+		value := encodeHeader(strings.Join(values, ", "), msg.charset, len(header)+2)
+		if msg.sevenBit && hasLongLine(value, len(header)+2) {
+			// split the long words in encoded words
+			value = forceEncodeHeader(strings.Join(values, ", "), msg.charset, len(header)+2)
+		}
+		headers += header + ": " + value + "\r\n"
 	}
 
 	headers = headers + "\r\n"
@@ -69,16 +125,48 @@ func (msg *message) getHeaders() (headers string) {
 	return
 }
 
+// nonStandardHeaders are the non-standard headers added by the builder,
+// omitted with Profile.StrictRFC
+var nonStandardHeaders = map[string]bool{
+	"X-Priority":        true,
+	"X-Msmail-Priority": true,
+	"Thread-Index":      true,
+	"Thread-Topic":      true,
+	TagsHeader:          true,
+}
+
+// singleHeaders are the headers that can only have one value
+var singleHeaders = []string{"Date", "Message-Id", "Mime-Version"}
+
+// checkSingleHeaders returns an error if any of the single headers has
+// several values, like a Message-Id added by a filter to an email that
+// already had one
+func checkSingleHeaders(headers textproto.MIMEHeader) error {
+	for _, header := range singleHeaders {
+		if len(headers[header]) > 1 {
+			return errors.New("Mail Error: Duplicate header; Header: [" + header + "]")
+		}
+	}
+
+	return nil
+}
+
+// now returns the current time of the message clock
+func (msg *message) now() time.Time {
+	if msg.clock != nil {
+		return msg.clock.Now()
+	}
+
+	return time.Now()
+}
+
 // getCID gets the generated CID for the provided text
 func (msg *message) getCID(text string) (cid string) {
-	// set the date format to use
-	const dateFormat = "20060102.150405"
-
 	// get the cid if we have one
 	cid, exists := msg.cids[text]
 	if !exists {
 		// generate a new cid
-		cid = time.Now().Format(dateFormat) + "." + strconv.Itoa(len(msg.cids)+1) + "@mail.0"
+		cid = newCID(msg.headers.Get("From"))
 		// save it
 		msg.cids[text] = cid
 	}
@@ -86,6 +174,23 @@ func (msg *message) getCID(text string) (cid string) {
 	return
 }
 
+// newCID returns a random Content-ID (RFC 2392) in the domain of the sender
+func newCID(from string) string {
+	random := make([]byte, 16)
+	rand.Read(random)
+
+	return hex.EncodeToString(random) + "@" + senderDomain(from, false)
+}
+
+// fileCID returns the CID of an inline file
+func (msg *message) fileCID(file *file) string {
+	if file.cid != "" {
+		return file.cid
+	}
+
+	return msg.getCID(file.filename)
+}
+
 // replaceCIDs replaces the CIDs found in a text string
 // with generated ones
 func (msg *message) replaceCIDs(text string) string {
@@ -102,89 +207,25 @@ func (msg *message) replaceCIDs(text string) string {
 
 // openMultipart creates a new part of a multipart message
 func (msg *message) openMultipart(multipartType string) {
-	// create a new multipart writer
-	msg.writers = append(msg.writers, multipart.NewWriter(msg.body))
-	// create the boundary
-	contentType := "multipart/" + multipartType + ";\n \tboundary=" + msg.writers[msg.parts].Boundary()
+	isRoot := msg.multipart.Depth() == 0
+
+	contentType, err := msg.multipart.Open(multipartType)
+	if err != nil {
+		if msg.err == nil {
+			msg.err = errors.New("Mail Error: Failed to open multipart: " + err.Error())
+		}
+		return
+	}
 
 	// if no existing parts, add header to main header group
-	if msg.parts == 0 {
+	if isRoot {
 		msg.headers.Set("Content-Type", contentType)
-	} else { // add header to multipart section
-		header := make(textproto.MIMEHeader)
-		header.Set("Content-Type", contentType)
-		msg.writers[msg.parts-1].CreatePart(header)
 	}
-
-	msg.parts++
 }
 
 // closeMultipart closes a part of a multipart message
 func (msg *message) closeMultipart() {
-	if msg.parts > 0 {
-		msg.writers[msg.parts-1].Close()
-		msg.parts--
-	}
-}
-
-// base64Encode base64 encodes the provided text with line wrapping
-func base64Encode(text []byte) []byte {
-	// create buffer
-	buf := new(bytes.Buffer)
-
-	// create base64 encoder that linewraps
-	encoder := base64.NewEncoder(base64.StdEncoding, &base64LineWrap{writer: buf})
-
-	// write the encoded text to buf
-	encoder.Write(text)
-	encoder.Close()
-
-	return buf.Bytes()
-}
-
-// qpEncode uses the quoted-printable encoding to encode the provided text
-func qpEncode(text []byte) []byte {
-	// create buffer
-	buf := new(bytes.Buffer)
-
-	encoder := quotedprintable.NewWriter(buf)
-
-	encoder.Write(text)
-	encoder.Close()
-
-	return buf.Bytes()
-}
-
-const maxLineChars = 76
-
-type base64LineWrap struct {
-	writer       io.Writer
-	numLineChars int
-}
-
-func (e *base64LineWrap) Write(p []byte) (n int, err error) {
-	n = 0
-	// while we have more chars than are allowed
-	for len(p)+e.numLineChars > maxLineChars {
-		numCharsToWrite := maxLineChars - e.numLineChars
-		// write the chars we can
-		e.writer.Write(p[:numCharsToWrite])
-		// write a line break
-		e.writer.Write([]byte("\r\n"))
-		// reset the line count
-		e.numLineChars = 0
-		// remove the chars that have been written
-		p = p[numCharsToWrite:]
-		// set the num of chars written
-		n += numCharsToWrite
-	}
-
-	// write what is left
-	e.writer.Write(p)
-	e.numLineChars += len(p)
-	n += len(p)
-
-	return
+	msg.multipart.Close()
 }
 
 func (msg *message) write(header textproto.MIMEHeader, body []byte, encoding encoding) {
@@ -194,34 +235,61 @@ func (msg *message) write(header textproto.MIMEHeader, body []byte, encoding enc
 
 func (msg *message) writeHeader(headers textproto.MIMEHeader) {
 	// if there are no parts add header to main headers
-	if msg.parts == 0 {
+	if msg.multipart.Depth() == 0 {
 		for header, value := range headers {
 			msg.headers[header] = value
 		}
 	} else { // add header to multipart section
-		msg.writers[msg.parts-1].CreatePart(headers)
+		msg.multipart.CreatePart(headers)
 	}
 }
 
 func (msg *message) writeBody(body []byte, encoding encoding) {
-	// encode and write the body
-	switch encoding {
-	case EncodingQuotedPrintable:
-		msg.body.Write(qpEncode(body))
-	case EncodingBase64:
-		msg.body.Write(base64Encode(body))
-	default:
-		msg.body.Write(body)
+	msg.writeSource(bytes.NewReader(body), encoding.encoder(), EncoderOptions{Size: int64(len(body))})
+}
+
+func (msg *message) writeSource(source io.Reader, encoder Encoder, options EncoderOptions) {
+	// the body is encoded when the message is read
+	options.LineLength = msg.profile.Base64LineLength
+	msg.flush()
+	msg.segments = append(msg.segments, segment{
+		source:  source,
+		encoder: encoder,
+		options: options,
+	})
+}
+
+// flush moves the data written in the body buffer to a segment
+func (msg *message) flush() {
+	if msg.body.Len() == 0 {
+		return
 	}
+
+	msg.segments = append(msg.segments, segment{data: append([]byte(nil), msg.body.Bytes()...)})
+	msg.body.Reset()
+}
+
+// finish adds the headers and the end of the body to the segments
+func (msg *message) finish() {
+	msg.flush()
+	msg.segments = append([]segment{{data: []byte(msg.getHeaders())}}, msg.segments...)
 }
 
 func (msg *message) addBody(contentType string, body []byte) {
-	body = []byte(msg.replaceCIDs(string(body)))
+	// avoid copying large bodies without cids
+	if bytes.Contains(body, []byte("cid:")) {
+		body = []byte(msg.replaceCIDs(string(body)))
+	}
+
+	encoding := msg.encoding
+	if msg.sevenBit && encoding == EncodingNone {
+		encoding = EncodingQuotedPrintable
+	}
 
 	header := make(textproto.MIMEHeader)
 	header.Set("Content-Type", contentType+"; charset="+msg.charset)
-	header.Set("Content-Transfer-Encoding", msg.encoding.string())
-	msg.write(header, body, msg.encoding)
+	header.Set("Content-Transfer-Encoding", encoding.string())
+	msg.write(header, body, encoding)
 }
 
 var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
@@ -231,18 +299,64 @@ func escapeQuotes(s string) string {
 }
 
 func (msg *message) addFiles(files []*file, inline bool) {
-	encoding := EncodingBase64
 	for _, file := range files {
-		header := make(textproto.MIMEHeader)
-		header.Set("Content-Type", file.mimeType+";\n \tname=\""+encodeHeader(escapeQuotes(file.filename), msg.charset, 6)+`"`)
-		header.Set("Content-Transfer-Encoding", encoding.string())
+		options := EncoderOptions{Filename: file.filename, Size: int64(len(file.data))}
+		if file.reader != nil {
+			options.Size = -1
+		}
+
+		msg.writeHeader(msg.fileHeader(file, inline))
+		msg.writeSource(file.source(), file.encoder(), options)
+	}
+}
+
+// fileHeader returns the part header of an attached file
+func (msg *message) fileHeader(file *file, inline bool) textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Transfer-Encoding", file.encoder().Name())
+
+	if file.contentType != "" {
+		header.Set("Content-Type", file.contentType)
+		header.Set("Content-Disposition", file.disposition)
+		return header
+	}
+
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+		header.Set("Content-ID", "<"+msg.fileCID(file)+">")
+	}
+
+	if msg.profile.AttachmentID && !msg.profile.StrictRFC {
+		msg.files++
 		if inline {
-			header.Set("Content-Disposition", "inline;\n \tfilename=\""+encodeHeader(escapeQuotes(file.filename), msg.charset, 10)+`"`)
-			header.Set("Content-ID", "<"+msg.getCID(file.filename)+">")
+			header.Set("X-Attachment-Id", msg.fileCID(file))
 		} else {
-			header.Set("Content-Disposition", "attachment;\n \tfilename=\""+encodeHeader(escapeQuotes(file.filename), msg.charset, 10)+`"`)
+			header.Set("X-Attachment-Id", "f_"+strconv.Itoa(msg.files))
 		}
+	}
+
+	var name, filename string
+	if msg.utf8Headers {
+		name = ";\r\n \tname=\"" + escapeQuotes(file.filename) + `"`
+		filename = ";\r\n \tfilename=\"" + escapeQuotes(file.filename) + `"`
+	} else if msg.profile.RFC2231Params {
+		name = ";\r\n " + mime.EncodeParam("name", mime.Transliterate(file.filename), msg.charset)
+		filename = ";\r\n " + mime.EncodeParam("filename", file.filename, msg.charset)
+	} else {
+		name = ";\r\n \tname=\"" + encodeHeader(escapeQuotes(file.filename), msg.charset, 6) + `"`
+		filename = ";\r\n \tfilename=\"" + encodeHeader(escapeQuotes(file.filename), msg.charset, 10) + `"`
+	}
 
-		msg.write(header, file.data, encoding)
+	switch msg.profile.AttachmentParams {
+	case ParamsFilename:
+		name = ""
+	case ParamsName:
+		filename = ""
 	}
+
+	header.Set("Content-Type", file.mimeType+name)
+	header.Set("Content-Disposition", disposition+filename)
+
+	return header
 }
@@ -0,0 +1,90 @@
+package mail
+
+import (
+	"errors"
+	"strings"
+)
+
+// TagsHeader is the header where the email tags are emitted
+const TagsHeader = "X-Tags"
+
+// SetMetadata sets a metadata value in the email. Metadata is not part of the
+// message itself, it's meant to be mapped by the transports to the provider
+// specific fields (SES message tags, SendGrid custom args, Mailgun variables),
+// so analytics data is kept when switching providers.
+func (email *Email) SetMetadata(key, value string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	if key == "" {
+		email.Error = errors.New("Mail Error: Metadata key can not be empty")
+		return email
+	}
+
+	if email.metadata == nil {
+		email.metadata = make(map[string]string)
+	}
+
+	email.metadata[key] = value
+
+	return email
+}
+
+// GetMetadata returns the metadata value for the given key, if any
+func (email *Email) GetMetadata(key string) string {
+	return email.metadata[key]
+}
+
+// Metadata returns a copy of all the metadata of the email
+func (email *Email) Metadata() map[string]string {
+	metadata := make(map[string]string, len(email.metadata))
+	for k, v := range email.metadata {
+		metadata[k] = v
+	}
+
+	return metadata
+}
+
+// AddTag adds tags to the email. Tags are emitted in the X-Tags header and
+// can be mapped by the transports to the provider specific tags or used to
+// filter emails. Duplicated tags are ignored.
+func (email *Email) AddTag(tags ...string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || strings.ContainsAny(tag, ",\r\n") {
+			email.Error = errors.New("Mail Error: Invalid tag; Tag: [" + tag + "]")
+			return email
+		}
+
+		if !email.HasTag(tag) {
+			email.tags = append(email.tags, tag)
+		}
+	}
+
+	if len(email.tags) > 0 {
+		email.headers.Set(TagsHeader, strings.Join(email.tags, ", "))
+	}
+
+	return email
+}
+
+// HasTag returns true if the email has the given tag
+func (email *Email) HasTag(tag string) bool {
+	for _, t := range email.tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tags returns the tags of the email
+func (email *Email) Tags() []string {
+	return append([]string(nil), email.tags...)
+}
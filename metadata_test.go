@@ -0,0 +1,100 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagsAndMetadata(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddTag("welcome", "onboarding").
+		AddTag("welcome").
+		SetMetadata("user_id", "42")
+
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+
+	if got := email.Tags(); len(got) != 2 || !email.HasTag("onboarding") {
+		t.Errorf("got tags %v, want [welcome onboarding]", got)
+	}
+
+	if got := email.GetMetadata("user_id"); got != "42" {
+		t.Errorf("got metadata %q, want 42", got)
+	}
+
+	if msg := email.GetMessage(); !strings.Contains(msg, "X-Tags: welcome, onboarding\r\n") {
+		t.Errorf("X-Tags header not found in message:\n%s", msg)
+	}
+
+	if email.AddTag("a,b"); email.Error == nil {
+		t.Error("expected error adding invalid tag")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	email := NewMSG()
+	if got := email.GetMetadata("missing"); got != "" {
+		t.Errorf("got metadata %q, want empty", got)
+	}
+	if got := email.Metadata(); got == nil || len(got) != 0 {
+		t.Errorf("got metadata %v, want an empty map", got)
+	}
+
+	email.SetMetadata("user_id", "42").SetMetadata("campaign", "spring").SetMetadata("user_id", "43")
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+	if got := email.GetMetadata("user_id"); got != "43" {
+		t.Errorf("got user_id %q, want the last value 43", got)
+	}
+
+	// Metadata returns a copy, changing it doesn't change the email
+	metadata := email.Metadata()
+	if len(metadata) != 2 || metadata["campaign"] != "spring" {
+		t.Errorf("got metadata %v", metadata)
+	}
+	metadata["campaign"] = "winter"
+	delete(metadata, "user_id")
+	if email.GetMetadata("campaign") != "spring" || email.GetMetadata("user_id") != "43" {
+		t.Errorf("the email metadata was changed through its copy: %v", email.Metadata())
+	}
+
+	// metadata is not part of the message
+	if msg := email.SetFrom("from@example.com").AddTo("to@example.com").GetMessage(); strings.Contains(msg, "spring") {
+		t.Errorf("got metadata in the message:\n%s", msg)
+	}
+
+	if email.SetMetadata("", "value"); email.Error == nil {
+		t.Error("expected error setting an empty metadata key")
+	}
+	if email.SetMetadata("other", "value"); email.GetMetadata("other") != "" {
+		t.Error("metadata set after an error")
+	}
+}
+
+func TestAddTag(t *testing.T) {
+	email := NewMSG().AddTag(" welcome ", "spring").AddTag("welcome")
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	// Tags returns a copy, in the order the tags were added
+	tags := email.Tags()
+	if len(tags) != 2 || tags[0] != "welcome" || tags[1] != "spring" {
+		t.Errorf("got tags %v, want [welcome spring]", tags)
+	}
+	tags[0] = "changed"
+	if !email.HasTag("welcome") || email.HasTag("changed") {
+		t.Errorf("the email tags were changed through their copy: %v", email.Tags())
+	}
+
+	for _, tag := range []string{"", " ", "a\r\nBcc: evil@example.com"} {
+		if NewMSG().AddTag(tag).Error == nil {
+			t.Errorf("expected error adding tag %q", tag)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package mime implements the low level MIME encoding used by Go Simple Mail:
+// RFC 2047 header encoding, RFC 2231 parameter encoding, line wrapped base64
+// and quoted-printable writers and nested multipart writers. It can be used
+// to produce exactly the same wire format outside of the mail package.
+package mime
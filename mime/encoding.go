@@ -0,0 +1,99 @@
+package mime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime/quotedprintable"
+)
+
+// MaxLineLength is the maximum length of the encoded lines, without CRLF
+const MaxLineLength = 76
+
+// NewBase64Writer returns a base64 encoder that writes to w wrapping the lines
+// at MaxLineLength characters. Close must be called to flush the last bytes.
+func NewBase64Writer(w io.Writer) io.WriteCloser {
+	return NewBase64WriterWidth(w, MaxLineLength)
+}
+
+// NewBase64WriterWidth is like NewBase64Writer but wraps the lines at width
+// characters, which should be a multiple of 4.
+func NewBase64WriterWidth(w io.Writer, width int) io.WriteCloser {
+	return base64.NewEncoder(base64.StdEncoding, &lineWrapper{writer: w, width: width})
+}
+
+// NewQPWriter returns a quoted-printable encoder that writes to w. Close must
+// be called to flush the last bytes.
+func NewQPWriter(w io.Writer) io.WriteCloser {
+	return quotedprintable.NewWriter(w)
+}
+
+// Base64Encode base64 encodes the provided text with line wrapping
+func Base64Encode(text []byte) []byte {
+	return Base64EncodeWidth(text, MaxLineLength)
+}
+
+// Base64EncodeWidth base64 encodes the provided text wrapping the lines at
+// width characters
+func Base64EncodeWidth(text []byte, width int) []byte {
+	buf := new(bytes.Buffer)
+
+	encoder := NewBase64WriterWidth(buf, width)
+	encoder.Write(text)
+	encoder.Close()
+
+	return buf.Bytes()
+}
+
+// QPEncode uses the quoted-printable encoding to encode the provided text
+func QPEncode(text []byte) []byte {
+	buf := new(bytes.Buffer)
+
+	encoder := NewQPWriter(buf)
+	encoder.Write(text)
+	encoder.Close()
+
+	return buf.Bytes()
+}
+
+// lineWrapper inserts a line break every width characters
+type lineWrapper struct {
+	writer       io.Writer
+	width        int
+	numLineChars int
+}
+
+func (e *lineWrapper) Write(p []byte) (n int, err error) {
+	width := e.width
+	if width <= 0 {
+		width = MaxLineLength
+	}
+
+	// while we have more chars than are allowed
+	for len(p)+e.numLineChars > width {
+		numCharsToWrite := width - e.numLineChars
+		// write the chars we can
+		if _, err = e.writer.Write(p[:numCharsToWrite]); err != nil {
+			return
+		}
+		// write a line break
+		if _, err = e.writer.Write([]byte("\r\n")); err != nil {
+			return
+		}
+		// reset the line count
+		e.numLineChars = 0
+		// remove the chars that have been written
+		p = p[numCharsToWrite:]
+		// set the num of chars written
+		n += numCharsToWrite
+	}
+
+	// write what is left
+	if _, err = e.writer.Write(p); err != nil {
+		return
+	}
+	e.numLineChars += len(p)
+	n += len(p)
+
+	return
+}
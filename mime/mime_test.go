@@ -0,0 +1,142 @@
+package mime
+
+import (
+	"bytes"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestEncodeHeader(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello", "Hello"},
+		{"Hello\r\nBcc: injected", "HelloBcc: injected"},
+		{"日本語", "=?UTF-8?Q?=E6=97=A5=E6=9C=AC=E8=AA=9E?="},
+	}
+
+	for _, test := range tests {
+		if got := EncodeHeader(test.in, "utf-8", 9); got != test.want {
+			t.Errorf("EncodeHeader(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestBase64Encode(t *testing.T) {
+	got := string(Base64Encode(bytes.Repeat([]byte("a"), 120)))
+
+	lines := strings.Split(got, "\r\n")
+	if len(lines) != 3 || len(lines[0]) != MaxLineLength || len(lines[1]) != MaxLineLength {
+		t.Errorf("got badly wrapped base64 %q", got)
+	}
+}
+
+func TestNestedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	n := NewNestedWriter(&buf)
+
+	root, err := n.Open("mixed")
+	if err != nil || !strings.HasPrefix(root, "multipart/mixed;") {
+		t.Fatalf("got Content-Type %q: %v", root, err)
+	}
+
+	if _, err = n.Open("alternative"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Depth() != 2 {
+		t.Errorf("got depth %d, want 2", n.Depth())
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", "text/plain")
+	w, err := n.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("Hello"))
+
+	n.Close()
+	n.Close()
+
+	if n.Depth() != 0 {
+		t.Errorf("got depth %d, want 0", n.Depth())
+	}
+
+	if got := strings.Count(buf.String(), "multipart/alternative"); got != 1 {
+		t.Errorf("got %d nested multipart headers, want 1:\n%s", got, buf.String())
+	}
+
+	if _, err = n.CreatePart(header); err == nil {
+		t.Error("expected error creating a part without open multipart")
+	}
+}
+
+func TestBoundaryFunc(t *testing.T) {
+	var buf bytes.Buffer
+	n := NewNestedWriter(&buf)
+	n.BoundaryFunc = SequenceBoundary("b")
+
+	n.Open("mixed")
+	got, _ := n.Open("alternative")
+
+	if want := "multipart/alternative;\n \tboundary=b2"; got != want {
+		t.Errorf("got Content-Type %q, want %q", got, want)
+	}
+
+	n.BoundaryFunc = func() string { return `invalid "boundary"` }
+	if _, err := n.Open("related"); err == nil {
+		t.Error("expected error with invalid boundary")
+	}
+
+	if b := PrefixBoundary("legacy_")(); !strings.HasPrefix(b, "legacy_") || len(b) != 39 {
+		t.Errorf("got boundary %q", b)
+	}
+}
+
+func TestEncodeParam(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"report.pdf", `filename="report.pdf"`},
+		{`my "file".txt`, `filename="my \"file\".txt"`},
+		{"résumé.pdf", "filename*=UTF-8''r%C3%A9sum%C3%A9.pdf"},
+		{strings.Repeat("a", 70), `filename*0="` + strings.Repeat("a", 60) + "\";\r\n filename*1=\"aaaaaaaaaa\""},
+		{strings.Repeat("é", 12), "filename*0*=UTF-8''%C3%A9%C3%A9%C3%A9%C3%A9%C3%A9%C3%A9%C3%A9%C3%A9;\r\n filename*1*=%C3%A9%C3%A9%C3%A9%C3%A9"},
+	}
+
+	for _, test := range tests {
+		if got := EncodeParam("filename", test.value, "utf-8"); got != test.want {
+			t.Errorf("EncodeParam(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	if got, want := Transliterate("Résumé Straße 日本.pdf"), "Resume Strasse __.pdf"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPreambleEpilogue(t *testing.T) {
+	var buf bytes.Buffer
+	n := NewNestedWriter(&buf)
+	n.BoundaryFunc = SequenceBoundary("b")
+	n.Preamble = "This is a multi-part message in MIME format."
+	n.Epilogue = "End"
+
+	n.Open("mixed")
+	n.Open("alternative")
+	n.CreatePart(nil)
+	n.Close()
+	n.Close()
+
+	want := "This is a multi-part message in MIME format.\r\n" +
+		"--b1\r\nContent-Type: multipart/alternative;\n \tboundary=b2\r\n\r\n" +
+		"--b2\r\n\r\n\r\n--b2--\r\n" +
+		"\r\n--b1--\r\nEnd\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
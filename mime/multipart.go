@@ -0,0 +1,141 @@
+package mime
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NestedWriter writes nested multipart bodies. Each call to Open starts a new
+// multipart inside the current one, and Close ends the innermost multipart.
+type NestedWriter struct {
+	// BoundaryFunc, if set, generates the boundaries of the multiparts.
+	// Boundaries must be 1 to 70 characters long and only contain the
+	// characters allowed by RFC 2046. By default random boundaries are used.
+	BoundaryFunc func() string
+	// Preamble and Epilogue, if set, are written before the first and
+	// after the last boundary of the outermost multipart. They are ignored
+	// by the MIME readers, but some archive validators expect them.
+	Preamble string
+	Epilogue string
+
+	w       io.Writer
+	writers []*multipart.Writer
+}
+
+// NewNestedWriter returns a nested multipart writer that writes to w.
+func NewNestedWriter(w io.Writer) *NestedWriter {
+	return &NestedWriter{w: w}
+}
+
+// Open starts a new multipart of the given subtype (mixed, related,
+// alternative...) and returns its Content-Type header value. If there is an
+// open multipart, the new one is written as a part of it, otherwise the
+// returned Content-Type must be used in the message headers.
+func (n *NestedWriter) Open(subtype string) (string, error) {
+	writer := multipart.NewWriter(n.w)
+	if n.BoundaryFunc != nil {
+		if err := writer.SetBoundary(n.BoundaryFunc()); err != nil {
+			return "", err
+		}
+	}
+
+	contentType := "multipart/" + subtype + ";\n \tboundary=" + writer.Boundary()
+
+	if len(n.writers) == 0 && n.Preamble != "" {
+		if _, err := io.WriteString(n.w, withCRLF(n.Preamble)); err != nil {
+			return "", err
+		}
+	}
+
+	if len(n.writers) > 0 {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", contentType)
+		if _, err := n.writers[len(n.writers)-1].CreatePart(header); err != nil {
+			return "", err
+		}
+	}
+
+	n.writers = append(n.writers, writer)
+
+	return contentType, nil
+}
+
+// CreatePart creates a new part with the given header in the innermost
+// multipart and returns the writer for its body.
+func (n *NestedWriter) CreatePart(header textproto.MIMEHeader) (io.Writer, error) {
+	if len(n.writers) == 0 {
+		return nil, errors.New("mime: no open multipart")
+	}
+
+	return n.writers[len(n.writers)-1].CreatePart(header)
+}
+
+// Close ends the innermost multipart. It does nothing if there is no open multipart.
+func (n *NestedWriter) Close() error {
+	if len(n.writers) == 0 {
+		return nil
+	}
+
+	err := n.writers[len(n.writers)-1].Close()
+	n.writers = n.writers[:len(n.writers)-1]
+
+	if err == nil && len(n.writers) == 0 && n.Epilogue != "" {
+		_, err = io.WriteString(n.w, withCRLF(n.Epilogue))
+	}
+
+	return err
+}
+
+// withCRLF returns the text ending with a line break
+func withCRLF(text string) string {
+	if strings.HasSuffix(text, "\n") {
+		return text
+	}
+
+	return text + "\r\n"
+}
+
+// Depth returns the number of open multiparts
+func (n *NestedWriter) Depth() int {
+	return len(n.writers)
+}
+
+// PrefixBoundary returns a boundary generator that adds a random suffix to
+// the given prefix. The prefix must be at most 38 characters long.
+func PrefixBoundary(prefix string) func() string {
+	return func() string {
+		var buf [16]byte
+		if _, err := io.ReadFull(rand.Reader, buf[:]); err != nil {
+			panic(err)
+		}
+
+		return prefix + hex.EncodeToString(buf[:])
+	}
+}
+
+// SequenceBoundary returns a boundary generator that adds a sequence number
+// to the given prefix, useful to get deterministic messages in tests. The
+// sequence continues across the messages built with the same generator, so
+// the boundaries are the same only with a new generator for every build.
+func SequenceBoundary(prefix string) func() string {
+	var (
+		mu sync.Mutex
+		n  int
+	)
+
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		n++
+
+		return prefix + strconv.Itoa(n)
+	}
+}
@@ -0,0 +1,131 @@
+package mime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxParamLength is the maximum length of each section of a parameter
+// value split with RFC 2231 continuations
+const maxParamLength = 60
+
+// EncodeParam encodes a Content-Type or Content-Disposition parameter as
+// specified by RFC 2231. Printable ASCII values are quoted, other values are
+// percent-encoded with the given charset, and long values are split in
+// several numbered sections separated by ";\r\n ".
+func EncodeParam(name, value, charset string) string {
+	if isASCIIPrintable(value) {
+		if len(value) <= maxParamLength {
+			return name + "=" + quote(value)
+		}
+
+		var sections []string
+		for i, s := range split(value, maxParamLength) {
+			sections = append(sections, name+"*"+strconv.Itoa(i)+"="+quote(s))
+		}
+
+		return strings.Join(sections, ";\r\n ")
+	}
+
+	prefix := strings.ToUpper(charset) + "''"
+	if encoded := prefix + percentEncode(value); len(encoded) <= maxParamLength {
+		return name + "*=" + encoded
+	}
+
+	// split between characters, each section is decoded on its own by some clients
+	var sections []string
+	section := prefix
+	for _, r := range value {
+		c := percentEncode(string(r))
+		if len(section)+len(c) > maxParamLength {
+			sections = append(sections, name+"*"+strconv.Itoa(len(sections))+"*="+section)
+			section = ""
+		}
+		section += c
+	}
+	sections = append(sections, name+"*"+strconv.Itoa(len(sections))+"*="+section)
+
+	return strings.Join(sections, ";\r\n ")
+}
+
+// quote returns s as a quoted-string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// isAttrChar returns true if c doesn't need percent encoding in an
+// extended parameter value (RFC 2231 attribute-char)
+func isAttrChar(c byte) bool {
+	return isVchar(c) && !strings.ContainsRune(`*'%()<>@,;:\"/[]?=`, rune(c))
+}
+
+func isASCIIPrintable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isVchar(s[i]) && s[i] != ' ' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func percentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if isAttrChar(s[i]) {
+			b.WriteByte(s[i])
+		} else {
+			fmt.Fprintf(&b, "%%%02X", s[i])
+		}
+	}
+
+	return b.String()
+}
+
+// split splits s in sections of up to n bytes
+func split(s string, n int) []string {
+	var sections []string
+	for len(s) > n {
+		sections = append(sections, s[:n])
+		s = s[n:]
+	}
+
+	return append(sections, s)
+}
+
+// transliterations are ASCII replacements of common non-ASCII letters
+var transliterations = map[rune]string{
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Æ': "AE",
+	'Ç': "C", 'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ì': "I", 'Í': "I",
+	'Î': "I", 'Ï': "I", 'Ð': "D", 'Ñ': "N", 'Ò': "O", 'Ó': "O", 'Ô': "O",
+	'Õ': "O", 'Ö': "O", 'Ø': "O", 'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U",
+	'Ý': "Y", 'Þ': "TH", 'ß': "ss", 'à': "a", 'á': "a", 'â': "a", 'ã': "a",
+	'ä': "a", 'å': "a", 'æ': "ae", 'ç': "c", 'è': "e", 'é': "e", 'ê': "e",
+	'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ð': "d", 'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ù': "u",
+	'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'þ': "th", 'ÿ': "y",
+	'Ł': "L", 'ł': "l", 'Œ': "OE", 'œ': "oe", 'Š': "S", 'š': "s",
+	'Ž': "Z", 'ž': "z", 'Č': "C", 'č': "c", 'Ř': "R", 'ř': "r",
+	'Ğ': "G", 'ğ': "g", 'İ': "I", 'ı': "i", 'Ş': "S", 'ş': "s",
+}
+
+// Transliterate returns an ASCII version of s, replacing common accented
+// letters with their base letters and any other non-ASCII character with
+// "_". It's used as the fallback for clients that don't support RFC 2231.
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf && (isVchar(byte(r)) || r == ' '):
+			b.WriteRune(r)
+		case transliterations[r] != "":
+			b.WriteString(transliterations[r])
+		default:
+			b.WriteByte('_')
+		}
+	}
+
+	return b.String()
+}
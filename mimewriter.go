@@ -0,0 +1,147 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+)
+
+// MIMEWriter writes an email message incrementally to an io.Writer, for
+// messages with parts generated on the fly that can't be held in an Email.
+// The message is always a multipart/mixed message. Only the headers of the
+// email are used, its parts and attachments are ignored, and filters are
+// not applied.
+//
+//	mw := email.NewMIMEWriter(w)
+//	mw.AddTextPart(mail.TextPlain, strings.NewReader("See the attached report"))
+//	mw.AddAttachmentStream(report, "report.csv", "text/csv")
+//	err := mw.Close()
+type MIMEWriter struct {
+	email         *Email
+	w             io.Writer
+	writer        *multipart.Writer
+	headerWritten bool
+	closed        bool
+}
+
+// NewMIMEWriter returns a writer of the email message to w.
+func (email *Email) NewMIMEWriter(w io.Writer) *MIMEWriter {
+	return &MIMEWriter{
+		email:  email,
+		w:      w,
+		writer: multipart.NewWriter(w),
+	}
+}
+
+// WriteHeader writes the email headers. It's called by the first Add call
+// if it was not called before.
+func (mw *MIMEWriter) WriteHeader() error {
+	if mw.closed {
+		return errors.New("Mail Error: MIME writer is closed")
+	}
+
+	if mw.headerWritten {
+		return errors.New("Mail Error: MIME header already written")
+	}
+
+	if mw.email.Error != nil {
+		return mw.email.Error
+	}
+
+	if mw.email.boundary != nil {
+		if err := mw.writer.SetBoundary(mw.email.boundary()); err != nil {
+			return errors.New("Mail Error: Invalid multipart boundary: " + err.Error())
+		}
+	}
+
+	// copy the headers so the email is not modified
+	headers := make(textproto.MIMEHeader, len(mw.email.headers))
+	for header, values := range mw.email.headers {
+		headers[header] = values
+	}
+	headers.Set("Content-Type", "multipart/mixed;\n \tboundary="+mw.writer.Boundary())
+
+	msg := &message{headers: headers, charset: mw.email.Charset, sevenBit: mw.email.sevenBit}
+
+	mw.headerWritten = true
+
+	_, err := io.WriteString(mw.w, msg.getHeaders())
+
+	return err
+}
+
+// AddTextPart adds a body part with the content read from r, encoded with
+// the email charset and encoding.
+func (mw *MIMEWriter) AddTextPart(contentType contentType, r io.Reader) error {
+	encoding := mw.email.Encoding
+	if mw.email.sevenBit && encoding == EncodingNone {
+		encoding = EncodingQuotedPrintable
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", contentType.string()+"; charset="+mw.email.Charset)
+	header.Set("Content-Transfer-Encoding", encoding.string())
+
+	return mw.addPart(header, r, encoding.encoder(), EncoderOptions{Size: -1})
+}
+
+// AddAttachmentStream adds an attachment with the content read from r.
+func (mw *MIMEWriter) AddAttachmentStream(r io.Reader, filename, mimeType string) error {
+	f := &file{filename: filename, mimeType: mimeType}
+	if f.mimeType == "" {
+		f.mimeType = mimeTypeByName(filename)
+	}
+
+	msg := &message{charset: mw.email.Charset, cids: make(map[string]string), profile: mw.email.profile}
+
+	return mw.addPart(msg.fileHeader(f, false), r, f.encoder(), EncoderOptions{Filename: filename, Size: -1})
+}
+
+// addPart writes a part with the content of r encoded
+func (mw *MIMEWriter) addPart(header textproto.MIMEHeader, r io.Reader, encoder Encoder, options EncoderOptions) error {
+	if !mw.headerWritten {
+		if err := mw.WriteHeader(); err != nil {
+			return err
+		}
+	}
+
+	if mw.closed {
+		return errors.New("Mail Error: MIME writer is closed")
+	}
+
+	pw, err := mw.writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	if encoder == nil {
+		_, err = io.Copy(pw, r)
+		return err
+	}
+
+	options.LineLength = mw.email.profile.Base64LineLength
+	w := encoder.NewWriter(pw, options)
+	if _, err = io.Copy(w, r); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// Close writes the end of the message. It doesn't close the underlying writer.
+func (mw *MIMEWriter) Close() error {
+	if mw.closed {
+		return nil
+	}
+
+	if !mw.headerWritten {
+		if err := mw.WriteHeader(); err != nil {
+			return err
+		}
+	}
+
+	mw.closed = true
+
+	return mw.writer.Close()
+}
@@ -0,0 +1,100 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	mailmime "github.com/xhit/go-simple-mail/v2/mime"
+)
+
+func TestMIMEWriter(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetSubject("Report")
+
+	var buf bytes.Buffer
+	mw := email.NewMIMEWriter(&buf)
+
+	if err := mw.AddTextPart(TextPlain, strings.NewReader("See the attached report")); err != nil {
+		t.Fatalf("AddTextPart: %v", err)
+	}
+	if err := mw.AddAttachmentStream(strings.NewReader("a,b\n1,2\n"), "report.csv", ""); err != nil {
+		t.Fatalf("AddAttachmentStream: %v", err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+
+	if got := msg.Header.Get("Subject"); got != "Report" {
+		t.Errorf("got Subject %q, want Report", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("got Content-Type %q: %v", msg.Header.Get("Content-Type"), err)
+	}
+
+	r := multipart.NewReader(msg.Body, params["boundary"])
+
+	var parts []string
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		data, _ := ioutil.ReadAll(p)
+		if p.Header.Get("Content-Transfer-Encoding") == "base64" {
+			data, _ = base64.StdEncoding.DecodeString(string(data))
+		}
+		parts = append(parts, string(data))
+		if p.FileName() != "" && p.FileName() != "report.csv" {
+			t.Errorf("got filename %q, want report.csv", p.FileName())
+		}
+	}
+
+	if len(parts) != 2 || parts[0] != "See the attached report" || parts[1] != "a,b\n1,2\n" {
+		t.Errorf("got parts %q", parts)
+	}
+
+	if err := mw.AddTextPart(TextPlain, strings.NewReader("late")); err == nil {
+		t.Error("expected error adding a part after Close")
+	}
+}
+
+func TestBoundaryFunc(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAlternative(TextHTML, "<p>Hello</p>").
+		AddAttachmentData([]byte("data"), "file.txt", "").
+		SetBoundaryFunc(mailmime.SequenceBoundary("boundary-"))
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+
+	for _, want := range []string{"boundary=boundary-1", "boundary=boundary-2", "--boundary-2--", "--boundary-1--"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("%q not found in message:\n%s", want, msg)
+		}
+	}
+
+	email.SetBoundaryFunc(func() string { return "" })
+	if msg := email.GetMessage(); msg != "" || email.Error == nil {
+		t.Error("expected error with invalid boundary")
+	}
+}
@@ -0,0 +1,208 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ntlmAuth implements the NTLM authentication of Exchange servers, with
+// NTLMv2 responses (MS-NLMP). The username can be "DOMAIN\user" or
+// "user@domain".
+type ntlmAuth struct {
+	domain, username, password string
+	// now and clientChallenge are fixed by the tests
+	now             func() time.Time
+	clientChallenge []byte
+}
+
+// NTLM negotiate flags
+const (
+	ntlmUnicode          = 0x00000001
+	ntlmRequestTarget    = 0x00000004
+	ntlmNTLM             = 0x00000200
+	ntlmAlwaysSign       = 0x00008000
+	ntlmExtendedSecurity = 0x00080000
+	ntlmTargetInfo       = 0x00800000
+	ntlm128              = 0x20000000
+	ntlm56               = 0x80000000
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+func newNTLMAuth(username, password string) *ntlmAuth {
+	a := &ntlmAuth{username: username, password: password, now: time.Now}
+	if i := strings.Index(username, "\\"); i >= 0 {
+		a.domain, a.username = username[:i], username[i+1:]
+	}
+
+	return a
+}
+
+func (a *ntlmAuth) Start(server *ServerInfo) (string, []byte, error) {
+	// the negotiate message, without domain and workstation
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmUnicode|ntlmRequestTarget|ntlmNTLM|ntlmAlwaysSign|
+		ntlmExtendedSecurity|ntlmTargetInfo|ntlm128|ntlm56)
+
+	return "NTLM", msg, nil
+}
+
+func (a *ntlmAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	// the challenge message
+	if len(fromServer) < 32 || !bytes.Equal(fromServer[:8], ntlmSignature) || binary.LittleEndian.Uint32(fromServer[8:]) != 2 {
+		return nil, errors.New("invalid NTLM challenge")
+	}
+	flags := binary.LittleEndian.Uint32(fromServer[20:])
+	serverChallenge := fromServer[24:32]
+
+	var targetInfo []byte
+	if len(fromServer) >= 48 {
+		var err error
+		if targetInfo, err = ntlmField(fromServer, 40); err != nil {
+			return nil, err
+		}
+	}
+
+	clientChallenge := a.clientChallenge
+	if clientChallenge == nil {
+		clientChallenge = make([]byte, 8)
+		rand.Read(clientChallenge)
+	}
+
+	key := ntlmV2Key(a.domain, a.username, a.password)
+
+	// the NTLMv2 client challenge, with the time in 100ns since 1601
+	temp := make([]byte, 28, 28+len(targetInfo)+4)
+	temp[0], temp[1] = 1, 1
+	now := a.now()
+	binary.LittleEndian.PutUint64(temp[8:], uint64(now.Unix()+11644473600)*1e7+uint64(now.Nanosecond()/100))
+	copy(temp[16:], clientChallenge)
+	temp = append(append(temp, targetInfo...), 0, 0, 0, 0)
+
+	ntProof := ntlmHMAC(key, serverChallenge, temp)
+	ntResponse := append(ntProof, temp...)
+	lmResponse := append(ntlmHMAC(key, serverChallenge, clientChallenge), clientChallenge...)
+
+	encode := func(s string) []byte {
+		if flags&ntlmUnicode == 0 {
+			return []byte(s)
+		}
+		return utf16le(s)
+	}
+
+	return ntlmAuthenticate(flags, lmResponse, ntResponse, encode(a.domain), encode(a.username)), nil
+}
+
+// ntlmAuthenticate returns the authenticate message with the payloads
+func ntlmAuthenticate(flags uint32, payloads ...[]byte) []byte {
+	// lm, nt, domain, user, workstation and session key fields
+	const headerSize = 64
+
+	msg := make([]byte, headerSize)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+
+	payloads = append(payloads, nil, nil)
+	for i, payload := range payloads {
+		field := msg[12+8*i:]
+		binary.LittleEndian.PutUint16(field, uint16(len(payload)))
+		binary.LittleEndian.PutUint16(field[2:], uint16(len(payload)))
+		binary.LittleEndian.PutUint32(field[4:], uint32(len(msg)))
+		msg = append(msg, payload...)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags&^ntlmTargetInfo)
+
+	return msg
+}
+
+// ntlmField returns the payload of the field at offset of a message
+func ntlmField(msg []byte, offset int) ([]byte, error) {
+	size := int(binary.LittleEndian.Uint16(msg[offset:]))
+	start := int(binary.LittleEndian.Uint32(msg[offset+4:]))
+	if start+size > len(msg) || start < 0 {
+		return nil, errors.New("invalid NTLM challenge field")
+	}
+
+	return msg[start : start+size], nil
+}
+
+// ntlmV2Key returns the NTOWFv2 key of the credentials
+func ntlmV2Key(domain, username, password string) []byte {
+	hash := md4(utf16le(password))
+	return ntlmHMAC(hash, utf16le(strings.ToUpper(username)+domain))
+}
+
+func ntlmHMAC(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+func utf16le(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// md4 returns the MD4 hash (RFC 1320) of the data, only used by NTLM
+func md4(data []byte) []byte {
+	s := [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+
+	// pad to 56 bytes modulo 64, then the length in bits
+	msg := append(append([]byte(nil), data...), 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	length := make([]byte, 8)
+	binary.LittleEndian.PutUint64(length, uint64(len(data))*8)
+	msg = append(msg, length...)
+
+	var x [16]uint32
+	for block := 0; block < len(msg); block += 64 {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(msg[block+4*i:])
+		}
+		a, b, c, d := s[0], s[1], s[2], s[3]
+
+		for i := 0; i < 16; i++ {
+			f := (b & c) | (^b & d)
+			a, b, c, d = d, bits.RotateLeft32(a+f+x[i], [4]int{3, 7, 11, 19}[i%4]), b, c
+		}
+		for n, i := range [16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15} {
+			g := (b & c) | (b & d) | (c & d)
+			a, b, c, d = d, bits.RotateLeft32(a+g+x[i]+0x5a827999, [4]int{3, 5, 9, 13}[n%4]), b, c
+		}
+		for n, i := range [16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15} {
+			h := b ^ c ^ d
+			a, b, c, d = d, bits.RotateLeft32(a+h+x[i]+0x6ed9eba1, [4]int{3, 9, 11, 15}[n%4]), b, c
+		}
+
+		s[0], s[1], s[2], s[3] = s[0]+a, s[1]+b, s[2]+c, s[3]+d
+	}
+
+	sum := make([]byte, 16)
+	for i, v := range s {
+		binary.LittleEndian.PutUint32(sum[4*i:], v)
+	}
+
+	return sum
+}
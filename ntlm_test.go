@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestMD4(t *testing.T) {
+	tests := map[string]string{
+		"":    "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"abc": "a448017aaf21d8525fc10ae87aa6729d",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	}
+
+	for data, want := range tests {
+		if got := hex.EncodeToString(md4([]byte(data))); got != want {
+			t.Errorf("md4(%q) = %s, want %s", data, got, want)
+		}
+	}
+}
+
+func TestNTLM(t *testing.T) {
+	// MS-NLMP 4.2.4
+	targetInfo, _ := hex.DecodeString("02000c0044006f006d00610069006e0001000c00530065007200760065007200" + "00000000")
+
+	challenge := make([]byte, 48, 48+len(targetInfo))
+	copy(challenge, ntlmSignature)
+	binary.LittleEndian.PutUint32(challenge[8:], 2)
+	binary.LittleEndian.PutUint32(challenge[20:], ntlmUnicode|ntlmNTLM|ntlmTargetInfo)
+	copy(challenge[24:], []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef})
+	binary.LittleEndian.PutUint16(challenge[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(challenge[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(challenge[44:], 48)
+	challenge = append(challenge, targetInfo...)
+
+	server := &SMTPServer{Username: `Domain\User`, Password: "Password", Authentication: AuthNTLM}
+	a, err := server.auth("servername", []string{"NTLM"})
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	ntlm := a.(*ntlmAuth)
+	ntlm.now = func() time.Time { return time.Unix(-11644473600, 0) }
+	ntlm.clientChallenge = bytes.Repeat([]byte{0xaa}, 8)
+
+	name, negotiate, err := a.Start(&ServerInfo{Name: "servername"})
+	if err != nil || name != "NTLM" || !bytes.HasPrefix(negotiate, ntlmSignature) {
+		t.Fatalf("got %s %x %v", name, negotiate, err)
+	}
+
+	msg, err := a.Next(challenge, true)
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+
+	lm, _ := ntlmField(msg, 12)
+	nt, _ := ntlmField(msg, 20)
+	domain, _ := ntlmField(msg, 28)
+	user, _ := ntlmField(msg, 36)
+
+	if got, want := hex.EncodeToString(lm), "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa"; got != want {
+		t.Errorf("got LMv2 response %s, want %s", got, want)
+	}
+	if got, want := hex.EncodeToString(nt[:16]), "68cd0ab851e51c96aabc927bebef6a1c"; got != want {
+		t.Errorf("got NTProofStr %s, want %s", got, want)
+	}
+	if !bytes.Equal(domain, utf16le("Domain")) || !bytes.Equal(user, utf16le("User")) {
+		t.Errorf("got domain %q and user %q", domain, user)
+	}
+
+	if _, err = a.Next([]byte("not a challenge"), true); err == nil {
+		t.Error("got no error with an invalid challenge")
+	}
+}
@@ -0,0 +1,101 @@
+package mail
+
+import "errors"
+
+// TokenProvider returns the OAuth 2.0 access token of the XOAUTH2 and
+// OAUTHBEARER authentications. It's called for every connection, so it can
+// refresh the token when it expires.
+type TokenProvider func() (string, error)
+
+// oauthToken returns the access token of the server, from the
+// TokenProvider if it's set or the Password otherwise
+func (server *SMTPServer) oauthToken() (string, error) {
+	if server.TokenProvider == nil {
+		return server.Password, nil
+	}
+
+	token, err := server.TokenProvider()
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", errors.New("empty OAuth token")
+	}
+
+	return token, nil
+}
+
+// xoauth2Auth implements the XOAUTH2 authentication of Gmail and Office 365
+type xoauth2Auth struct {
+	username, token string
+	host            string
+	// allowInsecure allows sending the token without TLS
+	allowInsecure bool
+}
+
+func (a *xoauth2Auth) Start(server *ServerInfo) (string, []byte, error) {
+	// the token is a bearer credential, protect it like a password
+	if !server.TLS && !a.allowInsecure && !isLocalhost(server.Name) {
+		return "", nil, errInsecureAuth
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	resp := []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01")
+	return "XOAUTH2", resp, nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// the challenge is the JSON error, an empty response gets the
+		// final error reply
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// oauthBearerAuth implements the OAUTHBEARER authentication (RFC 7628)
+type oauthBearerAuth struct {
+	username, token string
+	host            string
+	// allowInsecure allows sending the token without TLS
+	allowInsecure bool
+}
+
+func (a *oauthBearerAuth) Start(server *ServerInfo) (string, []byte, error) {
+	if !server.TLS && !a.allowInsecure && !isLocalhost(server.Name) {
+		return "", nil, errInsecureAuth
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	resp := []byte("n,a=" + saslName(a.username) + ",\x01host=" + a.host + "\x01auth=Bearer " + a.token + "\x01\x01")
+	return "OAUTHBEARER", resp, nil
+}
+
+func (a *oauthBearerAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// the challenge is the JSON error, a dummy response gets the
+		// final error reply (RFC 7628 3.2.3)
+		return []byte("\x01"), nil
+	}
+	return nil, nil
+}
+
+// saslName escapes the commas and equal signs of an authorization identity
+// (RFC 5801 4)
+func saslName(name string) string {
+	var s string
+	for _, c := range name {
+		switch c {
+		case ',':
+			s += "=2C"
+		case '=':
+			s += "=3D"
+		default:
+			s += string(c)
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestOAuth(t *testing.T) {
+	calls := 0
+	server := &SMTPServer{
+		Username:       "user@example.com",
+		Authentication: AuthXOAUTH2,
+		TokenProvider: func() (string, error) {
+			calls++
+			return "token" + strconv.Itoa(calls), nil
+		},
+	}
+
+	tests := []struct {
+		mechanism authType
+		name      string
+		resp      string
+		next      string
+	}{
+		{AuthXOAUTH2, "XOAUTH2", "user=user@example.com\x01auth=Bearer token1\x01\x01", ""},
+		{AuthOAuthBearer, "OAUTHBEARER", "n,a=user@example.com,\x01host=servername\x01auth=Bearer token2\x01\x01", "\x01"},
+	}
+
+	for _, test := range tests {
+		server.Authentication = test.mechanism
+		a, err := server.auth("servername", []string{"XOAUTH2", "OAUTHBEARER"})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		name, resp, err := a.Start(&ServerInfo{Name: "servername", TLS: true})
+		if err != nil || name != test.name || string(resp) != test.resp {
+			t.Errorf("got %s %q %v, want %s %q", name, resp, err, test.name, test.resp)
+		}
+
+		// a failure challenge is answered to get the error reply
+		if resp, err = a.Next([]byte(`{"status":"401"}`), true); err != nil || string(resp) != test.next {
+			t.Errorf("%s: got challenge response %q %v, want %q", test.name, resp, err, test.next)
+		}
+
+		if _, _, err = a.Start(&ServerInfo{Name: "servername"}); err != errInsecureAuth {
+			t.Errorf("%s: got error %v without TLS, want %v", test.name, err, errInsecureAuth)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("got %d token requests, want one per authentication", calls)
+	}
+
+	server.TokenProvider = func() (string, error) { return "", errors.New("expired") }
+	if _, err := server.auth("servername", nil); err == nil {
+		t.Error("got no error when the token provider fails")
+	}
+
+	server.TokenProvider = nil
+	server.Password = "static"
+	a, _ := server.auth("servername", nil)
+	if _, resp, _ := a.Start(&ServerInfo{Name: "servername", TLS: true}); string(resp) != "n,a=user@example.com,\x01host=servername\x01auth=Bearer static\x01\x01" {
+		t.Errorf("got response %q, want the password as token", resp)
+	}
+}
+
+func TestSASLName(t *testing.T) {
+	if got := saslName("a,b=c"); got != "a=2Cb=3Dc" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,224 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"strings"
+)
+
+// ParsedMessage is a received message parsed with ParseMessage.
+type ParsedMessage struct {
+	Header textproto.MIMEHeader
+	parts  []*parsedPart
+}
+
+// parsedPart is a leaf part of a parsed message, with its content decoded
+// from the transfer encoding
+type parsedPart struct {
+	header    textproto.MIMEHeader
+	mediaType string
+	params    map[string]string
+	data      []byte
+}
+
+// ParsedAttachment is an attachment or inline file of a parsed message.
+type ParsedAttachment struct {
+	// Filename is the name of the file without directories, safe to be
+	// used as a local file name
+	Filename string
+	// ContentType is the media type declared by the sender
+	ContentType string
+	// DetectedType is the media type detected from the content, which can
+	// differ from the declared one
+	DetectedType string
+	ContentID    string
+	Inline       bool
+	Size         int
+	data         []byte
+}
+
+// Open returns a reader of the decoded content of the attachment.
+func (a *ParsedAttachment) Open() io.Reader {
+	return bytes.NewReader(a.data)
+}
+
+// ParseMessage parses a RFC 5322 message, decoding the transfer encoding
+// of its parts. The parsing is lenient, parts with invalid headers are
+// read as plain text.
+func ParseMessage(r io.Reader) (*ParsedMessage, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &ParsedMessage{Header: textproto.MIMEHeader(m.Header)}
+	if parsed.parts, err = parseParts(parsed.Header, m.Body); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// parseParts returns the leaf parts of an entity
+func parseParts(header textproto.MIMEHeader, body io.Reader) ([]*parsedPart, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		var parts []*parsedPart
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				return parts, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			leaves, err := parseParts(p.Header, p)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, leaves...)
+		}
+	}
+
+	// the multipart reader decodes quoted-printable by itself
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: body})
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*parsedPart{{header: header, mediaType: mediaType, params: params, data: data}}, nil
+}
+
+// DecodedHeader returns the value of the header of the message, with the
+// encoded words (RFC 2047) decoded to UTF-8.
+func (parsed *ParsedMessage) DecodedHeader(name string) string {
+	return decodeHeader(parsed.Header.Get(name))
+}
+
+// Body returns the content of the first body of the media type, like
+// text/plain or text/html, decoded from its charset to UTF-8. It returns
+// an empty string if the message has no body of that type.
+func (parsed *ParsedMessage) Body(mediaType string) (string, error) {
+	for _, p := range parsed.parts {
+		if p.mediaType == mediaType && !p.isAttachment() {
+			return decodeCharset(p.params["charset"], p.data)
+		}
+	}
+
+	return "", nil
+}
+
+// base64Cleaner removes the characters that aren't base64, like line
+// breaks and spaces, so the decoder accepts loosely wrapped data
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		j := 0
+		for _, b := range p[:n] {
+			if b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '+' || b == '/' || b == '=' {
+				p[j] = b
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+// disposition returns the disposition type of the part and the filename
+// given by the sender, from the Content-Disposition or the name of the
+// Content-Type
+func (p *parsedPart) disposition() (string, string) {
+	disposition, params, err := mime.ParseMediaType(p.header.Get("Content-Disposition"))
+	if err != nil {
+		disposition, params = "", map[string]string{}
+	}
+
+	filename := params["filename"]
+	if filename == "" {
+		filename = p.params["name"]
+	}
+
+	return strings.ToLower(disposition), decodeHeader(filename)
+}
+
+// isAttachment returns true if the part is a file and not a body of the
+// message
+func (p *parsedPart) isAttachment() bool {
+	disposition, filename := p.disposition()
+	if disposition == "attachment" || filename != "" {
+		return true
+	}
+
+	return !strings.HasPrefix(p.mediaType, "text/")
+}
+
+// Attachments returns the attachments and inline files of the message.
+func (parsed *ParsedMessage) Attachments() []*ParsedAttachment {
+	var attachments []*ParsedAttachment
+	for _, p := range parsed.parts {
+		if !p.isAttachment() {
+			continue
+		}
+
+		disposition, filename := p.disposition()
+		attachments = append(attachments, &ParsedAttachment{
+			Filename:     safeFilename(filename),
+			ContentType:  p.mediaType,
+			DetectedType: http.DetectContentType(p.data),
+			ContentID:    strings.Trim(p.header.Get("Content-Id"), "<> "),
+			Inline:       disposition == "inline" || disposition == "" && p.header.Get("Content-Id") != "",
+			Size:         len(p.data),
+			data:         p.data,
+		})
+	}
+
+	return attachments
+}
+
+// safeFilename removes the directories and the control characters of a
+// filename given by the sender, so it can't write outside of the
+// directory it's saved in
+func safeFilename(filename string) string {
+	filename = strings.Replace(filename, "\\", "/", -1)
+	filename = strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 {
+			return -1
+		}
+		return r
+	}, filename)
+
+	filename = strings.TrimLeft(strings.TrimSpace(path.Base(filename)), ".")
+	if filename == "" || filename == "/" {
+		return "attachment"
+	}
+
+	return filename
+}
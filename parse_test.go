@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParsedAttachments(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n%fake document\n")
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAlternative(TextHTML, `<p>Hello <img src="cid:logo.png"></p>`).
+		AddAttachmentData(pdf, "../../etc/report.pdf", "image/png").
+		AddInlineData([]byte("\x89PNG\r\n\x1a\n"), "logo.png", "image/png")
+
+	parsed, err := ParseMessage(strings.NewReader(email.GetMessage()))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+
+	attachments := parsed.Attachments()
+	if len(attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2", len(attachments))
+	}
+
+	inline, report := attachments[0], attachments[1]
+	if !inline.Inline || inline.Filename != "logo.png" || inline.ContentID == "" || inline.DetectedType != "image/png" {
+		t.Errorf("got inline %+v", inline)
+	}
+
+	if report.Inline || report.Filename != "report.pdf" || report.ContentType != "image/png" || report.DetectedType != "application/pdf" {
+		t.Errorf("got attachment %+v", report)
+	}
+	if data, _ := ioutil.ReadAll(report.Open()); string(data) != string(pdf) || report.Size != len(pdf) {
+		t.Errorf("got content %q", data)
+	}
+}
+
+func TestSafeFilename(t *testing.T) {
+	tests := map[string]string{
+		"report.pdf":                "report.pdf",
+		"../../etc/passwd":          "passwd",
+		`C:\Windows\system32\a.dll`: "a.dll",
+		"..":                        "attachment",
+		"":                          "attachment",
+		"dir/":                      "dir",
+		"a\x00b\r\n.txt":            "ab.txt",
+	}
+
+	for name, want := range tests {
+		if got := safeFilename(name); got != want {
+			t.Errorf("safeFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package mail
+
+import (
+	"errors"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SplitMessage builds the email and splits it in message/partial messages
+// (RFC 2046) of at most maxSize bytes each, for gateways with hard size
+// limits. All the parts have the id of the original message, their number
+// and the total of parts, so the receiver can reassemble them, and can be
+// sent with SendMessage. The message is split at line boundaries.
+func (email *Email) SplitMessage(maxSize int) ([]string, error) {
+	filtered, err := email.applyFilters(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := filtered.buildMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	header, body := splitMessage(message)
+
+	// the enclosed header has the fields that describe the content, the
+	// rest of the fields are copied to the header of each part
+	var outer, enclosed string
+	for _, field := range headerFields(header) {
+		if isEnclosedField(fieldName(field)) {
+			enclosed += field
+		} else {
+			outer += field
+		}
+	}
+	enclosed += "\r\n" + body
+
+	subject := ""
+	for _, field := range headerFields(header) {
+		if strings.EqualFold(fieldName(field), "Subject") {
+			subject = field
+		}
+	}
+
+	id := strings.Trim(getField(header, "Message-Id"), "<>")
+	msg := newMessage(filtered)
+
+	partHeader := func(number, total int) string {
+		return outer + subject +
+			"Message-Id: " + msg.messageID() + "\r\n" +
+			"Mime-Version: 1.0\r\n" +
+			"Content-Type: message/partial; id=\"" + id + "\"; number=" + strconv.Itoa(number) +
+			"; total=" + strconv.Itoa(total) + "\r\n\r\n"
+	}
+
+	// the biggest header, with as many parts as bytes
+	size := maxSize - len(partHeader(len(enclosed), len(enclosed)))
+
+	var chunks []string
+	for len(enclosed) > 0 {
+		n := 0
+		for n < len(enclosed) {
+			end := strings.Index(enclosed[n:], "\r\n")
+			if end < 0 {
+				end = len(enclosed) - n
+			} else {
+				end += 2
+			}
+			if n+end > size {
+				break
+			}
+			n += end
+		}
+
+		if n == 0 {
+			return nil, errors.New("Mail Error: The message has lines longer than the part size; Size: [" + strconv.Itoa(maxSize) + "]")
+		}
+
+		chunks = append(chunks, enclosed[:n])
+		enclosed = enclosed[n:]
+	}
+
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = partHeader(i+1, len(chunks)) + chunk
+	}
+
+	return parts, nil
+}
+
+// JoinPartialMessages reassembles the message/partial messages (RFC 2046)
+// of a message, in any order. The header of the message has the fields of
+// the first part, with the content fields, the Subject and the Message-Id
+// of the original message.
+func JoinPartialMessages(parts []string) (string, error) {
+	type partial struct {
+		number int
+		header string
+		body   string
+	}
+
+	var partials []partial
+	var id string
+	total := 0
+
+	for _, message := range parts {
+		header, body := splitMessage(message)
+
+		mediaType, params, err := mime.ParseMediaType(getField(header, "Content-Type"))
+		if err != nil || mediaType != "message/partial" {
+			return "", errors.New("Mail Error: Not a message/partial message")
+		}
+
+		number, err := strconv.Atoi(params["number"])
+		if err != nil || number < 1 {
+			return "", errors.New("Mail Error: Invalid partial message number; Number: [" + params["number"] + "]")
+		}
+
+		if id == "" {
+			id = params["id"]
+		} else if params["id"] != id {
+			return "", errors.New("Mail Error: Partial messages with different ids; Id: [" + params["id"] + "]")
+		}
+
+		if params["total"] != "" {
+			if total, err = strconv.Atoi(params["total"]); err != nil {
+				return "", errors.New("Mail Error: Invalid partial message total; Total: [" + params["total"] + "]")
+			}
+		}
+
+		partials = append(partials, partial{number: number, header: header, body: body})
+	}
+
+	sort.Slice(partials, func(i, j int) bool { return partials[i].number < partials[j].number })
+
+	if total == 0 || len(partials) != total {
+		return "", errors.New("Mail Error: Missing partial messages; Id: [" + id + "]")
+	}
+	for i, p := range partials {
+		if p.number != i+1 {
+			return "", errors.New("Mail Error: Missing partial messages; Id: [" + id + "]")
+		}
+	}
+
+	var enclosed string
+	for _, p := range partials {
+		enclosed += p.body
+	}
+
+	var message string
+	for _, field := range headerFields(partials[0].header) {
+		if !isEnclosedField(fieldName(field)) {
+			message += field
+		}
+	}
+
+	// the other fields of the enclosed header are dropped
+	header, body := splitMessage(enclosed)
+	for _, field := range headerFields(header) {
+		if isEnclosedField(fieldName(field)) {
+			message += field
+		}
+	}
+
+	return message + "\r\n" + body, nil
+}
+
+// splitMessage returns the header, with the CRLF of the last field, and
+// the body of a message
+func splitMessage(message string) (string, string) {
+	if i := strings.Index(message, "\r\n\r\n"); i >= 0 {
+		return message[:i+2], message[i+4:]
+	}
+
+	return message, ""
+}
+
+// headerFields returns the fields of a header, with their folded lines
+func headerFields(header string) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if len(fields) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+
+	return fields
+}
+
+// fieldName returns the name of a header field
+func fieldName(field string) string {
+	if i := strings.Index(field, ":"); i >= 0 {
+		return strings.TrimSpace(field[:i])
+	}
+
+	return ""
+}
+
+// getField returns the unfolded value of the first header field with the
+// name
+func getField(header, name string) string {
+	for _, field := range headerFields(header) {
+		if strings.EqualFold(fieldName(field), name) {
+			value := field[strings.Index(field, ":")+1:]
+			return strings.TrimSpace(strings.NewReplacer("\r\n", "", "\n", "").Replace(value))
+		}
+	}
+
+	return ""
+}
+
+// isEnclosedField returns true if the field belongs to the header of the
+// enclosed message of a message/partial message
+func isEnclosedField(name string) bool {
+	name = strings.ToLower(name)
+
+	return strings.HasPrefix(name, "content-") || name == "subject" || name == "message-id" ||
+		name == "encrypted" || name == "mime-version"
+}
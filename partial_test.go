@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetSubject("Report").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte(strings.Repeat("0123456789", 500)), "report.txt", "text/plain")
+
+	parts, err := email.SplitMessage(1000)
+	if err != nil {
+		t.Fatalf("SplitMessage: %v", err)
+	}
+	if len(parts) < 2 {
+		t.Fatalf("got %d parts, want several", len(parts))
+	}
+
+	_, params, _ := mime.ParseMediaType(getField(parts[0], "Content-Type"))
+	id := params["id"]
+	for i, part := range parts {
+		if len(part) > 1000 {
+			t.Errorf("part %d has %d bytes, want at most 1000", i+1, len(part))
+		}
+		header, _ := splitMessage(part)
+		if getField(header, "To") != "<to@example.com>" || getField(header, "Subject") != "Report" {
+			t.Errorf("part %d header lacks To or Subject:\n%s", i+1, header)
+		}
+		want := "; number=" + strconv.Itoa(i+1) + "; total=" + strconv.Itoa(len(parts))
+		if ct := getField(header, "Content-Type"); !strings.HasPrefix(ct, "message/partial; id=") || !strings.HasSuffix(ct, want) {
+			t.Errorf("part %d has Content-Type %s", i+1, ct)
+		}
+		if strings.Trim(getField(header, "Message-Id"), "<>") == id {
+			t.Errorf("part %d has the Message-Id of the original message", i+1)
+		}
+	}
+
+	// reassemble in reverse order
+	reversed := make([]string, len(parts))
+	for i, part := range parts {
+		reversed[len(parts)-1-i] = part
+	}
+
+	joined, err := JoinPartialMessages(reversed)
+	if err != nil {
+		t.Fatalf("JoinPartialMessages: %v", err)
+	}
+
+	header, body := splitMessage(joined)
+	if ct := getField(header, "Content-Type"); !strings.HasPrefix(ct, "multipart/mixed;") {
+		t.Errorf("got Content-Type %s, want multipart/mixed", ct)
+	}
+	if getField(header, "From") != "<from@example.com>" || getField(header, "Subject") != "Report" {
+		t.Errorf("reassembled header lacks From or Subject:\n%s", header)
+	}
+	if got := getField(header, "Message-Id"); got != "<"+id+">" {
+		t.Errorf("got Message-Id %s, want <%s>", got, id)
+	}
+	if !strings.HasSuffix(body, "--\r\n") {
+		t.Errorf("reassembled body isn't complete:\n%s", body)
+	}
+	if err := checkMessage(email, joined); err != nil {
+		t.Errorf("reassembled message: %v", err)
+	}
+}
+
+func TestJoinPartialMessagesMissing(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, strings.Repeat("line of text\r\n", 200))
+
+	parts, err := email.SplitMessage(800)
+	if err != nil {
+		t.Fatalf("SplitMessage: %v", err)
+	}
+
+	if _, err := JoinPartialMessages(parts[1:]); err == nil {
+		t.Error("got no error with the first part missing")
+	}
+
+	if _, err := email.SplitMessage(100); err == nil {
+		t.Error("got no error with a part size smaller than the header")
+	}
+}
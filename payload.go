@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"errors"
+	"mime"
+	"strings"
+)
+
+// AddXMLAttachment attaches an XML document, like an electronic invoice,
+// with the Content-Type `application/xml; charset=UTF-8; name="filename"`.
+// The document is attached as is, in base64, without transcoding it or
+// changing its line endings, so its signature stays valid.
+func (email *Email) AddXMLAttachment(data []byte, filename string) *Email {
+	return email.AddAttachmentExact(data,
+		`application/xml; charset=UTF-8; name="`+escapeQuotes(filename)+`"`,
+		`attachment; filename="`+escapeQuotes(filename)+`"`)
+}
+
+// AddEDIAttachment attaches an EDIFACT interchange with the Content-Type
+// `application/EDIFACT; name="filename"` (RFC 1767), as is in base64.
+func (email *Email) AddEDIAttachment(data []byte, filename string) *Email {
+	return email.AddAttachmentExact(data,
+		`application/EDIFACT; name="`+escapeQuotes(filename)+`"`,
+		`attachment; filename="`+escapeQuotes(filename)+`"`)
+}
+
+// AddAttachmentExact attaches data with the given Content-Type and
+// Content-Disposition, written exactly as given, for the gateways that
+// reject the messages with other parameters, order or quoting. The profile
+// doesn't change the headers of the attachment, and the data is attached
+// as is in base64. An empty disposition is "attachment".
+func (email *Email) AddAttachmentExact(data []byte, contentType, disposition string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	if disposition == "" {
+		disposition = "attachment"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || strings.ContainsAny(contentType, "\r\n") {
+		email.Error = errors.New("Mail Error: Invalid attachment Content-Type: " + contentType)
+		return email
+	}
+
+	_, dispositionParams, err := mime.ParseMediaType(disposition)
+	if err != nil || strings.ContainsAny(disposition, "\r\n") {
+		email.Error = errors.New("Mail Error: Invalid attachment Content-Disposition: " + disposition)
+		return email
+	}
+
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+
+	email.attachments = append(email.attachments, &file{
+		filename:    filename,
+		mimeType:    mediaType,
+		data:        data,
+		contentType: contentType,
+		disposition: disposition,
+	})
+
+	return email
+}
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExactAttachments(t *testing.T) {
+	xml := []byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<Factura>Año</Factura>\n")
+	edi := []byte("UNA:+.? 'UNB+UNOC:3+SENDER+RECEIVER+210203:0405+1'")
+
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Invoice attached").
+		SetProfile(Profile{RFC2231Params: true, AttachmentID: true}).
+		AddXMLAttachment(xml, "invoice.xml").
+		AddEDIAttachment(edi, "order.edi")
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	for _, header := range []string{
+		"Content-Type: application/xml; charset=UTF-8; name=\"invoice.xml\"\r\n",
+		"Content-Disposition: attachment; filename=\"invoice.xml\"\r\n",
+		"Content-Type: application/EDIFACT; name=\"order.edi\"\r\n",
+	} {
+		if !strings.Contains(msg, header) {
+			t.Errorf("header %q not found in message:\n%s", header, msg)
+		}
+	}
+	if strings.Contains(msg, "X-Attachment-Id") {
+		t.Error("the profile changed the headers of the attachments")
+	}
+
+	if err := checkMessage(email, msg); err != nil {
+		t.Errorf("checkMessage: %v", err)
+	}
+	if f := email.attachments[0]; f.filename != "invoice.xml" || f.mimeType != "application/xml" || !bytes.Equal(f.data, xml) {
+		t.Errorf("got attachment %q %q", f.filename, f.mimeType)
+	}
+
+	email = NewMSG().AddAttachmentExact(xml, "application/xml;\r\n charset=UTF-8", "")
+	if email.Error == nil {
+		t.Error("expected an error for a Content-Type with line breaks")
+	}
+}
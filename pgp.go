@@ -0,0 +1,156 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+// PGPSigner signs the emails with PGP/MIME. With an openpgp entity, from
+// golang.org/x/crypto/openpgp or a maintained fork, it's implemented as:
+//
+//	func (s entitySigner) DetachSign(w io.Writer, message io.Reader) error {
+//		return openpgp.ArmoredDetachSign(w, s.entity, message, nil)
+//	}
+//
+//	func (s entitySigner) HashAlgorithm() string {
+//		return "sha256"
+//	}
+type PGPSigner interface {
+	// DetachSign writes the ASCII armored detached signature of the
+	// message to w
+	DetachSign(w io.Writer, message io.Reader) error
+	// HashAlgorithm returns the name of the hash of the signature, like
+	// "sha256", used in the micalg parameter
+	HashAlgorithm() string
+}
+
+// PGPEncrypter encrypts the emails with PGP/MIME. With openpgp entities
+// it's implemented with armor.Encode and openpgp.Encrypt.
+type PGPEncrypter interface {
+	// Encrypt writes the ASCII armored encrypted message to w
+	Encrypt(w io.Writer, message io.Reader) error
+}
+
+// PGPEncryptFunc is a function that implements PGPEncrypter.
+type PGPEncryptFunc func(w io.Writer, message io.Reader) error
+
+// Encrypt calls f(w, message).
+func (f PGPEncryptFunc) Encrypt(w io.Writer, message io.Reader) error {
+	return f(w, message)
+}
+
+// pgpOptions are the PGP/MIME options of an email
+type pgpOptions struct {
+	signer    PGPSigner
+	encrypter PGPEncrypter
+}
+
+// PGPSign signs the email with PGP/MIME (RFC 3156), as a multipart/signed
+// message with the detached signature. The signed content must not be
+// changed by the relays, so 8-bit bodies should be avoided, see
+// SetSevenBit. The signature is computed when the message is built, so
+// the whole message is kept in memory.
+func (email *Email) PGPSign(signer PGPSigner) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.pgp.signer = signer
+
+	return email
+}
+
+// PGPEncrypt encrypts the email with PGP/MIME (RFC 3156), as a
+// multipart/encrypted message. Signed emails are signed first and then
+// encrypted.
+func (email *Email) PGPEncrypt(encrypter PGPEncrypter) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.pgp.encrypter = encrypter
+
+	return email
+}
+
+// pgp wraps the built content of the message in the PGP/MIME structures
+func (msg *message) pgp(email *Email) {
+	if msg.err != nil || (email.pgp.signer == nil && email.pgp.encrypter == nil) {
+		return
+	}
+
+	if email.smime.signer != nil || len(email.smime.recipients) > 0 {
+		msg.err = errors.New("Mail Error: An email can't be protected with both S/MIME and PGP/MIME")
+		return
+	}
+
+	entity, err := msg.entity()
+	if err != nil {
+		msg.err = err
+		return
+	}
+
+	if email.pgp.signer != nil {
+		if entity, err = msg.pgpSign(entity, email.pgp.signer); err != nil {
+			msg.err = errors.New("Mail Error: Failed to sign the message: " + err.Error())
+			return
+		}
+	}
+
+	if email.pgp.encrypter != nil {
+		if entity, err = msg.pgpEncrypt(entity, email.pgp.encrypter); err != nil {
+			msg.err = errors.New("Mail Error: Failed to encrypt the message: " + err.Error())
+			return
+		}
+	}
+
+	msg.setEntity(entity)
+}
+
+// pgpSign returns the multipart/signed entity of the signed entity
+func (msg *message) pgpSign(entity string, signer PGPSigner) (string, error) {
+	var signature bytes.Buffer
+	if err := signer.DetachSign(&signature, strings.NewReader(entity)); err != nil {
+		return "", err
+	}
+
+	boundary := msg.boundary("signed-")
+
+	return "Content-Type: multipart/signed; micalg=pgp-" + strings.ToLower(signer.HashAlgorithm()) + ";\r\n" +
+		" protocol=\"application/pgp-signature\"; boundary=\"" + boundary + "\"\r\n\r\n" +
+		"This is an OpenPGP/MIME signed message (RFC 4880 and 3156)\r\n" +
+		"\r\n--" + boundary + "\r\n" +
+		entity +
+		"\r\n--" + boundary + "\r\n" +
+		"Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n" +
+		"Content-Description: OpenPGP digital signature\r\n" +
+		"Content-Disposition: attachment; filename=\"signature.asc\"\r\n\r\n" +
+		normalizeCRLF(strings.TrimRight(signature.String(), "\r\n")) +
+		"\r\n--" + boundary + "--\r\n", nil
+}
+
+// pgpEncrypt returns the multipart/encrypted entity of the encrypted entity
+func (msg *message) pgpEncrypt(entity string, encrypter PGPEncrypter) (string, error) {
+	var encrypted bytes.Buffer
+	if err := encrypter.Encrypt(&encrypted, strings.NewReader(entity)); err != nil {
+		return "", err
+	}
+
+	boundary := msg.boundary("encrypted-")
+
+	return "Content-Type: multipart/encrypted; protocol=\"application/pgp-encrypted\";\r\n" +
+		" boundary=\"" + boundary + "\"\r\n\r\n" +
+		"This is an OpenPGP/MIME encrypted message (RFC 4880 and 3156)\r\n" +
+		"\r\n--" + boundary + "\r\n" +
+		"Content-Type: application/pgp-encrypted\r\n" +
+		"Content-Description: PGP/MIME version identification\r\n\r\n" +
+		"Version: 1\r\n" +
+		"\r\n--" + boundary + "\r\n" +
+		"Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n" +
+		"Content-Description: OpenPGP encrypted message\r\n" +
+		"Content-Disposition: inline; filename=\"encrypted.asc\"\r\n\r\n" +
+		normalizeCRLF(strings.TrimRight(encrypted.String(), "\r\n")) +
+		"\r\n--" + boundary + "--\r\n", nil
+}
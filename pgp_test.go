@@ -0,0 +1,90 @@
+package mail
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// testPGPSigner "signs" with the SHA-256 of the message
+type testPGPSigner struct{}
+
+func (testPGPSigner) DetachSign(w io.Writer, message io.Reader) error {
+	data, err := ioutil.ReadAll(message)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	_, err = io.WriteString(w, "-----BEGIN PGP SIGNATURE-----\n\n"+hex.EncodeToString(sum[:])+"\n-----END PGP SIGNATURE-----\n")
+	return err
+}
+
+func (testPGPSigner) HashAlgorithm() string {
+	return "SHA256"
+}
+
+// testPGPEncrypt "encrypts" with base64
+func testPGPEncrypt(w io.Writer, message io.Reader) error {
+	data, err := ioutil.ReadAll(message)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "-----BEGIN PGP MESSAGE-----\n\n"+base64.StdEncoding.EncodeToString(data)+"\n-----END PGP MESSAGE-----\n")
+	return err
+}
+
+func TestPGP(t *testing.T) {
+	email := newSMIMEEmail().PGPSign(testPGPSigner{}).PGPEncrypt(PGPEncryptFunc(testPGPEncrypt))
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	i := strings.Index(msg, "\r\n\r\n")
+	header, body := strings.Replace(msg[:i], "\r\n ", " ", -1), msg[i+4:]
+	if !strings.Contains(header, "Content-Type: multipart/encrypted; protocol=\"application/pgp-encrypted\";") {
+		t.Fatalf("message not encrypted:\n%s", header)
+	}
+	if !strings.Contains(body, "Content-Type: application/pgp-encrypted\r\nContent-Description: PGP/MIME version identification\r\n\r\nVersion: 1\r\n") {
+		t.Errorf("version part not found:\n%s", body)
+	}
+
+	armored := body[strings.Index(body, "-----BEGIN PGP MESSAGE-----\r\n\r\n")+31:]
+	data, err := base64.StdEncoding.DecodeString(armored[:strings.Index(armored, "\r\n")])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := string(data)
+	if !strings.HasPrefix(signed, "Content-Type: multipart/signed; micalg=pgp-sha256;\r\n protocol=\"application/pgp-signature\"") {
+		t.Fatalf("unexpected encrypted entity:\n%s", signed)
+	}
+
+	boundary := signed[strings.Index(signed, `boundary="`)+10:]
+	boundary = boundary[:strings.Index(boundary, `"`)]
+	parts := strings.Split(signed, "\r\n--"+boundary)
+	if len(parts) != 4 {
+		t.Fatalf("got %d parts in the signed entity:\n%s", len(parts), signed)
+	}
+
+	sum := sha256.Sum256([]byte(strings.TrimPrefix(parts[1], "\r\n")))
+	if !strings.Contains(parts[2], "Content-Type: application/pgp-signature") || !strings.Contains(parts[2], hex.EncodeToString(sum[:])) {
+		t.Errorf("the signature is not of the signed part:\n%s", parts[2])
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email = newSMIMEEmail().PGPSign(testPGPSigner{}).SMIMESign(testCertificate(t, key), key)
+	if email.GetMessage(); email.Error == nil {
+		t.Error("expected an error protecting with both S/MIME and PGP/MIME")
+	}
+}
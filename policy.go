@@ -0,0 +1,93 @@
+package mail
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// RecipientPolicy restricts the recipients emails can be sent to.
+//
+// Patterns are domains ("example.com") or addresses ("user@example.com") and
+// can contain glob wildcards ("*.example.com", "*@example.com", "test-*@example.com").
+// Recipients matching any Deny pattern are always refused. If Allow is not
+// empty, only recipients matching any Allow pattern are accepted.
+type RecipientPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// PolicyError is returned when some recipients are refused by the recipient policy.
+type PolicyError struct {
+	Recipients []string
+}
+
+func (e *PolicyError) Error() string {
+	return "Mail Error: Recipients refused by policy: [" + strings.Join(e.Recipients, ", ") + "]"
+}
+
+// ErrInvalidPattern is returned when a recipient policy pattern is malformed
+var ErrInvalidPattern = errors.New("Mail Error: Invalid recipient policy pattern")
+
+// Allowed reports whether the policy allows sending to the address.
+func (p *RecipientPolicy) Allowed(address string) (bool, error) {
+	address = strings.ToLower(address)
+
+	denied, err := matchAny(p.Deny, address)
+	if err != nil || denied {
+		return false, err
+	}
+
+	if len(p.Allow) == 0 {
+		return true, nil
+	}
+
+	return matchAny(p.Allow, address)
+}
+
+// Check returns a PolicyError with the recipients refused by the policy, if any.
+func (p *RecipientPolicy) Check(recipients []string) error {
+	var refused []string
+
+	for _, r := range recipients {
+		ok, err := p.Allowed(r)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			refused = append(refused, r)
+		}
+	}
+
+	if len(refused) > 0 {
+		return &PolicyError{Recipients: refused}
+	}
+
+	return nil
+}
+
+// matchAny returns true if the address matches any pattern
+func matchAny(patterns []string, address string) (bool, error) {
+	domain := address[strings.LastIndex(address, "@")+1:]
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+
+		name := domain
+		if strings.Contains(pattern, "@") {
+			name = address
+		}
+
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return false, ErrInvalidPattern
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRecipientPolicy(t *testing.T) {
+	policy := &RecipientPolicy{
+		Allow: []string{"example.com", "*.example.org", "*@partner.net"},
+		Deny:  []string{"ceo@example.com", "test-*@*"},
+	}
+
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"user@example.com", true},
+		{"User@EXAMPLE.com", true},
+		{"ceo@example.com", false},
+		{"user@mail.example.org", true},
+		{"user@example.org", false},
+		{"anyone@partner.net", true},
+		{"test-1@partner.net", false},
+		{"user@gmail.com", false},
+	}
+
+	for _, test := range tests {
+		got, err := policy.Allowed(test.address)
+		if err != nil {
+			t.Fatalf("Allowed(%q): %v", test.address, err)
+		}
+		if got != test.want {
+			t.Errorf("Allowed(%q) = %t, want %t", test.address, got, test.want)
+		}
+	}
+
+	err := policy.Check([]string{"user@example.com", "ceo@example.com", "user@gmail.com"})
+
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || len(policyErr.Recipients) != 2 {
+		t.Errorf("got error %v, want 2 refused recipients", err)
+	}
+
+	if _, err := (&RecipientPolicy{Deny: []string{"[a-"}}).Allowed("user@example.com"); err != ErrInvalidPattern {
+		t.Errorf("got error %v, want ErrInvalidPattern", err)
+	}
+}
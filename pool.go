@@ -0,0 +1,173 @@
+package mail
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrPoolClosed is returned by the pool after Close
+var ErrPoolClosed = errors.New("Mail Error: The SMTP connection pool is closed")
+
+// Pool keeps up to size persistent connections to a SMTP server, shared by
+// the goroutines that send emails, so a bulk send doesn't open a
+// connection for every message. The connections are opened when needed,
+// checked with NOOP before they are handed out and reconnected if they
+// fail.
+type Pool struct {
+	// MaxMessagesPerConn is the number of messages sent in a connection
+	// before it's closed and a new one is opened, for the servers limiting
+	// the messages per connection. 0 means no limit
+	MaxMessagesPerConn int
+
+	server *SMTPServer
+	idle   chan *SMTPClient
+	// slots has an element for every open connection
+	slots  chan struct{}
+	done   chan struct{}
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewPool returns a pool of at most size connections to the server.
+func NewPool(server *SMTPServer, size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Pool{
+		server: server,
+		idle:   make(chan *SMTPClient, size),
+		slots:  make(chan struct{}, size),
+		done:   make(chan struct{}),
+	}
+}
+
+// Get returns a connection of the pool, waiting for one if all of them
+// are in use. It must be returned with Put.
+func (p *Pool) Get() (*SMTPClient, error) {
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	default:
+	}
+
+	// prefer the open connections
+	select {
+	case client := <-p.idle:
+		return p.check(client)
+	default:
+	}
+
+	select {
+	case client := <-p.idle:
+		return p.check(client)
+	case p.slots <- struct{}{}:
+		client, err := p.server.Connect()
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		client.KeepAlive = true
+		return client, nil
+	case <-p.done:
+		return nil, ErrPoolClosed
+	}
+}
+
+// check validates an idle connection with NOOP, reconnecting it if it
+// failed
+func (p *Pool) check(client *SMTPClient) (*SMTPClient, error) {
+	if client.Noop() == nil {
+		return client, nil
+	}
+
+	if err := client.Reconnect(); err != nil {
+		p.discard(client)
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Put returns a connection to the pool.
+func (p *Pool) Put(client *SMTPClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || p.exhausted(client) {
+		client.Quit()
+		p.discard(client)
+		return
+	}
+
+	p.idle <- client
+}
+
+// exhausted returns true if the connection sent MaxMessagesPerConn
+// messages
+func (p *Pool) exhausted(client *SMTPClient) bool {
+	return p.MaxMessagesPerConn > 0 && client.Client != nil && client.Client.messages >= p.MaxMessagesPerConn
+}
+
+// discard closes a connection and frees its slot
+func (p *Pool) discard(client *SMTPClient) {
+	if client.Client != nil {
+		client.Close()
+	}
+	<-p.slots
+}
+
+// Send sends the email with a connection of the pool.
+func (p *Pool) Send(email *Email) error {
+	return p.do(email.Send)
+}
+
+// SendMessage sends a RFC822 formatted message with a connection of the
+// pool, like SendMessage.
+func (p *Pool) SendMessage(from string, recipients []string, msg string) error {
+	return p.do(func(client *SMTPClient) error {
+		return SendMessage(from, recipients, msg, client)
+	})
+}
+
+// do sends with a connection of the pool, the connection is discarded if
+// it's broken after the send
+func (p *Pool) do(send func(client *SMTPClient) error) error {
+	client, err := p.Get()
+	if err != nil {
+		return err
+	}
+
+	if err = send(client); err != nil && client.Reset() != nil {
+		// the connection is broken
+		p.discard(client)
+		return err
+	}
+
+	p.Put(client)
+
+	return err
+}
+
+// Close closes the idle connections and the ones returned later with Put.
+// Get fails after Close.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+
+	for {
+		select {
+		case client := <-p.idle:
+			client.Quit()
+			p.discard(client)
+		default:
+			return nil
+		}
+	}
+}
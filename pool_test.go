@@ -0,0 +1,171 @@
+package mail
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingListener counts the accepted connections
+type countingListener struct {
+	net.Listener
+	accepted int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&l.accepted, 1)
+	}
+	return conn, err
+}
+
+func newPoolServer(ln net.Listener) *SMTPServer {
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	return server
+}
+
+func newPoolEmail() *Email {
+	return NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello")
+}
+
+func TestPool(t *testing.T) {
+	ln := &countingListener{Listener: newLocalListener(t)}
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go fakeSMTP(ln, messages)
+
+	pool := NewPool(newPoolServer(ln), 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pool.Send(newPoolEmail()); err != nil {
+				t.Errorf("Send: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(messages) != 10 {
+		t.Errorf("got %d messages, want 10", len(messages))
+	}
+	if n := atomic.LoadInt32(&ln.accepted); n < 1 || n > 2 {
+		t.Errorf("got %d connections, want at most 2", n)
+	}
+
+	pool.Close()
+	if err := pool.Send(newPoolEmail()); err != ErrPoolClosed {
+		t.Errorf("got error %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPoolReconnect(t *testing.T) {
+	ln := &countingListener{Listener: newLocalListener(t)}
+	defer ln.Close()
+
+	messages := make(chan string, 2)
+	// the first connection is dropped at the NOOP check
+	go fakeSMTP(ln, messages, map[string]string{"NOOP": ""})
+
+	pool := NewPool(newPoolServer(ln), 1)
+	defer pool.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := pool.Send(newPoolEmail()); err != nil {
+			t.Fatalf("Send %d: %v", i, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&ln.accepted); n != 2 {
+		t.Errorf("got %d connections, want a reconnection", n)
+	}
+}
+
+func TestPoolMaxMessagesPerConn(t *testing.T) {
+	ln := &countingListener{Listener: newLocalListener(t)}
+	defer ln.Close()
+
+	messages := make(chan string, 5)
+	go fakeSMTP(ln, messages)
+
+	pool := NewPool(newPoolServer(ln), 1)
+	pool.MaxMessagesPerConn = 2
+	defer pool.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := pool.Send(newPoolEmail()); err != nil {
+			t.Fatalf("Send %d: %v", i, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&ln.accepted); n != 3 {
+		t.Errorf("got %d connections, want 3", n)
+	}
+}
+
+func TestPoolGroup(t *testing.T) {
+	ln := &countingListener{Listener: newLocalListener(t)}
+	defer ln.Close()
+
+	messages := make(chan string, 20)
+	go fakeSMTP(ln, messages)
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	limited := newPoolServer(ln)
+	limited.Host = "localhost:" + port
+
+	group := NewPoolGroup(5, PoolLimit{Host: "LOCALHOST", MaxConns: 1, MaxMessagesPerConn: 10})
+	defer group.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := group.Send(limited, newPoolEmail()); err != nil {
+				t.Errorf("Send: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&ln.accepted); n != 1 {
+		t.Errorf("got %d connections, want 1 for the limited host", n)
+	}
+
+	pool, _ := group.Pool(limited)
+	if other, _ := group.Pool(newPoolServer(ln)); other == pool {
+		t.Error("got the same pool for another host")
+	}
+
+	group.Close()
+	if err := group.Send(limited, newPoolEmail()); err != ErrPoolClosed {
+		t.Errorf("got error %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPoolLimitMatches(t *testing.T) {
+	limit := PoolLimit{Host: "gmail-smtp-in.l.google.com"}
+
+	for host, want := range map[string]bool{
+		"gmail-smtp-in.l.google.com":      true,
+		"alt1.gmail-smtp-in.l.google.com": true,
+		"GMAIL-SMTP-IN.L.GOOGLE.COM.":     true,
+		"xgmail-smtp-in.l.google.com":     false,
+		"smtp.google.com":                 false,
+	} {
+		if got := limit.matches(host); got != want {
+			t.Errorf("matches(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
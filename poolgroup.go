@@ -0,0 +1,110 @@
+package mail
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// PoolLimit limits the connections to the SMTP servers of a host, to
+// follow the connection policies published by the big providers.
+type PoolLimit struct {
+	// Host is the host name of the servers, it matches its subdomains too:
+	// "gmail-smtp-in.l.google.com" matches
+	// "alt1.gmail-smtp-in.l.google.com"
+	Host string
+	// MaxConns is the maximum number of concurrent connections to a server
+	MaxConns int
+	// MaxMessagesPerConn is the number of messages sent in a connection
+	// before a new one is opened, 0 means no limit
+	MaxMessagesPerConn int
+}
+
+// matches returns true if the limit applies to the host
+func (l PoolLimit) matches(host string) bool {
+	pattern := strings.ToLower(strings.Trim(l.Host, "."))
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+// PoolGroup keeps a Pool for each SMTP server, with the limits of its host,
+// to send to several servers, like the MX servers of the recipient domains.
+// The pools are created when a server is first used, and keyed by host and
+// port: the configuration of the first SMTPServer of a host is used for all
+// its connections.
+type PoolGroup struct {
+	size   int
+	limits []PoolLimit
+
+	mu     sync.Mutex
+	pools  map[string]*Pool
+	closed bool
+}
+
+// NewPoolGroup returns a group of pools of at most size connections to
+// each server, or the limits of the first matching PoolLimit.
+func NewPoolGroup(size int, limits ...PoolLimit) *PoolGroup {
+	return &PoolGroup{
+		size:   size,
+		limits: limits,
+		pools:  make(map[string]*Pool),
+	}
+}
+
+// Pool returns the pool of the server, creating it if needed.
+func (g *PoolGroup) Pool(server *SMTPServer) (*Pool, error) {
+	host, port, err := server.hostPort()
+	if err != nil {
+		return nil, err
+	}
+	key := strings.ToLower(net.JoinHostPort(host, port))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil, ErrPoolClosed
+	}
+
+	if pool, ok := g.pools[key]; ok {
+		return pool, nil
+	}
+
+	size, maxMessages := g.size, 0
+	for _, limit := range g.limits {
+		if limit.matches(host) {
+			size, maxMessages = limit.MaxConns, limit.MaxMessagesPerConn
+			break
+		}
+	}
+
+	pool := NewPool(server, size)
+	pool.MaxMessagesPerConn = maxMessages
+	g.pools[key] = pool
+
+	return pool, nil
+}
+
+// Send sends the email with a connection of the pool of the server.
+func (g *PoolGroup) Send(server *SMTPServer, email *Email) error {
+	pool, err := g.Pool(server)
+	if err != nil {
+		return err
+	}
+
+	return pool.Send(email)
+}
+
+// Close closes the pools. Pool and Send fail after Close.
+func (g *PoolGroup) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+	for _, pool := range g.pools {
+		pool.Close()
+	}
+
+	return nil
+}
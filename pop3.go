@@ -0,0 +1,157 @@
+package mail
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// POP3Mailbox is a Mailbox of a POP3 server (RFC 1939), for the
+// BouncePoller. The messages marked deleted are deleted by Close.
+type POP3Mailbox struct {
+	text *textproto.Conn
+}
+
+// DialPOP3 connects to the POP3 server at addr and logs in with the
+// username and password. The connection uses TLS if tlsConfig is set, else
+// it's upgraded with STLS (RFC 2595), and the login is refused if the server
+// doesn't support it. DialPOP3Insecure logs in without TLS.
+func DialPOP3(addr string, tlsConfig *tls.Config, username, password string) (*POP3Mailbox, error) {
+	return dialPOP3(addr, tlsConfig, username, password, false)
+}
+
+// DialPOP3Insecure connects to the POP3 server at addr without TLS and logs in
+// with the username and password, which are sent in clear text.
+func DialPOP3Insecure(addr, username, password string) (*POP3Mailbox, error) {
+	return dialPOP3(addr, nil, username, password, true)
+}
+
+func dialPOP3(addr string, tlsConfig *tls.Config, username, password string, insecure bool) (*POP3Mailbox, error) {
+	if err := validateLine(username); err != nil {
+		return nil, err
+	}
+	if err := validateLine(password); err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mb := &POP3Mailbox{text: textproto.NewConn(conn)}
+	if _, err = mb.reply(); err == nil && tlsConfig == nil && !insecure {
+		err = mb.startTLS(conn, addr)
+	}
+	if err == nil {
+		if _, err = mb.cmd("USER " + username); err == nil {
+			_, err = mb.cmd("PASS " + password)
+		}
+	}
+	if err != nil {
+		mb.text.Close()
+		return nil, err
+	}
+
+	return mb, nil
+}
+
+// startTLS upgrades the connection with STLS
+func (mb *POP3Mailbox) startTLS(conn net.Conn, addr string) error {
+	if _, err := mb.cmd("STLS"); err != nil {
+		return errors.New("Mail Error: the POP3 server doesn't support STLS, use DialPOP3Insecure to log in without TLS")
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+	if err = tlsConn.Handshake(); err != nil {
+		return err
+	}
+	mb.text = textproto.NewConn(tlsConn)
+
+	return nil
+}
+
+// Messages returns the numbers of the messages.
+func (mb *POP3Mailbox) Messages() ([]string, error) {
+	if _, err := mb.cmd("LIST"); err != nil {
+		return nil, err
+	}
+
+	lines, err := mb.text.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			ids = append(ids, fields[0])
+		}
+	}
+
+	return ids, nil
+}
+
+// Fetch returns the message with the number.
+func (mb *POP3Mailbox) Fetch(id string) ([]byte, error) {
+	if _, err := mb.cmd("RETR " + id); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(mb.text.DotReader())
+}
+
+// Delete marks the message with the number as deleted.
+func (mb *POP3Mailbox) Delete(id string) error {
+	_, err := mb.cmd("DELE " + id)
+	return err
+}
+
+// Close deletes the messages marked as deleted and closes the connection.
+func (mb *POP3Mailbox) Close() error {
+	_, err := mb.cmd("QUIT")
+	if closeErr := mb.text.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (mb *POP3Mailbox) cmd(line string) (string, error) {
+	if err := mb.text.PrintfLine("%s", line); err != nil {
+		return "", err
+	}
+
+	return mb.reply()
+}
+
+// reply reads the status line of a reply
+func (mb *POP3Mailbox) reply() (string, error) {
+	line, err := mb.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(line, "+OK") {
+		return "", errors.New("Mail Error: POP3 error: " + line)
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+}
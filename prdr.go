@@ -0,0 +1,79 @@
+package mail
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RecipientError is the rejection of a single recipient.
+type RecipientError struct {
+	Address string
+	Err     error
+}
+
+// PartialSendError is the error returned when the message was accepted
+// for some recipients and rejected for others, as reported by servers
+// that support the PRDR extension (per-recipient data responses).
+type PartialSendError struct {
+	// Accepted are the recipients the message was delivered to
+	Accepted []string
+	// Rejected are the recipients that rejected the message, in the
+	// order they were sent
+	Rejected []RecipientError
+}
+
+func (e *PartialSendError) Error() string {
+	var rejected []string
+	for _, r := range e.Rejected {
+		rejected = append(rejected, r.Address+": "+r.Err.Error())
+	}
+
+	return "Mail Error: Message rejected for " + strconv.Itoa(len(e.Rejected)) + " of " +
+		strconv.Itoa(len(e.Rejected)+len(e.Accepted)) + " recipients: " + strings.Join(rejected, "; ")
+}
+
+// prdrCloser ends the data of a PRDR transaction and reads the response of
+// each recipient
+type prdrCloser struct {
+	c  *smtpClient
+	to []string
+	io.WriteCloser
+}
+
+func (d *prdrCloser) Close() error {
+	d.WriteCloser.Close()
+
+	if _, _, err := d.c.text.ReadResponse(353); err != nil {
+		// the server refused the message for all the recipients
+		return err
+	}
+
+	partial := &PartialSendError{}
+	for _, to := range d.to {
+		if _, _, err := d.c.text.ReadResponse(250); err != nil {
+			partial.Rejected = append(partial.Rejected, RecipientError{Address: to, Err: err})
+		} else {
+			partial.Accepted = append(partial.Accepted, to)
+		}
+	}
+
+	// the final response is an error if all the recipients rejected it
+	_, _, err := d.c.text.ReadResponse(250)
+	if len(partial.Rejected) > 0 {
+		return partial
+	}
+
+	return err
+}
+
+// dataPRDR issues a DATA command for a transaction started with the PRDR
+// parameter, the writer returns a PartialSendError when it's closed if
+// any of the recipients rejects the message.
+func (c *smtpClient) dataPRDR(to []string) (io.WriteCloser, error) {
+	_, _, err := c.cmd(354, "DATA")
+	if err != nil {
+		return nil, err
+	}
+	return &prdrCloser{c, to, c.text.DotWriter()}, nil
+}
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPRDR(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	go fakeSMTP(ln, make(chan string, 1), map[string]string{
+		"EHLO": "250-fake\r\n250 PRDR",
+		".":    "353 content analysis started\r\n250 ok\r\n550 5.7.1 rejected by policy\r\n250 done",
+	})
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	server.KeepAlive = true
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("one@example.com", "two@example.com").
+		SetBody(TextPlain, "Hello")
+
+	err = email.Send(client)
+
+	var partial *PartialSendError
+	if !errors.As(err, &partial) {
+		t.Fatalf("got error %v, want PartialSendError", err)
+	}
+	if len(partial.Accepted) != 1 || partial.Accepted[0] != "one@example.com" {
+		t.Errorf("got accepted %v, want [one@example.com]", partial.Accepted)
+	}
+	if len(partial.Rejected) != 1 || partial.Rejected[0].Address != "two@example.com" {
+		t.Errorf("got rejected %v, want two@example.com", partial.Rejected)
+	}
+
+}
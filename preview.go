@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"regexp"
+)
+
+// previewCIDs matches the cid references of the HTML part
+var previewCIDs = regexp.MustCompile(`(src|href|background)=(["'])cid:(.*?)(["'])`)
+
+// PreviewHTML writes the HTML part of the email, after the email filters,
+// to a new file in dir and returns its path, so it can be opened in a
+// browser during development. The cid references of the inline files are
+// replaced with data URIs.
+func (email *Email) PreviewHTML(dir string) (string, error) {
+	if email.Error != nil {
+		return "", email.Error
+	}
+
+	filtered, err := email.applyFilters(nil)
+	if err != nil {
+		return "", err
+	}
+
+	i := filtered.htmlPart()
+	if i < 0 {
+		return "", errors.New("Mail Error: No HTML part to preview")
+	}
+
+	inlines := make(map[string]*file, len(filtered.inlines))
+	for _, f := range filtered.inlines {
+		inlines[f.filename] = f
+	}
+
+	html := previewCIDs.ReplaceAllStringFunc(filtered.parts[i].body.String(), func(ref string) string {
+		m := previewCIDs.FindStringSubmatch(ref)
+		f, ok := inlines[m[3]]
+		if !ok {
+			return ref
+		}
+
+		return m[1] + "=" + m[2] + "data:" + f.mimeType + ";base64," + base64.StdEncoding.EncodeToString(f.data) + m[4]
+	})
+
+	out, err := ioutil.TempFile(dir, "preview-*.html")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = out.WriteString(html); err != nil {
+		out.Close()
+		return "", err
+	}
+
+	return out.Name(), out.Close()
+}
@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPreviewHTML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preview")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	email := NewMSG().
+		SetBody(TextPlain, "Hello").
+		AddAlternative(TextHTML, `<p>Hello <img src="cid:logo.png"> <img src='cid:missing.png'></p>`).
+		AddInlineData([]byte("PNG"), "logo.png", "image/png")
+
+	path, err := email.PreviewHTML(dir)
+	if err != nil {
+		t.Fatalf("PreviewHTML: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<p>Hello <img src="data:image/png;base64,UE5H"> <img src='cid:missing.png'></p>`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+	if !strings.HasPrefix(path, dir) {
+		t.Errorf("got path %s, want it in %s", path, dir)
+	}
+
+	if _, err = NewMSG().SetBody(TextPlain, "Hello").PreviewHTML(dir); err == nil {
+		t.Error("expected error without HTML part")
+	}
+}
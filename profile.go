@@ -0,0 +1,70 @@
+package mail
+
+// Profile tweaks the message output for the quirks of the mail clients of
+// the recipients. The zero Profile keeps the default output.
+type Profile struct {
+	// RFC2231Params encodes the attachment filename parameter only as
+	// specified by RFC 2231, instead of RFC 2047 encoded words inside quotes.
+	// The Content-Type name parameter is kept ASCII only, transliterated from
+	// the file name, for clients that don't support RFC 2231.
+	RFC2231Params bool
+	// AttachmentParams selects the parameters with the attachment file name.
+	AttachmentParams AttachmentParams
+	// AttachmentID adds the X-Attachment-Id header to the attachments.
+	AttachmentID bool
+	// RelatedInAlternative nests the HTML part and the inline files in a
+	// multipart/related part inside the multipart/alternative part, instead
+	// of the multipart/alternative part inside the multipart/related part.
+	RelatedInAlternative bool
+	// Base64LineLength is the line length of the base64 encoded attachments.
+	// Zero is the default of 76 characters.
+	Base64LineLength int
+	// UUEncodeAttachments embeds the attachments uuencoded at the end of the
+	// text/plain body, in begin/end blocks, instead of MIME parts, for the
+	// legacy systems that don't decode MIME. The inline files are not
+	// changed.
+	UUEncodeAttachments bool
+	// StrictRFC omits the non-standard headers added by the builder, for
+	// the receivers that reject unknown headers: X-Attachment-Id,
+	// X-Priority and X-MSMail-Priority, Thread-Index and Thread-Topic, and
+	// X-Tags. The other custom headers, like the correlation id, are kept.
+	StrictRFC bool
+}
+
+// AttachmentParams selects the parameters with the attachment file name
+type AttachmentParams int
+
+const (
+	// ParamsBoth sets both the Content-Type name and the Content-Disposition
+	// filename parameters
+	ParamsBoth AttachmentParams = iota
+	// ParamsFilename sets only the Content-Disposition filename parameter
+	ParamsFilename
+	// ParamsName sets only the Content-Type name parameter
+	ParamsName
+)
+
+var (
+	// ProfileRFC is the standards conformant profile.
+	ProfileRFC = Profile{RFC2231Params: true, StrictRFC: true}
+	// ProfileOutlook targets Outlook, which doesn't decode RFC 2231 file
+	// names in old versions and nests the inline images with the HTML part.
+	ProfileOutlook = Profile{RelatedInAlternative: true}
+	// ProfileGmail targets Gmail, which identifies the attachments with the
+	// X-Attachment-Id header.
+	ProfileGmail = Profile{AttachmentID: true}
+	// ProfileThunderbird targets Thunderbird, which supports RFC 2231 file
+	// names and wraps base64 at 72 characters.
+	ProfileThunderbird = Profile{RFC2231Params: true, Base64LineLength: 72}
+)
+
+// SetProfile sets the compatibility profile used to build the message.
+func (email *Email) SetProfile(profile Profile) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.profile = profile
+
+	return email
+}
@@ -0,0 +1,144 @@
+package mail
+
+import (
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestProfileRFC2231Params(t *testing.T) {
+	filename := "Résumé " + strings.Repeat("très long ", 5) + ".pdf"
+
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte("data"), filename, "application/pdf").
+		SetProfile(ProfileRFC)
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+	if strings.Contains(msg, "=?UTF-8?Q?") {
+		t.Errorf("found RFC 2047 encoded words in the parameters:\n%s", msg)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, _ := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	r := multipart.NewReader(m.Body, params["boundary"])
+
+	r.NextPart()
+	p, err := r.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.FileName(); got != filename {
+		t.Errorf("got filename %q, want %q", got, filename)
+	}
+
+	_, params, err = mime.ParseMediaType(p.Header.Get("Content-Type"))
+	if err != nil || params["name"] != "Resume "+strings.Repeat("tres long ", 5)+".pdf" {
+		t.Errorf("got name %q, %v, want the transliterated name", params["name"], err)
+	}
+}
+
+func TestProfileOutlookNesting(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAlternative(TextHTML, `<img src="cid:logo.png">`).
+		AddInlineData([]byte("png"), "logo.png", "image/png").
+		SetProfile(ProfileOutlook)
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("got root %s, want multipart/alternative", mediaType)
+	}
+
+	r := multipart.NewReader(m.Body, params["boundary"])
+	var types []string
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		mediaType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		types = append(types, mediaType)
+	}
+
+	if got, want := strings.Join(types, ","), "text/plain,multipart/related"; got != want {
+		t.Errorf("got alternative parts %s, want %s", got, want)
+	}
+}
+
+func TestProfileAttachmentOptions(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte(strings.Repeat("data", 100)), "file.bin", "application/octet-stream").
+		SetProfile(Profile{AttachmentID: true, AttachmentParams: ParamsFilename, Base64LineLength: 72})
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+
+	if !strings.Contains(msg, "X-Attachment-Id: f_1") {
+		t.Errorf("X-Attachment-Id not found:\n%s", msg)
+	}
+	if !strings.Contains(msg, "filename=\"file.bin\"") || strings.Contains(msg, "\tname=") {
+		t.Errorf("got name parameter with ParamsFilename:\n%s", msg)
+	}
+	for _, line := range strings.Split(msg, "\r\n") {
+		if len(line) == 76 {
+			t.Errorf("got line of 76 characters with Base64LineLength 72: %q", line)
+		}
+	}
+}
+
+func TestProfileStrictRFC(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte("data"), "data.bin", "").
+		SetPriority(PriorityHigh).
+		SetThread(Thread{MessageID: "<parent@example.com>"}).
+		AddTag("invoice").
+		SetCorrelationID("123").
+		SetProfile(Profile{AttachmentID: true, StrictRFC: true})
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	for _, header := range []string{"X-Attachment-Id", "X-Priority", "X-Msmail-Priority", "Thread-Index", "Thread-Topic", "X-Tags", "Content-Length"} {
+		if strings.Contains(msg, header+":") {
+			t.Errorf("found the non-standard header %s in the message:\n%s", header, msg)
+		}
+	}
+	for _, header := range []string{"Importance: High", "In-Reply-To: <parent@example.com>", "X-Correlation-Id: 123"} {
+		if !strings.Contains(msg, header+"\r\n") {
+			t.Errorf("header %q not found in the message:\n%s", header, msg)
+		}
+	}
+}
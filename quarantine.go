@@ -0,0 +1,175 @@
+package mail
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// QuarantineStore is implemented by stores where quarantined messages are held.
+type QuarantineStore interface {
+	// Quarantine saves the email and returns its id in the store
+	Quarantine(email *Email, reason string) (id string, err error)
+}
+
+// Holder is implemented by stores that hold messages awaiting approval
+// before delivery. Hold puts the message with the given id back on hold
+// and Release approves it for delivery.
+type Holder interface {
+	Hold(id, reason string) error
+	Release(id string) error
+}
+
+// HoldState is the state of a held message
+type HoldState int
+
+const (
+	// StateHeld means the message awaits approval
+	StateHeld HoldState = iota
+	// StateReleased means the message was approved and delivered
+	StateReleased
+)
+
+var holdStates = [...]string{"held", "released"}
+
+func (state HoldState) String() string {
+	return holdStates[state]
+}
+
+// QuarantinedMessage is a message held in a quarantine store.
+type QuarantinedMessage struct {
+	ID     string
+	Email  *Email
+	Reason string
+	State  HoldState
+	Time   time.Time
+}
+
+// ErrNotFound is returned when a message is not found in a store
+var ErrNotFound = errors.New("Mail Error: Message not found")
+
+// MemoryQuarantine is an in-memory quarantine store. Messages are held
+// until they are released or deleted.
+type MemoryQuarantine struct {
+	// OnRelease is called with the released message, usually to send it.
+	// If it returns an error the message stays on hold.
+	OnRelease func(m *QuarantinedMessage) error
+
+	mu       sync.Mutex
+	messages map[string]*QuarantinedMessage
+}
+
+// NewMemoryQuarantine returns a new in-memory quarantine store.
+func NewMemoryQuarantine() *MemoryQuarantine {
+	return &MemoryQuarantine{messages: make(map[string]*QuarantinedMessage)}
+}
+
+// Quarantine saves the email in memory.
+func (q *MemoryQuarantine) Quarantine(email *Email, reason string) (string, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.messages[id] = &QuarantinedMessage{
+		ID:     id,
+		Email:  email,
+		Reason: reason,
+		State:  StateHeld,
+		Time:   time.Now(),
+	}
+
+	return id, nil
+}
+
+// Hold puts the message with the given id on hold again with a new reason.
+func (q *MemoryQuarantine) Hold(id, reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	m, ok := q.messages[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	m.State = StateHeld
+	m.Reason = reason
+
+	return nil
+}
+
+// Release approves the message with the given id calling OnRelease.
+// Releasing a message that is not held has no effect.
+func (q *MemoryQuarantine) Release(id string) error {
+	q.mu.Lock()
+	m, ok := q.messages[id]
+	if !ok {
+		q.mu.Unlock()
+		return ErrNotFound
+	}
+
+	if m.State != StateHeld {
+		q.mu.Unlock()
+		return nil
+	}
+
+	// mark as released while OnRelease runs to avoid double releases
+	m.State = StateReleased
+	q.mu.Unlock()
+
+	if q.OnRelease != nil {
+		if err := q.OnRelease(m); err != nil {
+			q.mu.Lock()
+			m.State = StateHeld
+			q.mu.Unlock()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get returns the quarantined message with the given id, or nil if not found.
+func (q *MemoryQuarantine) Get(id string) *QuarantinedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.messages[id]
+}
+
+// List returns the quarantined messages in the given states, or all
+// messages if no state is provided.
+func (q *MemoryQuarantine) List(states ...HoldState) []*QuarantinedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	list := make([]*QuarantinedMessage, 0, len(q.messages))
+	for _, m := range q.messages {
+		if len(states) == 0 || hasState(states, m.State) {
+			list = append(list, m)
+		}
+	}
+
+	return list
+}
+
+// Delete removes the message with the given id from the store.
+func (q *MemoryQuarantine) Delete(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.messages, id)
+}
+
+func hasState(states []HoldState, state HoldState) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHoldRelease(t *testing.T) {
+	store := NewMemoryQuarantine()
+
+	var released []string
+	fail := true
+	store.OnRelease = func(m *QuarantinedMessage) error {
+		if fail {
+			return errors.New("send failed")
+		}
+		released = append(released, m.ID)
+		return nil
+	}
+
+	id, err := store.Quarantine(NewMSG(), "needs approval")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Release(id); err == nil {
+		t.Fatal("expected release error")
+	}
+	if got := store.Get(id).State; got != StateHeld {
+		t.Errorf("got state %s after failed release, want held", got)
+	}
+
+	fail = false
+	if err := store.Release(id); err != nil {
+		t.Fatalf("unexpected release error: %v", err)
+	}
+	// a second release does nothing
+	if err := store.Release(id); err != nil {
+		t.Fatalf("unexpected release error: %v", err)
+	}
+	if len(released) != 1 || released[0] != id {
+		t.Errorf("got released %v, want [%s]", released, id)
+	}
+	if got := len(store.List(StateHeld)); got != 0 {
+		t.Errorf("got %d held messages, want 0", got)
+	}
+
+	if err := store.Hold(id, "flagged again"); err != nil {
+		t.Fatal(err)
+	}
+	if m := store.Get(id); m.State != StateHeld || m.Reason != "flagged again" {
+		t.Errorf("got state %s reason %q, want held again", m.State, m.Reason)
+	}
+
+	if err := store.Release("unknown"); err != ErrNotFound {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,585 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQueueClosed is returned by Enqueue after Close
+var ErrQueueClosed = errors.New("Mail Error: The send queue is closed")
+
+var _ Holder = (*Queue)(nil)
+
+// QueueStore persists the emails of a Queue, saved as drafts, so they are
+// sent after a crash or a restart. MemoryStore and DirStore implement it.
+type QueueStore interface {
+	MessageStore
+	// Delete removes the message with the given id
+	Delete(id string) error
+	// List returns the ids of the messages
+	List() ([]string, error)
+}
+
+// RetryPolicy is the schedule of the attempts of the emails that failed
+// temporarily.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts before the email fails
+	MaxAttempts int
+	// InitialDelay is the delay after the first failed attempt
+	InitialDelay time.Duration
+	// MaxDelay limits the delay between attempts
+	MaxDelay time.Duration
+	// Multiplier multiplies the delay after each failed attempt
+	Multiplier float64
+}
+
+// DefaultRetryPolicy is the retry policy of a Queue without Retry
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  5,
+	InitialDelay: time.Minute,
+	MaxDelay:     time.Hour,
+	Multiplier:   2,
+}
+
+// delay returns the delay after the failed attempt, from 1
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := float64(p.InitialDelay)
+	for i := 1; i < attempt; i++ {
+		d *= p.Multiplier
+		if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+			break
+		}
+	}
+
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+
+	return time.Duration(d)
+}
+
+// IsPermanent returns true if the error of a send is a permanent failure,
+// that fails again if the email is sent again: a 5xx reply of the server,
+// a message rejected by a filter, or a PartialSendError whose recipients
+// all failed permanently. The other errors, like the 4xx replies, the
+// network errors and the timeouts, are temporary.
+func IsPermanent(err error) bool {
+	var transient *TransientError
+	if errors.As(err, &transient) {
+		return false
+	}
+
+	var partial *PartialSendError
+	if errors.As(err, &partial) {
+		for _, r := range partial.Rejected {
+			if !IsPermanent(r.Err) {
+				return false
+			}
+		}
+		return len(partial.Rejected) > 0
+	}
+
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code >= 500
+	}
+
+	var reject *RejectError
+	return errors.As(err, &reject)
+}
+
+// Queue sends emails, and raw messages, in the background with the
+// connections of a pool, retrying the temporary failures with an
+// exponential backoff. The fields must be set before Start.
+type Queue struct {
+	// Retry is the schedule of the retries, DefaultRetryPolicy if not set
+	Retry RetryPolicy
+	// Store, if set, persists the queued emails and messages. They are
+	// loaded by Start, with their attempts reset
+	Store QueueStore
+	// OnResult is called when an email is sent, with a nil error, or when
+	// it fails permanently or after the last attempt
+	OnResult func(id string, email *Email, err error)
+	// OnMessageResult is called like OnResult for the messages added with
+	// EnqueueMessage
+	OnMessageResult func(msg *QueuedMessage, err error)
+	// Limiter, if set, limits the rate of the emails sent to each domain of
+	// their recipients
+	Limiter *AdaptiveLimiter
+
+	pool    *Pool
+	workers int
+
+	mu      sync.Mutex
+	items   map[string]*queueItem
+	started bool
+	wake    chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+	// ctx is canceled by Close, to stop the waits for the Limiter
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// QueuedMessage is a RFC 822 message added with EnqueueMessage, sent as
+// is, like with SendMessage.
+type QueuedMessage struct {
+	// ID is the id of the message in the queue, a random id if not set
+	ID   string   `json:"id"`
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	// Data is the raw message
+	Data    []byte    `json:"data"`
+	Created time.Time `json:"created"`
+}
+
+// queueItem is a queued email, or message
+type queueItem struct {
+	email    *Email
+	msg      *QueuedMessage
+	attempts int
+	next     time.Time
+	sending  bool
+	// held is true while the email awaits approval, for the reason
+	held   bool
+	reason string
+}
+
+// The suffixes of the ids of the hold reasons and of the messages in the
+// Store, the emails are saved as drafts
+const (
+	holdSuffix    = ".hold"
+	messageSuffix = ".msg"
+)
+
+// recipients returns the recipients of the email or message
+func (item *queueItem) recipients() []string {
+	if item.msg != nil {
+		return item.msg.To
+	}
+
+	return item.email.recipients
+}
+
+// NewQueue returns a queue sending with the pool, with the given number of
+// workers sending at the same time.
+func NewQueue(pool *Pool, workers int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Queue{
+		pool:    pool,
+		workers: workers,
+		items:   make(map[string]*queueItem),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start loads the emails and messages of the Store and starts the workers.
+func (q *Queue) Start() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.started {
+		return errors.New("Mail Error: The send queue is already started")
+	}
+
+	if q.Store != nil {
+		ids, err := q.Store.List()
+		if err != nil {
+			return err
+		}
+		var held []string
+		for _, id := range ids {
+			if strings.HasSuffix(id, holdSuffix) {
+				held = append(held, id)
+				continue
+			}
+			if strings.HasSuffix(id, messageSuffix) {
+				data, err := q.Store.Load(id)
+				if err != nil {
+					return err
+				}
+				msg := &QueuedMessage{}
+				if err := json.Unmarshal(data, msg); err != nil {
+					return err
+				}
+				q.items[msg.ID] = &queueItem{msg: msg}
+				continue
+			}
+			email, err := LoadDraft(q.Store, id)
+			if err != nil {
+				return err
+			}
+			q.items[id] = &queueItem{email: email}
+		}
+		for _, id := range held {
+			reason, err := q.Store.Load(id)
+			if err != nil {
+				return err
+			}
+			if item, ok := q.items[strings.TrimSuffix(id, holdSuffix)]; ok {
+				item.held, item.reason = true, string(reason)
+			}
+		}
+	}
+
+	q.started = true
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	return nil
+}
+
+// Enqueue adds a copy of the email to the queue and returns its id. The
+// email is saved in the Store, if set, before Enqueue returns. Like in the
+// drafts, the filters of the email are not kept, the Filters of the pool
+// server apply.
+func (q *Queue) Enqueue(email *Email) (string, error) {
+	if email.Error != nil {
+		return "", email.Error
+	}
+
+	select {
+	case <-q.done:
+		return "", ErrQueueClosed
+	default:
+	}
+
+	// the email can be modified after it's enqueued
+	email = email.clone()
+
+	var id string
+	var err error
+	if q.Store != nil {
+		email.draftID = ""
+		id, err = email.SaveDraft(q.Store)
+	} else {
+		id, err = randomID()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	q.mu.Lock()
+	q.items[id] = &queueItem{email: email, next: time.Now()}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return id, nil
+}
+
+// EnqueueMessage adds a RFC 822 message to the queue and returns its id,
+// the ID of the message if set. The message is saved in the Store, if set,
+// before EnqueueMessage returns. It's sent as is, the filters don't apply.
+func (q *Queue) EnqueueMessage(msg *QueuedMessage) (string, error) {
+	if msg.From == "" {
+		return "", errors.New("Mail Error: No From email specifier")
+	}
+	if len(msg.To) < 1 {
+		return "", errors.New("Mail Error: No recipient specified")
+	}
+
+	select {
+	case <-q.done:
+		return "", ErrQueueClosed
+	default:
+	}
+
+	// the message can be modified after it's enqueued
+	copied := *msg
+	msg = &copied
+	msg.To = append([]string(nil), msg.To...)
+	if msg.ID == "" {
+		id, err := randomID()
+		if err != nil {
+			return "", err
+		}
+		msg.ID = id
+	}
+	if msg.Created.IsZero() {
+		msg.Created = time.Now()
+	}
+
+	q.mu.Lock()
+	_, exists := q.items[msg.ID]
+	q.mu.Unlock()
+	if exists {
+		return "", errors.New("Mail Error: The id is already queued: " + msg.ID)
+	}
+
+	if q.Store != nil {
+		if err := q.saveMessage(msg); err != nil {
+			return "", err
+		}
+	}
+
+	q.mu.Lock()
+	q.items[msg.ID] = &queueItem{msg: msg, next: time.Now()}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return msg.ID, nil
+}
+
+// saveMessage saves the message in the Store
+func (q *Queue) saveMessage(msg *QueuedMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return q.Store.Save(msg.ID+messageSuffix, data)
+}
+
+// Hold holds the queued email with the given id, it's not sent until
+// Release. An email being sent is only held if the send fails temporarily.
+// The hold is saved in the Store, if set, and kept after a restart.
+func (q *Queue) Hold(id, reason string) error {
+	q.mu.Lock()
+	item, ok := q.items[id]
+	if ok {
+		item.held, item.reason = true, reason
+	}
+	q.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	if q.Store != nil {
+		return q.Store.Save(id+holdSuffix, []byte(reason))
+	}
+
+	return nil
+}
+
+// Release approves the held email, it's sent right away.
+func (q *Queue) Release(id string) error {
+	q.mu.Lock()
+	item, ok := q.items[id]
+	if ok && item.held {
+		item.held, item.reason = false, ""
+		item.next = time.Now()
+	}
+	q.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	if q.Store != nil {
+		if err := q.Store.Delete(id + holdSuffix); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Held returns the reasons of the held emails, by id.
+func (q *Queue) Held() map[string]string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	held := make(map[string]string)
+	for id, item := range q.items {
+		if item.held {
+			held[id] = item.reason
+		}
+	}
+
+	return held
+}
+
+// Len returns the number of emails in the queue.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.items)
+}
+
+// Close stops the workers, after the emails being sent. The queued emails
+// are kept in the Store.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	select {
+	case <-q.done:
+	default:
+		close(q.done)
+		q.cancel()
+	}
+	q.mu.Unlock()
+
+	q.wg.Wait()
+
+	return nil
+}
+
+// work sends the emails of the queue when they are due
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for {
+		id, item, wait := q.next()
+		if item != nil {
+			q.send(id, item)
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-q.done:
+			timer.Stop()
+			return
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// next returns the email to send now, or the time to wait for one
+func (q *Queue) next() (string, *queueItem, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return "", nil, time.Hour
+	default:
+	}
+
+	var nextID string
+	var next *queueItem
+	for id, item := range q.items {
+		if !item.sending && !item.held && (next == nil || item.next.Before(next.next)) {
+			nextID, next = id, item
+		}
+	}
+
+	if next == nil {
+		return "", nil, time.Hour
+	}
+	if wait := time.Until(next.next); wait > 0 {
+		return "", nil, wait
+	}
+
+	next.sending = true
+
+	return nextID, next, 0
+}
+
+// send sends an email, and schedules it again if it failed temporarily
+func (q *Queue) send(id string, item *queueItem) {
+	if q.Limiter != nil {
+		if err := q.Limiter.waitDomains(q.ctx, item.recipients()); err != nil {
+			// the queue is closed, the email is kept for the next Start
+			q.mu.Lock()
+			item.sending = false
+			q.mu.Unlock()
+			return
+		}
+	}
+
+	var err error
+	if item.msg != nil {
+		err = q.pool.SendMessage(item.msg.From, item.msg.To, string(item.msg.Data))
+	} else {
+		err = q.pool.Send(item.email)
+	}
+	item.attempts++
+	if q.Limiter != nil {
+		q.Limiter.reportDomains(item.recipients(), err)
+	}
+
+	policy := q.Retry
+	if policy.MaxAttempts == 0 {
+		policy = DefaultRetryPolicy
+	}
+
+	if err == nil || IsPermanent(err) || item.attempts >= policy.MaxAttempts {
+		q.remove(id, item)
+		switch {
+		case item.msg != nil && q.OnMessageResult != nil:
+			q.OnMessageResult(item.msg, err)
+		case item.msg == nil && q.OnResult != nil:
+			q.OnResult(id, item.email, err)
+		}
+		return
+	}
+
+	delay := policy.delay(item.attempts)
+	var transient *TransientError
+	if errors.As(err, &transient) && transient.RetryAfter > delay {
+		delay = transient.RetryAfter
+	}
+
+	// only the recipients that failed temporarily are retried
+	var partial *PartialSendError
+	if errors.As(err, &partial) {
+		var retry []string
+		for _, r := range partial.Rejected {
+			if !IsPermanent(r.Err) {
+				retry = append(retry, r.Address)
+			}
+		}
+		if item.msg != nil {
+			item.msg.To = retry
+			if q.Store != nil {
+				q.saveMessage(item.msg)
+			}
+		} else {
+			item.email.recipients = retry
+			if q.Store != nil {
+				item.email.SaveDraft(q.Store)
+			}
+		}
+	}
+
+	q.mu.Lock()
+	item.next = time.Now().Add(delay)
+	item.sending = false
+	q.mu.Unlock()
+}
+
+// remove removes a sent or failed email
+func (q *Queue) remove(id string, item *queueItem) {
+	q.mu.Lock()
+	delete(q.items, id)
+	q.mu.Unlock()
+
+	if q.Store != nil {
+		if item.msg != nil {
+			q.Store.Delete(id + messageSuffix)
+		} else {
+			q.Store.Delete(id)
+		}
+		q.Store.Delete(id + holdSuffix)
+	}
+}
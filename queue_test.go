@@ -0,0 +1,288 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queueResult is a result reported by the OnResult of a queue
+type queueResult struct {
+	id  string
+	err error
+}
+
+func newTestQueue(ln net.Listener, results chan queueResult) *Queue {
+	q := NewQueue(NewPool(newPoolServer(ln), 1), 1)
+	q.Retry = RetryPolicy{MaxAttempts: 3, InitialDelay: 10 * time.Millisecond, Multiplier: 2}
+	q.OnResult = func(id string, email *Email, err error) {
+		results <- queueResult{id, err}
+	}
+	return q
+}
+
+func TestQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		replies  map[string]string
+		messages int
+		code     int
+	}{
+		{"sent", nil, 1, 0},
+		{"retried", map[string]string{"RCPT": "451 4.7.1 Try again later"}, 1, 0},
+		{"permanent", map[string]string{"RCPT": "550 5.1.1 No such user"}, 0, 550},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ln := newLocalListener(t)
+			defer ln.Close()
+
+			messages := make(chan string, 10)
+			go fakeSMTP(ln, messages, test.replies)
+
+			results := make(chan queueResult, 1)
+			q := newTestQueue(ln, results)
+			if err := q.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer q.Close()
+
+			id, err := q.Enqueue(newPoolEmail())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case result := <-results:
+				var reply *textproto.Error
+				switch {
+				case result.id != id:
+					t.Errorf("got id %q, want %q", result.id, id)
+				case test.code == 0 && result.err != nil:
+					t.Errorf("got error %v", result.err)
+				case test.code != 0 && (!errors.As(result.err, &reply) || reply.Code != test.code):
+					t.Errorf("got error %v, want %d", result.err, test.code)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no result")
+			}
+
+			if len(messages) != test.messages {
+				t.Errorf("got %d messages, want %d", len(messages), test.messages)
+			}
+			if n := q.Len(); n != 0 {
+				t.Errorf("got %d queued emails, want 0", n)
+			}
+		})
+	}
+}
+
+func TestQueueStore(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go fakeSMTP(ln, messages)
+
+	store := NewMemoryStore()
+	results := make(chan queueResult, 1)
+
+	// the queue is closed before it's started, like after a crash
+	q := newTestQueue(ln, results)
+	q.Store = store
+	id, err := q.Enqueue(newPoolEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	q = newTestQueue(ln, results)
+	q.Store = store
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	select {
+	case result := <-results:
+		if result.id != id || result.err != nil {
+			t.Errorf("got %+v, want %s sent", result, id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stored email was not sent")
+	}
+
+	if ids, _ := store.List(); len(ids) != 0 {
+		t.Errorf("got stored emails %v after the send", ids)
+	}
+	if _, err := q.Enqueue(newPoolEmail()); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	if _, err := q.Enqueue(newPoolEmail()); err != ErrQueueClosed {
+		t.Errorf("got error %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestQueueMessage(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go fakeSMTP(ln, messages)
+
+	store := NewMemoryStore()
+	results := make(chan *QueuedMessage, 1)
+	newMessageQueue := func() *Queue {
+		q := newTestQueue(ln, nil)
+		q.Store = store
+		q.OnMessageResult = func(msg *QueuedMessage, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			results <- msg
+		}
+		return q
+	}
+
+	// the queue is closed before it's started, like after a crash
+	q := newMessageQueue()
+	msg := &QueuedMessage{ID: "0123", From: "from@example.com", To: []string{"to@example.com"}, Data: []byte("Subject: Hello\r\n\r\nHello\r\n")}
+	if id, err := q.EnqueueMessage(msg); err != nil || id != msg.ID {
+		t.Fatalf("got id %q, error %v, want %s", id, err, msg.ID)
+	}
+	if _, err := q.EnqueueMessage(msg); err == nil {
+		t.Error("expected error adding the same id twice")
+	}
+	q.Close()
+
+	q = newMessageQueue()
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	select {
+	case sent := <-results:
+		if sent.ID != msg.ID || sent.From != msg.From || sent.Created.IsZero() {
+			t.Errorf("got %+v, want %+v", sent, msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stored message was not sent")
+	}
+	if got := <-messages; !strings.Contains(got, "Subject: Hello") {
+		t.Errorf("got message %q", got)
+	}
+
+	if ids, _ := store.List(); len(ids) != 0 {
+		t.Errorf("got stored entries %v after the send", ids)
+	}
+}
+
+func TestQueueHold(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go fakeSMTP(ln, messages)
+
+	store := NewMemoryStore()
+	results := make(chan queueResult, 1)
+
+	q := newTestQueue(ln, results)
+	q.Store = store
+	id, err := q.Enqueue(newPoolEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Hold(id, "awaiting approval"); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	// the hold is kept after a restart
+	q = newTestQueue(ln, results)
+	q.Store = store
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	select {
+	case result := <-results:
+		t.Fatalf("got %+v, want the email held", result)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if held := q.Held(); held[id] != "awaiting approval" {
+		t.Errorf("got held %v, want %s", held, id)
+	}
+	if err := q.Hold("missing", "reason"); err != ErrNotFound {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+
+	if err := q.Release(id); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case result := <-results:
+		if result.id != id || result.err != nil {
+			t.Errorf("got %+v, want %s sent", result, id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the released email was not sent")
+	}
+
+	if ids, _ := store.List(); len(ids) != 0 {
+		t.Errorf("got stored entries %v after the send", ids)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: time.Minute, MaxDelay: 5 * time.Minute, Multiplier: 2}
+
+	for attempt, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		if got := policy.delay(attempt + 1); got != want {
+			t.Errorf("delay(%d) = %v, want %v", attempt+1, got, want)
+		}
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&textproto.Error{Code: 550, Msg: "5.1.1 No such user"}, true},
+		{transientError(&textproto.Error{Code: 451, Msg: "4.7.1 Try again later"}), false},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, false},
+		{io.EOF, false},
+		{errors.New("Mail Error: unknown"), false},
+		{&RejectError{Reason: "spam"}, true},
+		{timeoutError("Mail Error: SMTP Connection timed out"), false},
+		{&PartialSendError{Rejected: []RecipientError{{"a@example.com", &textproto.Error{Code: 550}}}}, true},
+		{&PartialSendError{Rejected: []RecipientError{{"a@example.com", &textproto.Error{Code: 452}}}}, false},
+	}
+
+	// the server is down
+	ln := newLocalListener(t)
+	ln.Close()
+	_, err := newPoolServer(ln).Connect()
+	if err == nil {
+		t.Fatal("connected to a closed listener")
+	}
+	tests = append(tests, struct {
+		err  error
+		want bool
+	}{err, false})
+
+	for _, test := range tests {
+		if got := IsPermanent(test.err); got != test.want {
+			t.Errorf("IsPermanent(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
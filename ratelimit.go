@@ -0,0 +1,225 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdaptiveLimiter limits the rate of the messages sent to each destination
+// domain. Like the backoff of the MTAs, the rate of a domain is divided when
+// it defers a message with 421 or 450, and grows back to Rate after
+// sustained successes.
+type AdaptiveLimiter struct {
+	// Rate is the maximum rate, in messages per second, of each domain
+	Rate float64
+	// MinRate is the rate a domain never goes below, Rate / 64 if not set
+	MinRate float64
+	// Backoff divides the rate of a domain after each deferral, 2 if not set
+	Backoff float64
+	// RampUp is the number of successes in a row that double the rate of a
+	// domain, up to Rate, 10 if not set
+	RampUp int
+
+	mu      sync.Mutex
+	domains map[string]*domainRate
+}
+
+// domainRate is the current rate of a domain
+type domainRate struct {
+	rate      float64
+	next      time.Time
+	successes int
+}
+
+// NewAdaptiveLimiter returns a limiter sending up to rate messages per
+// second to each domain.
+func NewAdaptiveLimiter(rate float64) *AdaptiveLimiter {
+	return &AdaptiveLimiter{Rate: rate}
+}
+
+// domain returns the rate of a domain, added at the maximum rate
+func (l *AdaptiveLimiter) domain(domain string) *domainRate {
+	if l.domains == nil {
+		l.domains = make(map[string]*domainRate)
+	}
+
+	d, ok := l.domains[domain]
+	if !ok {
+		d = &domainRate{rate: l.Rate}
+		l.domains[domain] = d
+	}
+
+	return d
+}
+
+// Reserve reserves the next send to the domain and returns the delay to
+// wait before it.
+func (l *AdaptiveLimiter) Reserve(domain string) time.Duration {
+	domain = strings.ToLower(domain)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.Rate <= 0 {
+		return 0
+	}
+
+	d := l.domain(domain)
+	now := time.Now()
+	if d.next.Before(now) {
+		d.next = now
+	}
+	wait := d.next.Sub(now)
+	d.next = d.next.Add(time.Duration(float64(time.Second) / d.rate))
+
+	return wait
+}
+
+// Wait blocks until the next send to the domain is allowed, or the context
+// is done.
+func (l *AdaptiveLimiter) Wait(ctx context.Context, domain string) error {
+	wait := l.Reserve(domain)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Report adjusts the rate of the domain with the result of a send to it:
+// a 421 or 450 deferral slows the domain down, a success counts towards
+// ramping it back up. The other errors don't change the rate.
+func (l *AdaptiveLimiter) Report(domain string, err error) {
+	domain = strings.ToLower(domain)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.Rate <= 0 {
+		return
+	}
+
+	d := l.domain(domain)
+	switch {
+	case err == nil:
+		d.successes++
+		if d.successes < l.rampUp() {
+			return
+		}
+		d.successes = 0
+		if d.rate *= 2; d.rate > l.Rate {
+			d.rate = l.Rate
+		}
+	case isDeferral(err):
+		d.successes = 0
+		if d.rate /= l.backoff(); d.rate < l.minRate() {
+			d.rate = l.minRate()
+		}
+	}
+
+	// the domains back at the maximum rate are forgotten
+	if d.rate >= l.Rate && d.successes == 0 && !d.next.After(time.Now()) {
+		delete(l.domains, domain)
+	}
+}
+
+// DomainRate returns the current rate of the domain, in messages per second.
+func (l *AdaptiveLimiter) DomainRate(domain string) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if d, ok := l.domains[strings.ToLower(domain)]; ok {
+		return d.rate
+	}
+
+	return l.Rate
+}
+
+func (l *AdaptiveLimiter) minRate() float64 {
+	if l.MinRate > 0 {
+		return l.MinRate
+	}
+
+	return l.Rate / 64
+}
+
+func (l *AdaptiveLimiter) backoff() float64 {
+	if l.Backoff > 1 {
+		return l.Backoff
+	}
+
+	return 2
+}
+
+func (l *AdaptiveLimiter) rampUp() int {
+	if l.RampUp > 0 {
+		return l.RampUp
+	}
+
+	return 10
+}
+
+// isDeferral returns true if the server deferred the message because of
+// the rate: 421 service not available, or 450 mailbox unavailable
+func isDeferral(err error) bool {
+	var reply *textproto.Error
+	return errors.As(err, &reply) && (reply.Code == 421 || reply.Code == 450)
+}
+
+// addressDomain returns the domain of an address, lower case
+func addressDomain(address string) string {
+	return strings.ToLower(address[strings.LastIndex(address, "@")+1:])
+}
+
+// waitDomains waits for the limiter for each domain of the recipients
+func (l *AdaptiveLimiter) waitDomains(ctx context.Context, recipients []string) error {
+	seen := make(map[string]bool)
+	for _, r := range recipients {
+		domain := addressDomain(r)
+		if seen[domain] {
+			continue
+		}
+		seen[domain] = true
+		if err := l.Wait(ctx, domain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reportDomains reports the result of a send to the domains of the
+// recipients, per recipient for a PartialSendError
+func (l *AdaptiveLimiter) reportDomains(recipients []string, err error) {
+	results := make(map[string]error)
+	var partial *PartialSendError
+	if errors.As(err, &partial) {
+		for _, r := range partial.Accepted {
+			results[addressDomain(r)] = nil
+		}
+		// a deferral of one recipient slows its domain down
+		for _, r := range partial.Rejected {
+			results[addressDomain(r.Address)] = r.Err
+		}
+	} else {
+		for _, r := range recipients {
+			results[addressDomain(r)] = err
+		}
+	}
+
+	for domain, err := range results {
+		l.Report(domain, err)
+	}
+}
@@ -0,0 +1,71 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := &AdaptiveLimiter{Rate: 8, RampUp: 2}
+	deferral := transientError(&textproto.Error{Code: 421, Msg: "4.7.0 Too many messages, slow down"})
+
+	l.Report("Example.com", deferral)
+	l.Report("example.com", deferral)
+	if got := l.DomainRate("example.com"); got != 2 {
+		t.Errorf("got rate %v after 2 deferrals, want 2", got)
+	}
+	if got := l.DomainRate("example.org"); got != 8 {
+		t.Errorf("got rate %v for another domain, want 8", got)
+	}
+
+	// the other errors don't change the rate
+	l.Report("example.com", &textproto.Error{Code: 550, Msg: "5.1.1 No such user"})
+	l.Report("example.com", errors.New("Mail Error: No recipient specified"))
+	if got := l.DomainRate("example.com"); got != 2 {
+		t.Errorf("got rate %v after other errors, want 2", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		l.Report("example.com", nil)
+	}
+	if got := l.DomainRate("example.com"); got != 4 {
+		t.Errorf("got rate %v after 3 successes, want 4", got)
+	}
+	for i := 0; i < 10; i++ {
+		l.Report("example.com", nil)
+	}
+	if got := l.DomainRate("example.com"); got != 8 {
+		t.Errorf("got rate %v after the ramp up, want 8", got)
+	}
+
+	for i := 0; i < 20; i++ {
+		l.Report("example.com", deferral)
+	}
+	if got := l.DomainRate("example.com"); got != 8.0/64 {
+		t.Errorf("got rate %v, want the minimum rate", got)
+	}
+}
+
+func TestAdaptiveLimiterReserve(t *testing.T) {
+	l := NewAdaptiveLimiter(10)
+	l.Report("example.com", &textproto.Error{Code: 450, Msg: "4.2.1 Rate limited"})
+
+	if wait := l.Reserve("example.com"); wait != 0 {
+		t.Errorf("got wait %v for the first send, want 0", wait)
+	}
+	if wait := l.Reserve("example.com"); wait < 150*time.Millisecond || wait > 200*time.Millisecond {
+		t.Errorf("got wait %v at 5 messages/s, want 200ms", wait)
+	}
+	if wait := l.Reserve("example.org"); wait != 0 {
+		t.Errorf("got wait %v for another domain, want 0", wait)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, "example.com"); err != context.Canceled {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
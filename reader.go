@@ -0,0 +1,221 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// segment is a piece of the message, either literal data or a source that
+// is encoded when it's read
+type segment struct {
+	data    []byte
+	source  io.Reader
+	encoder Encoder
+	options EncoderOptions
+}
+
+// reader returns a reader of the segment, from the start of its source if
+// it's seekable
+func (s segment) reader() io.Reader {
+	if s.source == nil {
+		return bytes.NewReader(s.data)
+	}
+
+	if seeker, ok := s.source.(io.Seeker); ok {
+		seeker.Seek(0, io.SeekStart)
+	}
+
+	if s.encoder == nil {
+		return s.source
+	}
+
+	r := &encodingReader{source: s.source}
+	r.encoder = s.encoder.NewWriter(&r.buf, s.options)
+
+	return r
+}
+
+// encodingChunk is the size of the source read each time by encodingReader,
+// a multiple of 3 so base64 doesn't hold bytes back
+const encodingChunk = 3 * 1024
+
+// encodingReader encodes its source as it's read
+type encodingReader struct {
+	source  io.Reader
+	encoder io.WriteCloser
+	buf     bytes.Buffer
+	chunk   []byte
+	done    bool
+}
+
+func (r *encodingReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 && !r.done {
+		if r.chunk == nil {
+			r.chunk = make([]byte, encodingChunk)
+		}
+
+		n, err := r.source.Read(r.chunk)
+		r.encoder.Write(r.chunk[:n])
+
+		if err == io.EOF {
+			r.encoder.Close()
+			r.done = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	if r.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	return r.buf.Read(p)
+}
+
+// MessageReader reads an email message. The parts are encoded only when
+// they are read, so building a reader is cheap even for large messages.
+type MessageReader struct {
+	segments []segment
+	// lengths of the segments, -1 until they are read
+	lengths []int64
+	current io.Reader
+	next    int
+	read    int64
+	pos     int64
+}
+
+func newMessageReader(segments []segment) *MessageReader {
+	r := &MessageReader{segments: segments, lengths: make([]int64, len(segments))}
+	for i, s := range segments {
+		r.lengths[i] = -1
+		if s.source == nil {
+			r.lengths[i] = int64(len(s.data))
+		}
+	}
+
+	return r
+}
+
+// Read reads the next bytes of the message.
+func (r *MessageReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.next == len(r.segments) {
+				return 0, io.EOF
+			}
+			r.current = r.segments[r.next].reader()
+			r.next++
+			r.read = 0
+		}
+
+		n, err := r.current.Read(p)
+		r.read += int64(n)
+		r.pos += int64(n)
+
+		if err == io.EOF {
+			r.lengths[r.next-1] = r.read
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+// Pos returns the offset of the next byte to read.
+func (r *MessageReader) Pos() int64 {
+	return r.pos
+}
+
+// Seek sets the offset of the next byte to read, so an upload in chunks can
+// be resumed after a failure. whence can be io.SeekStart or io.SeekCurrent.
+// The parts before the offset are encoded again, unless they were already
+// read. It fails if the source of any part is not seekable.
+func (r *MessageReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	default:
+		return r.pos, errors.New("Mail Error: Unsupported seek whence")
+	}
+
+	if offset < 0 {
+		return r.pos, errors.New("Mail Error: Negative seek offset")
+	}
+
+	for _, s := range r.segments {
+		if _, ok := s.source.(io.Seeker); s.source != nil && !ok {
+			return r.pos, errors.New("Mail Error: Message part is not seekable")
+		}
+	}
+
+	// skip the segments with known length before the offset
+	i, start := 0, int64(0)
+	for ; i < len(r.segments) && r.lengths[i] >= 0 && start+r.lengths[i] <= offset; i++ {
+		start += r.lengths[i]
+	}
+
+	r.current = nil
+	r.next = i
+	r.pos = start
+
+	if _, err := io.CopyN(ioutil.Discard, r, offset-start); err != nil && err != io.EOF {
+		return r.pos, err
+	}
+
+	return r.pos, nil
+}
+
+// NewReader returns a reader of the email message, applying the email
+// filters. The message parts are laid out when the reader is created, but
+// they are only encoded when they are read. In 7-bit mode the message is
+// built and checked when the reader is created.
+func (email *Email) NewReader() (*MessageReader, error) {
+	if email.Error != nil {
+		return nil, email.Error
+	}
+
+	filtered, err := email.applyFilters(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if filtered.sevenBit {
+		// the 7-bit check needs the whole message before it's read
+		message, err := filtered.buildMessage()
+		if err != nil {
+			return nil, err
+		}
+		return newMessageReader([]segment{{data: []byte(message)}}), nil
+	}
+
+	msg, err := filtered.build()
+	if err != nil {
+		return nil, err
+	}
+
+	return newMessageReader(msg.segments), nil
+}
+
+// WriteTo writes the email message to w, applying the email filters, and
+// returns the number of bytes written. The parts are encoded while they
+// are written, without building the whole message in memory first, except
+// in 7-bit mode, see NewReader.
+func (email *Email) WriteTo(w io.Writer) (int64, error) {
+	if email.Error != nil {
+		return 0, email.Error
+	}
+
+	r, err := email.NewReader()
+	if err != nil {
+		return 0, err
+	}
+
+	return io.Copy(w, r)
+}
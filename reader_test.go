@@ -0,0 +1,196 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	mailmime "github.com/xhit/go-simple-mail/v2/mime"
+)
+
+func TestEncodedSegments(t *testing.T) {
+	body := []byte(strings.Repeat("<p>Report line with àccents = and a long text</p>\n", 2000))
+
+	for _, encoding := range []encoding{EncodingQuotedPrintable, EncodingBase64} {
+		msg := newMessage(NewMSG())
+		msg.writeBody(body, encoding)
+
+		got, err := ioutil.ReadAll(newMessageReader(msg.segments))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := mailmime.QPEncode(body)
+		if encoding == EncodingBase64 {
+			want = mailmime.Base64Encode(body)
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: read body differs from the encoded body", encoding.string())
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, strings.Repeat("Hello ", 1000)).
+		AddAttachmentData(bytes.Repeat([]byte{1, 2, 3}, 5000), "data.bin", "").
+		SetBoundaryFunc(func() string { return "boundary" }).
+		SetClock(fixedClock(time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)))
+
+	r, err := email.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the headers order is random, compare the sorted lines
+	lines := func(msg string) string {
+		l := strings.Split(msg, "\r\n")
+		sort.Strings(l)
+		return strings.Join(l, "\n")
+	}
+
+	if want := email.GetMessage(); lines(string(got)) != lines(want) {
+		t.Errorf("read message differs from GetMessage:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, strings.Repeat("Hello ", 1000)).
+		AddAttachmentData(bytes.Repeat([]byte{1, 2, 3}, 5000), "data.bin", "").
+		SetBoundaryFunc(func() string { return "boundary" }).
+		SetClock(fixedClock(time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)))
+
+	var _ io.WriterTo = email
+
+	var buf bytes.Buffer
+	n, err := email.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("got %d bytes written, wrote %d", n, buf.Len())
+	}
+
+	want := email.GetMessage()
+	if len(want) != buf.Len() || !strings.Contains(buf.String(), "\r\n--boundary--") {
+		t.Errorf("written message differs from GetMessage:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	email.Error = errors.New("invalid")
+	if _, err := email.WriteTo(&buf); err == nil {
+		t.Error("expected the email error")
+	}
+}
+
+func TestReaderSeek(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, strings.Repeat("Hello ", 1000)).
+		AddAlternative(TextHTML, strings.Repeat("<p>Hello</p>", 1000)).
+		AddAttachmentData(bytes.Repeat([]byte{1, 2, 3}, 5000), "data.bin", "")
+
+	r, err := email.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// read a first chunk, then seek before reading the rest
+	chunk := make([]byte, 5000)
+	if _, err = r.Read(chunk); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Pos() != int64(len(full)) {
+		t.Errorf("got Pos %d, want %d", r.Pos(), len(full))
+	}
+
+	for _, offset := range []int64{0, 1, 100, 4000, int64(len(full)) / 2, int64(len(full)) - 1} {
+		pos, err := r.Seek(offset, io.SeekStart)
+		if err != nil || pos != offset {
+			t.Fatalf("Seek(%d) = %d, %v", offset, pos, err)
+		}
+
+		rest, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(rest, full[offset:]) {
+			t.Errorf("read after Seek(%d) differs from the message", offset)
+		}
+	}
+
+	r.Seek(100, io.SeekStart)
+	if pos, _ := r.Seek(-50, io.SeekCurrent); pos != 50 {
+		t.Errorf("got position %d after seeking back, want 50", pos)
+	}
+}
+
+func TestAddAttachmentReader(t *testing.T) {
+	data := bytes.Repeat([]byte("streamed data "), 50000)
+
+	newEmail := func(r io.Reader) *Email {
+		email := NewMSG()
+		email.SetFrom("from@example.com").
+			AddTo("to@example.com").
+			SetBody(TextPlain, "Hello").
+			AddAttachmentReader(r, "data.txt", "").
+			AddHeader("Message-Id", "<stream@example.com>").
+			SetBoundaryFunc(func() string { return "boundary" }).
+			SetClock(fixedClock(time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)))
+		return email
+	}
+
+	stream := newEmail(ioutil.NopCloser(bytes.NewReader(data)))
+	r, err := stream.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := newEmail(bytes.NewReader(data)).GetMessage()
+	if string(got) != want {
+		t.Error("the streamed message differs from the message built from a seekable reader")
+	}
+	if !strings.Contains(want, string(mailmime.Base64Encode(data[:3000]))[:100]) {
+		t.Error("the attachment is not base64 encoded")
+	}
+
+	if _, err = r.Seek(0, io.SeekStart); err == nil {
+		t.Error("expected an error seeking a message with a non-seekable attachment")
+	}
+
+	if _, err = stream.SaveDraft(NewMemoryStore()); err == nil {
+		t.Error("expected an error saving a draft with an attachment stream")
+	}
+
+	if entries := stream.Manifest(); entries[0].Size != -1 || entries[0].SHA256 != "" {
+		t.Errorf("got manifest entry %+v of a consumed stream", entries[0])
+	}
+}
@@ -0,0 +1,315 @@
+package mail
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeliveryState is the state of a sent message for a recipient.
+type DeliveryState string
+
+const (
+	// DeliveryPending is the state of the recipients without reports
+	DeliveryPending DeliveryState = "pending"
+	// DeliveryDelivered is reported by a DSN when the message was delivered
+	// or relayed to a system that doesn't send DSNs
+	DeliveryDelivered DeliveryState = "delivered"
+	// DeliveryDelayed is reported by a DSN when the delivery is still
+	// being retried
+	DeliveryDelayed DeliveryState = "delayed"
+	// DeliveryFailed is reported by a DSN when the delivery failed
+	DeliveryFailed DeliveryState = "failed"
+	// DeliveryDisplayed is reported by a MDN when the message was displayed
+	DeliveryDisplayed DeliveryState = "displayed"
+	// DeliveryDeleted is reported by a MDN when the message was deleted
+	// without being displayed, or the receipt was denied
+	DeliveryDeleted DeliveryState = "deleted"
+)
+
+// RecipientDelivery is the delivery state of a message for a recipient.
+type RecipientDelivery struct {
+	State DeliveryState
+	// Status is the DSN status code, like 5.1.1
+	Status string
+	// Diagnostic is the DSN diagnostic code or the MDN disposition
+	Diagnostic string
+	Updated    time.Time
+}
+
+// DeliveryStatus is the delivery state of a sent message.
+type DeliveryStatus struct {
+	MessageID  string
+	Sent       time.Time
+	Recipients map[string]RecipientDelivery
+}
+
+// Report is a delivery report parsed from a DSN (RFC 3464) or MDN
+// (RFC 8098).
+type Report struct {
+	// MessageID is the id of the original message
+	MessageID  string
+	Recipients map[string]RecipientDelivery
+	// MIC is the Received-Content-MIC of an AS1 receipt
+	MIC string
+}
+
+// ErrNotReport is returned when a message is not a DSN or MDN
+var ErrNotReport = errors.New("Mail Error: Message is not a delivery report")
+
+// DeliveryTracker correlates the Message-IDs of the sent emails with the
+// DSNs and MDNs received for them, keeping the delivery state of each
+// recipient. It's an AuditLog, so setting it as the AuditLog of the client
+// tracks every email sent. The reports are passed to Handle, from any
+// source of incoming mail.
+type DeliveryTracker struct {
+	mu       sync.Mutex
+	messages map[string]*DeliveryStatus
+}
+
+// NewDeliveryTracker returns an empty delivery tracker.
+func NewDeliveryTracker() *DeliveryTracker {
+	return &DeliveryTracker{messages: make(map[string]*DeliveryStatus)}
+}
+
+// Record tracks the sent emails of the audit entries.
+func (t *DeliveryTracker) Record(entry *AuditEntry) error {
+	if entry.Result == AuditSent && entry.MessageID != "" {
+		t.Track(entry.MessageID, entry.Recipients)
+	}
+
+	return nil
+}
+
+// Track starts tracking a sent message, with all its recipients pending.
+func (t *DeliveryTracker) Track(messageID string, recipients []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := &DeliveryStatus{
+		MessageID:  messageID,
+		Sent:       time.Now(),
+		Recipients: make(map[string]RecipientDelivery),
+	}
+	for _, r := range recipients {
+		status.Recipients[strings.ToLower(r)] = RecipientDelivery{State: DeliveryPending}
+	}
+
+	t.messages[normalizeMessageID(messageID)] = status
+}
+
+// Handle parses a received message and, if it's a report of a tracked
+// message, updates the state of its recipients. It returns the parsed
+// report, or ErrNotReport if it isn't a DSN or MDN. The reports of
+// unknown messages are returned but ignored.
+func (t *DeliveryTracker) Handle(r io.Reader) (*Report, error) {
+	report, err := ParseReport(r)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.messages[normalizeMessageID(report.MessageID)]
+	if !ok {
+		return report, nil
+	}
+
+	for address, delivery := range report.Recipients {
+		// a MDN is sent after the delivery, don't downgrade it
+		if current := status.Recipients[address]; current.State == DeliveryDisplayed || current.State == DeliveryDeleted {
+			if delivery.State != DeliveryDisplayed && delivery.State != DeliveryDeleted {
+				continue
+			}
+		}
+		status.Recipients[address] = delivery
+	}
+
+	return report, nil
+}
+
+// Status returns a copy of the delivery status of a tracked message.
+func (t *DeliveryTracker) Status(messageID string) (*DeliveryStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.messages[normalizeMessageID(messageID)]
+	if !ok {
+		return nil, false
+	}
+
+	c := *status
+	c.Recipients = make(map[string]RecipientDelivery, len(status.Recipients))
+	for address, delivery := range status.Recipients {
+		c.Recipients[address] = delivery
+	}
+
+	return &c, true
+}
+
+// Forget stops tracking a message.
+func (t *DeliveryTracker) Forget(messageID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.messages, normalizeMessageID(messageID))
+}
+
+// ParseReport parses a DSN or MDN, a multipart/report message.
+func ParseReport(r io.Reader) (*Report, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" {
+		return nil, ErrNotReport
+	}
+
+	report := &Report{Recipients: make(map[string]RecipientDelivery)}
+	date, _ := m.Header.Date()
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var found bool
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			found = true
+			err = parseDeliveryStatus(p, report, date)
+		case "message/disposition-notification", "message/global-disposition-notification":
+			found = true
+			err = parseDisposition(p, report, date)
+		case "message/rfc822", "message/global", "text/rfc822-headers", "message/global-headers":
+			// the returned message or its headers
+			var header textproto.MIMEHeader
+			header, err = textproto.NewReader(bufio.NewReader(p)).ReadMIMEHeader()
+			if report.MessageID == "" {
+				report.MessageID = header.Get("Message-Id")
+			}
+			if err == io.EOF {
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !found {
+		return nil, ErrNotReport
+	}
+
+	return report, nil
+}
+
+// parseDeliveryStatus parses the per-message and per-recipient fields of a
+// delivery status
+func parseDeliveryStatus(r io.Reader, report *Report, date time.Time) error {
+	tr := textproto.NewReader(bufio.NewReader(r))
+
+	// the per-message fields
+	if _, err := tr.ReadMIMEHeader(); err != nil && err != io.EOF {
+		return err
+	}
+
+	for {
+		fields, err := tr.ReadMIMEHeader()
+		if recipient := reportAddress(fields.Get("Final-Recipient")); recipient != "" {
+			delivery := RecipientDelivery{
+				Status:     fields.Get("Status"),
+				Diagnostic: reportValue(fields.Get("Diagnostic-Code")),
+				Updated:    date,
+			}
+
+			switch strings.ToLower(fields.Get("Action")) {
+			case "delivered", "relayed", "expanded":
+				delivery.State = DeliveryDelivered
+			case "delayed":
+				delivery.State = DeliveryDelayed
+			case "failed":
+				delivery.State = DeliveryFailed
+			default:
+				delivery.State = DeliveryPending
+			}
+
+			report.Recipients[recipient] = delivery
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseDisposition parses the fields of a message disposition notification
+func parseDisposition(r io.Reader, report *Report, date time.Time) error {
+	fields, err := textproto.NewReader(bufio.NewReader(r)).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if id := fields.Get("Original-Message-Id"); id != "" {
+		report.MessageID = id
+	}
+	report.MIC = fields.Get("Received-Content-Mic")
+
+	mdn := newMDN(fields, date)
+	recipient := mdn.OriginalRecipient
+	if recipient == "" {
+		recipient = mdn.FinalRecipient
+	}
+	if recipient == "" {
+		return nil
+	}
+
+	report.Recipients[recipient] = RecipientDelivery{
+		State:      mdn.State(),
+		Diagnostic: fields.Get("Disposition"),
+		Updated:    date,
+	}
+
+	return nil
+}
+
+// reportAddress returns the address of a report field like
+// "rfc822; user@example.com"
+func reportAddress(value string) string {
+	return strings.ToLower(strings.Trim(reportValue(value), "<> "))
+}
+
+// reportValue returns the value of a typed report field without the type
+func reportValue(value string) string {
+	if i := strings.Index(value, ";"); i >= 0 {
+		value = value[i+1:]
+	}
+
+	return strings.TrimSpace(value)
+}
+
+func normalizeMessageID(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
+}
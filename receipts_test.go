@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDSN = `From: MAILER-DAEMON@mx.example.com
+To: from@example.com
+Subject: Delivery Status Notification
+Date: Fri, 01 Mar 2024 12:00:00 +0000
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="b"
+
+--b
+Content-Type: text/plain
+
+Your message could not be delivered to one recipient.
+
+--b
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.com
+
+Final-Recipient: rfc822; one@example.com
+Action: delivered
+Status: 2.0.0
+
+Final-Recipient: rfc822; Two@example.com
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 No such user
+
+--b
+Content-Type: text/rfc822-headers
+
+Message-Id: <123@example.com>
+From: from@example.com
+Subject: Hello
+
+--b--
+`
+
+const testMDN = `From: one@example.com
+To: from@example.com
+Subject: Read: Hello
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=disposition-notification; boundary="b"
+
+--b
+Content-Type: text/plain
+
+The message was displayed.
+
+--b
+Content-Type: message/disposition-notification
+
+Reporting-UA: client.example.com; Mail
+Final-Recipient: rfc822; one@example.com
+Original-Message-ID: <123@example.com>
+Disposition: manual-action/MDN-sent-manually; displayed
+
+--b--
+`
+
+func TestDeliveryTracker(t *testing.T) {
+	tracker := NewDeliveryTracker()
+	tracker.Record(&AuditEntry{MessageID: "<123@example.com>", Recipients: []string{"one@example.com", "two@example.com", "three@example.com"}, Result: AuditSent})
+
+	for _, report := range []string{testDSN, testMDN} {
+		if _, err := tracker.Handle(strings.NewReader(strings.Replace(report, "\n", "\r\n", -1))); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+	}
+
+	status, ok := tracker.Status("123@example.com")
+	if !ok {
+		t.Fatal("message not tracked")
+	}
+
+	for address, want := range map[string]DeliveryState{
+		"one@example.com":   DeliveryDisplayed,
+		"two@example.com":   DeliveryFailed,
+		"three@example.com": DeliveryPending,
+	} {
+		if got := status.Recipients[address].State; got != want {
+			t.Errorf("%s: got %s, want %s", address, got, want)
+		}
+	}
+
+	if got := status.Recipients["two@example.com"]; got.Status != "5.1.1" || got.Diagnostic != "550 5.1.1 No such user" {
+		t.Errorf("got %+v", got)
+	}
+
+	if _, err := tracker.Handle(strings.NewReader("Subject: Hello\r\n\r\nHello")); err != ErrNotReport {
+		t.Errorf("got %v, want ErrNotReport", err)
+	}
+}
@@ -0,0 +1,320 @@
+// Package recipients loads and validates recipient lists from CSV or JSON
+// files for bulk sends with Go Simple Mail. The recipients are read one at
+// a time, so large lists can be streamed to the sender, and the invalid
+// rows are reported without stopping the load:
+//
+//	r, err := recipients.NewCSVReader(file)
+//	...
+//	for {
+//		rcpt, err := r.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		if rowErr, ok := err.(*recipients.RowError); ok {
+//			log.Print(rowErr)
+//			continue
+//		}
+//		...
+//		email.AddTo(rcpt.String())
+//	}
+//
+// For the merge sends of a mail.BulkSender, the list is streamed in batches
+// of recipients with their merge fields:
+//
+//	invalid, err := r.EachBatch(1000, func(batch []mail.MergeRecipient) error {
+//		return bulk.Send(ctx, batch)
+//	})
+package recipients
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	netmail "net/mail"
+	"strconv"
+	"strings"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// Recipient is a recipient of a list, with its merge fields.
+type Recipient struct {
+	Name    string
+	Address string
+	// Fields are the merge fields of the recipient, like the first name
+	// or the account id
+	Fields map[string]string
+	// Row is the number of the row in the list, starting at 1 for the
+	// first recipient
+	Row int
+}
+
+// String returns the recipient as an address with display name, to add it
+// to an email.
+func (r *Recipient) String() string {
+	return (&netmail.Address{Name: r.Name, Address: r.Address}).String()
+}
+
+// MergeRecipient returns the recipient of a mail.BulkSender, with the merge
+// fields of the recipient and its name as the "name" field, unless the list
+// has a name field.
+func (r *Recipient) MergeRecipient() mail.MergeRecipient {
+	fields := make(map[string]string, len(r.Fields)+1)
+	fields["name"] = r.Name
+	for k, v := range r.Fields {
+		fields[k] = v
+	}
+
+	return mail.MergeRecipient{Address: r.String(), Fields: fields}
+}
+
+// RowError is returned for an invalid row of a list. The reader can
+// continue with the next row.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return "recipients: row " + strconv.Itoa(e.Row) + ": " + e.Err.Error()
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ErrDuplicate is the error of the rows with an address already read
+var ErrDuplicate = errors.New("duplicate address")
+
+// Reader reads the recipients of a list.
+type Reader struct {
+	next func() (*Recipient, error)
+	row  int
+	seen map[string]bool
+}
+
+// addressColumns are the column names accepted for the address
+var addressColumns = map[string]bool{"address": true, "email": true, "e-mail": true, "mail": true}
+
+// NewCSVReader returns a reader of a CSV list. The first row has the
+// column names: "name", "address" (or "email") and the merge fields.
+func NewCSVReader(r io.Reader) (*Reader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	columns, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("recipients: reading the CSV header: " + err.Error())
+	}
+
+	nameColumn, addressColumn := -1, -1
+	for i, c := range columns {
+		columns[i] = strings.TrimSpace(c)
+		switch name := strings.ToLower(columns[i]); {
+		case name == "name" && nameColumn < 0:
+			nameColumn = i
+		case addressColumns[name] && addressColumn < 0:
+			addressColumn = i
+		}
+	}
+
+	if addressColumn < 0 {
+		return nil, errors.New("recipients: the CSV header has no address column")
+	}
+
+	reader := &Reader{seen: make(map[string]bool)}
+	reader.next = func() (*Recipient, error) {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				return nil, &RowError{Err: err}
+			}
+			return nil, err
+		}
+
+		if len(record) != len(columns) {
+			return nil, &RowError{Err: fmt.Errorf("got %d columns, want %d", len(record), len(columns))}
+		}
+
+		rcpt := &Recipient{Address: record[addressColumn], Fields: make(map[string]string)}
+		for i, value := range record {
+			switch i {
+			case nameColumn:
+				rcpt.Name = value
+			case addressColumn:
+			default:
+				rcpt.Fields[columns[i]] = value
+			}
+		}
+
+		return rcpt, nil
+	}
+
+	return reader, nil
+}
+
+// NewJSONReader returns a reader of a JSON list, an array of objects with
+// the "name", "address" (or "email") and the merge fields, either as
+// members of the object or of a "fields" object.
+func NewJSONReader(r io.Reader) (*Reader, error) {
+	dec := json.NewDecoder(r)
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return nil, errors.New("recipients: the JSON list is not an array")
+	}
+
+	reader := &Reader{seen: make(map[string]bool)}
+	reader.next = func() (*Recipient, error) {
+		if !dec.More() {
+			return nil, io.EOF
+		}
+
+		var object map[string]interface{}
+		if err := dec.Decode(&object); err != nil {
+			if _, ok := err.(*json.UnmarshalTypeError); ok {
+				return nil, &RowError{Err: err}
+			}
+			return nil, errors.New("recipients: " + err.Error())
+		}
+
+		rcpt := &Recipient{Fields: make(map[string]string)}
+		for key, value := range object {
+			switch name := strings.ToLower(key); {
+			case name == "name":
+				rcpt.Name = jsonString(value)
+			case addressColumns[name]:
+				rcpt.Address = jsonString(value)
+			case name == "fields":
+				fields, ok := value.(map[string]interface{})
+				if !ok {
+					return nil, &RowError{Err: errors.New("fields is not an object")}
+				}
+				for k, v := range fields {
+					rcpt.Fields[k] = jsonString(v)
+				}
+			default:
+				rcpt.Fields[key] = jsonString(value)
+			}
+		}
+
+		return rcpt, nil
+	}
+
+	return reader, nil
+}
+
+// jsonString returns a JSON value as a merge field
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+
+	data, _ := json.Marshal(value)
+
+	return string(data)
+}
+
+// Next returns the next valid recipient, a *RowError if the row is
+// invalid, or io.EOF at the end of the list. Other errors stop the read.
+func (r *Reader) Next() (*Recipient, error) {
+	r.row++
+
+	rcpt, err := r.next()
+	if err != nil {
+		if rowErr, ok := err.(*RowError); ok {
+			rowErr.Row = r.row
+		}
+		return nil, err
+	}
+
+	rcpt.Row = r.row
+
+	address, err := netmail.ParseAddress(strings.TrimSpace(rcpt.Address))
+	if err != nil {
+		return nil, &RowError{Row: r.row, Err: errors.New("invalid address " + strconv.Quote(rcpt.Address))}
+	}
+
+	rcpt.Address = address.Address
+	if rcpt.Name == "" {
+		rcpt.Name = address.Name
+	}
+
+	key := strings.ToLower(rcpt.Address)
+	if r.seen[key] {
+		return nil, &RowError{Row: r.row, Err: ErrDuplicate}
+	}
+	r.seen[key] = true
+
+	return rcpt, nil
+}
+
+// Each calls fn with every valid recipient of the list, stopping if it
+// returns an error, and returns the invalid rows.
+func (r *Reader) Each(fn func(*Recipient) error) ([]*RowError, error) {
+	var invalid []*RowError
+
+	for {
+		rcpt, err := r.Next()
+		if err == io.EOF {
+			return invalid, nil
+		}
+		if rowErr, ok := err.(*RowError); ok {
+			invalid = append(invalid, rowErr)
+			continue
+		}
+		if err != nil {
+			return invalid, err
+		}
+
+		if err = fn(rcpt); err != nil {
+			return invalid, err
+		}
+	}
+}
+
+// Load reads all the valid recipients of the list and the invalid rows.
+func (r *Reader) Load() ([]*Recipient, []*RowError, error) {
+	var list []*Recipient
+
+	invalid, err := r.Each(func(rcpt *Recipient) error {
+		list = append(list, rcpt)
+		return nil
+	})
+
+	return list, invalid, err
+}
+
+// EachBatch calls fn with the valid recipients of the list, converted to
+// merge recipients, in batches of up to size recipients, so a large list
+// is sent without loading it. It stops if fn returns an error, and returns
+// the invalid rows.
+func (r *Reader) EachBatch(size int, fn func([]mail.MergeRecipient) error) ([]*RowError, error) {
+	if size < 1 {
+		return nil, errors.New("recipients: invalid batch size " + strconv.Itoa(size))
+	}
+
+	batch := make([]mail.MergeRecipient, 0, size)
+	invalid, err := r.Each(func(rcpt *Recipient) error {
+		batch = append(batch, rcpt.MergeRecipient())
+		if len(batch) < size {
+			return nil
+		}
+		err := fn(batch)
+		batch = make([]mail.MergeRecipient, 0, size)
+		return err
+	})
+	if err == nil && len(batch) > 0 {
+		err = fn(batch)
+	}
+
+	return invalid, err
+}
@@ -0,0 +1,200 @@
+package recipients
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func TestCSVReader(t *testing.T) {
+	const list = `Name,Email,Account
+John Smith,john@example.com,1001
+,"Jane Doe <jane@example.com>",1002
+Invalid,not an address,1003
+Again,John@example.com,1004
+Short,short@example.com
+`
+
+	r, err := NewCSVReader(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rcpts, invalid, err := r.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rcpts) != 2 {
+		t.Fatalf("got %d recipients, want 2", len(rcpts))
+	}
+	if got := rcpts[0]; got.Name != "John Smith" || got.Address != "john@example.com" || got.Fields["Account"] != "1001" || got.Row != 1 {
+		t.Errorf("got recipient %+v", got)
+	}
+	if got := rcpts[1].String(); got != `"Jane Doe" <jane@example.com>` {
+		t.Errorf("got recipient %s", got)
+	}
+
+	var rows []int
+	for _, e := range invalid {
+		rows = append(rows, e.Row)
+	}
+	if len(rows) != 3 || rows[0] != 3 || rows[1] != 4 || rows[2] != 5 {
+		t.Errorf("got invalid rows %v, want [3 4 5]", rows)
+	}
+	if invalid[1].Err != ErrDuplicate {
+		t.Errorf("got error %v, want ErrDuplicate", invalid[1].Err)
+	}
+
+	if _, err = NewCSVReader(strings.NewReader("name,account\n")); err == nil {
+		t.Error("expected error for a list without address column")
+	}
+}
+
+func TestJSONReader(t *testing.T) {
+	const list = `[
+		{"name": "John Smith", "address": "john@example.com", "account": 1001},
+		{"email": "jane@example.com", "fields": {"first": "Jane"}},
+		"invalid",
+		{"address": "invalid"}
+	]`
+
+	r, err := NewJSONReader(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rcpts, invalid, err := r.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rcpts) != 2 || len(invalid) != 2 {
+		t.Fatalf("got %d recipients and %d invalid rows, want 2 and 2", len(rcpts), len(invalid))
+	}
+	if got := rcpts[0]; got.Name != "John Smith" || got.Fields["account"] != "1001" {
+		t.Errorf("got recipient %+v", got)
+	}
+	if got := rcpts[1]; got.Address != "jane@example.com" || got.Fields["first"] != "Jane" {
+		t.Errorf("got recipient %+v", got)
+	}
+
+	if _, err = NewJSONReader(strings.NewReader(`{"address": "john@example.com"}`)); err == nil {
+		t.Error("expected error for a list that is not an array")
+	}
+}
+
+// smtpServer receives the messages of the test sends
+func smtpServer(t *testing.T, messages chan<- string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				text := textproto.NewConn(conn)
+				text.PrintfLine("220 ready")
+				for {
+					line, err := text.ReadLine()
+					if err != nil {
+						return
+					}
+					switch strings.ToUpper(strings.Fields(line + " ")[0]) {
+					case "DATA":
+						text.PrintfLine("354 Go ahead")
+						data, err := ioutil.ReadAll(text.DotReader())
+						if err != nil {
+							return
+						}
+						text.PrintfLine("250 OK")
+						messages <- string(data)
+					case "QUIT":
+						text.PrintfLine("221 Bye")
+						return
+					default:
+						text.PrintfLine("250 OK")
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln
+}
+
+func TestEachBatch(t *testing.T) {
+	const list = `name,email,code
+Ann,ann@example.com,A1
+Bob,bob@example.com,B2
+Invalid,not an address,X
+Carl,carl@example.com,C3
+`
+
+	messages := make(chan string, 10)
+	ln := smtpServer(t, messages)
+	defer ln.Close()
+
+	server := mail.NewSMTPClient()
+	server.Authentication = mail.AuthNone
+	server.Host = ln.Addr().String()
+	pool := mail.NewPool(server, 1)
+	defer pool.Close()
+
+	bulk, err := mail.NewBulkSender(pool, mail.NewMSG().
+		SetFrom("news@example.com").
+		SetSubject("Hello {{name}}").
+		SetBody(mail.TextPlain, "Your code is {{code}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewCSVReader(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batches []int
+	invalid, err := r.EachBatch(2, func(batch []mail.MergeRecipient) error {
+		batches = append(batches, len(batch))
+		return bulk.Send(context.Background(), batch)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invalid) != 1 || invalid[0].Row != 3 {
+		t.Errorf("got invalid rows %v, want row 3", invalid)
+	}
+	if len(batches) != 2 || batches[0] != 2 || batches[1] != 1 {
+		t.Errorf("got batches %v, want [2 1]", batches)
+	}
+
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(messages))
+	}
+	for _, want := range []struct{ to, name, code string }{
+		{"ann@example.com", "Ann", "A1"},
+		{"bob@example.com", "Bob", "B2"},
+		{"carl@example.com", "Carl", "C3"},
+	} {
+		msg := <-messages
+		if !strings.Contains(msg, want.to) || !strings.Contains(msg, "Subject: Hello "+want.name) || !strings.Contains(msg, "Your code is "+want.code) {
+			t.Errorf("got message, want %s with the merge fields:\n%s", want.to, msg)
+		}
+	}
+
+	if _, err := r.EachBatch(0, nil); err == nil {
+		t.Error("expected error for a batch size of 0")
+	}
+}
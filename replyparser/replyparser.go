@@ -0,0 +1,127 @@
+// Package replyparser extracts the new content of a reply, removing the
+// quoted history and the signature added by the common email clients, for
+// email-driven workflows like replying to a ticket by email:
+//
+//	parsed, err := mail.ParseMessage(r)
+//	...
+//	text, err := parsed.Text()
+//	...
+//	comment := replyparser.Parse(text).Text
+package replyparser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Reply is the text of a reply split in its parts.
+type Reply struct {
+	// Text is the new content of the reply
+	Text string
+	// Signature is the signature of the sender, if it's found
+	Signature string
+	// Quoted is the quoted history, from the attribution line like
+	// "On ... wrote:" or the header block of Outlook
+	Quoted string
+}
+
+var (
+	// attributions are the lines before the quoted message, in the
+	// languages of the common clients. They can be wrapped in two lines.
+	attributions = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)^On\b.+\bwrote:?$`),
+		regexp.MustCompile(`(?i)^Le\b.+\ba écrit ?:$`),
+		regexp.MustCompile(`(?i)^Am\b.+\bschrieb\b.*:$`),
+		regexp.MustCompile(`(?i)^El\b.+\bescribió:$`),
+		regexp.MustCompile(`(?i)^Il\b.+\bha scritto:$`),
+		regexp.MustCompile(`(?i)^Em\b.+\bescreveu:$`),
+		regexp.MustCompile(`(?i)^Op\b.+\bschreef\b.*:$`),
+	}
+
+	// separators start the quoted message of Outlook and webmails
+	separators = regexp.MustCompile(`(?i)^(-{2,} ?Original Message ?-{2,}|-{2,} ?Reply message ?-{2,}|_{10,})$`)
+
+	// headerBlock matches the first two lines of the quoted headers of
+	// Outlook, without separator
+	headerBlock = regexp.MustCompile(`(?i)^\*?(From|De|Von|Van|Da):\*? .+\n\*?(Sent|Date|Envoyé|Gesendet|Verzonden|Enviado|Inviato|To):\*? `)
+
+	// signatures are the lines starting a signature, the standard "-- "
+	// delimiter and the lines added by the mobile clients
+	signatures = regexp.MustCompile(`(?i)^(-- ?|__|Sent from my .+|Sent from (Mail|Outlook) for .+|Get Outlook for .+|Sent from Yahoo Mail.*|Enviado desde mi .+|Envoyé de mon .+)$`)
+)
+
+// Parse splits the text of a reply in the new content, the signature and
+// the quoted history. The lines quoted with ">" in the new content, like
+// the ones of inline replies, are removed.
+func Parse(text string) *Reply {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	lines := strings.Split(text, "\n")
+
+	reply := &Reply{}
+
+	end := quoteStart(lines)
+	if end < len(lines) {
+		reply.Quoted = strings.TrimSpace(strings.Join(lines[end:], "\n"))
+	}
+	lines = lines[:end]
+
+	for i, line := range lines {
+		if signatures.MatchString(strings.TrimRight(line, " \t")) {
+			reply.Signature = strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+			lines = lines[:i]
+			break
+		}
+	}
+
+	var content []string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, ">") {
+			content = append(content, line)
+		}
+	}
+	reply.Text = strings.TrimSpace(strings.Join(content, "\n"))
+
+	return reply
+}
+
+// quoteStart returns the index of the line starting the quoted history, or
+// the number of lines if there isn't one
+func quoteStart(lines []string) int {
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ">") {
+			continue
+		}
+
+		if separators.MatchString(line) {
+			return i
+		}
+
+		if i+1 < len(lines) {
+			next := strings.TrimSpace(lines[i+1])
+			if headerBlock.MatchString(line + "\n" + next) {
+				return i
+			}
+			// the attribution wrapped in two lines
+			if isAttribution(line+" "+next) && !isAttribution(next) {
+				return i
+			}
+		}
+
+		if isAttribution(line) {
+			return i
+		}
+	}
+
+	return len(lines)
+}
+
+func isAttribution(line string) bool {
+	for _, re := range attributions {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,76 @@
+package replyparser
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name, text               string
+		reply, signature, quoted string
+	}{
+		{
+			name:   "gmail",
+			text:   "Sounds good, see you then.\r\n\r\nOn Mon, Jan 8, 2024 at 10:00 AM John Smith <john@example.com> wrote:\r\n> Can we meet on Tuesday?\r\n",
+			reply:  "Sounds good, see you then.",
+			quoted: "On Mon, Jan 8, 2024 at 10:00 AM John Smith <john@example.com> wrote:\n> Can we meet on Tuesday?",
+		},
+		{
+			name:   "wrapped attribution",
+			text:   "Thanks!\n\nOn Mon, Jan 8, 2024 at 10:00 AM John Smith <john@example.com>\nwrote:\n\n> Hello\n",
+			reply:  "Thanks!",
+			quoted: "On Mon, Jan 8, 2024 at 10:00 AM John Smith <john@example.com>\nwrote:\n\n> Hello",
+		},
+		{
+			name:   "french",
+			text:   "Merci.\n\nLe lun. 8 janv. 2024 à 10:00, Jean <jean@example.com> a écrit :\n> Bonjour\n",
+			reply:  "Merci.",
+			quoted: "Le lun. 8 janv. 2024 à 10:00, Jean <jean@example.com> a écrit :\n> Bonjour",
+		},
+		{
+			name:   "outlook separator",
+			text:   "Done.\n\n-----Original Message-----\nFrom: John Smith\nSent: Monday, January 8, 2024 10:00 AM\nSubject: Task\n\nPlease do it.",
+			reply:  "Done.",
+			quoted: "-----Original Message-----\nFrom: John Smith\nSent: Monday, January 8, 2024 10:00 AM\nSubject: Task\n\nPlease do it.",
+		},
+		{
+			name:   "outlook headers",
+			text:   "Approved.\n\nFrom: John Smith <john@example.com>\nSent: Monday, January 8, 2024 10:00 AM\nTo: Jane\n\nPlease approve.",
+			reply:  "Approved.",
+			quoted: "From: John Smith <john@example.com>\nSent: Monday, January 8, 2024 10:00 AM\nTo: Jane\n\nPlease approve.",
+		},
+		{
+			name:      "signature",
+			text:      "Fixed in the last release.\n\n-- \nJane Doe\nSupport\n\nOn Mon, Jan 8, 2024, John wrote:\n> It's broken",
+			reply:     "Fixed in the last release.",
+			signature: "Jane Doe\nSupport",
+			quoted:    "On Mon, Jan 8, 2024, John wrote:\n> It's broken",
+		},
+		{
+			name:  "mobile signature",
+			text:  "Yes\n\nSent from my iPhone",
+			reply: "Yes",
+		},
+		{
+			name:  "inline reply",
+			text:  "> First question?\nFirst answer.\n> Second question?\nSecond answer.",
+			reply: "First answer.\nSecond answer.",
+		},
+		{
+			name:  "no quote",
+			text:  "On second thought, I agree.\nThe draft is fine.",
+			reply: "On second thought, I agree.\nThe draft is fine.",
+		},
+	}
+
+	for _, test := range tests {
+		reply := Parse(test.text)
+		if reply.Text != test.reply {
+			t.Errorf("%s: got reply %q, want %q", test.name, reply.Text, test.reply)
+		}
+		if reply.Signature != test.signature {
+			t.Errorf("%s: got signature %q, want %q", test.name, reply.Signature, test.signature)
+		}
+		if reply.Quoted != test.quoted {
+			t.Errorf("%s: got quoted %q, want %q", test.name, reply.Quoted, test.quoted)
+		}
+	}
+}
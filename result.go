@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SendResult is the result of a sent email.
+type SendResult struct {
+	MessageID  string
+	Recipients []string
+	// Size is the size in bytes of the transmitted message
+	Size int
+	// SHA256 is the hex encoded SHA-256 of the transmitted message, the same
+	// data written by SendAndTee, so it can be compared with the archived one.
+	// It's empty when a copy is sent to each recipient, see StampDeliveredTo.
+	SHA256 string
+}
+
+func newSendResult(email *Email, msg string) *SendResult {
+	sum := sha256.Sum256([]byte(msg))
+
+	return &SendResult{
+		MessageID:  email.headers.Get("Message-Id"),
+		Recipients: email.recipients,
+		Size:       len(msg),
+		SHA256:     hex.EncodeToString(sum[:]),
+	}
+}
+
+// SendWithResult sends the email like Send and returns the result.
+func (email *Email) SendWithResult(client *SMTPClient) (*SendResult, error) {
+	return email.sendEnvelope(email.from, client, sendOptions{})
+}
@@ -0,0 +1,142 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrServerClosing matches, with errors.Is, the errors returned when the
+// server replied 421 and closed the connection.
+var ErrServerClosing = errors.New("Mail Error: SMTP server closing the connection")
+
+// timeoutError is a connection or send timeout of the client. It's a
+// net.Error, so the email can be sent again like after a network timeout.
+type timeoutError string
+
+func (e timeoutError) Error() string {
+	return string(e)
+}
+
+// Timeout returns true, the operation timed out.
+func (e timeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary returns true, the operation can be retried.
+func (e timeoutError) Temporary() bool {
+	return true
+}
+
+// TransientError is returned when the server replies with a 4xx temporary
+// failure. The email can be sent again later, after RetryAfter if the
+// server suggested a delay.
+type TransientError struct {
+	Code    int
+	Message string
+	// RetryAfter is the delay suggested by the server, zero if there is none
+	RetryAfter time.Duration
+	err        error
+}
+
+func (e *TransientError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error, usually a *textproto.Error.
+func (e *TransientError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the server closed the connection, for ErrServerClosing.
+func (e *TransientError) Is(target error) bool {
+	return target == ErrServerClosing && e.Code == 421
+}
+
+// transientError wraps err in a TransientError if it's a 4xx reply
+func transientError(err error) error {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) || reply.Code < 400 || reply.Code > 499 {
+		return err
+	}
+
+	var transient *TransientError
+	if errors.As(err, &transient) {
+		return err
+	}
+
+	return &TransientError{
+		Code:       reply.Code,
+		Message:    reply.Msg,
+		RetryAfter: retryAfter(reply.Msg),
+		err:        err,
+	}
+}
+
+var (
+	retryAfterSeconds = regexp.MustCompile(`(?i)retry-after:?\s*(\d+)`)
+	retryAfterDelay   = regexp.MustCompile(`(?i)(?:try again|retry)(?: later)?\s+(?:in|after)\s+(\d+)\s*(s|sec|secs|seconds?|m|min|mins|minutes?|h|hours?)\b`)
+)
+
+// retryAfter returns the retry delay suggested in a reply message, like
+// "Retry-After: 120" or "try again in 5 minutes"
+func retryAfter(msg string) time.Duration {
+	if m := retryAfterSeconds.FindStringSubmatch(msg); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return time.Duration(n) * time.Second
+	}
+
+	m := retryAfterDelay.FindStringSubmatch(msg)
+	if m == nil {
+		return 0
+	}
+
+	n, _ := strconv.Atoi(m[1])
+	unit := time.Second
+	switch strings.ToLower(m[2])[0] {
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	}
+
+	return time.Duration(n) * unit
+}
+
+// connLostError is returned by sendMailProcess when the connection is lost
+// before the server accepted the message data
+type connLostError struct {
+	err error
+}
+
+func (e *connLostError) Error() string {
+	return e.err.Error()
+}
+
+func (e *connLostError) Unwrap() error {
+	return e.err
+}
+
+// connectionError wraps err in a connLostError if it's a network error
+func connectionError(err error) error {
+	var netErr net.Error
+	if err == io.EOF || err == io.ErrUnexpectedEOF || errors.As(err, &netErr) {
+		return &connLostError{err}
+	}
+
+	return err
+}
+
+// unwrapConnLost returns the error wrapped by a connLostError
+func unwrapConnLost(err error) error {
+	if lost, ok := err.(*connLostError); ok {
+		return lost.err
+	}
+
+	return err
+}
@@ -0,0 +1,249 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want time.Duration
+	}{
+		{"4.7.0 Too many connections", 0},
+		{"4.7.0 Retry-After: 120", 2 * time.Minute},
+		{"4.7.0 Try again in 5 minutes", 5 * time.Minute},
+		{"4.3.2 Service shutting down, retry after 30s", 30 * time.Second},
+		{"4.7.28 Rate limited, try again later in 1 hour", time.Hour},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.msg); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestTransientError(t *testing.T) {
+	err := transientError(&textproto.Error{Code: 421, Msg: "4.7.0 Try again in 10 seconds"})
+
+	var transient *TransientError
+	if !errors.As(err, &transient) || transient.RetryAfter != 10*time.Second {
+		t.Fatalf("got %#v, want TransientError with RetryAfter 10s", err)
+	}
+	if !errors.Is(err, ErrServerClosing) {
+		t.Errorf("got %v, want ErrServerClosing", err)
+	}
+
+	if err = transientError(&textproto.Error{Code: 550, Msg: "5.1.1 No such user"}); errors.As(err, &transient) {
+		t.Errorf("got TransientError for a permanent failure")
+	}
+}
+
+// fakeSMTP serves the connections accepted by ln. The first reply to each
+// command verb of the n-th connection is overridden by replies[n], an empty
+// reply drops the connection. The DATA contents are sent to messages.
+func fakeSMTP(ln net.Listener, messages chan<- string, replies ...map[string]string) {
+	for n := 0; ; n++ {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		override := map[string]string{}
+		if n < len(replies) {
+			override = replies[n]
+		}
+
+		go serveFakeSMTP(conn, messages, override)
+	}
+}
+
+func serveFakeSMTP(conn net.Conn, messages chan<- string, override map[string]string) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	reply := func(verb, def string) bool {
+		r, ok := override[verb]
+		delete(override, verb)
+		if !ok {
+			r = def
+		}
+		if r == "" {
+			// drop the connection
+			return false
+		}
+		text.PrintfLine("%s", r)
+		return !strings.HasPrefix(r, "421")
+	}
+
+	if !reply("", "220 fake ESMTP") {
+		return
+	}
+
+	var chunks []byte
+	// mail is true in a transaction, a MAIL command is refused until RSET
+	// or the end of the data, like RFC 5321 servers do
+	mail := false
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch verb {
+		case "EHLO":
+			mail = false
+			if !reply(verb, "250-fake\r\n250 8BITMIME") {
+				return
+			}
+		case "DATA":
+			if !reply(verb, "354 go ahead") {
+				return
+			}
+			data, err := ioutil.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			mail = false
+			if !reply(".", "250 queued") {
+				return
+			}
+			messages <- string(data)
+		case "BDAT":
+			args := strings.Fields(line)
+			size, _ := strconv.Atoi(args[1])
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(text.R, chunk); err != nil {
+				return
+			}
+			chunks = append(chunks, chunk...)
+			if len(args) < 3 {
+				text.PrintfLine("250 %d octets received", size)
+				continue
+			}
+			mail = false
+			if !reply(".", "250 queued") {
+				return
+			}
+			messages <- string(chunks)
+			chunks = nil
+		case "QUIT":
+			reply(verb, "221 bye")
+			return
+		case "MAIL":
+			if mail {
+				text.PrintfLine("503 5.5.1 Nested MAIL command")
+				continue
+			}
+			if !reply(verb, "250 ok") {
+				return
+			}
+			mail = true
+		case "RSET":
+			mail = false
+			if !reply(verb, "250 ok") {
+				return
+			}
+		default:
+			if !reply(verb, "250 ok") {
+				return
+			}
+		}
+	}
+}
+
+func TestAutoReconnect(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 1)
+	go fakeSMTP(ln, messages, map[string]string{"MAIL": "421 4.3.2 Service shutting down"})
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	server.KeepAlive = true
+	server.AutoReconnect = true
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Close()
+
+	email := NewMSG()
+	email.SetFrom("from@example.com").AddTo("to@example.com").SetBody(TextPlain, "Hello")
+
+	if err = email.Send(client); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if msg := <-messages; !strings.Contains(msg, "Hello") {
+		t.Errorf("got message %q", msg)
+	}
+
+}
+
+func TestServerClosingWithDelay(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	go fakeSMTP(ln, nil, map[string]string{"MAIL": "421 4.7.0 Try again in 5 minutes"})
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	server.AutoReconnect = true
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	email := NewMSG()
+	email.SetFrom("from@example.com").AddTo("to@example.com").SetBody(TextPlain, "Hello")
+
+	err = email.Send(client)
+
+	var transient *TransientError
+	if !errors.As(err, &transient) || !errors.Is(err, ErrServerClosing) || transient.RetryAfter != 5*time.Minute {
+		t.Errorf("got error %v, want ErrServerClosing with a 5 minutes delay", err)
+	}
+}
+
+func TestReconnectOnConnectionLoss(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 1)
+	go fakeSMTP(ln, messages, map[string]string{"RCPT": ""})
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	server.KeepAlive = true
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Close()
+
+	email := NewMSG()
+	email.SetFrom("from@example.com").AddTo("to@example.com").SetBody(TextPlain, "Hello")
+
+	if err = email.Send(client); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if msg := <-messages; !strings.Contains(msg, "Hello") {
+		t.Errorf("got message %q", msg)
+	}
+}
@@ -0,0 +1,136 @@
+package mail
+
+import (
+	"errors"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"regexp"
+)
+
+// RewriteAction is the action of a header rewrite rule
+type RewriteAction int
+
+const (
+	// RewriteReplace replaces the matching values of the header
+	RewriteReplace RewriteAction = iota
+	// RewriteAdd adds a value to the header
+	RewriteAdd
+	// RewriteRemove removes the matching values of the header
+	RewriteRemove
+)
+
+// RewriteRule is a header rewrite rule.
+type RewriteRule struct {
+	// Header is the header name. It can contain glob wildcards, like "X-Internal-*".
+	Header string
+	// Match is the regular expression the header values must match.
+	// A nil Match matches any value. For RewriteAdd, the value is only added
+	// if Match is nil or any existing value of the header matches.
+	Match  *regexp.Regexp
+	Action RewriteAction
+	// Value is the replacement for RewriteReplace, it can reference Match
+	// submatches like $1, or the value to add for RewriteAdd.
+	Value string
+}
+
+// HeaderRewriter is a filter that applies header rewrite rules to the email,
+// in the same order they were defined.
+//
+// For example, to rewrite the From domain and remove internal headers:
+//
+//	rewriter := mail.NewHeaderRewriter(
+//		mail.RewriteRule{Header: "From", Match: regexp.MustCompile(`@internal\.example\.com>$`), Value: "@example.com>"},
+//		mail.RewriteRule{Header: "X-Internal-*", Action: mail.RewriteRemove},
+//	)
+type HeaderRewriter struct {
+	Rules []RewriteRule
+}
+
+// NewHeaderRewriter returns a header rewriter filter with the given rules.
+func NewHeaderRewriter(rules ...RewriteRule) *HeaderRewriter {
+	return &HeaderRewriter{Rules: rules}
+}
+
+// Filter applies the rewrite rules to the email headers.
+func (r *HeaderRewriter) Filter(email *Email) error {
+	for _, rule := range r.Rules {
+		pattern := textproto.CanonicalMIMEHeaderKey(rule.Header)
+
+		if rule.Action == RewriteAdd {
+			if rule.Match == nil || matchValues(rule.Match, email.headers[pattern]) {
+				email.headers.Add(pattern, rule.Value)
+			}
+			continue
+		}
+
+		for header, values := range email.headers {
+			if ok, err := path.Match(pattern, header); err != nil {
+				return errors.New("Mail Error: Invalid rewrite rule header: " + rule.Header)
+			} else if !ok {
+				continue
+			}
+
+			var rewritten []string
+			for _, value := range values {
+				switch {
+				case rule.Match != nil && !rule.Match.MatchString(value):
+					rewritten = append(rewritten, value)
+				case rule.Action == RewriteReplace && rule.Match != nil:
+					rewritten = append(rewritten, rule.Match.ReplaceAllString(value, rule.Value))
+				case rule.Action == RewriteReplace:
+					rewritten = append(rewritten, rule.Value)
+				}
+			}
+
+			if len(rewritten) == 0 {
+				email.headers.Del(header)
+				continue
+			}
+
+			email.headers[header] = rewritten
+
+			if err := email.updateAddress(header, rewritten[0]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// updateAddress keeps the sender addresses in sync with rewritten headers
+func (email *Email) updateAddress(header, value string) error {
+	var field *string
+
+	switch header {
+	case "From":
+		field = &email.from
+	case "Sender":
+		field = &email.sender
+	case "Reply-To":
+		field = &email.replyTo
+	default:
+		return nil
+	}
+
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return errors.New("Mail Error: Rewritten " + header + " is not a valid address: " + err.Error())
+	}
+
+	*field = address.Address
+
+	return nil
+}
+
+// matchValues returns true if any value matches re
+func matchValues(re *regexp.Regexp, values []string) bool {
+	for _, value := range values {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestHeaderRewriter(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("App <app@internal.example.com>").
+		AddTo("to@example.com").
+		AddHeader("X-Internal-Host", "db01").
+		AddHeader("X-Internal-User", "admin").
+		AddHeader("X-Mailer", "app")
+
+	rewriter := NewHeaderRewriter(
+		RewriteRule{Header: "From", Match: regexp.MustCompile(`@internal\.(example\.com>)$`), Value: "@$1"},
+		RewriteRule{Header: "X-Internal-*", Action: RewriteRemove},
+		RewriteRule{Header: "X-Mailer", Value: "Go Simple Mail"},
+		RewriteRule{Header: "X-Env", Action: RewriteAdd, Value: "staging"},
+	)
+
+	filtered, err := email.applyFilters([]Filter{rewriter})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := filtered.headers.Get("From"), `"App" <app@example.com>`; got != want {
+		t.Errorf("got From %q, want %q", got, want)
+	}
+	if got := filtered.GetFrom(); got != "app@example.com" {
+		t.Errorf("got envelope from %q, want app@example.com", got)
+	}
+	if got := filtered.headers.Get("X-Internal-Host") + filtered.headers.Get("X-Internal-User"); got != "" {
+		t.Errorf("internal headers not removed: %q", got)
+	}
+	if got := filtered.headers.Get("X-Mailer"); got != "Go Simple Mail" {
+		t.Errorf("got X-Mailer %q, want Go Simple Mail", got)
+	}
+	if got := filtered.headers.Get("X-Env"); got != "staging" {
+		t.Errorf("got X-Env %q, want staging", got)
+	}
+}
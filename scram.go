@@ -0,0 +1,152 @@
+package mail
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// scramAuth implements the SCRAM-SHA-1 and SCRAM-SHA-256 authentications
+// (RFC 5802 and RFC 7677). The password is never sent, and the server
+// proves it knows it too.
+type scramAuth struct {
+	mechanism          string
+	hash               func() hash.Hash
+	username, password string
+	nonce              string
+	clientFirst        string
+	serverSignature    []byte
+	verified           bool
+}
+
+func newSCRAMAuth(mechanism authType, username, password string) *scramAuth {
+	a := &scramAuth{mechanism: mechanism.String(), hash: sha256.New, username: username, password: password}
+	if mechanism == AuthSCRAMSHA1 {
+		a.hash = sha1.New
+	}
+
+	random := make([]byte, 18)
+	rand.Read(random)
+	a.nonce = base64.StdEncoding.EncodeToString(random)
+
+	return a
+}
+
+func (a *scramAuth) Start(server *ServerInfo) (string, []byte, error) {
+	a.clientFirst = "n=" + saslName(a.username) + ",r=" + a.nonce
+	// without channel binding
+	return a.mechanism, []byte("n,," + a.clientFirst), nil
+}
+
+func (a *scramAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		if !a.verified {
+			return nil, errors.New("the server signature wasn't verified")
+		}
+		return nil, nil
+	}
+
+	if a.serverSignature != nil {
+		return a.verify(string(fromServer))
+	}
+
+	return a.clientFinal(string(fromServer))
+}
+
+// clientFinal returns the client proof of the server-first message
+func (a *scramAuth) clientFinal(serverFirst string) ([]byte, error) {
+	attrs := scramAttributes(serverFirst)
+
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, a.nonce) || len(nonce) == len(a.nonce) {
+		return nil, errors.New("invalid SCRAM server nonce")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("invalid SCRAM salt")
+	}
+
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return nil, errors.New("invalid SCRAM iteration count")
+	}
+
+	withoutProof := "c=biws,r=" + nonce
+	authMessage := []byte(a.clientFirst + "," + serverFirst + "," + withoutProof)
+
+	salted := pbkdf2(a.hash, []byte(a.password), salt, iterations)
+	clientKey := a.hmac(salted, []byte("Client Key"))
+	h := a.hash()
+	h.Write(clientKey)
+	clientSignature := a.hmac(h.Sum(nil), authMessage)
+
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+
+	a.serverSignature = a.hmac(a.hmac(salted, []byte("Server Key")), authMessage)
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verify checks the server signature of the server-final message
+func (a *scramAuth) verify(serverFinal string) ([]byte, error) {
+	attrs := scramAttributes(serverFinal)
+	if e := attrs["e"]; e != "" {
+		return nil, errors.New("SCRAM error: " + e)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(signature, a.serverSignature) {
+		return nil, errors.New("invalid SCRAM server signature")
+	}
+	a.verified = true
+
+	return []byte{}, nil
+}
+
+func (a *scramAuth) hmac(key, data []byte) []byte {
+	mac := hmac.New(a.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// scramAttributes returns the attributes of a SCRAM message
+func scramAttributes(message string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(message, ",") {
+		if len(attr) >= 2 && attr[1] == '=' {
+			attrs[attr[:1]] = attr[2:]
+		}
+	}
+
+	return attrs
+}
+
+// pbkdf2 derives a key of the size of the hash (RFC 8018)
+func pbkdf2(h func() hash.Hash, password, salt []byte, iterations int) []byte {
+	mac := hmac.New(h, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+
+	key := append([]byte(nil), u...)
+	for n := 1; n < iterations; n++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for i := range key {
+			key[i] ^= u[i]
+		}
+	}
+
+	return key
+}
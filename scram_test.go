@@ -0,0 +1,103 @@
+package mail
+
+import "testing"
+
+func TestSCRAM(t *testing.T) {
+	tests := []struct {
+		mechanism   authType
+		nonce       string
+		serverFirst string
+		clientFinal string
+		serverFinal string
+	}{
+		// RFC 5802 5
+		{
+			AuthSCRAMSHA1,
+			"fyko+d2lbbFgONRv9qkxdawL",
+			"r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+			"c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+			"v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+		},
+		// RFC 7677 3
+		{
+			AuthSCRAMSHA256,
+			"rOprNGfwEbeRWgbNEkqO",
+			"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+			"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+		},
+	}
+
+	for _, test := range tests {
+		a := newSCRAMAuth(test.mechanism, "user", "pencil")
+		a.nonce = test.nonce
+
+		name, resp, err := a.Start(&ServerInfo{Name: "servername"})
+		if err != nil || name != test.mechanism.String() || string(resp) != "n,,n=user,r="+test.nonce {
+			t.Errorf("got %s %q %v", name, resp, err)
+		}
+
+		if resp, err = a.Next([]byte(test.serverFirst), true); err != nil || string(resp) != test.clientFinal {
+			t.Errorf("%s: got client final %q %v, want %q", name, resp, err, test.clientFinal)
+		}
+
+		if resp, err = a.Next([]byte(test.serverFinal), true); err != nil || len(resp) != 0 {
+			t.Errorf("%s: got %q %v verifying the server", name, resp, err)
+		}
+
+		if _, err = a.Next([]byte("2.7.0 Authentication successful"), false); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestSCRAMBadServer(t *testing.T) {
+	a := newSCRAMAuth(AuthSCRAMSHA256, "user", "pencil")
+	a.nonce = "rOprNGfwEbeRWgbNEkqO"
+	a.Start(&ServerInfo{Name: "servername"})
+
+	if _, err := a.Next([]byte("r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"), true); err == nil {
+		t.Error("got no error with a server nonce of another client")
+	}
+
+	a.Next([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"), true)
+	if _, err := a.Next([]byte("v=AAAA"), true); err == nil {
+		t.Error("got no error with a wrong server signature")
+	}
+	if _, err := a.Next([]byte("2.7.0 Authentication successful"), false); err == nil {
+		t.Error("got no error with a success without the server signature")
+	}
+}
+
+func TestSCRAMNegotiation(t *testing.T) {
+	server := &SMTPServer{Username: "user", Password: "pencil", Authentication: AuthSCRAMSHA1}
+
+	a, err := server.auth("servername", []string{"PLAIN", "SCRAM-SHA-1", "SCRAM-SHA-256"})
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	if name, _, _ := a.Start(&ServerInfo{Name: "servername"}); name != "SCRAM-SHA-256" {
+		t.Errorf("got mechanism %s, want SCRAM-SHA-256", name)
+	}
+
+	a, _ = server.auth("servername", []string{"PLAIN", "SCRAM-SHA-1"})
+	if name, _, _ := a.Start(&ServerInfo{Name: "servername"}); name != "SCRAM-SHA-1" {
+		t.Errorf("got mechanism %s, want SCRAM-SHA-1", name)
+	}
+
+	// SCRAM-SHA-256 is never downgraded
+	server.Authentication = AuthSCRAMSHA256
+	if _, err := server.auth("servername", []string{"PLAIN", "SCRAM-SHA-1"}); err == nil {
+		t.Error("expected error when SCRAM-SHA-256 is not advertised")
+	}
+
+	// unless SCRAM-SHA-1 is allowed
+	server.AuthMechanisms = []authType{AuthSCRAMSHA256, AuthSCRAMSHA1}
+	a, err = server.auth("servername", []string{"PLAIN", "SCRAM-SHA-1"})
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	if name, _, _ := a.Start(&ServerInfo{Name: "servername"}); name != "SCRAM-SHA-1" {
+		t.Errorf("got mechanism %s, want SCRAM-SHA-1 from AuthMechanisms", name)
+	}
+}
@@ -0,0 +1,258 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+// Validator validates a message of the self check corpus, given its name and
+// the raw message. It can be used to run the corpus through external parsers.
+type Validator func(name string, msg []byte) error
+
+// CorpusMessage is a representative message of the self check corpus.
+type CorpusMessage struct {
+	Name  string
+	Email *Email
+}
+
+// Corpus returns the representative messages checked by SelfCheck.
+func Corpus() []CorpusMessage {
+	const html = "<html><body><p>Hello, this is a <b>test</b> message.</p></body></html>"
+	longText := strings.Repeat("A long line of text with a few more words. ", 40)
+
+	newEmail := func() *Email {
+		email := NewMSG()
+		email.SetFrom("From Example <from@example.com>").
+			AddTo("to@example.com", "Other <other@example.org>").
+			AddCc("cc@example.com").
+			SetSubject("Test message")
+		return email
+	}
+
+	var corpus []CorpusMessage
+	add := func(name string, email *Email) {
+		corpus = append(corpus, CorpusMessage{Name: name, Email: email})
+	}
+
+	add("plain", newEmail().SetBody(TextPlain, "Hello\r\n\r\nThis is a test message."))
+
+	add("non-ascii", newEmail().
+		SetSubject("Ñandú, 日本語 and emoji 🎉 in the subject").
+		SetBody(TextPlain, "Héllo wörld, 日本語 "+longText))
+
+	add("alternative", newEmail().
+		SetBody(TextPlain, longText).
+		AddAlternative(TextHTML, html))
+
+	add("attachments", newEmail().
+		SetBody(TextPlain, "See the attached files").
+		AddAlternative(TextHTML, `<p>See the attached files <img src="cid:logo.png"></p>`).
+		AddInlineData([]byte("\x89PNG fake image"), "logo.png", "image/png").
+		AddAttachmentData([]byte("a,b\n1,2\n"), "report.csv", "text/csv").
+		AddAttachmentData(bytes.Repeat([]byte{0, 1, 2, 255}, 1000), "data.bin", "application/octet-stream"))
+
+	base64Email := newEmail().SetBody(TextPlain, "Héllo "+longText)
+	base64Email.Encoding = EncodingBase64
+	add("base64", base64Email)
+
+	add("seven-bit", newEmail().
+		SetSubject(strings.Repeat("Long subject ", 10)).
+		SetBody(TextPlain, "Héllo "+longText).
+		AddAttachmentData([]byte("%PDF-1.4"), "quarterly-financial-report-2026.pdf", "").
+		SetSevenBit(true))
+
+	for _, p := range []struct {
+		name    string
+		profile Profile
+	}{
+		{"rfc", ProfileRFC},
+		{"outlook", ProfileOutlook},
+		{"gmail", ProfileGmail},
+		{"thunderbird", ProfileThunderbird},
+	} {
+		add("profile-"+p.name, newEmail().
+			SetBody(TextPlain, "Hello").
+			AddAlternative(TextHTML, html).
+			AddInlineData([]byte("\x89PNG fake image"), "logo.png", "image/png").
+			AddAttachmentData([]byte("données"), "Résumé très long "+strings.Repeat("é", 30)+".txt", "text/plain").
+			SetProfile(p.profile))
+	}
+
+	return corpus
+}
+
+// SelfCheck builds the corpus messages and validates them with the Go
+// net/mail and mime parsers, checking the decoded bodies, attachments and
+// subject match the originals, and then with the given validators.
+func SelfCheck(validators ...Validator) error {
+	for _, c := range Corpus() {
+		msg := c.Email.GetMessage()
+		if c.Email.Error != nil {
+			return errors.New("Mail Error: Self check " + c.Name + ": " + c.Email.Error.Error())
+		}
+
+		if err := checkMessage(c.Email, msg); err != nil {
+			return errors.New("Mail Error: Self check " + c.Name + ": " + err.Error())
+		}
+
+		for _, validate := range validators {
+			if err := validate(c.Name, []byte(msg)); err != nil {
+				return errors.New("Mail Error: Self check " + c.Name + ": " + err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// leafPart is a decoded part of a parsed message
+type leafPart struct {
+	contentType string
+	filename    string
+	data        []byte
+}
+
+// checkMessage parses msg and compares it with the email it was built from
+func checkMessage(email *Email, msg string) error {
+	email, err := email.withPlainText().withUUEncodedAttachments()
+	if err != nil {
+		return err
+	}
+
+	for i, line := range strings.Split(msg, "\n") {
+		if len(line) > 998 {
+			return errors.New("line " + strconv.Itoa(i+1) + " is longer than 998 characters")
+		}
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		return err
+	}
+
+	var dec mime.WordDecoder
+	subject, err := dec.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		return err
+	}
+	if want := strings.TrimSpace(email.headers.Get("Subject")); subject != want {
+		return errors.New("got subject " + strconv.Quote(subject) + ", want " + strconv.Quote(want))
+	}
+
+	if _, err = m.Header.AddressList("To"); err != nil {
+		return errors.New("invalid To header: " + err.Error())
+	}
+
+	leaves, err := parseLeaves(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Header.Get("Content-Disposition"), m.Body)
+	if err != nil {
+		return err
+	}
+
+	if got, want := len(leaves), len(email.parts)+len(email.attachments)+len(email.inlines); got != want {
+		return errors.New("got " + strconv.Itoa(got) + " parts, want " + strconv.Itoa(want))
+	}
+
+	for _, p := range email.parts {
+		if strings.Contains(p.body.String(), "cid:") {
+			// the cids are replaced
+			continue
+		}
+		// the parameters of the part, like the method of text/calendar,
+		// aren't compared
+		mediaType := strings.TrimSpace(strings.SplitN(p.contentType, ";", 2)[0])
+		if !hasLeaf(leaves, mediaType, "", p.body.Bytes()) {
+			return errors.New("part " + p.contentType + " not found or modified")
+		}
+	}
+
+	for _, f := range append(append([]*file(nil), email.attachments...), email.inlines...) {
+		if f.reader != nil {
+			// the stream was consumed by the build
+			continue
+		}
+		if _, ok := f.encoder().(base64Encoder); !ok {
+			// only the built-in encodings are decoded
+			continue
+		}
+		if !hasLeaf(leaves, "", f.filename, f.data) {
+			return errors.New("file " + strconv.Quote(f.filename) + " not found or modified")
+		}
+	}
+
+	return nil
+}
+
+// parseLeaves decodes the leaf parts of a message body
+func parseLeaves(contentType, transferEncoding, disposition string, body io.Reader) ([]leafPart, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.New("invalid Content-Type " + strconv.Quote(contentType) + ": " + err.Error())
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var leaves []leafPart
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				return leaves, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			l, err := parseLeaves(p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p.Header.Get("Content-Disposition"), p)
+			if err != nil {
+				return nil, err
+			}
+			leaves = append(leaves, l...)
+		}
+	}
+
+	switch strings.ToLower(transferEncoding) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf := leafPart{contentType: mediaType, data: data}
+
+	if disposition != "" {
+		_, params, err := mime.ParseMediaType(disposition)
+		if err != nil {
+			return nil, errors.New("invalid Content-Disposition " + strconv.Quote(disposition) + ": " + err.Error())
+		}
+
+		var dec mime.WordDecoder
+		if leaf.filename, err = dec.DecodeHeader(params["filename"]); err != nil {
+			return nil, err
+		}
+	}
+
+	return []leafPart{leaf}, nil
+}
+
+func hasLeaf(leaves []leafPart, contentType, filename string, data []byte) bool {
+	for _, l := range leaves {
+		if (contentType == "" || l.contentType == contentType) && l.filename == filename && bytes.Equal(l.data, data) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestSelfCheck(t *testing.T) {
+	if err := SelfCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestSelfCheckPython validates the corpus with the Python email parser, if
+// python3 is available.
+func TestSelfCheckPython(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+
+	err = SelfCheck(func(name string, msg []byte) error {
+		cmd := exec.Command(python, "testdata/validate_email.py")
+		cmd.Stdin = bytes.NewReader(msg)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return errors.New(string(out))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,35 @@
+package mail
+
+import "context"
+
+// Sender is implemented by the transports of the emails: the SMTP client,
+// the pool, and the API and file transports of the sub-packages. The
+// applications can send with a Sender to switch the transport with the
+// configuration, or to record the emails in the tests.
+type Sender interface {
+	Send(ctx context.Context, email *Email) error
+}
+
+// SenderFunc is an adapter to allow the use of ordinary functions as senders.
+type SenderFunc func(ctx context.Context, email *Email) error
+
+// Send calls f(ctx, email).
+func (f SenderFunc) Send(ctx context.Context, email *Email) error {
+	return f(ctx, email)
+}
+
+// Send sends the email with the client, like SendWithContext, so the
+// client is a Sender.
+func (smtpClient *SMTPClient) Send(ctx context.Context, email *Email) error {
+	return email.SendWithContext(ctx, smtpClient)
+}
+
+// Sender returns a Sender sending the emails with the connections of the
+// pool.
+func (p *Pool) Sender() Sender {
+	return SenderFunc(func(ctx context.Context, email *Email) error {
+		return p.do(func(client *SMTPClient) error {
+			return email.SendWithContext(ctx, client)
+		})
+	})
+}
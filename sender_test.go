@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSender(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go fakeSMTP(ln, messages)
+
+	pool := NewPool(newPoolServer(ln), 1)
+	defer pool.Close()
+
+	client, err := newPoolServer(ln).Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, sender := range []Sender{client, pool.Sender()} {
+		if err := sender.Send(context.Background(), newPoolEmail()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(messages) != 2 {
+		t.Errorf("got %d messages, want 2", len(messages))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pool.Sender().Send(ctx, newPoolEmail()); err != context.Canceled {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,253 @@
+// Package sendgrid sends emails built with Go Simple Mail with the SendGrid
+// v3 Mail Send API. The message is built like for SMTP, with the filters of
+// the email, and converted to the JSON payload of the API, so switching off
+// SMTP only changes the sender:
+//
+//	client := sendgrid.NewClient(apiKey)
+//	err := client.Send(ctx, email)
+//
+// The recipients of the envelope that are not in the To or Cc headers are
+// sent as Bcc. The metadata of the email is sent as custom args and its
+// tags as categories. Signed or encrypted messages can't be converted.
+package sendgrid
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	netmail "net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// DefaultURL is the endpoint of the Mail Send API
+const DefaultURL = "https://api.sendgrid.com/v3/mail/send"
+
+var _ mail.Sender = (*Client)(nil)
+
+// Client sends the emails with the SendGrid API.
+type Client struct {
+	// URL is the endpoint of the API, DefaultURL if not set
+	URL string
+	// APIKey is the key of the account, with the Mail Send permission
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// StatusError is returned when the API doesn't reply with a 2xx status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return "sendgrid: " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode) + ": " + e.Body
+}
+
+// Temporary returns true if the email can be sent again later, when the
+// API is unavailable or limits the rate.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// NewClient returns a client authenticated with the API key.
+func NewClient(apiKey string) *Client {
+	return &Client{
+		URL:        DefaultURL,
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// address is an address of the payload
+type address struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type personalization struct {
+	To  []address `json:"to,omitempty"`
+	Cc  []address `json:"cc,omitempty"`
+	Bcc []address `json:"bcc,omitempty"`
+}
+
+type content struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type attachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// payload is the request of the Mail Send API
+type payload struct {
+	Personalizations []personalization `json:"personalizations"`
+	From             address           `json:"from"`
+	ReplyTo          *address          `json:"reply_to,omitempty"`
+	Subject          string            `json:"subject,omitempty"`
+	Content          []content         `json:"content,omitempty"`
+	Attachments      []attachment      `json:"attachments,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	Categories       []string          `json:"categories,omitempty"`
+	CustomArgs       map[string]string `json:"custom_args,omitempty"`
+}
+
+// Send sends the email to all its recipients, including Bcc.
+func (c *Client) Send(ctx context.Context, email *mail.Email) error {
+	p, err := newPayload(email)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	url := c.URL
+	if url == "" {
+		url = DefaultURL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+
+	return nil
+}
+
+// newPayload converts the message of the email to the payload of the API
+func newPayload(email *mail.Email) (*payload, error) {
+	if email.Error != nil {
+		return nil, email.Error
+	}
+
+	r, err := email.NewReader()
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := mail.ParseMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &payload{
+		Subject:    parsed.DecodedHeader("Subject"),
+		Categories: email.Tags(),
+	}
+	if metadata := email.Metadata(); len(metadata) > 0 {
+		p.CustomArgs = metadata
+	}
+	var personal personalization
+	// the envelope recipients that are not in the headers are Bcc
+	listed := make(map[string]bool)
+
+	for header := range parsed.Header {
+		switch header {
+		case "From", "Reply-To", "To", "Cc":
+			list, err := netmail.ParseAddressList(parsed.Header.Get(header))
+			if err != nil {
+				return nil, errors.New("sendgrid: invalid " + header + " header: " + err.Error())
+			}
+			var addresses []address
+			for _, a := range list {
+				addresses = append(addresses, address{Email: a.Address, Name: a.Name})
+				listed[strings.ToLower(a.Address)] = true
+			}
+			switch header {
+			case "From":
+				p.From = addresses[0]
+			case "Reply-To":
+				p.ReplyTo = &addresses[0]
+			case "To":
+				personal.To = addresses
+			case "Cc":
+				personal.Cc = addresses
+			}
+		case "Subject", "Bcc", "Mime-Version":
+		default:
+			// the API sets the content headers
+			if !strings.HasPrefix(header, "Content-") {
+				if p.Headers == nil {
+					p.Headers = make(map[string]string)
+				}
+				p.Headers[header] = parsed.DecodedHeader(header)
+			}
+		}
+	}
+
+	_, recipients, err := email.Envelope()
+	if err != nil {
+		return nil, err
+	}
+	for _, recipient := range recipients {
+		if !listed[strings.ToLower(recipient)] {
+			personal.Bcc = append(personal.Bcc, address{Email: recipient})
+		}
+	}
+	p.Personalizations = []personalization{personal}
+
+	// the API requires the text/plain content first
+	for _, mediaType := range []string{"text/plain", "text/html"} {
+		value, err := parsed.Body(mediaType)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			p.Content = append(p.Content, content{Type: mediaType, Value: value})
+		}
+	}
+
+	for _, a := range parsed.Attachments() {
+		data, err := ioutil.ReadAll(a.Open())
+		if err != nil {
+			return nil, err
+		}
+		att := attachment{
+			Content:     base64.StdEncoding.EncodeToString(data),
+			Type:        a.ContentType,
+			Filename:    a.Filename,
+			Disposition: "attachment",
+		}
+		if a.Inline {
+			att.Disposition = "inline"
+			att.ContentID = a.ContentID
+		}
+		p.Attachments = append(p.Attachments, att)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,80 @@
+package sendgrid
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func TestSend(t *testing.T) {
+	var got payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, `{"errors":[{"message":"unauthorized"}]}`, http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client := NewClient("key")
+	client.URL = server.URL
+
+	email := mail.NewMSG().
+		SetFrom("From <from@example.com>").
+		AddTo("Ann <ann@example.com>").
+		AddCc("bob@example.com").
+		AddBcc("carl@example.com").
+		SetReplyTo("reply@example.com").
+		SetSubject("Héllo").
+		AddHeader("X-Campaign", "spring").
+		SetMetadata("user_id", "42").
+		AddTag("welcome", "spring").
+		SetBody(mail.TextHTML, "<p>Hello</p>").
+		AddAlternative(mail.TextPlain, "Hello").
+		AddAttachmentData([]byte("data"), "data.txt", "text/plain")
+
+	if err := client.Send(context.Background(), email); err != nil {
+		t.Fatal(err)
+	}
+
+	personal := got.Personalizations[0]
+	if got.From != (address{"from@example.com", "From"}) || got.ReplyTo == nil || got.ReplyTo.Email != "reply@example.com" {
+		t.Errorf("got from %+v, reply to %+v", got.From, got.ReplyTo)
+	}
+	if len(personal.To) != 1 || personal.To[0] != (address{"ann@example.com", "Ann"}) ||
+		len(personal.Cc) != 1 || personal.Cc[0].Email != "bob@example.com" ||
+		len(personal.Bcc) != 1 || personal.Bcc[0].Email != "carl@example.com" {
+		t.Errorf("got personalization %+v", personal)
+	}
+	if got.Subject != "Héllo" || got.Headers["X-Campaign"] != "spring" {
+		t.Errorf("got subject %q, headers %v", got.Subject, got.Headers)
+	}
+	if got.CustomArgs["user_id"] != "42" || len(got.CustomArgs) != 1 {
+		t.Errorf("got custom args %v", got.CustomArgs)
+	}
+	if len(got.Categories) != 2 || got.Categories[0] != "welcome" || got.Categories[1] != "spring" {
+		t.Errorf("got categories %v", got.Categories)
+	}
+	if len(got.Content) != 2 || got.Content[0] != (content{"text/plain", "Hello"}) || got.Content[1] != (content{"text/html", "<p>Hello</p>"}) {
+		t.Errorf("got content %+v", got.Content)
+	}
+	if len(got.Attachments) != 1 || got.Attachments[0].Filename != "data.txt" ||
+		got.Attachments[0].Content != base64.StdEncoding.EncodeToString([]byte("data")) {
+		t.Errorf("got attachments %+v", got.Attachments)
+	}
+
+	client.APIKey = "invalid"
+	err := client.Send(context.Background(), email)
+	if e, ok := err.(*StatusError); !ok || e.StatusCode != http.StatusUnauthorized || e.Temporary() {
+		t.Errorf("got error %v, want a 401 StatusError", err)
+	}
+}
@@ -0,0 +1,101 @@
+package sendmail
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+var _ mail.Sender = (*Pickup)(nil)
+
+// Pickup writes the emails in a pickup directory, as .eml files with the
+// envelope in X-Sender and X-Receiver headers. The file is written in
+// TempDir first, and renamed in Dir when complete, so the MTA never reads
+// a partial message.
+type Pickup struct {
+	// Dir is the pickup directory
+	Dir string
+	// TempDir is the directory the messages are written in before they are
+	// moved to Dir, on the same file system. The temporary files are
+	// written in Dir, with a .tmp extension, if not set
+	TempDir string
+}
+
+// Send writes the message of the email in the pickup directory.
+func (p *Pickup) Send(ctx context.Context, email *mail.Email) error {
+	if email.Error != nil {
+		return email.Error
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	from, recipients, err := email.Envelope()
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return errors.New("sendmail: no recipient specified")
+	}
+
+	for _, address := range append([]string{from}, recipients...) {
+		if strings.ContainsAny(address, "\r\n") {
+			return errors.New("sendmail: invalid address " + address)
+		}
+	}
+
+	name, err := newName()
+	if err != nil {
+		return err
+	}
+
+	tempDir := p.TempDir
+	if tempDir == "" {
+		tempDir = p.Dir
+	}
+
+	f, err := ioutil.TempFile(tempDir, name+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	w.WriteString("X-Sender: " + from + "\r\n")
+	for _, recipient := range recipients {
+		w.WriteString("X-Receiver: " + recipient + "\r\n")
+	}
+	_, err = email.WriteTo(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(p.Dir, name+".eml"))
+}
+
+// newName returns a random file name
+func newName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
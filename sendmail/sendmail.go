@@ -0,0 +1,175 @@
+// Package sendmail sends emails built with Go Simple Mail without SMTP, for
+// the hosts that can only deliver through the local MTA. Command pipes the
+// message to the sendmail binary of Postfix, Exim or msmtp, and Pickup
+// drops it in the pickup directory of the MTAs that watch one, like IIS,
+// Exchange or hMailServer:
+//
+//	err := sendmail.New().Send(ctx, email)
+//
+//	pickup := &sendmail.Pickup{Dir: `C:\inetpub\mailroot\Pickup`}
+//	err := pickup.Send(ctx, email)
+//
+// The maildrop directory of Postfix has its own queue file format, only
+// written by postdrop, so Postfix is used with Command and its sendmail.
+package sendmail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// DefaultPath is the path of the sendmail binary of a Command without Path
+const DefaultPath = "/usr/sbin/sendmail"
+
+// exTempFail is the exit code of the temporary failures, EX_TEMPFAIL of
+// sysexits.h
+const exTempFail = 75
+
+var _ mail.Sender = (*Command)(nil)
+
+// Command pipes the emails to a sendmail compatible command. The command
+// is run with the Args, then "-f <sender> -- <recipients>", and reads the
+// message, with LF line endings, from its standard input.
+type Command struct {
+	// Path is the path of the command, DefaultPath if not set
+	Path string
+	// Args are the options of the command, "-i" so a line with a single dot
+	// doesn't end the message, if nil
+	Args []string
+}
+
+// ExitError is returned when the command fails.
+type ExitError struct {
+	// Code is the exit code of the command
+	Code int
+	// Stderr is the start of the error output of the command
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	msg := "sendmail: exit status " + strconv.Itoa(e.Code)
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+
+	return msg
+}
+
+// Temporary returns true if the command exited with EX_TEMPFAIL, the email
+// can be sent again later.
+func (e *ExitError) Temporary() bool {
+	return e.Code == exTempFail
+}
+
+// New returns a command running DefaultPath.
+func New() *Command {
+	return &Command{Path: DefaultPath}
+}
+
+// Send runs the command with the sender and the recipients, including Bcc,
+// of the email, and writes the message to it.
+func (c *Command) Send(ctx context.Context, email *mail.Email) error {
+	if email.Error != nil {
+		return email.Error
+	}
+
+	from, recipients, err := email.Envelope()
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return errors.New("sendmail: no recipient specified")
+	}
+
+	path := c.Path
+	if path == "" {
+		path = DefaultPath
+	}
+
+	args := c.Args
+	if args == nil {
+		args = []string{"-i"}
+	}
+	args = append(args[:len(args):len(args)], "-f", from, "--")
+	args = append(args, recipients...)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stderr = &stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(&lfWriter{w: stdin})
+	_, writeErr := email.WriteTo(w)
+	if writeErr == nil {
+		writeErr = w.Flush()
+	}
+	stdin.Close()
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return &ExitError{Code: exit.ExitCode(), Stderr: firstLine(stderr.String())}
+		}
+		return err
+	}
+
+	// the message can be invalid if the command exited before reading it
+	return writeErr
+}
+
+// lfWriter writes the CRLF line endings of the message as LF
+type lfWriter struct {
+	w io.Writer
+	// cr is true if the last byte written was a CR, not written yet
+	cr bool
+}
+
+func (l *lfWriter) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p)+1)
+	for _, b := range p {
+		if l.cr && b != '\n' {
+			out = append(out, '\r')
+		}
+		l.cr = b == '\r'
+		if !l.cr {
+			out = append(out, b)
+		}
+	}
+
+	if _, err := l.w.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// firstLine returns the first line of the output, limited to 512 bytes
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if len(s) > 512 {
+		s = s[:512]
+	}
+
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,119 @@
+package sendmail
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func newEmail() *mail.Email {
+	return mail.NewMSG().
+		SetFrom("from@example.com").
+		AddTo("ann@example.com").
+		AddBcc("bob@example.com").
+		SetSubject("Hello").
+		SetBody(mail.TextPlain, "Hello\n.\nBye")
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no /bin/sh")
+	}
+
+	dir, err := ioutil.TempDir("", "sendmail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the fake sendmail saves its arguments and its input
+	script := `echo "$@" > "$OUT.args"; cat > "$OUT.msg"`
+	os.Setenv("OUT", filepath.Join(dir, "out"))
+	defer os.Unsetenv("OUT")
+
+	c := &Command{Path: "/bin/sh", Args: []string{"-c", script, "sendmail", "-i"}}
+	if err := c.Send(context.Background(), newEmail()); err != nil {
+		t.Fatal(err)
+	}
+
+	args, _ := ioutil.ReadFile(filepath.Join(dir, "out.args"))
+	if got := strings.TrimSpace(string(args)); got != "-i -f from@example.com -- ann@example.com bob@example.com" {
+		t.Errorf("got args %q", got)
+	}
+
+	if err := c.Send(context.Background(), newEmail().AddFilter(mail.Sandbox("sandbox@example.com"))); err != nil {
+		t.Fatal(err)
+	}
+	args, _ = ioutil.ReadFile(filepath.Join(dir, "out.args"))
+	if got := strings.TrimSpace(string(args)); got != "-i -f from@example.com -- sandbox@example.com" {
+		t.Errorf("got args %q, want the sandbox", got)
+	}
+
+	msg, _ := ioutil.ReadFile(filepath.Join(dir, "out.msg"))
+	if bytes.Contains(msg, []byte("\r")) || !bytes.Contains(msg, []byte("\nSubject: Hello\n")) {
+		t.Errorf("got message %q", msg)
+	}
+
+	c.Args = []string{"-c", `echo "451 4.3.0 queue full" >&2; exit 75`}
+	err = c.Send(context.Background(), newEmail())
+	if e, ok := err.(*ExitError); !ok || !e.Temporary() || e.Stderr != "451 4.3.0 queue full" {
+		t.Errorf("got error %v, want a temporary ExitError", err)
+	}
+}
+
+func TestLFWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &lfWriter{w: &buf}
+	w.Write([]byte("a\r\nb\r"))
+	w.Write([]byte("\nc\rd\r\n"))
+
+	if got := buf.String(); got != "a\nb\nc\rd\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestPickup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pickup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &Pickup{Dir: dir}
+	if err := p.Send(context.Background(), newEmail()); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || filepath.Ext(files[0]) != ".eml" {
+		t.Fatalf("got files %v, want one .eml file", files)
+	}
+
+	msg, _ := ioutil.ReadFile(files[0])
+	if !strings.HasPrefix(string(msg), "X-Sender: from@example.com\r\nX-Receiver: ann@example.com\r\nX-Receiver: bob@example.com\r\n") {
+		t.Errorf("got message %q", msg)
+	}
+	if strings.Contains(string(msg), "Bcc:") {
+		t.Error("the message has a Bcc header")
+	}
+	// the filters of the email change the envelope
+	os.Remove(files[0])
+	if err := p.Send(context.Background(), newEmail().AddFilter(mail.Sandbox("sandbox@example.com"))); err != nil {
+		t.Fatal(err)
+	}
+	files, _ = filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("got files %v", files)
+	}
+	msg, _ = ioutil.ReadFile(files[0])
+	if !strings.HasPrefix(string(msg), "X-Sender: from@example.com\r\nX-Receiver: sandbox@example.com\r\nDate:") {
+		t.Errorf("got message %q, want the sandbox", msg)
+	}
+}
@@ -0,0 +1,207 @@
+// Package ses sends emails built with Go Simple Mail with the SendRawEmail
+// action of the Amazon SES API. The message is built like for SMTP, with
+// the filters of the email, and the requests are signed with AWS Signature
+// Version 4, so switching off SMTP only changes the sender:
+//
+//	client := ses.NewClient("eu-west-1", accessKeyID, secretAccessKey)
+//	err := client.Send(ctx, email)
+//
+// The identity of the From address, or of its domain, must be verified in
+// SES.
+//
+// The metadata of the email is sent as message tags, and each of its tags
+// as a message tag with the value true. The characters that SES doesn't
+// allow in the tags are replaced with _.
+package ses
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+var _ mail.Sender = (*Client)(nil)
+
+// Client sends the emails with the SES API.
+type Client struct {
+	// Region is the AWS region of the SES endpoint, like "us-east-1"
+	Region string
+	// Endpoint is the URL of the API, "https://email.<Region>.amazonaws.com"
+	// if not set
+	Endpoint string
+	// AccessKeyID and SecretAccessKey are the credentials of the requests,
+	// and SessionToken, if set, the token of temporary credentials
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// ConfigurationSet, if set, is the configuration set of the emails
+	ConfigurationSet string
+	HTTPClient       *http.Client
+}
+
+// Error is an error returned by the API.
+type Error struct {
+	StatusCode int
+	// Code is the error code, like "MessageRejected" or "Throttling"
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return "ses: " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode) + ": " + e.Message
+	}
+
+	return "ses: " + e.Code + ": " + e.Message
+}
+
+// Temporary returns true if the email can be sent again later, when the
+// API is unavailable or limits the rate.
+func (e *Error) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests || e.Code == "Throttling"
+}
+
+// NewClient returns a client of the region with the credentials.
+func NewClient(region, accessKeyID, secretAccessKey string) *Client {
+	return &Client{
+		Region:          region,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		HTTPClient:      &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Send sends the message of the email to all its recipients, including
+// Bcc.
+func (c *Client) Send(ctx context.Context, email *mail.Email) error {
+	_, err := c.SendWithID(ctx, email)
+	return err
+}
+
+// SendWithID sends the email like Send and returns the message id given by
+// SES, to match its notifications.
+func (c *Client) SendWithID(ctx context.Context, email *mail.Email) (string, error) {
+	if email.Error != nil {
+		return "", email.Error
+	}
+
+	from, recipients, err := email.Envelope()
+	if err != nil {
+		return "", err
+	}
+
+	var msg strings.Builder
+	if _, err := email.WriteTo(&msg); err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("Action", "SendRawEmail")
+	form.Set("Version", "2010-12-01")
+	form.Set("Source", from)
+	for i, recipient := range recipients {
+		form.Set("Destinations.member."+strconv.Itoa(i+1), recipient)
+	}
+	form.Set("RawMessage.Data", base64.StdEncoding.EncodeToString([]byte(msg.String())))
+	if c.ConfigurationSet != "" {
+		form.Set("ConfigurationSetName", c.ConfigurationSet)
+	}
+	setTags(form, email)
+	body := []byte(form.Encode())
+
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = "https://email." + c.Region + ".amazonaws.com"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+	c.sign(req, body, signingService, time.Now())
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		var result struct {
+			Error struct {
+				Code    string
+				Message string
+			}
+		}
+		if xml.Unmarshal(data, &result) != nil || result.Error.Code == "" {
+			return "", &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		}
+		return "", &Error{StatusCode: resp.StatusCode, Code: result.Error.Code, Message: result.Error.Message}
+	}
+
+	var result struct {
+		MessageID string `xml:"SendRawEmailResult>MessageId"`
+	}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return "", err
+	}
+
+	return result.MessageID, nil
+}
+
+// setTags sets the metadata and the tags of the email as the message tags
+func setTags(form url.Values, email *mail.Email) {
+	metadata := email.Metadata()
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	n := 0
+	setTag := func(name, value string) {
+		n++
+		prefix := "Tags.member." + strconv.Itoa(n) + "."
+		form.Set(prefix+"Name", tagValue(name))
+		form.Set(prefix+"Value", tagValue(value))
+	}
+	for _, key := range keys {
+		setTag(key, metadata[key])
+	}
+	for _, tag := range email.Tags() {
+		setTag(tag, "true")
+	}
+}
+
+// tagValue replaces the characters not allowed in a message tag, only
+// ASCII letters, digits, _ and - are
+func tagValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
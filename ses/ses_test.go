@@ -0,0 +1,93 @@
+package ses
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func TestSign(t *testing.T) {
+	// the get-vanilla case of the AWS Signature Version 4 test suite
+	c := &Client{Region: "us-east-1", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	c.sign(req, nil, "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>InvalidClientTokenId</Code><Message>The security token included in the request is invalid.</Message></Error></ErrorResponse>`))
+			return
+		}
+		r.ParseForm()
+		form = r.PostForm
+		if form.Get("Destinations.member.2") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>MessageRejected</Code><Message>Email address is not verified.</Message></Error></ErrorResponse>`))
+			return
+		}
+		w.Write([]byte(`<SendRawEmailResponse><SendRawEmailResult><MessageId>0100-abc</MessageId></SendRawEmailResult></SendRawEmailResponse>`))
+	}))
+	defer server.Close()
+
+	client := NewClient("eu-west-1", "key", "secret")
+	client.Endpoint = server.URL
+
+	email := mail.NewMSG().
+		SetFrom("from@example.com").
+		AddTo("ann@example.com").
+		AddBcc("bob@example.com").
+		SetSubject("Hello").
+		SetMetadata("user_id", "42").
+		AddTag("spring sale").
+		SetBody(mail.TextPlain, "Hello")
+
+	id, err := client.SendWithID(context.Background(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "0100-abc" {
+		t.Errorf("got id %q", id)
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(form.Get("RawMessage.Data"))
+	if form.Get("Action") != "SendRawEmail" || form.Get("Source") != "from@example.com" ||
+		form.Get("Destinations.member.1") != "ann@example.com" || !strings.Contains(string(raw), "Subject: Hello") {
+		t.Errorf("got form %v", form)
+	}
+	if form.Get("Tags.member.1.Name") != "user_id" || form.Get("Tags.member.1.Value") != "42" ||
+		form.Get("Tags.member.2.Name") != "spring_sale" || form.Get("Tags.member.2.Value") != "true" {
+		t.Errorf("got tags %v", form)
+	}
+
+	// the filters of the email change the envelope
+	_, err = client.SendWithID(context.Background(), mail.NewMSG().
+		SetFrom("from@example.com").
+		AddTo("realcustomer@example.org").
+		AddBcc("bob@example.com").
+		SetBody(mail.TextPlain, "Hello").
+		AddFilter(mail.Sandbox("sandbox@example.com")))
+	if got := form.Get("Destinations.member.1"); got != "sandbox@example.com" || form.Get("Destinations.member.2") != "" {
+		t.Errorf("got destinations %v, want the sandbox", form)
+	}
+
+	err = client.Send(context.Background(), mail.NewMSG().SetFrom("from@example.com").AddTo("ann@example.com").SetBody(mail.TextPlain, "Hello"))
+	if e, ok := err.(*Error); !ok || e.Code != "MessageRejected" || e.Temporary() {
+		t.Errorf("got error %v, want MessageRejected", err)
+	}
+}
@@ -0,0 +1,57 @@
+package mail
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// MaxSevenBitLineLength is the maximum line length, without CRLF, of the
+// messages built in 7-bit mode.
+const MaxSevenBitLineLength = 78
+
+// SetSevenBit enables the strict 7-bit mode, for legacy gateways that
+// mangle 8-bit content. Bodies without encoding are encoded as
+// quoted-printable, long header words are split in encoded words, and the
+// message fails to build if any byte is 8-bit or any line is longer than
+// MaxSevenBitLineLength.
+func (email *Email) SetSevenBit(enabled bool) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.sevenBit = enabled
+
+	return email
+}
+
+// check7bit returns an error if the message has 8-bit bytes or long lines.
+// The bare line feeds end the lines too, as they are sent as CRLF.
+func check7bit(msg string) error {
+	for i, line := range strings.Split(normalizeCRLF(msg), "\r\n") {
+		if len(line) > MaxSevenBitLineLength {
+			return errors.New("Mail Error: Line " + strconv.Itoa(i+1) + " is longer than " + strconv.Itoa(MaxSevenBitLineLength) + " characters in 7-bit mode")
+		}
+
+		for j := 0; j < len(line); j++ {
+			if line[j] >= 0x80 {
+				return errors.New("Mail Error: Line " + strconv.Itoa(i+1) + " has 8-bit characters in 7-bit mode")
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasLongLine reports whether an encoded header value, starting after
+// usedChars characters, has lines longer than MaxSevenBitLineLength
+func hasLongLine(value string, usedChars int) bool {
+	for _, line := range strings.Split(value, "\r\n") {
+		if usedChars+len(line) > MaxSevenBitLineLength {
+			return true
+		}
+		usedChars = 0
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSevenBit(t *testing.T) {
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetSubject("Résumé "+strings.Repeat("x", 100)).
+		SetBody(TextPlain, "Héllo "+strings.Repeat("long line ", 20))
+	email.Encoding = EncodingNone
+	email.SetSevenBit(true)
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+
+	if err := check7bit(msg); err != nil {
+		t.Errorf("message is not 7-bit: %v\n%s", err, msg)
+	}
+	if !strings.Contains(msg, "Content-Transfer-Encoding: quoted-printable") {
+		t.Errorf("body not encoded as quoted-printable:\n%s", msg)
+	}
+
+	// a printable but long subject is split in encoded words
+	email = NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetSubject(strings.Repeat("x", 100)).
+		SetBody(TextPlain, "Hello")
+	email.SetSevenBit(true)
+
+	if msg = email.GetMessage(); email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+	if err := check7bit(msg); err != nil {
+		t.Errorf("message is not 7-bit: %v\n%s", err, msg)
+	}
+}
+
+func TestSevenBitAttachment(t *testing.T) {
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte("%PDF"), "quarterly-financial-report-2026.pdf", "").
+		SetSevenBit(true)
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatalf("unexpected error: %v", email.Error)
+	}
+	if !strings.Contains(msg, "filename=\"quarterly-financial-report-2026.pdf\"") {
+		t.Errorf("attachment not found:\n%s", msg)
+	}
+
+	// the reader checks the message like GetMessage
+	email = NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte("data"), strings.Repeat("x", 80)+".txt", "").
+		SetSevenBit(true)
+	if _, err := email.NewReader(); err == nil {
+		t.Error("expected error from NewReader for a line too long in 7-bit mode")
+	}
+	if _, err := email.WriteTo(ioutil.Discard); err == nil {
+		t.Error("expected error from WriteTo for a line too long in 7-bit mode")
+	}
+}
+
+func TestCheck7bit(t *testing.T) {
+	if err := check7bit("Subject: Héllo\r\n"); err == nil {
+		t.Errorf("expected error for 8-bit characters")
+	}
+	if err := check7bit(strings.Repeat("x", 79) + "\r\n"); err == nil {
+		t.Errorf("expected error for a long line")
+	}
+	if err := check7bit("Content-Type: text/plain;\n \tname=\"" + strings.Repeat("x", 60) + "\"\r\n"); err != nil {
+		t.Errorf("unexpected error for a header folded with a bare line feed: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build go1.21
+// +build go1.21
+
+package mail
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SetLogger sets the logger of the records of the clients of the server.
+// The connections and closes are logged, with the send results, at the
+// Debug, Info, Warn and Error levels, with the keys:
+//
+//	host, port        the server, in every record
+//	encryption        the encryption of a connection
+//	duration          the duration of a connection or a send
+//	message_id, from  the Message-Id and the envelope sender of a send
+//	recipients, size  the number of recipients and the size of a message
+//	code, error       the SMTP reply code and the error of a failure
+//
+// A nil logger disables the logging, the default.
+func (server *SMTPServer) SetLogger(logger *slog.Logger) *SMTPServer {
+	if logger == nil {
+		server.logger = nil
+	} else {
+		server.logger = slogLogger{logger}
+	}
+
+	return server
+}
+
+// slogLogger sends the log records to a slog logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) log(level logLevel, msg string, args ...interface{}) {
+	l.logger.Log(context.Background(), slog.Level(level), msg, args...)
+}
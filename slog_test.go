@@ -0,0 +1,128 @@
+//go:build go1.21
+// +build go1.21
+
+package mail
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/textproto"
+	"testing"
+)
+
+func TestSetLogger(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go fakeSMTP(ln, messages, map[string]string{"RCPT": "550 5.1.1 No such user"})
+
+	var buf bytes.Buffer
+	server := newPoolServer(ln)
+	server.KeepAlive = true
+	server.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPoolEmail().Send(client)
+	if err := newPoolEmail().Send(client); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	want := []struct {
+		level, msg string
+	}{
+		{"INFO", "smtp connected"},
+		{"WARN", "smtp send failed"},
+		{"INFO", "smtp message sent"},
+		{"DEBUG", "smtp connection closed"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got records %v", records)
+	}
+	for i, w := range want {
+		if records[i]["level"] != w.level || records[i]["msg"] != w.msg || records[i][logKeyHost] != server.Host {
+			t.Errorf("got record %v, want %s %q", records[i], w.level, w.msg)
+		}
+	}
+
+	failed := records[1]
+	if failed[logKeyCode] != float64(550) || failed[logKeyError] != (&textproto.Error{Code: 550, Msg: "5.1.1 No such user"}).Error() {
+		t.Errorf("got failure record %v", failed)
+	}
+	if records[2][logKeyFrom] != "from@example.com" || records[2][logKeyRecipients] != float64(1) || records[2][logKeySize] == nil {
+		t.Errorf("got send record %v", records[2])
+	}
+}
+
+func TestLoggerCorrelationID(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go fakeSMTP(ln, messages,
+		map[string]string{"MAIL": "421 4.3.2 Service shutting down"},
+		map[string]string{"RCPT": "550 5.1.1 No such user"})
+
+	var buf bytes.Buffer
+	server := newPoolServer(ln)
+	server.KeepAlive = true
+	server.AutoReconnect = true
+	server.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// the server closes the first connection, the email is sent again in
+	// a new connection
+	newPoolEmail().SetCorrelationID("order-42").Send(client)
+	if err := newPoolEmail().SetCorrelationID("order-43").Send(client); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	want := []struct {
+		msg, correlation string
+	}{
+		{"smtp connected", ""},
+		{"smtp reconnecting", "order-42"},
+		{"smtp connected", "order-42"},
+		{"smtp send failed", "order-42"},
+		{"smtp message sent", "order-43"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got records %v", records)
+	}
+	for i, w := range want {
+		id, ok := records[i][logKeyCorrelationID]
+		if records[i]["msg"] != w.msg || (w.correlation == "" && ok) || (w.correlation != "" && id != w.correlation) {
+			t.Errorf("got record %v, want %q with correlation id %q", records[i], w.msg, w.correlation)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// DefaultSlowPeerWindow is the window the data rate is measured over when
+// SMTPServer.SlowPeerWindow is not set.
+const DefaultSlowPeerWindow = 30 * time.Second
+
+// SlowPeerError is returned when the server reads the message data slower
+// than SMTPServer.MinDataRate. The connection is closed.
+type SlowPeerError struct {
+	// Rate is the measured rate in bytes per second
+	Rate   float64
+	Window time.Duration
+}
+
+func (e *SlowPeerError) Error() string {
+	return fmt.Sprintf("Mail Error: SMTP server reading data at %.0f bytes/s over %v, below the minimum rate", e.Rate, e.Window)
+}
+
+// rateWriter aborts the writes to w if they are slower than minRate bytes
+// per second over a window
+type rateWriter struct {
+	w           io.Writer
+	setDeadline func(time.Time) error
+	minRate     int
+	window      time.Duration
+	start       time.Time
+	n           int
+}
+
+// rateChunk is the size of each write, so the rate is checked often
+const rateChunk = 4096
+
+func newRateWriter(w io.Writer, conn net.Conn, minRate int, window time.Duration) *rateWriter {
+	if window == 0 {
+		window = DefaultSlowPeerWindow
+	}
+
+	return &rateWriter{
+		w:           w,
+		setDeadline: conn.SetWriteDeadline,
+		minRate:     minRate,
+		window:      window,
+		start:       time.Now(),
+	}
+}
+
+func (r *rateWriter) Write(p []byte) (int, error) {
+	written := 0
+
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > rateChunk {
+			chunk = chunk[:rateChunk]
+		}
+
+		// a chunk blocked for a whole window is below the minimum rate
+		r.setDeadline(time.Now().Add(r.window))
+
+		n, err := r.w.Write(chunk)
+		written += n
+		r.n += n
+		p = p[n:]
+
+		elapsed := time.Since(r.start)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return written, &SlowPeerError{Rate: float64(r.n) / elapsed.Seconds(), Window: r.window}
+			}
+			return written, err
+		}
+
+		if elapsed >= r.window {
+			if rate := float64(r.n) / elapsed.Seconds(); rate < float64(r.minRate) {
+				return written, &SlowPeerError{Rate: rate, Window: r.window}
+			}
+			r.start = time.Now()
+			r.n = 0
+		}
+	}
+
+	return written, nil
+}
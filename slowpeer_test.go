@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// slowWriter sleeps before every write
+type slowWriter struct {
+	bytes.Buffer
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.Buffer.Write(p)
+}
+
+func TestRateWriter(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 10*rateChunk)
+	noDeadline := func(time.Time) error { return nil }
+
+	fast := &rateWriter{w: &slowWriter{}, setDeadline: noDeadline, minRate: 1024, window: 20 * time.Millisecond, start: time.Now()}
+	if _, err := fast.Write(data); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// 4096 bytes every 10ms is about 400KB/s
+	slow := &rateWriter{w: &slowWriter{delay: 10 * time.Millisecond}, setDeadline: noDeadline, minRate: 1 << 20, window: 20 * time.Millisecond, start: time.Now()}
+	n, err := slow.Write(data)
+	if _, ok := err.(*SlowPeerError); !ok {
+		t.Fatalf("got error %v, want SlowPeerError", err)
+	}
+	if n >= len(data) {
+		t.Errorf("wrote %d bytes, want the write aborted", n)
+	}
+}
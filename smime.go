@@ -0,0 +1,203 @@
+package mail
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/xhit/go-simple-mail/v2/mime"
+)
+
+// smimeOptions are the S/MIME options of an email
+type smimeOptions struct {
+	signer     *smimeSigner
+	recipients []*x509.Certificate
+}
+
+// smimeSigner is the signer of the S/MIME signed emails
+type smimeSigner struct {
+	cert  *x509.Certificate
+	key   crypto.Signer
+	chain []*x509.Certificate
+}
+
+// SMIMESign signs the email with S/MIME (RFC 8551), as a multipart/signed
+// message with a detached SHA-256 signature. The chain certificates, if
+// any, are included in the signature so the recipients can verify it. The
+// signature is computed when the message is built, so the whole message is
+// kept in memory. The key is not saved in the drafts.
+func (email *Email) SMIMESign(cert *x509.Certificate, key crypto.Signer, chain ...*x509.Certificate) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	if cert == nil || key == nil {
+		email.Error = errors.New("Mail Error: S/MIME signing needs a certificate and a key")
+		return email
+	}
+
+	email.smime.signer = &smimeSigner{cert: cert, key: key, chain: chain}
+
+	return email
+}
+
+// SMIMEEncrypt encrypts the email with S/MIME (RFC 8551) for the
+// recipients certificates, as an application/pkcs7-mime message encrypted
+// with AES-256-CBC. The certificates must have RSA keys. Signed emails are
+// signed first and then encrypted. Include the certificate of the sender
+// to be able to read the sent message.
+func (email *Email) SMIMEEncrypt(recipientCerts []*x509.Certificate) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	if len(recipientCerts) == 0 {
+		email.Error = errors.New("Mail Error: S/MIME encryption needs at least a recipient certificate")
+		return email
+	}
+
+	email.smime.recipients = recipientCerts
+
+	return email
+}
+
+// smime wraps the built content of the message in the S/MIME structures
+func (msg *message) smime(email *Email) {
+	if msg.err != nil || (email.smime.signer == nil && len(email.smime.recipients) == 0) {
+		return
+	}
+
+	entity, err := msg.entity()
+	if err != nil {
+		msg.err = err
+		return
+	}
+
+	if email.smime.signer != nil {
+		if entity, err = msg.smimeSign(entity, email.smime.signer); err != nil {
+			msg.err = errors.New("Mail Error: Failed to sign the message: " + err.Error())
+			return
+		}
+	}
+
+	if len(email.smime.recipients) > 0 {
+		if entity, err = smimeEncrypt(entity, email.smime.recipients); err != nil {
+			msg.err = errors.New("Mail Error: Failed to encrypt the message: " + err.Error())
+			return
+		}
+	}
+
+	msg.setEntity(entity)
+}
+
+// entity removes the content headers and body of the message and returns
+// them as a MIME entity in canonical form, with CRLF line endings, to be
+// signed or encrypted
+func (msg *message) entity() (string, error) {
+	header := make(textproto.MIMEHeader)
+	for name, values := range msg.headers {
+		if strings.HasPrefix(name, "Content-") {
+			header[name] = values
+			delete(msg.headers, name)
+		}
+	}
+
+	msg.flush()
+	body, err := ioutil.ReadAll(newMessageReader(msg.segments))
+	if err != nil {
+		return "", err
+	}
+
+	return normalizeCRLF(entityHeader(header) + string(body)), nil
+}
+
+// setEntity sets the content headers and body of the message to the ones
+// of the entity
+func (msg *message) setEntity(entity string) {
+	i := strings.Index(entity, "\r\n\r\n")
+	lines := strings.Split(entity[:i], "\r\n")
+	for j := 0; j < len(lines); j++ {
+		name, value := lines[j], ""
+		if k := strings.Index(name, ": "); k >= 0 {
+			name, value = name[:k], name[k+2:]
+		}
+		// the folded lines
+		for j+1 < len(lines) && strings.HasPrefix(lines[j+1], " ") {
+			value += "\r\n" + lines[j+1]
+			j++
+		}
+		msg.headers.Set(name, value)
+	}
+
+	msg.segments = []segment{{data: []byte(entity[i+4:])}}
+}
+
+// boundary returns a new boundary for the multiparts written by hand
+func (msg *message) boundary(prefix string) string {
+	if msg.multipart.BoundaryFunc != nil {
+		return msg.multipart.BoundaryFunc()
+	}
+
+	return mime.PrefixBoundary(prefix)()
+}
+
+// entityHeader returns the header of a MIME entity, the Content-Type first
+func entityHeader(header textproto.MIMEHeader) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		if name != "Content-Type" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	s := "Content-Type: " + header.Get("Content-Type") + "\r\n"
+	for _, name := range names {
+		for _, value := range header[name] {
+			s += name + ": " + value + "\r\n"
+		}
+	}
+
+	return s + "\r\n"
+}
+
+// smimeSign returns the multipart/signed entity of the signed entity
+func (msg *message) smimeSign(entity string, signer *smimeSigner) (string, error) {
+	signature, err := cmsSign([]byte(entity), signer.cert, signer.key, signer.chain, msg.now())
+	if err != nil {
+		return "", err
+	}
+
+	boundary := msg.boundary("signed-")
+
+	// the entity is written as is, the signature is over its exact bytes
+	return "Content-Type: multipart/signed; protocol=\"application/pkcs7-signature\"; micalg=sha-256;\r\n" +
+		" boundary=\"" + boundary + "\"\r\n\r\n" +
+		"This is an S/MIME signed message\r\n" +
+		"\r\n--" + boundary + "\r\n" +
+		entity +
+		"\r\n--" + boundary + "\r\n" +
+		"Content-Type: application/pkcs7-signature; name=\"smime.p7s\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-Disposition: attachment; filename=\"smime.p7s\"\r\n\r\n" +
+		string(mime.Base64Encode(signature)) +
+		"\r\n--" + boundary + "--\r\n", nil
+}
+
+// smimeEncrypt returns the application/pkcs7-mime entity of the encrypted
+// entity
+func smimeEncrypt(entity string, recipients []*x509.Certificate) (string, error) {
+	enveloped, err := cmsEncrypt([]byte(entity), recipients)
+	if err != nil {
+		return "", err
+	}
+
+	return "Content-Type: application/pkcs7-mime; smime-type=enveloped-data; name=\"smime.p7m\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-Disposition: attachment; filename=\"smime.p7m\"\r\n\r\n" +
+		string(mime.Base64Encode(enveloped)), nil
+}
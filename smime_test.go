@@ -0,0 +1,196 @@
+package mail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCertificate(t *testing.T, key crypto.Signer) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "from@example.com"},
+		NotBefore:    time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2041, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func newSMIMEEmail() *Email {
+	return NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetSubject("Signed").
+		SetBody(TextPlain, "Hello\nSigned world").
+		AddAttachmentData([]byte("abc"), "data.txt", "").
+		SetBoundaryFunc(func() string { return "b" + time.Now().Format("150405.000000000") })
+}
+
+// verifySignature verifies the signature of a multipart/signed body
+func verifySignature(t *testing.T, body, boundary string, pub crypto.PublicKey) string {
+	parts := strings.Split(body, "\r\n--"+boundary)
+	if len(parts) != 4 {
+		t.Fatalf("got %d parts in the signed body:\n%s", len(parts), body)
+	}
+	entity := strings.TrimPrefix(parts[1], "\r\n")
+
+	p7s := parts[2][strings.Index(parts[2], "\r\n\r\n")+4:]
+	der, err := base64.StdEncoding.DecodeString(strings.Replace(p7s, "\r\n", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var info cmsContentInfo
+	var signed cmsSignedData
+	if _, err = asn1.Unmarshal(der, &info); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		t.Fatal(err)
+	}
+
+	signer := signed.SignerInfos[0]
+	digest := sha256.Sum256([]byte(entity))
+	if !bytes.Contains(signer.SignedAttrs.Bytes, digest[:]) {
+		t.Error("the message digest doesn't match the signed entity")
+	}
+
+	set, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: signer.SignedAttrs.Bytes})
+	attrsDigest := sha256.Sum256(set)
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, attrsDigest[:], signer.Signature)
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if _, err = asn1.Unmarshal(signer.Signature, &sig); err == nil && !ecdsa.Verify(pub, attrsDigest[:], sig.R, sig.S) {
+			err = rsa.ErrVerification
+		}
+	}
+	if err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+
+	return entity
+}
+
+func TestSMIMESign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		email := newSMIMEEmail().SMIMESign(testCertificate(t, key), key)
+		msg := email.GetMessage()
+		if email.Error != nil {
+			t.Fatal(email.Error)
+		}
+
+		i := strings.Index(msg, "\r\n\r\n")
+		header, body := strings.Replace(msg[:i], "\r\n ", " ", -1), msg[i+4:]
+		if !strings.Contains(header, "Content-Type: multipart/signed; protocol=\"application/pkcs7-signature\"; micalg=sha-256;") {
+			t.Fatalf("message not signed:\n%s", header)
+		}
+		if !strings.Contains(header, "Subject: Signed") {
+			t.Errorf("headers not kept:\n%s", header)
+		}
+
+		boundary := header[strings.Index(header, `boundary="`)+10:]
+		boundary = boundary[:strings.Index(boundary, `"`)]
+
+		entity := verifySignature(t, body, boundary, key.Public())
+		if !strings.HasPrefix(entity, "Content-Type: multipart/mixed") || !strings.Contains(entity, "Signed world") {
+			t.Errorf("unexpected signed entity:\n%s", entity)
+		}
+	}
+}
+
+func TestSMIMEEncrypt(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := testCertificate(t, key)
+
+	email := newSMIMEEmail().SMIMESign(cert, key).SMIMEEncrypt([]*x509.Certificate{cert})
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	i := strings.Index(msg, "\r\n\r\n")
+	if !strings.Contains(msg[:i], "Content-Type: application/pkcs7-mime; smime-type=enveloped-data") {
+		t.Fatalf("message not encrypted:\n%s", msg[:i])
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.Replace(msg[i+4:], "\r\n", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var info cmsContentInfo
+	var enveloped cmsEnvelopedData
+	if _, err = asn1.Unmarshal(der, &info); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = asn1.Unmarshal(info.Content.Bytes, &enveloped); err != nil {
+		t.Fatal(err)
+	}
+
+	contentKey, err := rsa.DecryptPKCS1v15(rand.Reader, key, enveloped.RecipientInfos[0].EncryptedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := aes.NewCipher(contentKey)
+	content := enveloped.EncryptedContentInfo
+	data := content.EncryptedContent
+	cipher.NewCBCDecrypter(block, content.ContentEncryptionAlgorithm.Parameters.Bytes).CryptBlocks(data, data)
+	data = data[:len(data)-int(data[len(data)-1])]
+
+	entity := string(data)
+	if !strings.HasPrefix(entity, "Content-Type: multipart/signed;") {
+		t.Fatalf("unexpected encrypted entity:\n%s", entity)
+	}
+
+	j := strings.Index(entity, "\r\n\r\n")
+	boundary := entity[strings.Index(entity, `boundary="`)+10:]
+	boundary = boundary[:strings.Index(boundary, `"`)]
+	verifySignature(t, entity[j+4:], boundary, key.Public())
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	email = newSMIMEEmail().SMIMEEncrypt([]*x509.Certificate{testCertificate(t, ecKey)})
+	if email.GetMessage(); email.Error == nil {
+		t.Error("expected an error encrypting for an ECDSA certificate")
+	}
+}
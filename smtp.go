@@ -9,6 +9,8 @@
 //	AUTH      RFC 2554
 //	STARTTLS  RFC 3207
 //  SIZE      RFC 1870
+//  PRDR      draft-hall-prdr
+//  CHUNKING  RFC 3030
 // Additional extensions may be handled by clients using smtp.go in golang source code or pull request Go Simple Mail
 
 // smtp.go file is a modification of smtp golang package what is frozen and is not accepting new features.
@@ -24,6 +26,7 @@ import (
 	"net"
 	"net/textproto"
 	"strings"
+	"time"
 )
 
 // A Client represents a client connection to an SMTP server.
@@ -43,6 +46,11 @@ type smtpClient struct {
 	localName  string // the name to use in HELO/EHLO
 	didHello   bool   // whether we've said HELO/EHLO
 	helloError error  // the error from the hello
+	// minimum data rate and the window it's measured over
+	minDataRate    int
+	slowPeerWindow time.Duration
+	// messages is the number of mail transactions of the connection
+	messages int
 }
 
 // newClient returns a new smtpClient using an existing connection and host as a
@@ -158,12 +166,12 @@ func (c *smtpClient) startTLS(config *tls.Config) error {
 // authenticate authenticates a client using the provided authentication mechanism.
 // A failed authentication closes the connection.
 // Only servers that advertise the AUTH extension support this function.
-func (c *smtpClient) authenticate(a auth) error {
+func (c *smtpClient) authenticate(a Auth) error {
 	if err := c.hello(); err != nil {
 		return err
 	}
 	encoding := base64.StdEncoding
-	mech, resp, err := a.start(&serverInfo{c.serverName, c.tls, c.a})
+	mech, resp, err := a.Start(&ServerInfo{c.serverName, c.tls, c.a})
 	if err != nil {
 		c.quit()
 		return err
@@ -183,7 +191,7 @@ func (c *smtpClient) authenticate(a auth) error {
 			err = &textproto.Error{Code: code, Msg: msg64}
 		}
 		if err == nil {
-			resp, err = a.next(msg, code == 334)
+			resp, err = a.Next(msg, code == 334)
 		}
 		if err != nil {
 			// abort the AUTH
@@ -208,47 +216,72 @@ func (c *smtpClient) authenticate(a auth) error {
 // SMTPUTF8 parameter.
 // This initiates a mail transaction and is followed by one or more Rcpt calls.
 func (c *smtpClient) mail(from string, extArgs ...map[string]string) error {
-	var args []interface{}
 	var extMap map[string]string
 
 	if len(extArgs) > 0 {
 		extMap = extArgs[0]
 	}
 
+	return c.mailParams(from, extMap, nil)
+}
+
+// mailParams issues a MAIL command with the automatic parameters and the
+// given ESMTP parameters, which replace the automatic ones with the same
+// keyword.
+func (c *smtpClient) mailParams(from string, extMap map[string]string, params []Param) error {
+	var args []interface{}
+
 	if err := validateLine(from); err != nil {
 		return err
 	}
 	if err := c.hello(); err != nil {
 		return err
 	}
+	if err := c.checkParams(params); err != nil {
+		return err
+	}
 	cmdStr := "MAIL FROM:<%s>"
 	if c.ext != nil {
-		if _, ok := c.ext["8BITMIME"]; ok {
+		if _, ok := c.ext["8BITMIME"]; ok && !hasParam(params, "BODY") {
 			cmdStr += " BODY=8BITMIME"
 		}
-		if _, ok := c.ext["SMTPUTF8"]; ok {
+		if _, ok := c.ext["SMTPUTF8"]; ok && !hasParam(params, "SMTPUTF8") {
 			cmdStr += " SMTPUTF8"
 		}
-		if _, ok := c.ext["SIZE"]; ok {
+		if _, ok := c.ext["SIZE"]; ok && !hasParam(params, "SIZE") {
 			if extMap["SIZE"] != "" {
 				cmdStr += " SIZE=%s"
 				args = append(args, extMap["SIZE"])
 			}
 		}
 	}
+	for _, p := range params {
+		cmdStr += " %s"
+		args = append(args, p.String())
+	}
 	args = append([]interface{}{from}, args...)
 	_, _, err := c.cmd(250, cmdStr, args...)
 	return err
 }
 
-// rcpt issues a RCPT command to the server using the provided email address.
+// rcpt issues a RCPT command to the server using the provided email address
+// and ESMTP parameters.
 // A call to Rcpt must be preceded by a call to Mail and may be followed by
 // a Data call or another Rcpt call.
-func (c *smtpClient) rcpt(to string) error {
+func (c *smtpClient) rcpt(to string, params ...Param) error {
 	if err := validateLine(to); err != nil {
 		return err
 	}
-	_, _, err := c.cmd(25, "RCPT TO:<%s>", to)
+	if err := c.checkParams(params); err != nil {
+		return err
+	}
+	cmdStr := "RCPT TO:<%s>"
+	args := []interface{}{to}
+	for _, p := range params {
+		cmdStr += " %s"
+		args = append(args, p.String())
+	}
+	_, _, err := c.cmd(25, cmdStr, args...)
 	return err
 }
 
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"io"
 	"net"
 	"net/textproto"
@@ -18,7 +19,7 @@ import (
 )
 
 type authTest struct {
-	auth       auth
+	auth       Auth
 	challenges []string
 	name       string
 	responses  []string
@@ -35,7 +36,7 @@ var authTests = []authTest{
 func TestAuth(t *testing.T) {
 testLoop:
 	for i, test := range authTests {
-		name, resp, err := test.auth.start(&serverInfo{"testserver", true, nil})
+		name, resp, err := test.auth.Start(&ServerInfo{"testserver", true, nil})
 		if name != test.name {
 			t.Errorf("#%d got name %s, expected %s", i, name, test.name)
 		}
@@ -48,7 +49,7 @@ testLoop:
 		for j := range test.challenges {
 			challenge := []byte(test.challenges[j])
 			expected := []byte(test.responses[j+1])
-			resp, err := test.auth.next(challenge, true)
+			resp, err := test.auth.Next(challenge, true)
 			if err != nil {
 				t.Errorf("#%d error: %s", i, err)
 				continue testLoop
@@ -65,27 +66,33 @@ func TestAuthPlain(t *testing.T) {
 
 	tests := []struct {
 		authName string
-		server   *serverInfo
+		server   *ServerInfo
 		err      string
 	}{
 		{
 			authName: "servername",
-			server:   &serverInfo{name: "servername", tls: true},
+			server:   &ServerInfo{Name: "servername", TLS: true},
 		},
 		{
 			// OK to use plainAuthfn on localhost without TLS
 			authName: "localhost",
-			server:   &serverInfo{name: "localhost", tls: false},
+			server:   &ServerInfo{Name: "localhost", TLS: false},
 		},
 		{
+			// not OK to send the credentials without TLS
 			authName: "servername",
-			server:   &serverInfo{name: "attacker", tls: true},
+			server:   &ServerInfo{Name: "servername", TLS: false},
+			err:      errInsecureAuth.Error(),
+		},
+		{
+			authName: "servername",
+			server:   &ServerInfo{Name: "attacker", TLS: true},
 			err:      "wrong host name",
 		},
 	}
 	for i, tt := range tests {
 		auth := plainAuthfn("foo", "bar", "baz", tt.authName)
-		_, _, err := auth.start(tt.server)
+		_, _, err := auth.Start(tt.server)
 		got := ""
 		if err != nil {
 			got = err.Error()
@@ -100,27 +107,33 @@ func TestAuthLogin(t *testing.T) {
 
 	tests := []struct {
 		authName string
-		server   *serverInfo
+		server   *ServerInfo
 		err      string
 	}{
 		{
 			authName: "servername",
-			server:   &serverInfo{name: "servername", tls: true},
+			server:   &ServerInfo{Name: "servername", TLS: true},
 		},
 		{
 			// OK to use loginAuthfn on localhost without TLS
 			authName: "localhost",
-			server:   &serverInfo{name: "localhost", tls: false},
+			server:   &ServerInfo{Name: "localhost", TLS: false},
+		},
+		{
+			// not OK to send the credentials without TLS
+			authName: "servername",
+			server:   &ServerInfo{Name: "servername", TLS: false},
+			err:      errInsecureAuth.Error(),
 		},
 		{
 			authName: "servername",
-			server:   &serverInfo{name: "attacker", tls: true},
+			server:   &ServerInfo{Name: "attacker", TLS: true},
 			err:      "wrong host name",
 		},
 	}
 	for i, tt := range tests {
 		auth := loginAuthfn("foo", "bar", "baz", tt.authName)
-		_, _, err := auth.start(tt.server)
+		_, _, err := auth.Start(tt.server)
 		got := ""
 		if err != nil {
 			got = err.Error()
@@ -131,6 +144,79 @@ func TestAuthLogin(t *testing.T) {
 	}
 }
 
+func TestAuthMechanisms(t *testing.T) {
+	server := &SMTPServer{
+		Host:           "servername",
+		Username:       "foo",
+		Password:       "bar",
+		Authentication: AuthPlain,
+		AuthMechanisms: []authType{AuthCRAMMD5, AuthLogin},
+	}
+
+	a, err := server.auth("servername", []string{"PLAIN", "LOGIN"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := a.(*loginAuth); !ok {
+		t.Errorf("got auth %T, want *loginAuth", a)
+	}
+
+	// LOGIN over an unencrypted connection is refused
+	if _, _, err = a.Start(&ServerInfo{Name: "servername"}); err != errInsecureAuth {
+		t.Errorf("got error %v, want %v", err, errInsecureAuth)
+	}
+
+	server.AllowInsecureAuth = true
+	a, _ = server.auth("servername", []string{"PLAIN", "LOGIN"})
+	if _, _, err = a.Start(&ServerInfo{Name: "servername"}); err != nil {
+		t.Errorf("unexpected error with AllowInsecureAuth: %v", err)
+	}
+
+	if _, err = server.auth("servername", []string{"PLAIN"}); err == nil {
+		t.Errorf("expected error when no mechanism is advertised")
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	tests := []struct {
+		host       string
+		port       int
+		wantHost   string
+		wantPort   string
+		shouldFail bool
+	}{
+		{host: "smtp.example.com", port: 587, wantHost: "smtp.example.com", wantPort: "587"},
+		{host: "smtp.example.com:2525", wantHost: "smtp.example.com", wantPort: "2525"},
+		{host: "[192.0.2.1]", port: 25, wantHost: "192.0.2.1", wantPort: "25"},
+		{host: "[IPv6:2001:db8::1]", port: 25, wantHost: "2001:db8::1", wantPort: "25"},
+		{host: "[2001:db8::1]:2525", wantHost: "2001:db8::1", wantPort: "2525"},
+		{host: "2001:db8::1", port: 25, wantHost: "2001:db8::1", wantPort: "25"},
+		{host: "[IPv6:2001:db8::1]:2525", wantHost: "2001:db8::1", wantPort: "2525"},
+		{host: "[192.0.2.1]:2525", wantHost: "192.0.2.1", wantPort: "2525"},
+		{host: "[not an ip]", port: 25, shouldFail: true},
+		{host: "[not-an-ip]:2525", shouldFail: true},
+		{host: "IPv6:2001:db8::1", port: 25, shouldFail: true},
+		{host: "[IPv6:192.0.2.1:25]", shouldFail: true},
+		{host: "smtp.example.com:2525", port: 25, shouldFail: true},
+		{host: "smtp.example.com", shouldFail: true},
+		{host: "smtp.example.com", port: 70000, shouldFail: true},
+		{host: "", port: 25, shouldFail: true},
+	}
+	for i, tt := range tests {
+		server := &SMTPServer{Host: tt.host, Port: tt.port}
+		host, port, err := server.hostPort()
+		if tt.shouldFail {
+			if err == nil {
+				t.Errorf("%d. expected error for %q", i, tt.host)
+			}
+			continue
+		}
+		if err != nil || host != tt.wantHost || port != tt.wantPort {
+			t.Errorf("%d. got %q, %q, %v, want %q, %q", i, host, port, err, tt.wantHost, tt.wantPort)
+		}
+	}
+}
+
 // Issue https://github.com/golang/go/issues/17794: don't send a trailing space on AUTH command when there's no password.
 func TestClientAuthTrimSpace(t *testing.T) {
 	server := "220 hello world\r\n" +
@@ -157,17 +243,49 @@ func TestClientAuthTrimSpace(t *testing.T) {
 	}
 }
 
+func TestCheckNoAuth(t *testing.T) {
+	tests := []struct {
+		server string
+		err    error
+	}{
+		{"220 hello\r\n250 ok\r\n250 ok\r\n", nil},
+		{"220 hello\r\n530 5.7.0 Authentication required\r\n", ErrAuthRequired},
+	}
+	for i, tt := range tests {
+		var wrote bytes.Buffer
+		var fake faker
+		fake.ReadWriter = struct {
+			io.Reader
+			io.Writer
+		}{
+			strings.NewReader(tt.server),
+			&wrote,
+		}
+		c, err := newClient(fake, "fake.host")
+		if err != nil {
+			t.Fatalf("NewClient: %v", err)
+		}
+		c.didHello = true
+		if err = checkNoAuth(c); err != tt.err {
+			t.Errorf("%d. got error %v, want %v", i, err, tt.err)
+		}
+		if !strings.HasPrefix(wrote.String(), "MAIL FROM:<>\r\n") {
+			t.Errorf("%d. wrote %q", i, wrote.String())
+		}
+	}
+}
+
 // toServerEmptyAuth is an implementation of Auth that only implements
 // the Start method, and returns "FOOAUTH", nil, nil. Notably, it returns
 // zero bytes for "toServer" so we can test that we don't send spaces at
 // the end of the line. See TestClientAuthTrimSpace.
 type toServerEmptyAuth struct{}
 
-func (toServerEmptyAuth) start(server *serverInfo) (proto string, toServer []byte, err error) {
+func (toServerEmptyAuth) Start(server *ServerInfo) (proto string, toServer []byte, err error) {
 	return "FOOAUTH", nil, nil
 }
 
-func (toServerEmptyAuth) next(fromServer []byte, more bool) (toServer []byte, err error) {
+func (toServerEmptyAuth) Next(fromServer []byte, more bool) (toServer []byte, err error) {
 	panic("unexpected call")
 }
 
@@ -567,6 +685,48 @@ QUIT
 			t.Fatalf("Got:\n%s\nExpected:\n%s", actualcmds, client)
 		}
 	})
+
+	t.Run("esmtp params", func(t *testing.T) {
+		const (
+			basicServer = `250-mx.example.com
+250-8BITMIME
+250-AUTH PLAIN
+250 DSN
+250 Sender OK
+250 Recipient OK
+221 Goodbye
+`
+
+			basicClient = `EHLO localhost
+MAIL FROM:<user@example.com> BODY=7BIT AUTH=<> RET=HDRS
+RCPT TO:<to@example.com> NOTIFY=NEVER
+QUIT
+`
+		)
+
+		c, bcmdbuf, cmdbuf := faker(basicServer)
+
+		params := []Param{{"BODY", "7BIT"}, {"AUTH", "<>"}, {"RET", "HDRS"}}
+		if err := c.mailParams("user@example.com", nil, params); err != nil {
+			t.Fatalf("MAIL FROM failed: %s", err)
+		}
+		if err := c.rcpt("to@example.com", Param{"NOTIFY", "NEVER"}); err != nil {
+			t.Fatalf("RCPT TO failed: %s", err)
+		}
+		if err := c.rcpt("to@example.com", Param{"MT-PRIORITY", "3"}); err == nil {
+			t.Fatalf("Should fail for a parameter of an extension not advertised")
+		}
+		if err := c.quit(); err != nil {
+			t.Fatalf("QUIT failed: %s", err)
+		}
+
+		bcmdbuf.Flush()
+		actualcmds := cmdbuf.String()
+		client := strings.Join(strings.Split(basicClient, "\n"), "\r\n")
+		if client != actualcmds {
+			t.Fatalf("Got:\n%s\nExpected:\n%s", actualcmds, client)
+		}
+	})
 }
 
 func TestNewClient(t *testing.T) {
@@ -1062,3 +1222,46 @@ qgkeluku4GjxRlDMBuXk94xOBEinUs+p/hwP1Alll80Tpg==
 -----END RSA TESTING KEY-----`))
 
 func testingKey(s string) string { return strings.ReplaceAll(s, "TESTING KEY", "PRIVATE KEY") }
+
+// tokenAuth is a custom mechanism sending a token in the AUTH command
+type tokenAuth struct {
+	server *ServerInfo
+}
+
+func (a *tokenAuth) Start(server *ServerInfo) (string, []byte, error) {
+	a.server = server
+	return "X-TOKEN", []byte("secret"), nil
+}
+
+func (a *tokenAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("unexpected challenge")
+	}
+	return nil, nil
+}
+
+func TestSetCustomAuth(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 1)
+	go fakeSMTP(ln, messages, map[string]string{
+		"EHLO": "250-fake\r\n250 AUTH PLAIN X-TOKEN",
+		"AUTH": "235 authenticated",
+	})
+
+	a := &tokenAuth{}
+	server := NewSMTPClient().SetCustomAuth(a)
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Close()
+
+	if a.server == nil || a.server.Name != "127.0.0.1" || a.server.TLS || strings.Join(a.server.Auth, " ") != "PLAIN X-TOKEN" {
+		t.Errorf("got server info %+v", a.server)
+	}
+}
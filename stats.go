@@ -0,0 +1,143 @@
+package mail
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DomainStats are the delivery counters of a destination domain.
+type DomainStats struct {
+	// Sent is the number of recipients accepted by the server
+	Sent int
+	// Deferred is the number of recipients that failed temporarily, with a
+	// 4xx reply or a network error
+	Deferred int
+	// Bounced is the number of recipients rejected permanently
+	Bounced int
+	// AvgLatency is the average duration of the mail transactions
+	AvgLatency time.Duration
+	// LastError is the last error of the domain, and LastErrorTime its time
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// DeliveryStats collects the DomainStats of the emails sent by the clients
+// of a server, for the dashboards. It's safe for concurrent use.
+type DeliveryStats struct {
+	mu      sync.Mutex
+	domains map[string]*domainCounters
+}
+
+// domainCounters are the stats of a domain with the total latency
+type domainCounters struct {
+	DomainStats
+	transactions int
+	latency      time.Duration
+}
+
+// NewDeliveryStats returns empty delivery stats.
+func NewDeliveryStats() *DeliveryStats {
+	return &DeliveryStats{domains: make(map[string]*domainCounters)}
+}
+
+// Domains returns the stats of each domain, by lower case domain.
+func (s *DeliveryStats) Domains() map[string]DomainStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	domains := make(map[string]DomainStats, len(s.domains))
+	for domain, c := range s.domains {
+		domains[domain] = c.DomainStats
+	}
+
+	return domains
+}
+
+// Domain returns the stats of a domain.
+func (s *DeliveryStats) Domain(domain string) DomainStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.domains[strings.ToLower(domain)]; ok {
+		return c.DomainStats
+	}
+
+	return DomainStats{}
+}
+
+// Reset clears the stats.
+func (s *DeliveryStats) Reset() {
+	s.mu.Lock()
+	s.domains = make(map[string]*domainCounters)
+	s.mu.Unlock()
+}
+
+// record counts the result of a mail transaction to the recipients
+func (s *DeliveryStats) record(recipients []string, err error, latency time.Duration) {
+	// the errors of the recipients, nil if accepted
+	results := make(map[string]error, len(recipients))
+	var partial *PartialSendError
+	if errors.As(err, &partial) {
+		for _, r := range partial.Accepted {
+			results[r] = nil
+		}
+		for _, r := range partial.Rejected {
+			results[r.Address] = r.Err
+		}
+	} else {
+		for _, r := range recipients {
+			results[r] = err
+		}
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.domains == nil {
+		s.domains = make(map[string]*domainCounters)
+	}
+
+	counted := make(map[string]bool)
+	for address, err := range results {
+		domain := addressDomain(address)
+		c, ok := s.domains[domain]
+		if !ok {
+			c = &domainCounters{}
+			s.domains[domain] = c
+		}
+
+		if !counted[domain] {
+			counted[domain] = true
+			c.transactions++
+			c.latency += latency
+			c.AvgLatency = c.latency / time.Duration(c.transactions)
+		}
+
+		switch {
+		case err == nil:
+			c.Sent++
+			continue
+		case IsPermanent(err):
+			c.Bounced++
+		default:
+			c.Deferred++
+		}
+		c.LastError = err.Error()
+		c.LastErrorTime = now
+	}
+}
+
+// DomainStats returns the delivery stats of each destination domain of the
+// emails sent with the client, or with all the clients of the server if it
+// has Stats.
+func (smtpClient *SMTPClient) DomainStats() map[string]DomainStats {
+	if smtpClient.Stats == nil {
+		return map[string]DomainStats{}
+	}
+
+	return smtpClient.Stats.Domains()
+}
@@ -0,0 +1,61 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDomainStats(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go fakeSMTP(ln, messages,
+		map[string]string{"RCPT": "451 4.7.1 Try again later"},
+		map[string]string{"RCPT": "550 5.1.1 No such user"},
+	)
+
+	server := newPoolServer(ln)
+	server.KeepAlive = true
+	server.Stats = NewDeliveryStats()
+
+	first, err := server.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	if err := newPoolEmail().Send(first); err == nil {
+		t.Fatal("the deferred send succeeded")
+	}
+	if err := newPoolEmail().Send(first); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := server.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	if err := NewMSG().SetFrom("from@example.com").AddTo("user@Example.org").SetBody(TextPlain, "Hello").Send(second); err == nil {
+		t.Fatal("the bounced send succeeded")
+	}
+
+	stats := second.DomainStats()
+	com, org := stats["example.com"], stats["example.org"]
+	if com.Sent != 1 || com.Deferred != 1 || com.Bounced != 0 || !strings.Contains(com.LastError, "451") {
+		t.Errorf("got example.com stats %+v", com)
+	}
+	if org.Sent != 0 || org.Deferred != 0 || org.Bounced != 1 || !strings.Contains(org.LastError, "550") || org.LastErrorTime.IsZero() {
+		t.Errorf("got example.org stats %+v", org)
+	}
+	if com.AvgLatency <= 0 {
+		t.Errorf("got average latency %v", com.AvgLatency)
+	}
+
+	server.Stats.Reset()
+	if n := len(first.DomainStats()); n != 0 {
+		t.Errorf("got %d domains after Reset, want 0", n)
+	}
+}
@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// AddAttachmentReader adds an attachment read from r, like a pipe or a
+// remote object, without buffering it. The reader is consumed when the
+// message is read: NewReader and WriteTo stream it, so their memory stays
+// flat even for large files, but Send builds the whole message in memory
+// as it may send it more than once, in batches or after a reconnection.
+// If r is not an io.Seeker the email can only be built once, and it can't
+// be scanned or saved as draft.
+func (email *Email) AddAttachmentReader(r io.Reader, filename, mimeType string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	if mimeType == "" {
+		mimeType = mimeTypeByName(filename)
+	}
+
+	email.attachments = append(email.attachments, &file{
+		filename: filename,
+		mimeType: mimeType,
+		reader:   r,
+	})
+
+	return email
+}
+
+// open returns a reader of the file contents from the start
+func (f *file) open() (io.Reader, error) {
+	if f.reader == nil {
+		return bytes.NewReader(f.data), nil
+	}
+
+	seeker, ok := f.reader.(io.Seeker)
+	if !ok {
+		return nil, errors.New("Mail Error: Attachment stream can only be read once; File: [" + f.filename + "]")
+	}
+
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return f.reader, nil
+}
+
+// source returns the source of the file segment
+func (f *file) source() io.Reader {
+	if f.reader == nil {
+		return bytes.NewReader(f.data)
+	}
+
+	return f.reader
+}
+
+// errReader is a reader that always fails
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
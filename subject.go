@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"regexp"
+	"strings"
+)
+
+// subjectLead matches the reply and forward markers and the tags at the
+// start of a subject, like "Re: [EXTERNAL] Fwd: "
+var subjectLead = regexp.MustCompile(`^(?i)(\s*((re|fw|fwd|aw|wg|sv|vs|tr|rv)(\[\d+\])?\s*:|\[[^\]]*\]))*\s*`)
+
+// PrefixSubject returns the subject with the prefix, like "[EXTERNAL]" or
+// a ticket id "[#12345]", added at the start. The subject is returned
+// unchanged if it already has the prefix, even after reply or forward
+// markers, so replies are not prefixed again.
+func PrefixSubject(subject, prefix string) string {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return subject
+	}
+
+	lead := subjectLead.FindString(subject)
+	if strings.HasPrefix(strings.TrimSpace(subject), prefix) || strings.Contains(lead, prefix) {
+		return subject
+	}
+
+	if subject = strings.TrimLeft(subject, " "); subject == "" {
+		return prefix
+	}
+
+	return prefix + " " + subject
+}
+
+// SuffixSubject returns the subject with the suffix, like an environment
+// tag "(staging)", added at the end, unless it already ends with it.
+func SuffixSubject(subject, suffix string) string {
+	suffix = strings.TrimSpace(suffix)
+	if suffix == "" || strings.HasSuffix(strings.TrimSpace(subject), suffix) {
+		return subject
+	}
+
+	return strings.TrimRight(subject, " ") + " " + suffix
+}
+
+// AddSubjectPrefix adds a prefix to the subject of the email, see
+// PrefixSubject.
+func (email *Email) AddSubjectPrefix(prefix string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.headers.Set("Subject", PrefixSubject(email.headers.Get("Subject"), prefix))
+
+	return email
+}
+
+// AddSubjectSuffix adds a suffix to the subject of the email, see
+// SuffixSubject.
+func (email *Email) AddSubjectSuffix(suffix string) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	email.headers.Set("Subject", SuffixSubject(email.headers.Get("Subject"), suffix))
+
+	return email
+}
+
+// SubjectPrefix returns a filter that adds a prefix to the subject of the
+// emails, like an environment tag "[TEST]" in the client filters.
+func SubjectPrefix(prefix string) Filter {
+	return FilterFunc(func(email *Email) error {
+		email.AddSubjectPrefix(prefix)
+		return nil
+	})
+}
+
+// SubjectSuffix returns a filter that adds a suffix to the subject of the
+// emails.
+func SubjectSuffix(suffix string) Filter {
+	return FilterFunc(func(email *Email) error {
+		email.AddSubjectSuffix(suffix)
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package mail
+
+import "testing"
+
+func TestPrefixSubject(t *testing.T) {
+	for _, c := range []struct {
+		subject, prefix, want string
+	}{
+		{"Hello", "[EXTERNAL]", "[EXTERNAL] Hello"},
+		{"[EXTERNAL] Hello", "[EXTERNAL]", "[EXTERNAL] Hello"},
+		{"Re: [EXTERNAL] Hello", "[EXTERNAL]", "Re: [EXTERNAL] Hello"},
+		{"RE: Fwd: [#12345] Printer broken", "[#12345]", "RE: Fwd: [#12345] Printer broken"},
+		{"AW: [#12345] [EXTERNAL] Hello", "[EXTERNAL]", "AW: [#12345] [EXTERNAL] Hello"},
+		{"Re: Hello", "[#12345]", "[#12345] Re: Hello"},
+		{"Hello [EXTERNAL] world", "[EXTERNAL]", "[EXTERNAL] Hello [EXTERNAL] world"},
+		{"", "[TEST]", "[TEST]"},
+		{"Hello", "", "Hello"},
+	} {
+		if got := PrefixSubject(c.subject, c.prefix); got != c.want {
+			t.Errorf("PrefixSubject(%q, %q): got %q, want %q", c.subject, c.prefix, got, c.want)
+		}
+	}
+}
+
+func TestSuffixSubject(t *testing.T) {
+	for _, c := range []struct {
+		subject, suffix, want string
+	}{
+		{"Hello", "(staging)", "Hello (staging)"},
+		{"Hello (staging) ", "(staging)", "Hello (staging) "},
+		{"Re: Hello (staging)", "(staging)", "Re: Hello (staging)"},
+	} {
+		if got := SuffixSubject(c.subject, c.suffix); got != c.want {
+			t.Errorf("SuffixSubject(%q, %q): got %q, want %q", c.subject, c.suffix, got, c.want)
+		}
+	}
+}
+
+func TestSubjectPrefixFilter(t *testing.T) {
+	email := NewMSG().SetSubject("Hello").AddFilter(SubjectPrefix("[TEST]"), SubjectSuffix("(staging)"))
+
+	filtered, err := email.applyFilters(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := filtered.headers.Get("Subject"); got != "[TEST] Hello (staging)" {
+		t.Errorf("got subject %q", got)
+	}
+	if got := email.headers.Get("Subject"); got != "Hello" {
+		t.Errorf("got original subject %q, want Hello", got)
+	}
+}
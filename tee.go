@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"io"
+	"strings"
+)
+
+// SendAndTee sends the email and writes the transmitted message to w at the
+// same time, to archive or hash it without building the message twice. The
+// data written is the message as sent in the DATA command, before the
+// SMTP dot-stuffing. If the send fails, w can have a partial message.
+func (smtpClient *SMTPClient) SendAndTee(email *Email, w io.Writer) error {
+	_, err := email.sendEnvelope(email.from, smtpClient, sendOptions{tee: w})
+	return err
+}
+
+// teeWriteCloser writes to a multi writer and closes the data writer
+type teeWriteCloser struct {
+	io.Writer
+	closer io.Closer
+}
+
+func (t *teeWriteCloser) Close() error {
+	return t.closer.Close()
+}
+
+// normalizeCRLF adds a CR before the bare line feeds, as the DATA writer does
+func normalizeCRLF(msg string) string {
+	if !hasBareLF(msg) {
+		return msg
+	}
+
+	var b strings.Builder
+	b.Grow(len(msg) + len(msg)/50)
+
+	afterCR := false
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c == '\n' && !afterCR {
+			b.WriteByte('\r')
+		}
+		// same as the DATA writer, a CR after a CR doesn't precede a CRLF
+		afterCR = c == '\r' && !afterCR
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+func hasBareLF(msg string) bool {
+	afterCR := false
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '\n' && !afterCR {
+			return true
+		}
+		afterCR = msg[i] == '\r' && !afterCR
+	}
+
+	return false
+}
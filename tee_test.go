@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSendAndTee(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	messages := make(chan string, 1)
+	go fakeSMTP(ln, messages)
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello\n.leading dot").
+		AddAttachmentData([]byte("data"), "file.txt", "")
+
+	var archive bytes.Buffer
+	if err = client.SendAndTee(email, &archive); err != nil {
+		t.Fatalf("SendAndTee: %v", err)
+	}
+
+	// the fake server reads the data with LF line endings
+	if got, want := strings.Replace(archive.String(), "\r\n", "\n", -1), <-messages; got != want {
+		t.Errorf("tee data differs from the received message:\n%q\nwant:\n%q", got, want)
+	}
+	if strings.Contains(archive.String(), "\n..leading") || hasBareLF(archive.String()) {
+		t.Errorf("tee data is not the message as transmitted:\n%s", archive.String())
+	}
+}
+
+func TestNormalizeCRLF(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"a\r\nb", "a\r\nb"},
+		{"a\nb\n", "a\r\nb\r\n"},
+		{"a\rb", "a\rb"},
+		{"a\r\r\n", "a\r\r\r\n"},
+	}
+	for _, tt := range tests {
+		if got := normalizeCRLF(tt.in); got != tt.want {
+			t.Errorf("normalizeCRLF(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSendWithResult(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	go fakeSMTP(ln, make(chan string, 2))
+
+	server := NewSMTPClient()
+	server.Authentication = AuthNone
+	server.Host = ln.Addr().String()
+	server.KeepAlive = true
+
+	client, err := server.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	email := NewMSG()
+	email.SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Hello").
+		AddAttachmentData([]byte("data"), "file.txt", "")
+
+	var archive bytes.Buffer
+	if err = client.SendAndTee(email, &archive); err != nil {
+		t.Fatalf("SendAndTee: %v", err)
+	}
+
+	sum := sha256.Sum256(archive.Bytes())
+	archived := newSendResult(email, archive.String())
+	if archived.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("got SHA256 %s, want %x", archived.SHA256, sum)
+	}
+
+	result, err := email.SendWithResult(client)
+	if err != nil {
+		t.Fatalf("SendWithResult: %v", err)
+	}
+	if result.Size == 0 || len(result.SHA256) != 64 || result.Recipients[0] != "to@example.com" {
+		t.Errorf("got result %+v", result)
+	}
+}
@@ -0,0 +1,66 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	texttemplate "text/template"
+)
+
+// SetBodyFromTemplate executes the HTML template with the data and sets the
+// result as the text/html body. It replaces the HTML body if there is one
+// and keeps the plain text body, so it can be combined with
+// SetTextBodyFromTemplate in any order. The template errors are set in
+// Email.Error.
+func (email *Email) SetBodyFromTemplate(tpl *template.Template, data interface{}) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	var body bytes.Buffer
+	if err := tpl.Execute(&body, data); err != nil {
+		email.Error = errors.New("Mail Error: Failed to execute the template " + tpl.Name() + ": " + err.Error())
+		return email
+	}
+
+	email.setTemplateBody(TextHTML, &body)
+
+	return email
+}
+
+// SetTextBodyFromTemplate executes the text template with the data and sets
+// the result as the text/plain body, before the HTML body if there is one.
+// The template errors are set in Email.Error.
+func (email *Email) SetTextBodyFromTemplate(tpl *texttemplate.Template, data interface{}) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	var body bytes.Buffer
+	if err := tpl.Execute(&body, data); err != nil {
+		email.Error = errors.New("Mail Error: Failed to execute the template " + tpl.Name() + ": " + err.Error())
+		return email
+	}
+
+	email.setTemplateBody(TextPlain, &body)
+
+	return email
+}
+
+// setTemplateBody replaces the body of the content type, or adds it with
+// the plain text first as the least preferred alternative
+func (email *Email) setTemplateBody(contentType contentType, body *bytes.Buffer) {
+	for i, p := range email.parts {
+		if p.contentType == contentType.string() {
+			email.parts[i].body = body
+			return
+		}
+	}
+
+	p := part{contentType: contentType.string(), body: body}
+	if contentType == TextPlain {
+		email.parts = append([]part{p}, email.parts...)
+	} else {
+		email.parts = append(email.parts, p)
+	}
+}
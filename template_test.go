@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	texttemplate "text/template"
+)
+
+func TestSetBodyFromTemplate(t *testing.T) {
+	data := struct{ Name string }{"<Jöhn>"}
+
+	html := template.Must(template.New("welcome.html").Parse(`<p>Welcome {{.Name}}</p>`))
+	text := texttemplate.Must(texttemplate.New("welcome.txt").Parse(`Welcome {{.Name}}`))
+
+	email := NewMSG().
+		SetBodyFromTemplate(html, data).
+		SetTextBodyFromTemplate(text, data)
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	parts := email.Parts()
+	if len(parts) != 2 || parts[0].ContentType != "text/plain" || parts[0].Body != "Welcome <Jöhn>" ||
+		parts[1].ContentType != "text/html" || parts[1].Body != "<p>Welcome &lt;Jöhn&gt;</p>" {
+		t.Errorf("got parts %+v", parts)
+	}
+
+	// executing again replaces the bodies
+	email.SetBodyFromTemplate(html, struct{ Name string }{"Jane"})
+	if parts = email.Parts(); len(parts) != 2 || parts[1].Body != "<p>Welcome Jane</p>" {
+		t.Errorf("got parts %+v", parts)
+	}
+
+	bad := template.Must(template.New("bad").Parse(`{{.Missing}}`))
+	email = NewMSG().SetBodyFromTemplate(bad, data)
+	if email.Error == nil || !strings.Contains(email.Error.Error(), "template bad") {
+		t.Errorf("got error %v", email.Error)
+	}
+}
@@ -0,0 +1,27 @@
+#!/usr/bin/env python3
+"""Validates the message read from stdin with the Python email parser.
+
+Exits with status 1 and prints the defects found in the message or any of
+its parts. Used by TestSelfCheckPython.
+"""
+
+import sys
+from email import message_from_bytes, policy
+
+
+def main():
+    msg = message_from_bytes(sys.stdin.buffer.read(), policy=policy.default)
+
+    defects = []
+    for part in msg.walk():
+        defects.extend(str(d) for d in part.defects)
+        if not part.is_multipart():
+            part.get_content()
+
+    if defects:
+        print("\n".join(defects))
+        sys.exit(1)
+
+
+if __name__ == "__main__":
+    main()
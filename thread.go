@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Thread is the conversation of a message being replied to.
+type Thread struct {
+	// MessageID is the Message-ID of the parent message
+	MessageID string
+	// References are the References of the parent message
+	References []string
+	// ThreadIndex is the Thread-Index of the parent message, if any
+	ThreadIndex string
+	// Topic is the Thread-Topic of the parent message, if any. If it's
+	// empty, the subject of the reply without markers is used.
+	Topic string
+}
+
+// replyMarkers matches the reply and forward markers at the start of a
+// subject, which are not part of the thread topic
+var replyMarkers = regexp.MustCompile(`^(?i)(\s*(re|fw|fwd|aw|wg|sv|vs|tr|rv)(\[\d+\])?\s*:)*\s*`)
+
+// fileTimeEpoch is the difference between the FILETIME epoch (1601) and
+// the unix epoch, in 100 nanoseconds
+const fileTimeEpoch = 116444736000000000
+
+// SetThread makes the email a reply in the thread of the parent message,
+// setting the In-Reply-To and References headers and, for Outlook and
+// Exchange, the Thread-Index and Thread-Topic headers. It must be called
+// after SetSubject.
+func (email *Email) SetThread(parent Thread) *Email {
+	if email.Error != nil {
+		return email
+	}
+
+	now := time.Now()
+	if email.clock != nil {
+		now = email.clock.Now()
+	}
+
+	var index string
+	var err error
+	if parent.ThreadIndex != "" {
+		index, err = ChildThreadIndex(parent.ThreadIndex, now)
+	} else {
+		index, err = NewThreadIndex(now)
+	}
+	if err != nil {
+		email.Error = err
+		return email
+	}
+
+	topic := parent.Topic
+	if topic == "" {
+		topic = replyMarkers.ReplaceAllString(email.headers.Get("Subject"), "")
+	}
+
+	if parent.MessageID != "" {
+		email.headers.Set("In-Reply-To", parent.MessageID)
+		email.headers.Set("References", strings.Join(append(parent.References[:len(parent.References):len(parent.References)], parent.MessageID), " "))
+	}
+	email.headers.Set("Thread-Index", index)
+	email.headers.Set("Thread-Topic", topic)
+
+	return email
+}
+
+// fileTime returns t as a Windows FILETIME
+func fileTime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100 + fileTimeEpoch)
+}
+
+// NewThreadIndex returns the Thread-Index of a new conversation started at
+// the given time: the 6 high bytes of the FILETIME and a random GUID.
+func NewThreadIndex(t time.Time) (string, error) {
+	index := make([]byte, 22)
+
+	var ft [8]byte
+	binary.BigEndian.PutUint64(ft[:], fileTime(t))
+	copy(index, ft[:6])
+
+	if _, err := rand.Read(index[6:]); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(index), nil
+}
+
+// ChildThreadIndex returns the Thread-Index of a reply to a message with
+// the parent Thread-Index, adding a 5 bytes block with the time elapsed
+// since the conversation started.
+func ChildThreadIndex(parent string, t time.Time) (string, error) {
+	index, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parent))
+	if err != nil || len(index) < 22 || (len(index)-22)%5 != 0 {
+		return "", errors.New("Mail Error: Invalid Thread-Index")
+	}
+
+	var ft [8]byte
+	copy(ft[:6], index[:6])
+	start := binary.BigEndian.Uint64(ft[:])
+
+	var delta uint64
+	if now := fileTime(t); now > start {
+		delta = now - start
+	}
+
+	// the delta is stored in 31 bits, with less precision if it's large
+	var block uint32
+	if delta < 1<<49 {
+		block = uint32(delta >> 18)
+	} else {
+		block = 1<<31 | uint32(delta>>23)&(1<<31-1)
+	}
+
+	child := make([]byte, 5)
+	binary.BigEndian.PutUint32(child, block)
+	rand.Read(child[4:])
+
+	return base64.StdEncoding.EncodeToString(append(index, child...)), nil
+}
@@ -0,0 +1,298 @@
+// Package thread groups messages parsed with Go Simple Mail into
+// conversation trees, with the algorithm of Jamie Zawinski used by most
+// mail readers: the messages are linked by their Message-ID, References
+// and In-Reply-To headers, and the conversations without those headers are
+// grouped by subject:
+//
+//	var messages []*mail.ParsedMessage
+//	...
+//	for _, root := range thread.Build(messages) {
+//		printTree(root, 0)
+//	}
+package thread
+
+import (
+	netmail "net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// Container is a node of a conversation tree. The containers of the
+// messages referenced but not in the set have a nil Message, they keep the
+// replies to the same missing message together.
+type Container struct {
+	Message   *mail.ParsedMessage
+	MessageID string
+	Parent    *Container
+	Children  []*Container
+}
+
+var (
+	// messageIDs matches the ids of the References and In-Reply-To headers
+	messageIDs = regexp.MustCompile(`<[^<>\s]+>`)
+	// replyPrefix matches the reply and forward markers of a subject
+	replyPrefix = regexp.MustCompile(`^(?i)(\s*(re|fw|fwd|aw|wg|sv|vs|tr|rv)(\[\d+\])?\s*:)+\s*`)
+)
+
+// Build returns the roots of the conversations of the messages, sorted by
+// date like the replies of each message.
+func Build(messages []*mail.ParsedMessage) []*Container {
+	ids := make(map[string]*Container)
+
+	container := func(id string) *Container {
+		c, ok := ids[id]
+		if !ok {
+			c = &Container{MessageID: id}
+			ids[id] = c
+		}
+		return c
+	}
+
+	for n, msg := range messages {
+		id := strings.TrimSpace(msg.Header.Get("Message-Id"))
+		c := container(id)
+		if id == "" || c.Message != nil {
+			// a message without id or with a duplicated id gets its own
+			// container, that can't be referenced
+			c = container("<" + strconv.Itoa(n) + "@thread.invalid>")
+			c.MessageID = id
+		}
+		c.Message = msg
+
+		// link the references from the oldest
+		var parent *Container
+		for _, ref := range references(msg) {
+			r := container(ref)
+			if parent != nil && r.Parent == nil && r != parent && !r.isAncestorOf(parent) {
+				parent.adopt(r)
+			}
+			parent = r
+		}
+
+		// the last reference is the parent of the message
+		if parent != nil && (parent == c || c.isAncestorOf(parent)) {
+			parent = nil
+		}
+		if c.Parent != nil {
+			c.Parent.remove(c)
+		}
+		if parent != nil {
+			parent.adopt(c)
+		}
+	}
+
+	var roots []*Container
+	for _, c := range ids {
+		if c.Parent == nil {
+			roots = append(roots, c)
+		}
+	}
+
+	roots = prune(roots, true)
+	roots = groupBySubject(roots)
+	sortByDate(roots)
+
+	return roots
+}
+
+// references returns the ids referenced by the message, from the oldest
+func references(msg *mail.ParsedMessage) []string {
+	refs := messageIDs.FindAllString(msg.Header.Get("References"), -1)
+
+	if inReplyTo := messageIDs.FindString(msg.Header.Get("In-Reply-To")); inReplyTo != "" {
+		if len(refs) == 0 || refs[len(refs)-1] != inReplyTo {
+			refs = append(refs, inReplyTo)
+		}
+	}
+
+	return refs
+}
+
+func (c *Container) isAncestorOf(other *Container) bool {
+	for p := other.Parent; p != nil; p = p.Parent {
+		if p == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Container) adopt(child *Container) {
+	if child.Parent != nil {
+		child.Parent.remove(child)
+	}
+	child.Parent = c
+	c.Children = append(c.Children, child)
+}
+
+func (c *Container) remove(child *Container) {
+	for i, ch := range c.Children {
+		if ch == child {
+			c.Children = append(c.Children[:i], c.Children[i+1:]...)
+			break
+		}
+	}
+	child.Parent = nil
+}
+
+// prune removes the containers without message and without replies, and
+// replaces the ones without message by their replies, except at the root
+// level where the replies are only promoted if there's one
+func prune(containers []*Container, root bool) []*Container {
+	var pruned []*Container
+	for _, c := range containers {
+		c.Children = prune(c.Children, false)
+		for _, child := range c.Children {
+			child.Parent = c
+		}
+
+		switch {
+		case c.Message != nil:
+			pruned = append(pruned, c)
+		case len(c.Children) == 0:
+			// nothing left
+		case !root || len(c.Children) == 1:
+			for _, child := range c.Children {
+				child.Parent = c.Parent
+			}
+			pruned = append(pruned, c.Children...)
+		default:
+			pruned = append(pruned, c)
+		}
+	}
+
+	for _, c := range pruned {
+		if root {
+			c.Parent = nil
+		}
+	}
+
+	return pruned
+}
+
+// groupBySubject groups the roots of the same subject, for the replies of
+// clients that don't set the References and In-Reply-To headers
+func groupBySubject(roots []*Container) []*Container {
+	subjects := make(map[string]*Container)
+	for _, c := range roots {
+		subject, reply := c.subject()
+		if subject == "" {
+			continue
+		}
+
+		old, ok := subjects[subject]
+		if !ok || c.Message == nil && old.Message != nil {
+			subjects[subject] = c
+			continue
+		}
+		if _, oldReply := old.subject(); oldReply && !reply && old.Message != nil && c.Message != nil {
+			subjects[subject] = c
+		}
+	}
+
+	var grouped []*Container
+	for _, c := range roots {
+		if c.Parent != nil {
+			// already grouped under another root
+			continue
+		}
+
+		subject, reply := c.subject()
+		other := subjects[subject]
+		if subject == "" || other == c {
+			grouped = append(grouped, c)
+			continue
+		}
+		_, otherReply := other.subject()
+
+		switch {
+		case c.Message == nil && other.Message == nil:
+			for len(c.Children) > 0 {
+				other.adopt(c.Children[0])
+			}
+		case other.Message == nil:
+			other.adopt(c)
+		case c.Message == nil:
+			// keep the empty container as the root of both
+			c.adopt(other)
+			subjects[subject] = c
+			grouped = replace(grouped, other, c)
+		case !otherReply && reply:
+			other.adopt(c)
+		case otherReply && !reply:
+			c.adopt(other)
+			subjects[subject] = c
+			grouped = replace(grouped, other, c)
+		default:
+			// siblings, under a new empty container
+			parent := &Container{}
+			parent.adopt(other)
+			parent.adopt(c)
+			subjects[subject] = parent
+			grouped = replace(grouped, other, parent)
+		}
+	}
+
+	return grouped
+}
+
+// replace replaces old by c in the containers, or appends c if old isn't
+// there yet
+func replace(containers []*Container, old, c *Container) []*Container {
+	for i := range containers {
+		if containers[i] == old {
+			containers[i] = c
+			return containers
+		}
+	}
+
+	return append(containers, c)
+}
+
+// subject returns the subject of the container without reply markers and
+// whether it had any. The empty containers use the subject of their first
+// reply.
+func (c *Container) subject() (string, bool) {
+	msg := c.Message
+	if msg == nil {
+		if len(c.Children) == 0 || c.Children[0].Message == nil {
+			return "", false
+		}
+		msg = c.Children[0].Message
+	}
+
+	subject := msg.DecodedHeader("Subject")
+	stripped := replyPrefix.ReplaceAllString(subject, "")
+
+	return strings.ToLower(strings.TrimSpace(stripped)), stripped != subject
+}
+
+// date returns the date of the message, or of the first reply of an empty
+// container
+func (c *Container) date() time.Time {
+	if c.Message != nil {
+		date, _ := netmail.ParseDate(c.Message.Header.Get("Date"))
+		return date
+	}
+	if len(c.Children) > 0 {
+		return c.Children[0].date()
+	}
+
+	return time.Time{}
+}
+
+func sortByDate(containers []*Container) {
+	for _, c := range containers {
+		sortByDate(c.Children)
+	}
+
+	sort.SliceStable(containers, func(i, j int) bool {
+		return containers[i].date().Before(containers[j].date())
+	})
+}
@@ -0,0 +1,75 @@
+package thread
+
+import (
+	"strings"
+	"testing"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+func message(t *testing.T, id, subject, date string, headers ...string) *mail.ParsedMessage {
+	raw := "Message-ID: " + id + "\r\nSubject: " + subject + "\r\nDate: " + date + "\r\n"
+	for _, h := range headers {
+		raw += h + "\r\n"
+	}
+
+	msg, err := mail.ParseMessage(strings.NewReader(raw + "\r\nbody\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msg
+}
+
+// tree returns the ids of the containers, with the replies in brackets
+func tree(containers []*Container) string {
+	var s []string
+	for _, c := range containers {
+		id := strings.TrimSuffix(strings.Trim(c.MessageID, "<>"), "@x")
+		if c.Message == nil {
+			id = "-"
+		}
+		if len(c.Children) > 0 {
+			id += "[" + tree(c.Children) + "]"
+		}
+		s = append(s, id)
+	}
+
+	return strings.Join(s, " ")
+}
+
+func TestBuild(t *testing.T) {
+	messages := []*mail.ParsedMessage{
+		message(t, "<b@x>", "Re: Lunch", "Mon, 8 Jan 2024 11:00:00 +0000", "In-Reply-To: <a@x>"),
+		message(t, "<a@x>", "Lunch", "Mon, 8 Jan 2024 10:00:00 +0000"),
+		message(t, "<c@x>", "Re: Lunch", "Mon, 8 Jan 2024 12:00:00 +0000", "References: <a@x> <b@x>"),
+		message(t, "<d@x>", "Re: Lunch", "Mon, 8 Jan 2024 10:30:00 +0000", "References: <a@x>", "In-Reply-To: <a@x>"),
+		// replies to a missing message
+		message(t, "<f@x>", "Re: Report", "Tue, 9 Jan 2024 10:00:00 +0000", "In-Reply-To: <e@x>"),
+		message(t, "<g@x>", "Re: Report", "Tue, 9 Jan 2024 09:00:00 +0000", "In-Reply-To: <e@x>"),
+		// a reply without references, grouped by subject
+		message(t, "<i@x>", "RE: Budget", "Wed, 10 Jan 2024 11:00:00 +0000"),
+		message(t, "<h@x>", "Budget", "Wed, 10 Jan 2024 10:00:00 +0000"),
+		// a reply whose parent is missing, and the parent of the parent
+		// is there
+		message(t, "<k@x>", "Re: Plan", "Thu, 11 Jan 2024 12:00:00 +0000", "References: <j@x> <missing@x>"),
+		message(t, "<j@x>", "Plan", "Thu, 11 Jan 2024 10:00:00 +0000"),
+	}
+
+	if got, want := tree(Build(messages)), "a[d b[c]] -[g f] h[i] j[k]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestBuildLoop(t *testing.T) {
+	messages := []*mail.ParsedMessage{
+		message(t, "<a@x>", "Loop", "Mon, 8 Jan 2024 10:00:00 +0000", "In-Reply-To: <b@x>"),
+		message(t, "<b@x>", "Re: Loop", "Mon, 8 Jan 2024 11:00:00 +0000", "In-Reply-To: <a@x>"),
+		message(t, "<a@x>", "Duplicate", "Mon, 8 Jan 2024 12:00:00 +0000"),
+	}
+
+	roots := Build(messages)
+	if got := tree(roots); got != "b[a] a" {
+		t.Errorf("got %s", tree(roots))
+	}
+}
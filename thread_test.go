@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestSetThread(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	parentIndex, err := NewThreadIndex(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email := NewMSG().
+		SetClock(fixedClock(start.Add(time.Hour))).
+		SetSubject("RE: Fwd: Quarterly report").
+		SetThread(Thread{MessageID: "<2@example.com>", References: []string{"<1@example.com>"}, ThreadIndex: parentIndex})
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	for header, want := range map[string]string{
+		"In-Reply-To":  "<2@example.com>",
+		"References":   "<1@example.com> <2@example.com>",
+		"Thread-Topic": "Quarterly report",
+	} {
+		if got := email.headers.Get(header); got != want {
+			t.Errorf("%s: got %q, want %q", header, got, want)
+		}
+	}
+
+	parent, _ := base64.StdEncoding.DecodeString(parentIndex)
+	child, err := base64.StdEncoding.DecodeString(email.headers.Get("Thread-Index"))
+	if err != nil || len(child) != 27 || string(child[:22]) != string(parent) {
+		t.Fatalf("got Thread-Index %x, want a child of %x", child, parent)
+	}
+
+	// one hour in 100ns units, shifted 18 bits
+	delta := uint32(child[22])<<24 | uint32(child[23])<<16 | uint32(child[24])<<8 | uint32(child[25])
+	if want := uint32(uint64(time.Hour/100) >> 18); delta != want && delta != want+1 {
+		t.Errorf("got delta %d, want %d", delta, want)
+	}
+
+	if _, err := ChildThreadIndex("invalid", start); err == nil {
+		t.Error("expected error for an invalid Thread-Index")
+	}
+}
@@ -0,0 +1,157 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// uuLineLength is the number of bytes encoded in each uuencoded line
+const uuLineLength = 45
+
+// uuEncoder is the x-uuencode Content-Transfer-Encoding
+type uuEncoder struct{}
+
+func (uuEncoder) Name() string {
+	return "x-uuencode"
+}
+
+func (uuEncoder) NewWriter(w io.Writer, options EncoderOptions) io.WriteCloser {
+	return newUUWriter(w, options.Filename)
+}
+
+func init() {
+	RegisterEncoder(uuEncoder{})
+}
+
+// uuWriter uuencodes the data written to it in a begin/end block
+type uuWriter struct {
+	w        io.Writer
+	filename string
+	begun    bool
+	buf      []byte
+	err      error
+}
+
+func newUUWriter(w io.Writer, filename string) *uuWriter {
+	if filename == "" {
+		filename = "data"
+	}
+
+	// the name ends at the end of the line
+	filename = strings.NewReplacer("\r", "", "\n", "").Replace(filename)
+
+	return &uuWriter{w: w, filename: filename}
+}
+
+func (u *uuWriter) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+
+	u.begin()
+	u.buf = append(u.buf, p...)
+	for len(u.buf) >= uuLineLength && u.err == nil {
+		u.line(u.buf[:uuLineLength])
+		u.buf = u.buf[uuLineLength:]
+	}
+
+	return len(p), u.err
+}
+
+// Close writes the last line and the end of the block.
+func (u *uuWriter) Close() error {
+	u.begin()
+	if len(u.buf) > 0 {
+		u.line(u.buf)
+		u.buf = nil
+	}
+
+	if u.err == nil {
+		_, u.err = io.WriteString(u.w, "`\r\nend\r\n")
+	}
+
+	return u.err
+}
+
+func (u *uuWriter) begin() {
+	if !u.begun && u.err == nil {
+		u.begun = true
+		_, u.err = io.WriteString(u.w, "begin 644 "+u.filename+"\r\n")
+	}
+}
+
+// line writes a line with the bytes, at most uuLineLength
+func (u *uuWriter) line(data []byte) {
+	line := make([]byte, 0, 2+(len(data)+2)/3*4+2)
+	line = append(line, uuChar(byte(len(data))))
+
+	for i := 0; i < len(data); i += 3 {
+		var b [3]byte
+		copy(b[:], data[i:])
+		line = append(line,
+			uuChar(b[0]>>2),
+			uuChar((b[0]<<4|b[1]>>4)&0x3f),
+			uuChar((b[1]<<2|b[2]>>6)&0x3f),
+			uuChar(b[2]&0x3f))
+	}
+
+	_, u.err = u.w.Write(append(line, '\r', '\n'))
+}
+
+// uuChar returns the character of a 6 bit value, with a backquote instead
+// of a space for zero so trailing spaces are not stripped
+func uuChar(c byte) byte {
+	if c == 0 {
+		return '`'
+	}
+
+	return c + ' '
+}
+
+// withUUEncodedAttachments returns a copy of the email with the attachments
+// uuencoded at the end of the text body, see Profile.UUEncodeAttachments
+func (email *Email) withUUEncodedAttachments() (*Email, error) {
+	if !email.profile.UUEncodeAttachments || len(email.attachments) == 0 {
+		return email, nil
+	}
+
+	var blocks bytes.Buffer
+	for _, f := range email.attachments {
+		r, err := f.open()
+		if err != nil {
+			return nil, err
+		}
+
+		blocks.WriteString("\r\n")
+		u := newUUWriter(&blocks, f.filename)
+		if _, err = io.Copy(u, r); err != nil {
+			return nil, err
+		}
+		u.Close()
+	}
+
+	c := *email
+	c.attachments = nil
+	c.parts = append([]part(nil), email.parts...)
+
+	text := -1
+	for i, p := range c.parts {
+		if p.contentType == TextPlain.string() {
+			text = i
+			break
+		}
+	}
+	if text < 0 {
+		text = len(c.parts)
+		c.parts = append(c.parts, part{contentType: TextPlain.string(), body: new(bytes.Buffer)})
+	}
+
+	body := append([]byte(nil), c.parts[text].body.Bytes()...)
+	if len(body) > 0 && !bytes.HasSuffix(body, []byte("\n")) {
+		body = append(body, '\r', '\n')
+	}
+	c.parts[text].body = bytes.NewBuffer(append(body, blocks.Bytes()...))
+
+	return &c, nil
+}
@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// uudecode decodes the lines of a uuencoded block
+func uudecode(lines []string) []byte {
+	var data []byte
+	for _, line := range lines {
+		n := int((line[0] - ' ') & 0x3f)
+		var decoded []byte
+		for i := 1; i+3 < len(line); i += 4 {
+			c := [4]byte{}
+			for j := range c {
+				c[j] = (line[i+j] - ' ') & 0x3f
+			}
+			decoded = append(decoded, c[0]<<2|c[1]>>4, c[1]<<4|c[2]>>2, c[2]<<6|c[3])
+		}
+		data = append(data, decoded[:n]...)
+	}
+
+	return data
+}
+
+func TestUUEncodeAttachments(t *testing.T) {
+	data := bytes.Repeat([]byte{0, 1, 2, 250, 'a', '\n'}, 20)
+
+	email := NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Report attached").
+		AddAttachmentData([]byte("Cat"), "cat.txt", "").
+		AddAttachmentData(data, "data.bin", "").
+		SetProfile(Profile{UUEncodeAttachments: true})
+
+	msg := email.GetMessage()
+	if email.Error != nil {
+		t.Fatal(email.Error)
+	}
+
+	if strings.Contains(msg, "multipart/") {
+		t.Errorf("the attachments are MIME parts:\n%s", msg)
+	}
+	if !strings.Contains(msg, "Report attached\r\n\r\nbegin 644 cat.txt\r\n#0V%T\r\n`\r\nend\r\n") {
+		t.Errorf("uuencoded block not found:\n%s", msg)
+	}
+
+	block := msg[strings.Index(msg, "begin 644 data.bin\r\n")+20:]
+	block = block[:strings.Index(block, "\r\n`\r\nend")]
+	if got := uudecode(strings.Split(block, "\r\n")); !bytes.Equal(got, data) {
+		t.Errorf("got decoded data %v, want %v", got, data)
+	}
+
+	if err := checkMessage(email, msg); err != nil {
+		t.Errorf("checkMessage: %v", err)
+	}
+
+	email = NewMSG().
+		SetFrom("from@example.com").
+		AddTo("to@example.com").
+		SetBody(TextPlain, "Report attached").
+		AddAttachmentData([]byte("Cat"), "cat.txt", "").
+		SetAttachmentEncoding("cat.txt", "x-uuencode")
+
+	msg = email.GetMessage()
+	if !strings.Contains(msg, "Content-Transfer-Encoding: x-uuencode\r\n") || !strings.Contains(msg, "begin 644 cat.txt\r\n#0V%T\r\n`\r\nend\r\n") {
+		t.Errorf("attachment not encoded with x-uuencode:\n%s", msg)
+	}
+}
@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// yEncLineLength is the length of the yEnc encoded lines
+const yEncLineLength = 128
+
+// yEncEncoder is the x-yencode Content-Transfer-Encoding, for the parts
+// submitted to mail-to-news gateways
+type yEncEncoder struct{}
+
+func (yEncEncoder) Name() string {
+	return "x-yencode"
+}
+
+func (yEncEncoder) NewWriter(w io.Writer, options EncoderOptions) io.WriteCloser {
+	filename := options.Filename
+	if filename == "" {
+		filename = "data"
+	}
+
+	return &yEncWriter{
+		w:        w,
+		filename: strings.NewReplacer("\r", "", "\n", "").Replace(filename),
+		size:     options.Size,
+		crc:      crc32.NewIEEE(),
+	}
+}
+
+func init() {
+	RegisterEncoder(yEncEncoder{})
+}
+
+// yEncWriter yEnc encodes the data written to it. The size is written in
+// the =ybegin line, so the data of unknown size is buffered until Close.
+type yEncWriter struct {
+	w        io.Writer
+	filename string
+	size     int64
+	written  int64
+	begun    bool
+	pending  bytes.Buffer
+	line     []byte
+	crc      hash.Hash32
+	err      error
+}
+
+func (y *yEncWriter) Write(p []byte) (int, error) {
+	if y.err != nil {
+		return 0, y.err
+	}
+
+	if y.size < 0 {
+		return y.pending.Write(p)
+	}
+
+	y.encode(p)
+
+	return len(p), y.err
+}
+
+// Close writes the last line and the =yend line.
+func (y *yEncWriter) Close() error {
+	if y.size < 0 {
+		y.size = int64(y.pending.Len())
+		y.encode(y.pending.Bytes())
+	}
+
+	y.begin()
+	if len(y.line) > 0 {
+		y.flushLine()
+	}
+
+	if y.err == nil {
+		_, y.err = fmt.Fprintf(y.w, "=yend size=%d crc32=%08x\r\n", y.written, y.crc.Sum32())
+	}
+
+	return y.err
+}
+
+func (y *yEncWriter) begin() {
+	if !y.begun && y.err == nil {
+		y.begun = true
+		_, y.err = fmt.Fprintf(y.w, "=ybegin line=%d size=%d name=%s\r\n", yEncLineLength, y.size, y.filename)
+	}
+}
+
+func (y *yEncWriter) encode(p []byte) {
+	y.begin()
+	y.crc.Write(p)
+	y.written += int64(len(p))
+
+	for _, b := range p {
+		c := b + 42
+
+		escape := false
+		switch c {
+		case 0, '\n', '\r', '=':
+			escape = true
+		case '\t', ' ':
+			// the whitespace at the start of the lines can be stripped
+			escape = len(y.line) == 0
+		case '.':
+			// a dot at the start of a line is doubled by NNTP
+			escape = len(y.line) == 0
+		}
+
+		if escape {
+			y.line = append(y.line, '=', c+64)
+		} else {
+			y.line = append(y.line, c)
+		}
+
+		if len(y.line) >= yEncLineLength {
+			y.flushLine()
+		}
+	}
+}
+
+func (y *yEncWriter) flushLine() {
+	line := y.line
+	// the whitespace at the end of the lines can be stripped
+	if n := len(line); line[n-1] == ' ' || line[n-1] == '\t' {
+		line = append(line[:n-1:n-1], '=', line[n-1]+64)
+	}
+
+	if y.err == nil {
+		_, y.err = y.w.Write(append(line, '\r', '\n'))
+	}
+	y.line = y.line[:0]
+}
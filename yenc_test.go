@@ -0,0 +1,77 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// ydecode decodes the lines of a yEnc block
+func ydecode(lines []string) []byte {
+	var data []byte
+	for _, line := range lines {
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			if c == '=' {
+				i++
+				c = line[i] - 64
+			}
+			data = append(data, c-42)
+		}
+	}
+
+	return data
+}
+
+func TestYEnc(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	for _, stream := range []bool{false, true} {
+		email := NewMSG().
+			SetFrom("from@example.com").
+			AddTo("to@example.com").
+			SetBody(TextPlain, "Binary post")
+		if stream {
+			email.AddAttachmentReader(bytes.NewReader(data), "post.bin", "")
+		} else {
+			email.AddAttachmentData(data, "post.bin", "")
+		}
+		email.SetAttachmentEncoding("post.bin", "x-yencode")
+
+		msg := email.GetMessage()
+		if email.Error != nil {
+			t.Fatal(email.Error)
+		}
+
+		if !strings.Contains(msg, "Content-Transfer-Encoding: x-yencode\r\n") {
+			t.Errorf("attachment not yEnc encoded:\n%s", msg)
+		}
+
+		begin := "=ybegin line=128 size=1000 name=post.bin\r\n"
+		i := strings.Index(msg, begin)
+		if i < 0 {
+			t.Fatalf("=ybegin line not found:\n%s", msg)
+		}
+		block := msg[i+len(begin):]
+		end := fmt.Sprintf("=yend size=1000 crc32=%08x\r\n", crc32.ChecksumIEEE(data))
+		j := strings.Index(block, end)
+		if j < 0 {
+			t.Fatalf("=yend line %q not found:\n%s", end, block)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(block[:j], "\r\n"), "\r\n")
+		for _, line := range lines {
+			if len(line) > 129 || strings.HasPrefix(line, ".") || strings.HasSuffix(line, " ") {
+				t.Errorf("invalid yEnc line %q", line)
+			}
+		}
+		if got := ydecode(lines); !bytes.Equal(got, data) {
+			t.Errorf("decoded data differs")
+		}
+	}
+}